@@ -141,8 +141,8 @@ const Dashboard = ({ selectedTab }) => {
         return {
           product_id: product.product_id,
           title: product.title || "N/A",
-          image: product.image || null,
-          video: product.video || null,
+          image: product.image_url || product.image || null,
+          video: product.video_url || product.video || null,
           describe: product.describe_product || product.describe || "N/A",
           year: product.year_product || product.year || null,
           name_factory: factoryName, // Sử dụng tên đã tra cứu
@@ -71,6 +71,23 @@ export function Posts() {
     };
   }, [openDetailDialog]);
 
+  // API trả từng trang kèm pagings.next_cursor, đi theo cursor để lấy đủ danh sách
+  const fetchAllPages = async (url) => {
+    const items = [];
+    let cursor = "";
+    do {
+      const sep = url.includes("?") ? "&" : "?";
+      const res = await fetch(`${url}${sep}limit=50${cursor ? `&cursor=${encodeURIComponent(cursor)}` : ""}`);
+      if (!res.ok) {
+        throw new Error(`HTTP error! status: ${res.status}`);
+      }
+      const data = await res.json();
+      items.push(...(data.data || []));
+      cursor = data.pagings?.next_cursor || "";
+    } while (cursor);
+    return items;
+  };
+
   const fetchProducts = async (locationId, factoryId) => {
     setLoading(true);
     let url;
@@ -88,13 +105,9 @@ export function Posts() {
         url = "http://localhost:8000/thientancay/product/list";
       }
 
-      const res = await fetch(url);
-      if (!res.ok) {
-        throw new Error(`HTTP error! status: ${res.status}`);
-      }
-      const data = await res.json();
-      console.log("API data", data);
-      const validPosts = (data.data || data.products || []).filter((post) => {
+      const products = await fetchAllPages(url);
+      console.log("API data", products);
+      const validPosts = products.filter((post) => {
         const isValid = post.title && post.status;
         if (!isValid) {
           console.warn("Invalid product data:", post);
@@ -143,14 +156,9 @@ export function Posts() {
     }
     const fetchFactoriesByLocation = async () => {
       try {
-        const res = await fetch(
+        const factoriesData = await fetchAllPages(
           `http://localhost:8000/thientancay/factory/list/by-local?name_local=${selectedLocationObj.name_local}`
         );
-        if (!res.ok) {
-          throw new Error(`HTTP error! status: ${res.status}`);
-        }
-        const data = await res.json();
-        const factoriesData = data.factories || data.data || [];
         console.log("Fetched factories for location:", selectedLocationObj.name_local, factoriesData);
         setFactories(factoriesData);
         setSelectedFactoryId("");
@@ -363,12 +371,12 @@ export function Posts() {
 
         <div className="container mx-auto grid grid-cols-1 sm:grid-cols-2 lg:grid-cols-4 gap-8 px-4">
           {currentPosts.map((product) => {
-            const { image, title, status, year_product, name_factory, describe_product, product_id, factory_id } = product;
+            const { image, image_url, image_variants, title, status, year_product, name_factory, describe_product, product_id, factory_id } = product;
             const productFactory = factories.find((factory) => factory.factory_id === factory_id);
             return (
               <BlogPostCard
                 key={product_id}
-                img={image}
+                img={image_variants?.medium || image_url || image}
                 title={title}
                 status={status}
                 year={year_product ? new Date(year_product).getFullYear() : ""}
@@ -450,15 +458,13 @@ export function Posts() {
             {selectedProduct?.describe_product || "Không có mô tả"}
           </Typography>
 
-          {selectedProduct?.image && (
+          {(selectedProduct?.image_url || selectedProduct?.image) && (
             <div className="w-full h-[24rem] overflow-hidden rounded-lg shadow-lg mb-4">
               <img
                 src={
-                  selectedProduct?.image
-                    ? (selectedProduct.image.startsWith('http')
-                        ? selectedProduct.image
-                        : `http://localhost:8000/${selectedProduct.image.replace(/^\/+/, '')}`)
-                    : ''
+                  (selectedProduct.image_url || selectedProduct.image).startsWith('http')
+                    ? (selectedProduct.image_url || selectedProduct.image)
+                    : `http://localhost:8000/${(selectedProduct.image_url || selectedProduct.image).replace(/^\/+/, '')}`
                 }
                 alt={selectedProduct?.title}
                 className="w-full h-full object-contain"
@@ -466,7 +472,7 @@ export function Posts() {
             </div>
           )}
 
-          {selectedProduct?.video && (
+          {(selectedProduct?.video_url || selectedProduct?.video) && (
             <div className="mb-4">
               <video
                 controls
@@ -476,7 +482,7 @@ export function Posts() {
                 preload="metadata"
                 tabIndex={-1}
               >
-                <source src={selectedProduct.video} type="video/mp4" />
+                <source src={selectedProduct.video_url || selectedProduct.video} type="video/mp4" />
                 Trình duyệt của bạn không hỗ trợ video.
               </video>
             </div>
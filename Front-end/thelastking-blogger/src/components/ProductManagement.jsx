@@ -80,6 +80,22 @@ const ProductManagement = ({ selectedTab, products, setProducts }) => {
               );
               setShowToast({ visible: true, message: 'Sản phẩm đã được cập nhật!', isError: false });
               break;
+            case 'product:status_changed':
+              // Sản phẩm mới luôn là draft, trạng thái chỉ đổi qua POST /product/:product_id/transition
+              const changedProduct = message.data;
+              setProducts((prev) =>
+                prev.map((p) =>
+                  p.product_id === changedProduct.product_id
+                    ? { ...p, status: toProperCase(changedProduct.to_status) }
+                    : p
+                )
+              );
+              setShowToast({ visible: true, message: 'Trạng thái sản phẩm đã thay đổi!', isError: false });
+              break;
+            case 'import:completed':
+              // Nhập hàng loạt qua POST /import không gửi product:created cho từng dòng
+              setShowToast({ visible: true, message: `Đã nhập ${message.data.counts?.products?.create ?? 0} sản phẩm, tải lại trang để xem!`, isError: false });
+              break;
             case 'product:deleted':
               const deletedProduct = message.data;
               setProducts((prev) => prev.filter((p) => p.product_id !== deletedProduct.product_id)); // Update state directly
@@ -132,7 +148,6 @@ const ProductManagement = ({ selectedTab, products, setProducts }) => {
 
     const formData = new FormData();
     formData.append('title', productForm.title || "");
-    formData.append('status', productForm.status || "");
     formData.append('year_product', formattedYear || "");
     formData.append('describe_product', productForm.describe_product || "");
     formData.append('name_factory', productForm.name_factory || "");
@@ -217,7 +232,6 @@ const ProductManagement = ({ selectedTab, products, setProducts }) => {
           <div className="grid grid-cols-1 md:grid-cols-2 gap-6">
             <Input label="Tiêu đề" value={productForm.title} onChange={e => setProductForm({ ...productForm, title: e.target.value })} />
             <Input label="Năm sản xuất" type="date" value={productForm.year_product} onChange={e => setProductForm({ ...productForm, year_product: e.target.value })} />
-            <Input label="Trạng thái" value={productForm.status} onChange={e => setProductForm({ ...productForm, status: e.target.value })} />
             <Input label="Tên nhà máy" value={productForm.name_factory} onChange={e => setProductForm({ ...productForm, name_factory: e.target.value })} />
           </div>
 
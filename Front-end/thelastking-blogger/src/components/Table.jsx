@@ -4,6 +4,20 @@ import axios from 'axios';
 import { Button, Dialog, DialogHeader, DialogBody, DialogFooter, Typography, Input, Card } from "@material-tailwind/react";
 import { getAccessToken } from "../utils/tokenMemory";
 
+// Các bước chuyển trạng thái giống policy.ProductTransition ở backend, quyền vẫn do server kiểm tra
+const STATUS_TRANSITIONS = {
+  draft: [{ to: 'in_review', label: 'Gửi duyệt' }],
+  in_review: [
+    { to: 'published', label: 'Xuất bản' },
+    { to: 'draft', label: 'Trả về bản nháp', requireReason: true },
+  ],
+  published: [
+    { to: 'archived', label: 'Lưu trữ', requireReason: true },
+    { to: 'draft', label: 'Gỡ về bản nháp', requireReason: true },
+  ],
+  archived: [{ to: 'draft', label: 'Mở lại bản nháp', requireReason: true }],
+};
+
 const Table = () => {
   const [products, setProducts] = useState([]); // Keep products state as raw data from API/WebSocket
   const [factories, setFactories] = useState([]); // factories state is needed for factoryMap
@@ -89,8 +103,8 @@ const Table = () => {
       title: rawProduct.title || "N/A",
       year_product: rawProduct.year_product, // Keep original format, will be parsed for display
       status: rawProduct.status || "N/A",
-      image: rawProduct.image || null,
-      video: rawProduct.video || null,
+      image: rawProduct.image_url || rawProduct.image || null,
+      video: rawProduct.video_url || rawProduct.video || null,
       describe_product: rawProduct.describe_product || rawProduct.describe || "N/A", // Backend might use describe or describe_product
       // Look up factory name using the latest factoryMap
       name_factory: rawProduct.factory_id && factoryMap[rawProduct.factory_id] ? factoryMap[rawProduct.factory_id] : rawProduct.name_factory || "N/A", // Prefer map lookup, fallback to name_factory if provided
@@ -137,6 +151,15 @@ const Table = () => {
           fetchFactories();
           fetchProducts(); // Refetch products to ensure the latest data is available for processing
           break;
+        case 'product:status_changed':
+          setProducts(prev =>
+            prev.map(p =>
+              p.product_id === rawData.product_id
+                ? { ...p, status: rawData.status }
+                : p
+            )
+          );
+          break;
         case 'product:deleted':
           setProducts(prev => prev.filter(p => p.product_id !== rawData.product_id));
           // No need to refetch factories or products on delete, the item is just removed.
@@ -283,7 +306,6 @@ const Table = () => {
 
     const formData = new FormData();
     formData.append('title', editingProduct.title || "");
-    formData.append('status', editingProduct.status || "");
     formData.append('describe_product', editingProduct.describe_product || "");
     formData.append('name_factory', editingProduct.name_factory || "");
     if (editingProduct.year_product) {
@@ -311,6 +333,44 @@ const Table = () => {
     }
   }, [editingProduct]);
 
+  // Handle status transition (POST /product/:product_id/transition), trạng thái không sửa trực tiếp trong form
+  const handleTransition = useCallback(async (product, transition) => {
+    if (!product?.product_id) return;
+
+    const accessToken = getAccessToken();
+    if (!accessToken) {
+      setError("Không có token xác thực. Vui lòng đăng nhập lại.");
+      return;
+    }
+
+    let reason = '';
+    if (transition.requireReason) {
+      reason = window.prompt(`Lý do "${transition.label}" cho sản phẩm ${product.title || ''}:`);
+      if (reason === null) return;
+      if (!reason.trim()) {
+        setShowToast({ visible: true, message: 'Bước chuyển này cần ghi lý do', isError: true });
+        return;
+      }
+    }
+
+    try {
+      await axios.post(
+        `http://localhost:8000/thientancay/product/${product.product_id}/transition`,
+        { to: transition.to, from: product.status, reason: reason.trim() },
+        {
+          headers: { Authorization: `Bearer ${accessToken}` },
+          withCredentials: true,
+        }
+      );
+      setEditingProduct(prev => (prev?.product_id === product.product_id ? { ...prev, status: transition.to } : prev));
+      setShowToast({ visible: true, message: `Đã chuyển sang ${transition.to}`, isError: false });
+      await fetchProducts();
+    } catch (err) {
+      const data = err.response?.data;
+      setShowToast({ visible: true, message: data?.comment || data?.error || err.message, isError: true });
+    }
+  }, [fetchProducts]);
+
   // Calculate available years using PROCESSED products
   const availableYears = useMemo(() => {
     if (!Array.isArray(processedProducts)) return [];
@@ -655,19 +715,21 @@ const Table = () => {
 
             <div>
               <Typography variant="h6" className="mb-2">Trạng thái</Typography>
-              <select
-                label="Trạng thái sản phẩm"
-                value={editingProduct?.status ?? ''}
-                onChange={(e) => setEditingProduct({ ...editingProduct, status: e.target.value })}
-                className="border px-3 py-2 rounded-md w-full h-[42px]"
-              >
-                <option value="">Chọn trạng thái</option>
-                {availableStatuses.map((status, index) => (
-                  <option key={index} value={status}>
-                    {status}
-                  </option>
+              <Typography variant="small" className="mb-2">
+                Hiện tại: <b>{editingProduct?.status || 'N/A'}</b>
+              </Typography>
+              <div className="flex flex-wrap gap-2">
+                {(STATUS_TRANSITIONS[editingProduct?.status] || []).map(transition => (
+                  <Button
+                    key={transition.to}
+                    size="sm"
+                    variant="outlined"
+                    onClick={() => handleTransition(editingProduct, transition)}
+                  >
+                    {transition.label}
+                  </Button>
                 ))}
-              </select>
+              </div>
             </div>
 
             <div>
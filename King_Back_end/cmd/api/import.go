@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"thelastking-blogger.com/src/config/db_config"
+	importconfig "thelastking-blogger.com/src/config/import_config"
+	"thelastking-blogger.com/src/importer"
+	"thelastking-blogger.com/src/module"
+	"thelastking-blogger.com/src/repository/import_repo"
+	"thelastking-blogger.com/src/service/import_service"
+	"thelastking-blogger.com/src/storage"
+	"thelastking-blogger.com/src/utils"
+)
+
+const importUsage = `usage: kingbackend import [--dry-run] [--entity locations|factories|products] <file.csv|file.xlsx>
+
+XLSX reads the sheets named locations, factories and products.
+CSV holds a single entity, detected from the header unless --entity is given.
+Nothing is written when any row is invalid; --dry-run only prints the report.`
+
+// runImport xử lý subcommand import và trả về exit code.
+// Chạy ngoài server nên tiến độ in ra stderr thay cho socket, audit_log không có người thực hiện.
+func runImport(args []string) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprintln(os.Stderr, importUsage) }
+	dryRun := flags.Bool("dry-run", false, "validate only")
+	entity := flags.String("entity", "", "entity of a CSV file")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, importUsage)
+		return 2
+	}
+
+	path := flags.Arg(0)
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import: %v\n", err)
+		return 1
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import: %v\n", err)
+		return 1
+	}
+	file, err := importer.Read(filepath.Base(path), f, info.Size(), *entity, importconfig.Get().MaxRows)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import: %v\n", err)
+		return 2
+	}
+
+	db := db_config.GetInstance().Run()
+	if db == nil {
+		fmt.Fprintln(os.Stderr, "import: cannot connect to database")
+		return 1
+	}
+	if err := storage.Init(); err != nil {
+		fmt.Fprintf(os.Stderr, "import: storage: %v\n", err)
+		return 1
+	}
+	importID, err := utils.GenerateUUID()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import: %v\n", err)
+		return 1
+	}
+
+	progress := importer.Throttle(time.Second, func(p module.ImportProgress) {
+		fmt.Fprintf(os.Stderr, "%-8s %d/%d\n", p.Phase, p.Done, p.Total)
+	})
+	importCtrl := import_service.NewImportController(import_repo.NewSql(db), storage.Get())
+	report, err := importCtrl.NewImport(context.Background(), importID, file, *dryRun, progress)
+	if report != nil {
+		printImportReport(report)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import: %v\n", err)
+		if errors.Is(err, module.ErrImportInvalid) || errors.Is(err, module.ErrImportEmpty) || errors.Is(err, module.ErrImportTooLarge) {
+			return 2
+		}
+		return 1
+	}
+	return 0
+}
+
+// printImportReport in các dòng lỗi rồi số dòng theo từng loại
+func printImportReport(report *module.ImportReport) {
+	for _, row := range report.Rows {
+		if row.Action == module.ImportActionInvalid {
+			fmt.Printf("%-9s row %-5d %q: %s\n", row.Entity, row.Row, row.Name, strings.Join(row.Errors, "; "))
+		}
+	}
+	for _, entity := range []string{module.ImportEntityLocation, module.ImportEntityFactory, module.ImportEntityProduct} {
+		counts := report.Counts[entity]
+		fmt.Printf("%-9s create %d, exists %d, invalid %d\n", entity, counts.Create, counts.Exists, counts.Invalid)
+	}
+	switch {
+	case report.Committed:
+		fmt.Println("committed")
+	case report.DryRun:
+		fmt.Println("dry run, nothing written")
+	}
+}
@@ -1,9 +1,19 @@
 package main
 
 import (
+	"os"
+
 	"thelastking-blogger.com/src/server"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			os.Exit(runMigrate(os.Args[2:]))
+		case "import":
+			os.Exit(runImport(os.Args[2:]))
+		}
+	}
 	server.Server()
 }
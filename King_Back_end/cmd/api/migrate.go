@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"thelastking-blogger.com/src/config/db_config"
+	"thelastking-blogger.com/src/database"
+)
+
+const migrateUsage = `usage: kingbackend migrate <command>
+
+commands:
+  up          apply all pending migrations
+  down [N]    revert the last N migrations (default 1)
+  status      list migrations and whether they are applied
+  to N        migrate up or down to version N`
+
+// runMigrate xử lý subcommand migrate và trả về exit code
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	db := db_config.GetInstance().Run()
+	if db == nil {
+		fmt.Fprintln(os.Stderr, "migrate: cannot connect to database")
+		return 1
+	}
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate: %v\n", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		err = migrator.Up(ctx)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil {
+				fmt.Fprintf(os.Stderr, "migrate: invalid step count %q\n", args[1])
+				return 2
+			}
+		}
+		err = migrator.Down(ctx, steps)
+	case "to":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			return 2
+		}
+		version, convErr := strconv.ParseInt(args[1], 10, 64)
+		if convErr != nil {
+			fmt.Fprintf(os.Stderr, "migrate: invalid version %q\n", args[1])
+			return 2
+		}
+		err = migrator.To(ctx, version)
+	case "status":
+		var status []database.MigrationStatus
+		if status, err = migrator.Status(ctx); err == nil {
+			for _, s := range status {
+				state := "pending"
+				if s.Applied {
+					state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+				}
+				fmt.Printf("%4d  %-30s %s\n", s.Version, s.Name, state)
+			}
+		}
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate %s: %v\n", args[0], err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,131 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+	"thelastking-blogger.com/src/module"
+	"thelastking-blogger.com/src/utils"
+)
+
+// redacted thay cho giá trị của các trường nhạy cảm, chỉ ghi nhận là trường đó đã đổi
+const redacted = "[redacted]"
+
+var sensitiveFields = map[string]bool{
+	"password_user": true,
+}
+
+// Actor là người thực hiện thay đổi, được middleware gắn vào context của request
+type Actor struct {
+	UserID           string
+	Role             string
+	ServiceAccountID string
+	IPAddress        string
+}
+
+type actorKey struct{}
+
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom trả về Actor rỗng khi thay đổi không đến từ request (job, CLI)
+func ActorFrom(ctx context.Context) Actor {
+	actor, _ := ctx.Value(actorKey{}).(Actor)
+	return actor
+}
+
+// Record ghi một dòng audit_log bằng tx của thao tác đang thực hiện để log và dữ liệu cùng commit hoặc cùng rollback.
+// before là nil với create, after là nil với delete; update không đổi trường nào thì không ghi.
+func Record(ctx context.Context, tx *gorm.DB, entityType, entityID, action string, before, after any) error {
+	changes, err := Diff(before, after)
+	if err != nil {
+		return err
+	}
+	if action == module.AuditActionUpdate && len(changes) == 0 {
+		return nil
+	}
+	auditID, err := utils.GenerateUUID()
+	if err != nil {
+		return err
+	}
+	actor := ActorFrom(ctx)
+	entry := &module.AuditLog{
+		AuditID:    auditID,
+		ActorRole:  actor.Role,
+		IPAddress:  actor.IPAddress,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Action:     action,
+		Changes:    changes,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if actor.UserID != "" {
+		entry.ActorID = &actor.UserID
+	}
+	if actor.ServiceAccountID != "" {
+		entry.ServiceAccountID = &actor.ServiceAccountID
+	}
+	return tx.Table("audit_log").Create(entry).Error
+}
+
+// Diff so sánh hai đối tượng theo dạng JSON của chúng và chỉ giữ các trường khác nhau
+func Diff(before, after any) (module.AuditChanges, error) {
+	oldFields, err := toFields(before)
+	if err != nil {
+		return nil, err
+	}
+	newFields, err := toFields(after)
+	if err != nil {
+		return nil, err
+	}
+	changes := module.AuditChanges{}
+	for key, oldValue := range oldFields {
+		newValue, ok := newFields[key]
+		if ok && reflect.DeepEqual(oldValue, newValue) {
+			continue
+		}
+		changes[key] = change(key, oldValue, newValue)
+	}
+	for key, newValue := range newFields {
+		if _, ok := oldFields[key]; !ok {
+			changes[key] = change(key, nil, newValue)
+		}
+	}
+	return changes, nil
+}
+
+func change(key string, oldValue, newValue any) module.AuditChange {
+	if sensitiveFields[key] {
+		if oldValue != nil {
+			oldValue = redacted
+		}
+		if newValue != nil {
+			newValue = redacted
+		}
+	}
+	return module.AuditChange{Old: oldValue, New: newValue}
+}
+
+func toFields(value any) (map[string]any, error) {
+	if value == nil || (reflect.ValueOf(value).Kind() == reflect.Pointer && reflect.ValueOf(value).IsNil()) {
+		return nil, nil
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]any)
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	for key, v := range fields {
+		if v == nil {
+			delete(fields, key)
+		}
+	}
+	return fields, nil
+}
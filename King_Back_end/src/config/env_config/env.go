@@ -0,0 +1,82 @@
+// Package envconfig gồm các hàm đọc biến môi trường dùng chung cho mọi package *_config.
+// Biến không đặt thì dùng fallback, giá trị sai định dạng hoặc không dương thì ghi cảnh báo rồi cũng dùng fallback.
+package envconfig
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"thelastking-blogger.com/src/config/logger"
+)
+
+func GetEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func GetInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		logger.GetLogger().Warnf("Invalid %s=%q, using %d", key, value, fallback)
+		return fallback
+	}
+	return n
+}
+
+func GetInt64(key string, fallback int64) int64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n <= 0 {
+		logger.GetLogger().Warnf("Invalid %s=%q, using %d", key, value, fallback)
+		return fallback
+	}
+	return n
+}
+
+func GetBool(key string, fallback bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		logger.GetLogger().Warnf("Invalid %s=%q, using %t", key, value, fallback)
+		return fallback
+	}
+	return b
+}
+
+func GetDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		logger.GetLogger().Warnf("Invalid %s=%q, using %s", key, value, fallback)
+		return fallback
+	}
+	return d
+}
+
+// GetList đọc danh sách cách nhau bởi dấu phẩy, bỏ các phần tử rỗng
+func GetList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,84 @@
+package imageconfig
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/joho/godotenv"
+	envconfig "thelastking-blogger.com/src/config/env_config"
+	"thelastking-blogger.com/src/config/logger"
+)
+
+// Các định dạng của biến thể
+const (
+	FormatAuto = "auto" // JPEG, hoặc PNG nếu ảnh có vùng trong suốt
+	FormatWebP = "webp" // WebP lossless, ảnh chụp lớn sẽ nặng hơn JPEG nên mặc định chỉ tạo ở cỡ vừa
+)
+
+const defaultVariants = "thumb:320:auto,medium:800:auto,large:1600:auto,webp:800:webp"
+
+// Variant là một bản thu nhỏ của ảnh sản phẩm, ảnh nhỏ hơn MaxSize thì giữ nguyên kích thước
+type Variant struct {
+	Name    string
+	MaxSize int // cạnh dài nhất, tính bằng pixel
+	Format  string
+}
+
+// Config cấu hình xử lý ảnh sản phẩm khi upload
+type Config struct {
+	Variants    []Variant
+	JPEGQuality int
+	MaxPixels   int // chặn ảnh có kích thước quá lớn (decompression bomb) trước khi giải mã
+}
+
+var (
+	once     sync.Once
+	instance *Config
+)
+
+// Get đọc cấu hình từ biến môi trường một lần duy nhất
+func Get() *Config {
+	once.Do(func() {
+		if err := godotenv.Load(".env"); err != nil {
+			logger.GetLogger().Warnf("Error loading .env file: %v", err)
+		}
+		variants, err := parseVariants(envconfig.GetEnv("IMAGE_VARIANTS", defaultVariants))
+		if err != nil {
+			logger.GetLogger().Warnf("Invalid IMAGE_VARIANTS: %v, using %q", err, defaultVariants)
+			variants, _ = parseVariants(defaultVariants)
+		}
+		instance = &Config{
+			Variants:    variants,
+			JPEGQuality: envconfig.GetInt("IMAGE_JPEG_QUALITY", 85),
+			MaxPixels:   envconfig.GetInt("IMAGE_MAX_PIXELS", 40_000_000),
+		}
+		if instance.JPEGQuality > 100 {
+			instance.JPEGQuality = 100
+		}
+	})
+	return instance
+}
+
+// parseVariants đọc danh sách dạng name:max_size:format, cách nhau bởi dấu phẩy
+func parseVariants(value string) ([]Variant, error) {
+	var variants []Variant
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(value, ",") {
+		parts := strings.Split(strings.TrimSpace(item), ":")
+		if len(parts) != 3 || parts[0] == "" || seen[parts[0]] {
+			return nil, fmt.Errorf("invalid variant %q, expected name:max_size:auto|webp", item)
+		}
+		size, err := strconv.Atoi(parts[1])
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("invalid variant %q, expected name:max_size:auto|webp", item)
+		}
+		if parts[2] != FormatAuto && parts[2] != FormatWebP {
+			return nil, fmt.Errorf("invalid variant %q, expected name:max_size:auto|webp", item)
+		}
+		seen[parts[0]] = true
+		variants = append(variants, Variant{Name: parts[0], MaxSize: size, Format: parts[2]})
+	}
+	return variants, nil
+}
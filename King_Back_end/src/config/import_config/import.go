@@ -0,0 +1,34 @@
+package importconfig
+
+import (
+	"sync"
+
+	"github.com/joho/godotenv"
+	envconfig "thelastking-blogger.com/src/config/env_config"
+	"thelastking-blogger.com/src/config/logger"
+)
+
+// Config giới hạn file nhập hàng loạt khu vực, nhà máy, sản phẩm (CSV/XLSX)
+type Config struct {
+	MaxSize int64 // kích thước tối đa của file upload
+	MaxRows int   // tổng số dòng dữ liệu tối đa của mọi sheet, cả file nằm trong một transaction
+}
+
+var (
+	once     sync.Once
+	instance *Config
+)
+
+// Get đọc cấu hình từ biến môi trường một lần duy nhất
+func Get() *Config {
+	once.Do(func() {
+		if err := godotenv.Load(".env"); err != nil {
+			logger.GetLogger().Warnf("Error loading .env file: %v", err)
+		}
+		instance = &Config{
+			MaxSize: envconfig.GetInt64("IMPORT_MAX_SIZE", 10<<20),
+			MaxRows: int(envconfig.GetInt64("IMPORT_MAX_ROWS", 5000)),
+		}
+	})
+	return instance
+}
@@ -1,22 +1,57 @@
 package jwtconfig
 
 import (
-	"log"
+	"encoding/base64"
 	"os"
+	"sync"
+	"time"
 
 	"github.com/joho/godotenv"
+	envconfig "thelastking-blogger.com/src/config/env_config"
+	"thelastking-blogger.com/src/config/logger"
 )
 
-var KeyJwt string
+// Config cấu hình ký JWT bằng khóa bất đối xứng và lịch xoay vòng khóa
+type Config struct {
+	Algorithm        string        // RS256 hoặc EdDSA
+	RotationInterval time.Duration // sau khoảng này khóa mới được tạo để ký, khóa cũ chỉ còn dùng để verify
+	AccessTTL        time.Duration
+	RefreshTTL       time.Duration
+	Issuer           string
+	KeyEncryptionKey []byte // 32 byte AES-256 mã hóa private key lưu trong jwt_keys, bắt buộc
+}
 
-func init() {
-	err := godotenv.Load(".env")
-	if err != nil {
-		log.Fatal("Error loading .env file")
-	}
+var (
+	once     sync.Once
+	instance *Config
+)
 
-	KeyJwt = os.Getenv("KEY_JWT")
-	if KeyJwt == "" {
-		log.Fatal("KEY_JWT is not set")
-	}
+// Get đọc cấu hình từ biến môi trường một lần duy nhất
+func Get() *Config {
+	once.Do(func() {
+		if err := godotenv.Load(".env"); err != nil {
+			logger.GetLogger().Warnf("Error loading .env file: %v", err)
+		}
+		instance = &Config{
+			Algorithm:        envconfig.GetEnv("JWT_ALGORITHM", "EdDSA"),
+			RotationInterval: envconfig.GetDuration("JWT_KEY_ROTATION", 30*24*time.Hour),
+			AccessTTL:        envconfig.GetDuration("JWT_ACCESS_TTL", 15*time.Minute),
+			RefreshTTL:       envconfig.GetDuration("JWT_REFRESH_TTL", 7*24*time.Hour),
+			Issuer:           envconfig.GetEnv("JWT_ISSUER", "thientancay"),
+		}
+		// JWT_KEY_ENCRYPTION_KEY là 32 byte ngẫu nhiên dạng base64, ví dụ tạo bằng `openssl rand -base64 32`
+		if raw := os.Getenv("JWT_KEY_ENCRYPTION_KEY"); raw != "" {
+			key, err := base64.StdEncoding.DecodeString(raw)
+			if err != nil || len(key) != 32 {
+				logger.GetLogger().Warnf("Invalid JWT_KEY_ENCRYPTION_KEY, expected 32 bytes in base64")
+			} else {
+				instance.KeyEncryptionKey = key
+			}
+		}
+		if instance.Algorithm != "RS256" && instance.Algorithm != "EdDSA" {
+			logger.GetLogger().Warnf("Unsupported JWT_ALGORITHM %q, falling back to EdDSA", instance.Algorithm)
+			instance.Algorithm = "EdDSA"
+		}
+	})
+	return instance
 }
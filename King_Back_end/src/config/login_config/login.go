@@ -0,0 +1,56 @@
+package loginconfig
+
+import (
+	"sync"
+	"time"
+
+	"github.com/joho/godotenv"
+	envconfig "thelastking-blogger.com/src/config/env_config"
+	"thelastking-blogger.com/src/config/logger"
+)
+
+// Config giới hạn số lần đăng nhập sai trước khi khóa tạm thời
+type Config struct {
+	AccountMaxAttempts int           // số lần sai liên tiếp của một tài khoản trước khi khóa
+	IPMaxAttempts      int           // số lần sai từ một IP trước khi khóa IP đó
+	Window             time.Duration // không sai thêm lần nào trong khoảng này thì bộ đếm về 0
+	LockoutBase        time.Duration // thời gian khóa lần đầu, nhân đôi sau mỗi lần sai tiếp theo
+	LockoutMax         time.Duration
+}
+
+var (
+	once     sync.Once
+	instance *Config
+)
+
+// Get đọc cấu hình từ biến môi trường một lần duy nhất
+func Get() *Config {
+	once.Do(func() {
+		if err := godotenv.Load(".env"); err != nil {
+			logger.GetLogger().Warnf("Error loading .env file: %v", err)
+		}
+		instance = &Config{
+			AccountMaxAttempts: envconfig.GetInt("LOGIN_MAX_ATTEMPTS", 5),
+			IPMaxAttempts:      envconfig.GetInt("LOGIN_IP_MAX_ATTEMPTS", 20),
+			Window:             envconfig.GetDuration("LOGIN_ATTEMPT_WINDOW", 15*time.Minute),
+			LockoutBase:        envconfig.GetDuration("LOGIN_LOCKOUT_BASE", time.Minute),
+			LockoutMax:         envconfig.GetDuration("LOGIN_LOCKOUT_MAX", time.Hour),
+		}
+	})
+	return instance
+}
+
+// LockoutFor tính thời gian khóa theo số lần sai: base, 2*base, 4*base... tối đa LockoutMax
+func (c *Config) LockoutFor(failures, threshold int) time.Duration {
+	if failures < threshold {
+		return 0
+	}
+	d := c.LockoutBase
+	for i := threshold; i < failures && d < c.LockoutMax; i++ {
+		d *= 2
+	}
+	if d > c.LockoutMax {
+		d = c.LockoutMax
+	}
+	return d
+}
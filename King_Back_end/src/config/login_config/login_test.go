@@ -0,0 +1,39 @@
+package loginconfig
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockoutFor(t *testing.T) {
+	cfg := &Config{LockoutBase: time.Minute, LockoutMax: 10 * time.Minute}
+	tests := []struct {
+		name      string
+		failures  int
+		threshold int
+		want      time.Duration
+	}{
+		{"no failures", 0, 5, 0},
+		{"below threshold", 4, 5, 0},
+		{"at threshold", 5, 5, time.Minute},
+		{"one over threshold", 6, 5, 2 * time.Minute},
+		{"three over threshold", 8, 5, 8 * time.Minute},
+		{"capped at max", 9, 5, 10 * time.Minute},
+		{"far over threshold", 500, 5, 10 * time.Minute},
+		{"ip threshold", 21, 20, 2 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cfg.LockoutFor(tt.failures, tt.threshold); got != tt.want {
+				t.Errorf("LockoutFor(%d, %d) = %v, want %v", tt.failures, tt.threshold, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLockoutForBaseAboveMax(t *testing.T) {
+	cfg := &Config{LockoutBase: time.Hour, LockoutMax: time.Minute}
+	if got := cfg.LockoutFor(5, 5); got != time.Minute {
+		t.Errorf("LockoutFor() = %v, want LockoutMax", got)
+	}
+}
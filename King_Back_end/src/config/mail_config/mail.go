@@ -0,0 +1,55 @@
+package mailconfig
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/joho/godotenv"
+	envconfig "thelastking-blogger.com/src/config/env_config"
+	"thelastking-blogger.com/src/config/logger"
+)
+
+// Config cấu hình gửi email và link đặt lại mật khẩu
+type Config struct {
+	Driver   string // smtp hoặc file
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	FilePath string // file nhận email khi Driver=file, để trống thì chỉ ghi log
+
+	ResetURL string // link trên frontend, token được nối vào query ?token=
+	ResetTTL time.Duration
+}
+
+var (
+	once     sync.Once
+	instance *Config
+)
+
+// Get đọc cấu hình từ biến môi trường một lần duy nhất
+func Get() *Config {
+	once.Do(func() {
+		if err := godotenv.Load(".env"); err != nil {
+			logger.GetLogger().Warnf("Error loading .env file: %v", err)
+		}
+		instance = &Config{
+			Driver:   envconfig.GetEnv("MAIL_DRIVER", "file"),
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     envconfig.GetInt("SMTP_PORT", 587),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     envconfig.GetEnv("MAIL_FROM", "no-reply@thientancay.local"),
+			FilePath: os.Getenv("MAIL_FILE_PATH"),
+			ResetURL: envconfig.GetEnv("PASSWORD_RESET_URL", "http://localhost:5173/reset-password"),
+			ResetTTL: envconfig.GetDuration("PASSWORD_RESET_TTL", 30*time.Minute),
+		}
+		if instance.Driver != "smtp" && instance.Driver != "file" {
+			logger.GetLogger().Warnf("Unsupported MAIL_DRIVER %q, falling back to file", instance.Driver)
+			instance.Driver = "file"
+		}
+	})
+	return instance
+}
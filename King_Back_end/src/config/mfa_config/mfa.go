@@ -0,0 +1,54 @@
+package mfaconfig
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joho/godotenv"
+	envconfig "thelastking-blogger.com/src/config/env_config"
+	"thelastking-blogger.com/src/config/logger"
+)
+
+// Config cấu hình xác thực hai bước
+type Config struct {
+	Issuer        string          // tên hiển thị trong ứng dụng authenticator
+	PendingTTL    time.Duration   // thời gian sống của mfa_pending token
+	RequiredRoles map[string]bool // role bắt buộc bật 2FA
+}
+
+var (
+	once     sync.Once
+	instance *Config
+)
+
+// Get đọc cấu hình từ biến môi trường một lần duy nhất
+func Get() *Config {
+	once.Do(func() {
+		if err := godotenv.Load(".env"); err != nil {
+			logger.GetLogger().Warnf("Error loading .env file: %v", err)
+		}
+		instance = &Config{
+			Issuer:        envconfig.GetEnv("MFA_ISSUER", "ThienTanCay"),
+			PendingTTL:    envconfig.GetDuration("MFA_PENDING_TTL", 5*time.Minute),
+			RequiredRoles: make(map[string]bool),
+		}
+		// MFA_REQUIRED_ROLES= (để trống có chủ đích) thì không role nào bị bắt buộc
+		roles, ok := os.LookupEnv("MFA_REQUIRED_ROLES")
+		if !ok {
+			roles = "ADMIN,ROOT"
+		}
+		for _, role := range strings.Split(roles, ",") {
+			if role = strings.ToUpper(strings.TrimSpace(role)); role != "" {
+				instance.RequiredRoles[role] = true
+			}
+		}
+	})
+	return instance
+}
+
+// IsRequired cho biết role có bắt buộc bật 2FA hay không
+func (c *Config) IsRequired(role *string) bool {
+	return role != nil && c.RequiredRoles[strings.ToUpper(*role)]
+}
@@ -0,0 +1,112 @@
+package oidcconfig
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joho/godotenv"
+	envconfig "thelastking-blogger.com/src/config/env_config"
+	"thelastking-blogger.com/src/config/logger"
+	"thelastking-blogger.com/src/module"
+)
+
+// Config cấu hình đăng nhập một lần qua OpenID Connect (authorization code + PKCE).
+// OIDC_ISSUER để trống thì tắt hẳn tính năng này.
+type Config struct {
+	Issuer               string
+	ClientID             string
+	ClientSecret         string // để trống với public client, khi đó chỉ dựa vào PKCE
+	RedirectURL          string
+	Scopes               []string
+	EmailClaim           string
+	NameClaim            string
+	GroupsClaim          string
+	RoleMapping          map[string]string // nhóm bên IdP -> role, ví dụ "it-admins=ADMIN"
+	DefaultRole          string            // role khi không thuộc nhóm nào được map, áp dụng cả cho tài khoản đã có
+	DefaultTag           string
+	AllowedDomain        string // chỉ nhận email thuộc domain này, khớp validator thientan_email
+	AutoProvision        bool
+	RequireEmailVerified bool
+	StateTTL             time.Duration
+	SuccessURL           string // trang SPA nhận trình duyệt sau khi đăng nhập xong, access token lấy qua /refresh-token
+	LoginURL             string // trang đăng nhập của SPA, nhận lỗi qua ?sso_error= và bước 2FA qua #mfa_token=
+}
+
+var (
+	once     sync.Once
+	instance *Config
+)
+
+// Get đọc cấu hình từ biến môi trường một lần duy nhất
+func Get() *Config {
+	once.Do(func() {
+		if err := godotenv.Load(".env"); err != nil {
+			logger.GetLogger().Warnf("Error loading .env file: %v", err)
+		}
+		instance = &Config{
+			Issuer:               strings.TrimRight(os.Getenv("OIDC_ISSUER"), "/"),
+			ClientID:             os.Getenv("OIDC_CLIENT_ID"),
+			ClientSecret:         os.Getenv("OIDC_CLIENT_SECRET"),
+			RedirectURL:          os.Getenv("OIDC_REDIRECT_URL"),
+			Scopes:               strings.Fields(envconfig.GetEnv("OIDC_SCOPES", "openid email profile")),
+			EmailClaim:           envconfig.GetEnv("OIDC_EMAIL_CLAIM", "email"),
+			NameClaim:            envconfig.GetEnv("OIDC_NAME_CLAIM", "name"),
+			GroupsClaim:          envconfig.GetEnv("OIDC_GROUPS_CLAIM", "groups"),
+			RoleMapping:          make(map[string]string),
+			DefaultRole:          module.USER.String(),
+			DefaultTag:           envconfig.GetEnv("OIDC_DEFAULT_TAG", "SSO"),
+			AllowedDomain:        strings.ToLower(envconfig.GetEnv("OIDC_ALLOWED_DOMAIN", "thientan.com")),
+			AutoProvision:        envconfig.GetBool("OIDC_AUTO_PROVISION", true),
+			RequireEmailVerified: envconfig.GetBool("OIDC_REQUIRE_EMAIL_VERIFIED", true),
+			StateTTL:             envconfig.GetDuration("OIDC_STATE_TTL", 10*time.Minute),
+			SuccessURL:           envconfig.GetEnv("OIDC_SUCCESS_URL", "http://localhost:5173/server"),
+			LoginURL:             envconfig.GetEnv("OIDC_LOGIN_URL", "http://localhost:5173/login"),
+		}
+		if role, ok := module.ParseRoles(envconfig.GetEnv("OIDC_DEFAULT_ROLE", module.USER.String())); ok {
+			instance.DefaultRole = role.String()
+		} else {
+			logger.GetLogger().Warnf("Invalid OIDC_DEFAULT_ROLE, using %s", instance.DefaultRole)
+		}
+		// OIDC_ROLE_MAPPING=nhom-a=ADMIN,nhom-b=USER
+		for _, pair := range strings.Split(os.Getenv("OIDC_ROLE_MAPPING"), ",") {
+			group, role, found := strings.Cut(strings.TrimSpace(pair), "=")
+			if !found {
+				continue
+			}
+			parsed, ok := module.ParseRoles(strings.TrimSpace(role))
+			if !ok || strings.TrimSpace(group) == "" {
+				logger.GetLogger().Warnf("Invalid OIDC_ROLE_MAPPING entry %q", pair)
+				continue
+			}
+			instance.RoleMapping[strings.TrimSpace(group)] = parsed.String()
+		}
+		if instance.Issuer != "" && (instance.ClientID == "" || instance.RedirectURL == "") {
+			logger.GetLogger().Warnf("OIDC_ISSUER is set but OIDC_CLIENT_ID or OIDC_REDIRECT_URL is missing, OIDC login disabled")
+			instance.Issuer = ""
+		}
+	})
+	return instance
+}
+
+// Enabled cho biết đăng nhập OIDC đã được cấu hình
+func (c *Config) Enabled() bool {
+	return c.Issuer != ""
+}
+
+// MapRole trả về role cao nhất trong các nhóm được map, false nếu không nhóm nào được map
+func (c *Config) MapRole(groups []string) (string, bool) {
+	best, found := module.USER, false
+	for _, group := range groups {
+		role, ok := c.RoleMapping[group]
+		if !ok {
+			continue
+		}
+		parsed, _ := module.ParseRoles(role)
+		if !found || parsed > best {
+			best, found = parsed, true
+		}
+	}
+	return best.String(), found
+}
@@ -0,0 +1,52 @@
+package passwordconfig
+
+import (
+	"sync"
+
+	"github.com/joho/godotenv"
+	"golang.org/x/crypto/bcrypt"
+	envconfig "thelastking-blogger.com/src/config/env_config"
+	"thelastking-blogger.com/src/config/logger"
+)
+
+// Config chính sách băm mật khẩu; hash cũ yếu hơn chính sách được băm lại khi user đăng nhập
+type Config struct {
+	Algorithm     string // argon2id hoặc bcrypt
+	BcryptCost    int
+	Argon2Memory  uint32 // KiB
+	Argon2Time    uint32
+	Argon2Threads uint8
+}
+
+var (
+	once     sync.Once
+	instance *Config
+)
+
+// Get đọc cấu hình từ biến môi trường một lần duy nhất
+func Get() *Config {
+	once.Do(func() {
+		if err := godotenv.Load(".env"); err != nil {
+			logger.GetLogger().Warnf("Error loading .env file: %v", err)
+		}
+		instance = &Config{
+			Algorithm:     envconfig.GetEnv("PASSWORD_HASHER", "argon2id"),
+			BcryptCost:    envconfig.GetInt("BCRYPT_COST", 12),
+			Argon2Memory:  uint32(envconfig.GetInt("ARGON2_MEMORY", 64*1024)),
+			Argon2Time:    uint32(envconfig.GetInt("ARGON2_TIME", 3)),
+			Argon2Threads: uint8(envconfig.GetInt("ARGON2_THREADS", 2)),
+		}
+		if instance.Algorithm != "argon2id" && instance.Algorithm != "bcrypt" {
+			logger.GetLogger().Warnf("Unsupported PASSWORD_HASHER %q, falling back to argon2id", instance.Algorithm)
+			instance.Algorithm = "argon2id"
+		}
+		if instance.BcryptCost < bcrypt.MinCost || instance.BcryptCost > bcrypt.MaxCost {
+			logger.GetLogger().Warnf("Invalid BCRYPT_COST=%d, using 12", instance.BcryptCost)
+			instance.BcryptCost = 12
+		}
+		if instance.Argon2Threads == 0 {
+			instance.Argon2Threads = 2
+		}
+	})
+	return instance
+}
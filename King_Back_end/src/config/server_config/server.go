@@ -0,0 +1,35 @@
+package serverconfig
+
+import (
+	"sync"
+
+	"github.com/joho/godotenv"
+	envconfig "thelastking-blogger.com/src/config/env_config"
+	"thelastking-blogger.com/src/config/logger"
+)
+
+// Config là cấu hình của HTTP server
+type Config struct {
+	// TrustedProxies là IP/CIDR của reverse proxy được tin để đọc X-Forwarded-For, X-Real-IP (TRUSTED_PROXIES, cách nhau bởi dấu phẩy).
+	// Để trống thì không tin proxy nào, ClientIP là địa chỉ của kết nối nên client không giả được IP
+	// dùng cho khóa đăng nhập, phiên đăng nhập, audit log và last_used_ip của api key.
+	TrustedProxies []string
+}
+
+var (
+	once     sync.Once
+	instance *Config
+)
+
+// Get đọc cấu hình từ biến môi trường một lần duy nhất
+func Get() *Config {
+	once.Do(func() {
+		if err := godotenv.Load(".env"); err != nil {
+			logger.GetLogger().Warnf("Error loading .env file: %v", err)
+		}
+		instance = &Config{
+			TrustedProxies: envconfig.GetList("TRUSTED_PROXIES"),
+		}
+	})
+	return instance
+}
@@ -0,0 +1,61 @@
+package storageconfig
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joho/godotenv"
+	envconfig "thelastking-blogger.com/src/config/env_config"
+	"thelastking-blogger.com/src/config/logger"
+)
+
+// Config chọn nơi lưu file media (ảnh, video) của sản phẩm
+type Config struct {
+	Driver string // local hoặc s3
+
+	LocalDir string // thư mục lưu file khi Driver=local, được phục vụ tại /uploads
+	LocalURL string // tiền tố URL trả cho client, đổi khi /uploads nằm sau CDN hoặc domain khác
+
+	S3Endpoint   string // host[:port] của S3 hoặc MinIO, không kèm scheme
+	S3AccessKey  string
+	S3SecretKey  string
+	S3Bucket     string
+	S3Region     string
+	S3UseSSL     bool
+	S3PublicURL  string        // bucket public hoặc có CDN thì URL = S3PublicURL/key, để trống thì dùng presigned URL
+	S3PresignTTL time.Duration // thời hạn của presigned URL
+}
+
+var (
+	once     sync.Once
+	instance *Config
+)
+
+// Get đọc cấu hình từ biến môi trường một lần duy nhất
+func Get() *Config {
+	once.Do(func() {
+		if err := godotenv.Load(".env"); err != nil {
+			logger.GetLogger().Warnf("Error loading .env file: %v", err)
+		}
+		instance = &Config{
+			Driver:       envconfig.GetEnv("STORAGE_DRIVER", "local"),
+			LocalDir:     envconfig.GetEnv("STORAGE_LOCAL_DIR", "uploads"),
+			LocalURL:     strings.TrimRight(envconfig.GetEnv("STORAGE_LOCAL_URL", "/uploads"), "/"),
+			S3Endpoint:   os.Getenv("S3_ENDPOINT"),
+			S3AccessKey:  os.Getenv("S3_ACCESS_KEY"),
+			S3SecretKey:  os.Getenv("S3_SECRET_KEY"),
+			S3Bucket:     envconfig.GetEnv("S3_BUCKET", "thientancay"),
+			S3Region:     envconfig.GetEnv("S3_REGION", "us-east-1"),
+			S3UseSSL:     os.Getenv("S3_USE_SSL") == "true",
+			S3PublicURL:  strings.TrimRight(os.Getenv("S3_PUBLIC_URL"), "/"),
+			S3PresignTTL: envconfig.GetDuration("S3_PRESIGN_TTL", time.Hour),
+		}
+		if instance.Driver != "local" && instance.Driver != "s3" {
+			logger.GetLogger().Warnf("Unsupported STORAGE_DRIVER %q, falling back to local", instance.Driver)
+			instance.Driver = "local"
+		}
+	})
+	return instance
+}
@@ -0,0 +1,39 @@
+package uploadconfig
+
+import (
+	"sync"
+	"time"
+
+	"github.com/joho/godotenv"
+	envconfig "thelastking-blogger.com/src/config/env_config"
+	"thelastking-blogger.com/src/config/logger"
+)
+
+// Config cấu hình upload video theo từng phần (có thể tiếp tục khi mất kết nối)
+type Config struct {
+	TmpDir    string        // nơi giữ phần đã nhận, nhiều instance thì phải là ổ đĩa chia sẻ
+	MaxSize   int64         // kích thước tối đa của cả file
+	ChunkSize int64         // kích thước tối đa của một phần, client nên gửi đúng bằng giá trị này
+	TTL       time.Duration // upload không có phần mới hoặc không được gắn vào sản phẩm sau khoảng này thì bị dọn
+}
+
+var (
+	once     sync.Once
+	instance *Config
+)
+
+// Get đọc cấu hình từ biến môi trường một lần duy nhất
+func Get() *Config {
+	once.Do(func() {
+		if err := godotenv.Load(".env"); err != nil {
+			logger.GetLogger().Warnf("Error loading .env file: %v", err)
+		}
+		instance = &Config{
+			TmpDir:    envconfig.GetEnv("UPLOAD_TMP_DIR", "uploads_tmp"),
+			MaxSize:   envconfig.GetInt64("UPLOAD_MAX_SIZE", 2<<30),
+			ChunkSize: envconfig.GetInt64("UPLOAD_CHUNK_SIZE", 8<<20),
+			TTL:       envconfig.GetDuration("UPLOAD_TTL", 24*time.Hour),
+		}
+	})
+	return instance
+}
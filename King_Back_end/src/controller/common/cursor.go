@@ -0,0 +1,101 @@
+package common
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Keyset là cặp cột (thời gian, khóa chính) quyết định thứ tự mặc định của danh sách, mới nhất lên đầu.
+// Khóa chính phân định các dòng cùng thời gian nên thứ tự luôn xác định.
+type Keyset struct {
+	Time string
+	ID   string
+}
+
+func (k Keyset) order() string {
+	return k.Time + " DESC, " + k.ID + " DESC"
+}
+
+// cursor là vị trí của dòng cuối trang trước, client chỉ thấy chuỗi base64 không cần hiểu nội dung
+type cursor struct {
+	Time time.Time `json:"t"`
+	ID   string    `json:"id"`
+}
+
+func encodeCursor(t time.Time, id string) string {
+	raw, _ := json.Marshal(cursor{Time: t.UTC(), ID: id})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// ParseCursor giải mã cursor client gửi lên. Cursor chỉ có nghĩa với thứ tự mặc định nên không đi cùng sort.
+func (p *Paggings) ParseCursor(query *ListQuery) error {
+	if p.Cursor == "" {
+		return nil
+	}
+	if query.Sorted() {
+		return fmt.Errorf("%w: cursor cannot be combined with sort", ErrInvalidCursor)
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(p.Cursor)
+	if err != nil {
+		return ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(raw, &c); err != nil || c.ID == "" || c.Time.IsZero() {
+		return ErrInvalidCursor
+	}
+	p.after = &c
+	return nil
+}
+
+// NoCursor dùng cho danh sách không theo thứ tự (thời gian, khóa chính) như kết quả tìm kiếm,
+// cursor gửi lên bị từ chối thay vì bị bỏ qua âm thầm rồi trả lại trang đầu
+func (p *Paggings) NoCursor() error {
+	if p.Cursor != "" {
+		return fmt.Errorf("%w: this list only supports page", ErrInvalidCursor)
+	}
+	return nil
+}
+
+// FindPage đọc một trang của db theo bộ lọc query:
+//   - có cursor: lấy các dòng đứng sau cursor theo keyset, không COUNT và không bị lệch khi có dòng mới chèn vào
+//   - không có cursor: phân trang theo page như cũ và điền Total
+//
+// Khi đi theo thứ tự mặc định, trang còn dòng phía sau thì NextCursor trỏ vào dòng cuối của trang.
+// key trả về giá trị của hai cột keyset trên một dòng.
+func FindPage[T any](db *gorm.DB, p *Paggings, query *ListQuery, keys Keyset, key func(*T) (*time.Time, string)) ([]T, error) {
+	var data []T
+	db = query.Where(db)
+	if p.after != nil {
+		db = db.Where(fmt.Sprintf("(%s, %s) < (?, ?)", keys.Time, keys.ID), p.after.Time, p.after.ID)
+	} else {
+		if err := db.Count(&p.Total).Error; err != nil {
+			return nil, err
+		}
+		db = db.Offset((p.Page - 1) * p.Limit)
+	}
+
+	if query.Sorted() {
+		if err := query.Order(db, keys.order()).Limit(p.Limit).Find(&data).Error; err != nil {
+			return nil, err
+		}
+		return data, nil
+	}
+	// Đọc thêm một dòng để biết còn trang sau hay không
+	if err := db.Order(keys.order()).Limit(p.Limit + 1).Find(&data).Error; err != nil {
+		return nil, err
+	}
+	if len(data) > p.Limit {
+		data = data[:p.Limit]
+		if t, id := key(&data[len(data)-1]); t != nil {
+			p.NextCursor = encodeCursor(*t, id)
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,90 @@
+package common
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		time time.Time
+		id   string
+	}{
+		{"utc", time.Date(2024, 6, 1, 8, 30, 0, 0, time.UTC), "a1"},
+		{"microseconds kept", time.Date(2024, 6, 1, 8, 30, 0, 123456000, time.UTC), "b2"},
+		{"other zone becomes utc", time.Date(2024, 6, 1, 15, 30, 0, 0, time.FixedZone("ICT", 7*3600)), "c3"},
+		{"id with symbols", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), "5f0c-uuid/+="},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Paggings{Cursor: encodeCursor(tt.time, tt.id)}
+			if err := p.ParseCursor(nil); err != nil {
+				t.Fatalf("ParseCursor() error: %v", err)
+			}
+			if p.after == nil {
+				t.Fatal("ParseCursor() did not set the position")
+			}
+			if !p.after.Time.Equal(tt.time) || p.after.Time.Location() != time.UTC {
+				t.Errorf("time = %v, want %v in UTC", p.after.Time, tt.time)
+			}
+			if p.after.ID != tt.id {
+				t.Errorf("id = %q, want %q", p.after.ID, tt.id)
+			}
+		})
+	}
+}
+
+func TestParseCursorInvalid(t *testing.T) {
+	encode := func(raw string) string { return base64.RawURLEncoding.EncodeToString([]byte(raw)) }
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"not base64", "%%%"},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte(`{"t":"2024-06-01T00:00:00Z","id":"a"}`))},
+		{"not json", encode("hello")},
+		{"missing id", encode(`{"t":"2024-06-01T00:00:00Z"}`)},
+		{"missing time", encode(`{"id":"a"}`)},
+		{"bad time", encode(`{"t":"yesterday","id":"a"}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Paggings{Cursor: tt.cursor}
+			if err := p.ParseCursor(nil); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("ParseCursor() error = %v, want ErrInvalidCursor", err)
+			}
+			if p.after != nil {
+				t.Error("invalid cursor set the position")
+			}
+		})
+	}
+}
+
+func TestParseCursorWithSort(t *testing.T) {
+	query, err := ParseListQuery(url.Values{"sort": {"title"}}, testFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Paggings{Cursor: encodeCursor(time.Now(), "a")}
+	if err := p.ParseCursor(query); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("ParseCursor() with sort error = %v, want ErrInvalidCursor", err)
+	}
+
+	p = &Paggings{}
+	if err := p.ParseCursor(query); err != nil {
+		t.Errorf("ParseCursor() without cursor error = %v", err)
+	}
+}
+
+func TestNoCursor(t *testing.T) {
+	if err := (&Paggings{}).NoCursor(); err != nil {
+		t.Errorf("NoCursor() without cursor = %v", err)
+	}
+	if err := (&Paggings{Cursor: encodeCursor(time.Now(), "a")}).NoCursor(); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("NoCursor() with cursor = %v, want ErrInvalidCursor", err)
+	}
+}
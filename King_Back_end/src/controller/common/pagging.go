@@ -1,9 +1,15 @@
 package common
 
+// Paggings: có cursor thì phân trang theo keyset và bỏ qua page/total,
+// next_cursor chỉ có khi danh sách theo thứ tự mặc định và còn trang sau
 type Paggings struct {
-	Limit int   `json:"limit" form:"limit"`
-	Page  int   `json:"page" form:"page"`
-	Total int64 `json:"total" form:"-"`
+	Limit      int    `json:"limit" form:"limit"`
+	Page       int    `json:"page" form:"page"`
+	Total      int64  `json:"total" form:"-"`
+	Cursor     string `json:"-" form:"cursor"`
+	NextCursor string `json:"next_cursor,omitempty" form:"-"`
+
+	after *cursor
 }
 
 func (p *Paggings) Process() {
@@ -0,0 +1,244 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FieldType quyết định cách đọc giá trị lọc và các toán tử được dùng
+type FieldType int
+
+const (
+	FieldString FieldType = iota // so sánh bằng, nhiều giá trị cách nhau bởi dấu phẩy
+	FieldDate                    // YYYY-MM-DD, so sánh bằng hoặc khoảng
+	FieldTime                    // RFC3339 hoặc YYYY-MM-DD (cả ngày, theo UTC), so sánh bằng hoặc khoảng
+)
+
+// Field là một trường client được lọc/sắp xếp, Column là cột SQL và có thể kèm alias bảng (p.status)
+type Field struct {
+	Column string
+	Type   FieldType
+	Filter bool
+	Sort   bool
+}
+
+// ListFields là whitelist trường của một loại đối tượng, key là tên trường trên query string
+type ListFields map[string]Field
+
+var ErrInvalidListQuery = errors.New("invalid list query")
+
+// reservedParams là tham số của phân trang/phạm vi, không phải trường lọc
+var reservedParams = map[string]bool{
+	"page":   true,
+	"limit":  true,
+	"sort":   true,
+	"scope":  true,
+	"cursor": true,
+}
+
+// filterKey: field hoặc field[op]
+var filterKey = regexp.MustCompile(`^([a-z_]+)(?:\[([a-z]+)\])?$`)
+
+var rangeOps = map[string]bool{"eq": true, "gt": true, "gte": true, "lt": true, "lte": true}
+
+type sortField struct {
+	column clause.Column
+	desc   bool
+}
+
+// ListQuery là điều kiện lọc và thứ tự đã được kiểm tra theo whitelist, chỉ gồm tên cột trong ListFields
+// và giá trị được truyền dưới dạng tham số nên an toàn khi đưa vào GORM
+type ListQuery struct {
+	filters []clause.Expression
+	sorts   []sortField
+}
+
+// ParseListQuery đọc bộ lọc và sort từ query string:
+//
+//	?status=active,draft&factory=<id>            bằng một trong các giá trị
+//	?year_product[gte]=2020-01-01&created_at[lt]=2024-06-01T00:00:00Z
+//	?sort=-created_at,title                      dấu - là giảm dần
+//
+// Trường không có trong fields, toán tử sai hoặc giá trị sai định dạng trả về lỗi bọc ErrInvalidListQuery.
+func ParseListQuery(values url.Values, fields ListFields) (*ListQuery, error) {
+	query := &ListQuery{}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	// Duyệt theo thứ tự cố định để thông báo lỗi và câu SQL không đổi giữa các lần gọi
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if reservedParams[key] {
+			continue
+		}
+		match := filterKey.FindStringSubmatch(key)
+		if match == nil {
+			return nil, fmt.Errorf("%w: unknown filter field %q, allowed: %s", ErrInvalidListQuery, key, fields.allowed(true))
+		}
+		name, op := match[1], match[2]
+		field, ok := fields[name]
+		if !ok || !field.Filter {
+			return nil, fmt.Errorf("%w: unknown filter field %q, allowed: %s", ErrInvalidListQuery, name, fields.allowed(true))
+		}
+		if op == "" {
+			op = "eq"
+		}
+		for _, raw := range values[key] {
+			exprs, err := field.conditions(name, op, raw)
+			if err != nil {
+				return nil, err
+			}
+			query.filters = append(query.filters, exprs...)
+		}
+	}
+
+	if raw := values.Get("sort"); raw != "" {
+		seen := make(map[string]bool)
+		for _, item := range strings.Split(raw, ",") {
+			item = strings.TrimSpace(item)
+			desc := strings.HasPrefix(item, "-")
+			name := strings.TrimLeft(item, "+-")
+			field, ok := fields[name]
+			if !ok || !field.Sort {
+				return nil, fmt.Errorf("%w: unknown sort field %q, allowed: %s", ErrInvalidListQuery, name, fields.allowed(false))
+			}
+			if seen[name] {
+				return nil, fmt.Errorf("%w: duplicate sort field %q", ErrInvalidListQuery, name)
+			}
+			seen[name] = true
+			query.sorts = append(query.sorts, sortField{column: column(field.Column), desc: desc})
+		}
+	}
+	return query, nil
+}
+
+// Where thêm điều kiện lọc vào db, q nil thì giữ nguyên
+func (q *ListQuery) Where(db *gorm.DB) *gorm.DB {
+	if q == nil {
+		return db
+	}
+	for _, expr := range q.filters {
+		db = db.Where(expr)
+	}
+	return db
+}
+
+// Sorted cho biết client có tự chọn thứ tự sắp xếp hay không
+func (q *ListQuery) Sorted() bool {
+	return q != nil && len(q.sorts) > 0
+}
+
+// Order sắp xếp theo sort của client rồi tới fallback (thường là khóa chính) để thứ tự phân trang ổn định
+func (q *ListQuery) Order(db *gorm.DB, fallback string) *gorm.DB {
+	if q != nil {
+		for _, s := range q.sorts {
+			db = db.Order(clause.OrderByColumn{Column: s.column, Desc: s.desc})
+		}
+	}
+	return db.Order(fallback)
+}
+
+// conditions chuyển một cặp field[op]=raw thành điều kiện SQL
+func (f Field) conditions(name, op, raw string) ([]clause.Expression, error) {
+	col := column(f.Column)
+	if f.Type == FieldString {
+		if op != "eq" {
+			return nil, fmt.Errorf("%w: %s only supports equality, use %s=a,b", ErrInvalidListQuery, name, name)
+		}
+		var values []any
+		for _, value := range strings.Split(raw, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+		if len(values) == 0 {
+			return nil, fmt.Errorf("%w: %s must not be empty", ErrInvalidListQuery, name)
+		}
+		if len(values) == 1 {
+			return []clause.Expression{clause.Eq{Column: col, Value: values[0]}}, nil
+		}
+		return []clause.Expression{clause.IN{Column: col, Values: values}}, nil
+	}
+
+	if !rangeOps[op] {
+		return nil, fmt.Errorf("%w: unknown operator %q for %s, allowed: eq, gt, gte, lt, lte", ErrInvalidListQuery, op, name)
+	}
+	value, wholeDay, err := f.parseTime(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s must be %s", ErrInvalidListQuery, name, f.layoutHint())
+	}
+	// Với cột thời gian, một ngày YYYY-MM-DD là cả khoảng [00:00, 24:00)
+	if wholeDay {
+		next := value.AddDate(0, 0, 1)
+		switch op {
+		case "eq":
+			return []clause.Expression{clause.Gte{Column: col, Value: value}, clause.Lt{Column: col, Value: next}}, nil
+		case "gt":
+			return []clause.Expression{clause.Gte{Column: col, Value: next}}, nil
+		case "lte":
+			return []clause.Expression{clause.Lt{Column: col, Value: next}}, nil
+		}
+	}
+	switch op {
+	case "gt":
+		return []clause.Expression{clause.Gt{Column: col, Value: value}}, nil
+	case "gte":
+		return []clause.Expression{clause.Gte{Column: col, Value: value}}, nil
+	case "lt":
+		return []clause.Expression{clause.Lt{Column: col, Value: value}}, nil
+	case "lte":
+		return []clause.Expression{clause.Lte{Column: col, Value: value}}, nil
+	default:
+		return []clause.Expression{clause.Eq{Column: col, Value: value}}, nil
+	}
+}
+
+// parseTime trả về wholeDay=true khi cột thời gian nhận giá trị chỉ có ngày
+func (f Field) parseTime(raw string) (time.Time, bool, error) {
+	if f.Type == FieldDate {
+		value, err := time.Parse(time.DateOnly, raw)
+		return value, false, err
+	}
+	if value, err := time.Parse(time.DateOnly, raw); err == nil {
+		return value, true, nil
+	}
+	value, err := time.Parse(time.RFC3339, raw)
+	return value.UTC(), false, err
+}
+
+func (f Field) layoutHint() string {
+	if f.Type == FieldDate {
+		return "YYYY-MM-DD"
+	}
+	return "RFC3339 or YYYY-MM-DD"
+}
+
+// allowed liệt kê tên trường lọc (filter=true) hoặc sắp xếp được để đưa vào thông báo lỗi
+func (fields ListFields) allowed(filter bool) string {
+	names := make([]string, 0, len(fields))
+	for name, field := range fields {
+		if (filter && field.Filter) || (!filter && field.Sort) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// column tách alias bảng để GORM quote đúng "p"."status"
+func column(name string) clause.Column {
+	if table, col, ok := strings.Cut(name, "."); ok {
+		return clause.Column{Table: table, Name: col}
+	}
+	return clause.Column{Name: name}
+}
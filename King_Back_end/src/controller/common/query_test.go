@@ -0,0 +1,175 @@
+package common
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+var testFields = ListFields{
+	"status":     {Column: "p.status", Type: FieldString, Filter: true, Sort: true},
+	"title":      {Column: "title", Type: FieldString, Sort: true},
+	"year":       {Column: "year_product", Type: FieldDate, Filter: true, Sort: true},
+	"created_at": {Column: "created_at", Type: FieldTime, Filter: true, Sort: true},
+	"factory":    {Column: "factory_id", Type: FieldString, Filter: true},
+}
+
+func date(value string) time.Time {
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestParseListQuery(t *testing.T) {
+	status := clause.Column{Table: "p", Name: "status"}
+	year := clause.Column{Name: "year_product"}
+	created := clause.Column{Name: "created_at"}
+
+	tests := []struct {
+		name        string
+		query       string
+		wantFilters []clause.Expression
+		wantSorts   []sortField
+	}{
+		{
+			name:  "empty",
+			query: "",
+		},
+		{
+			name:  "paging params are ignored",
+			query: "page=2&limit=10&scope=mine&cursor=abc",
+		},
+		{
+			name:        "single value is equality",
+			query:       "status=draft",
+			wantFilters: []clause.Expression{clause.Eq{Column: status, Value: "draft"}},
+		},
+		{
+			name:        "comma list is IN, blanks dropped",
+			query:       "status=draft,+published,",
+			wantFilters: []clause.Expression{clause.IN{Column: status, Values: []any{"draft", "published"}}},
+		},
+		{
+			name:  "filters are applied in key order",
+			query: "status=draft&factory=f1",
+			wantFilters: []clause.Expression{
+				clause.Eq{Column: clause.Column{Name: "factory_id"}, Value: "f1"},
+				clause.Eq{Column: status, Value: "draft"},
+			},
+		},
+		{
+			name:        "date range",
+			query:       "year[gte]=2020-01-01",
+			wantFilters: []clause.Expression{clause.Gte{Column: year, Value: date("2020-01-01")}},
+		},
+		{
+			name:        "date without operator is equality",
+			query:       "year=2020-01-01",
+			wantFilters: []clause.Expression{clause.Eq{Column: year, Value: date("2020-01-01")}},
+		},
+		{
+			name:        "time with RFC3339",
+			query:       "created_at[lt]=2024-06-01T07:00:00%2B07:00",
+			wantFilters: []clause.Expression{clause.Lt{Column: created, Value: date("2024-06-01")}},
+		},
+		{
+			name:  "time equal to a whole day",
+			query: "created_at=2024-06-01",
+			wantFilters: []clause.Expression{
+				clause.Gte{Column: created, Value: date("2024-06-01")},
+				clause.Lt{Column: created, Value: date("2024-06-02")},
+			},
+		},
+		{
+			name:        "time after a whole day starts the next day",
+			query:       "created_at[gt]=2024-06-01",
+			wantFilters: []clause.Expression{clause.Gte{Column: created, Value: date("2024-06-02")}},
+		},
+		{
+			name:        "time up to a whole day includes that day",
+			query:       "created_at[lte]=2024-06-01",
+			wantFilters: []clause.Expression{clause.Lt{Column: created, Value: date("2024-06-02")}},
+		},
+		{
+			name:        "time before a whole day",
+			query:       "created_at[lt]=2024-06-01",
+			wantFilters: []clause.Expression{clause.Lt{Column: created, Value: date("2024-06-01")}},
+		},
+		{
+			name:  "sort with direction",
+			query: "sort=-created_at,+title,year",
+			wantSorts: []sortField{
+				{column: created, desc: true},
+				{column: clause.Column{Name: "title"}},
+				{column: year},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ParseListQuery(values, testFields)
+			if err != nil {
+				t.Fatalf("ParseListQuery() error: %v", err)
+			}
+			if !reflect.DeepEqual(got.filters, tt.wantFilters) {
+				t.Errorf("filters = %#v, want %#v", got.filters, tt.wantFilters)
+			}
+			if !reflect.DeepEqual(got.sorts, tt.wantSorts) {
+				t.Errorf("sorts = %#v, want %#v", got.sorts, tt.wantSorts)
+			}
+			if got.Sorted() != (len(tt.wantSorts) > 0) {
+				t.Errorf("Sorted() = %v", got.Sorted())
+			}
+		})
+	}
+}
+
+func TestParseListQueryErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"unknown field", "owner=me"},
+		{"field not filterable", "title=abc"},
+		{"malformed key", "status[eq=draft"},
+		{"uppercase key", "Status=draft"},
+		{"operator on string field", "status[gt]=draft"},
+		{"empty string value", "status=,"},
+		{"unknown operator", "year[ne]=2020-01-01"},
+		{"bad date", "year=2020-13-01"},
+		{"time on date field", "year=2020-01-01T00:00:00Z"},
+		{"bad time", "created_at=yesterday"},
+		{"unknown sort field", "sort=owner"},
+		{"field not sortable", "sort=factory"},
+		{"duplicate sort field", "sort=title,-title"},
+		{"empty sort item", "sort=title,,year"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ParseListQuery(values, testFields); !errors.Is(err, ErrInvalidListQuery) {
+				t.Errorf("error = %v, want ErrInvalidListQuery", err)
+			}
+		})
+	}
+}
+
+func TestListQueryNil(t *testing.T) {
+	var q *ListQuery
+	if q.Sorted() {
+		t.Error("nil query reports Sorted")
+	}
+}
@@ -0,0 +1,39 @@
+package common
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"thelastking-blogger.com/src/module"
+)
+
+// TrashMessages là comment trả về cho từng loại lỗi khi khôi phục/xóa vĩnh viễn một đối tượng trong thùng rác
+type TrashMessages struct {
+	NotFound      string
+	ParentDeleted string
+	Failed        string
+}
+
+// RespondTrashError trả lỗi của thao tác trên thùng rác với cùng mã HTTP cho mọi loại đối tượng:
+// không tìm thấy là 404, cha vẫn còn trong thùng rác là 409, còn lại là 500
+func RespondTrashError(c *gin.Context, err error, msg TrashMessages) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   err.Error(),
+			"comment": msg.NotFound,
+		})
+	case errors.Is(err, module.ErrParentDeleted):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   err.Error(),
+			"comment": msg.ParentDeleted,
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   err.Error(),
+			"comment": msg.Failed,
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package factory_handler
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
@@ -8,6 +9,7 @@ import (
 	"github.com/go-playground/validator/v10"
 	"gorm.io/gorm"
 	"thelastking-blogger.com/src/controller/common"
+	"thelastking-blogger.com/src/controller/handler/application_handler/scope_handler"
 	"thelastking-blogger.com/src/controller/handler/socket_handler"
 	"thelastking-blogger.com/src/module"
 	"thelastking-blogger.com/src/module/req_users"
@@ -35,9 +37,25 @@ func HandlerCreateFactories(db *gorm.DB, socketServer *socket_handler.SocketServ
 			})
 			return
 		}
+		// Chỉ tạo nhà máy trong khu vực thuộc phạm vi của mình, khu vực theo tên được kiểm tra trong transaction ghi
+		scope, ok := scope_handler.CallerScope(c, db)
+		if !ok {
+			return
+		}
 
 		buss := factory_service.NewFactoryController(factory_repo.NewSql(db))
-		if err := buss.NewCreateFactory(c.Request.Context(), &dataFactory); err != nil {
+		if err := buss.NewCreateFactory(c.Request.Context(), &dataFactory, scope); err != nil {
+			switch {
+			case errors.Is(err, module.ErrOutOfScope):
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "comment": "Khu vực nằm ngoài phạm vi quản lý của bạn"})
+				return
+			case errors.Is(err, module.ErrAmbiguousName):
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "comment": "Có nhiều khu vực trùng tên, hãy đổi tên để phân biệt"})
+				return
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "comment": "Không tìm thấy khu vực"})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":   err.Error(),
 				"comment": "Invalid database location",
@@ -96,6 +114,13 @@ func HandlerUpdFactories(db *gorm.DB, socketServer *socket_handler.SocketServer)
 			})
 			return
 		}
+		if !scope_handler.CheckFactory(c, db, map[string]any{"f.factory_id": idFactory}) {
+			return
+		}
+		// Chuyển sang khu vực khác thì khu vực mới cũng phải thuộc phạm vi
+		if updFactory.Location_ID != "" && !scope_handler.CheckLocation(c, db, map[string]any{"l.location_id": updFactory.Location_ID}) {
+			return
+		}
 		times := time.Now().UTC()
 		updFactory.UpdatedAt = &times
 		buss := factory_service.NewFactoryController(factory_repo.NewSql(db))
@@ -127,6 +152,9 @@ func HandlerDeletedFactory(db *gorm.DB, socketServer *socket_handler.SocketServe
 			})
 			return
 		}
+		if !scope_handler.CheckFactory(c, db, map[string]any{"f.factory_id": idFactory}) {
+			return
+		}
 		buss := factory_service.NewFactoryController(factory_repo.NewSql(db))
 		if err := buss.NewDeleteFactory(c.Request.Context(), idFactory); err != nil {
 			c.JSON(http.StatusNotFound, gin.H{
@@ -156,8 +184,23 @@ func HandlerListFactory(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 		paging.Process()
+		query, err := common.ParseListQuery(c.Request.URL.Query(), factory_repo.ListFields)
+		if err == nil {
+			err = paging.ParseCursor(query)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   err.Error(),
+				"comment": "Tham số lọc, sắp xếp hoặc cursor không hợp lệ",
+			})
+			return
+		}
+		scope, ok := scope_handler.ListScope(c, db)
+		if !ok {
+			return
+		}
 		factoryCtrl := factory_service.NewFactoryController(factory_repo.NewSql(db))
-		dataListFactory, err := factoryCtrl.NewGetFactoryList(c.Request.Context(), &paging)
+		dataListFactory, err := factoryCtrl.NewGetFactoryList(c.Request.Context(), &paging, query, scope)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":   "getList factory database faild",
@@ -165,7 +208,7 @@ func HandlerListFactory(db *gorm.DB) gin.HandlerFunc {
 			})
 			return
 		}
-		c.JSON(http.StatusOK, common.ItemsResponse(dataListFactory))
+		c.JSON(http.StatusOK, common.ListResponse(dataListFactory, paging))
 	}
 }
 
@@ -177,8 +220,23 @@ func HandlerListFactoryByLocation(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing location name"})
 			return
 		}
+		var paging common.Paggings
+		if err := c.ShouldBind(&paging); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "pagging faild",
+			})
+			return
+		}
+		paging.Process()
+		if err := paging.ParseCursor(nil); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   err.Error(),
+				"comment": "Cursor không hợp lệ",
+			})
+			return
+		}
 		facotoryCtrl := factory_service.NewFactoryController(factory_repo.NewSql(db))
-		dataListFactory, err := facotoryCtrl.NewGetFactoryListByLocal(c.Request.Context(), locationName)
+		dataListFactory, err := facotoryCtrl.NewGetFactoryListByLocal(c.Request.Context(), locationName, &paging)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":   "getList factory database faild",
@@ -186,6 +244,88 @@ func HandlerListFactoryByLocation(db *gorm.DB) gin.HandlerFunc {
 			})
 			return
 		}
-		c.JSON(http.StatusOK, common.ItemsResponse(dataListFactory))
+		c.JSON(http.StatusOK, common.ListResponse(dataListFactory, paging))
+	}
+}
+
+// TRASH
+func HandlerListTrashFactory(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var paging common.Paggings
+		if err := c.ShouldBind(&paging); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "pagging faild",
+			})
+			return
+		}
+		paging.Process()
+		if err := paging.ParseCursor(nil); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   err.Error(),
+				"comment": "Cursor không hợp lệ",
+			})
+			return
+		}
+		scope, ok := scope_handler.CallerScope(c, db)
+		if !ok {
+			return
+		}
+		factoryCtrl := factory_service.NewFactoryController(factory_repo.NewSql(db))
+		dataList, err := factoryCtrl.NewListTrashFactory(c.Request.Context(), &paging, scope)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "getList trash factory database faild",
+				"details": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, common.ListResponse(dataList, paging))
 	}
 }
+
+// RESTORE: khôi phục cả sản phẩm bị xóa cùng lúc với nhà máy
+func HandlerRestoreFactory(db *gorm.DB, socketServer *socket_handler.SocketServer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idFactory := c.Param("factory_id")
+		if !scope_handler.CheckFactory(c, db, map[string]any{"f.factory_id": idFactory}) {
+			return
+		}
+		factoryCtrl := factory_service.NewFactoryController(factory_repo.NewSql(db))
+		if err := factoryCtrl.NewRestoreFactory(c.Request.Context(), idFactory); err != nil {
+			common.RespondTrashError(c, err, factoryTrashMessages)
+			return
+		}
+		socketServer.BroadcastMessage(socket_handler.Message{
+			Event: "factory:restored",
+			Data: gin.H{
+				"factory_id": idFactory,
+			},
+		})
+		c.JSON(http.StatusOK, common.ItemsResponse("Restore success!"))
+	}
+}
+
+// PURGE: xóa vĩnh viễn nhà máy đang nằm trong thùng rác
+func HandlerPurgeFactory(db *gorm.DB, socketServer *socket_handler.SocketServer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idFactory := c.Param("factory_id")
+		factoryCtrl := factory_service.NewFactoryController(factory_repo.NewSql(db))
+		if err := factoryCtrl.NewPurgeFactory(c.Request.Context(), idFactory); err != nil {
+			common.RespondTrashError(c, err, factoryTrashMessages)
+			return
+		}
+		socketServer.BroadcastMessage(socket_handler.Message{
+			Event: "factory:purged",
+			Data: gin.H{
+				"factory_id": idFactory,
+			},
+		})
+		c.JSON(http.StatusOK, common.ItemsResponse("Purge success!"))
+	}
+}
+
+var factoryTrashMessages = common.TrashMessages{
+	NotFound:      "Không tìm thấy nhà máy trong thùng rác",
+	ParentDeleted: "Khu vực của nhà máy đang nằm trong thùng rác, cần khôi phục khu vực trước",
+	Failed:        "error data factory",
+}
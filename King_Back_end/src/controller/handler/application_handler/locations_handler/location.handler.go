@@ -8,6 +8,7 @@ import (
 	"github.com/go-playground/validator/v10"
 	"gorm.io/gorm"
 	"thelastking-blogger.com/src/controller/common"
+	"thelastking-blogger.com/src/controller/handler/application_handler/scope_handler"
 	"thelastking-blogger.com/src/controller/handler/socket_handler"
 	"thelastking-blogger.com/src/module"
 	"thelastking-blogger.com/src/repository/location_repo"
@@ -110,6 +111,9 @@ func HandlerUpdLocation(db *gorm.DB, socketServer *socket_handler.SocketServer)
 			})
 			return
 		}
+		if !scope_handler.CheckLocation(c, db, map[string]any{"l.location_id": idLocation}) {
+			return
+		}
 		times := time.Now().UTC()
 		updLoca.UpdatedAt = &times
 		buss := location_service.NewLocationController(location_repo.NewSql(db))
@@ -141,6 +145,9 @@ func HandlerDeletedLocation(db *gorm.DB, socketServer *socket_handler.SocketServ
 			})
 			return
 		}
+		if !scope_handler.CheckLocation(c, db, map[string]any{"l.location_id": idLocation}) {
+			return
+		}
 		buss := location_service.NewLocationController(location_repo.NewSql(db))
 		if err := buss.NewDeleteLocation(c.Request.Context(), idLocation); err != nil {
 			c.JSON(http.StatusNotFound, gin.H{
@@ -169,8 +176,23 @@ func HandlerListLocation(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 		paging.Process()
+		query, err := common.ParseListQuery(c.Request.URL.Query(), location_repo.ListFields)
+		if err == nil {
+			err = paging.ParseCursor(query)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   err.Error(),
+				"comment": "Tham số lọc, sắp xếp hoặc cursor không hợp lệ",
+			})
+			return
+		}
+		scope, ok := scope_handler.ListScope(c, db)
+		if !ok {
+			return
+		}
 		locationCtrl := location_service.NewLocationController(location_repo.NewSql(db))
-		dataListLocations, err := locationCtrl.NewListLocation(c.Request.Context(), &paging)
+		dataListLocations, err := locationCtrl.NewListLocation(c.Request.Context(), &paging, query, scope)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":   "getList location database faild",
@@ -178,6 +200,87 @@ func HandlerListLocation(db *gorm.DB) gin.HandlerFunc {
 			})
 			return
 		}
-		c.JSON(http.StatusOK, common.ItemsResponse(dataListLocations))
+		c.JSON(http.StatusOK, common.ListResponse(dataListLocations, paging))
 	}
 }
+
+// TRASH
+func HandlerListTrashLocation(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var paging common.Paggings
+		if err := c.ShouldBind(&paging); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "pagging faild",
+			})
+			return
+		}
+		paging.Process()
+		if err := paging.ParseCursor(nil); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   err.Error(),
+				"comment": "Cursor không hợp lệ",
+			})
+			return
+		}
+		scope, ok := scope_handler.CallerScope(c, db)
+		if !ok {
+			return
+		}
+		locationCtrl := location_service.NewLocationController(location_repo.NewSql(db))
+		dataListLocations, err := locationCtrl.NewListTrashLocation(c.Request.Context(), &paging, scope)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "getList trash location database faild",
+				"details": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, common.ListResponse(dataListLocations, paging))
+	}
+}
+
+// RESTORE: khôi phục cả nhà máy và sản phẩm bị xóa cùng lúc với khu vực
+func HandlerRestoreLocation(db *gorm.DB, socketServer *socket_handler.SocketServer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idLocation := c.Param("location_id")
+		if !scope_handler.CheckLocation(c, db, map[string]any{"l.location_id": idLocation}) {
+			return
+		}
+		buss := location_service.NewLocationController(location_repo.NewSql(db))
+		if err := buss.NewRestoreLocation(c.Request.Context(), idLocation); err != nil {
+			common.RespondTrashError(c, err, locationTrashMessages)
+			return
+		}
+		socketServer.BroadcastMessage(socket_handler.Message{
+			Event: "location:restored",
+			Data: gin.H{
+				"location_id": idLocation,
+			},
+		})
+		c.JSON(http.StatusOK, common.ItemsResponse("Restore success!"))
+	}
+}
+
+// PURGE: xóa vĩnh viễn khu vực đang nằm trong thùng rác
+func HandlerPurgeLocation(db *gorm.DB, socketServer *socket_handler.SocketServer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idLocation := c.Param("location_id")
+		buss := location_service.NewLocationController(location_repo.NewSql(db))
+		if err := buss.NewPurgeLocation(c.Request.Context(), idLocation); err != nil {
+			common.RespondTrashError(c, err, locationTrashMessages)
+			return
+		}
+		socketServer.BroadcastMessage(socket_handler.Message{
+			Event: "location:purged",
+			Data: gin.H{
+				"location_id": idLocation,
+			},
+		})
+		c.JSON(http.StatusOK, common.ItemsResponse("Purge success!"))
+	}
+}
+
+var locationTrashMessages = common.TrashMessages{
+	NotFound: "Không tìm thấy khu vực trong thùng rác",
+	Failed:   "error data location",
+}
@@ -0,0 +1,233 @@
+package product_handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+	"thelastking-blogger.com/src/controller/common"
+	"thelastking-blogger.com/src/controller/handler/application_handler/scope_handler"
+	"thelastking-blogger.com/src/controller/handler/socket_handler"
+	"thelastking-blogger.com/src/module"
+	"thelastking-blogger.com/src/module/req_users"
+	"thelastking-blogger.com/src/repository/product_repo"
+	"thelastking-blogger.com/src/service/product_service"
+)
+
+// HandlerAddProductMedia: POST /product/:product_id/media (multipart)
+// media_type=image|video, file, caption, alt_text, is_primary; video lớn có thể gửi upload_id thay cho file.
+func HandlerAddProductMedia(db *gorm.DB, socketServer *socket_handler.SocketServer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idProduct := c.Param("product_id")
+		if !scope_handler.CheckProduct(c, db, idProduct) {
+			return
+		}
+		meta := req_users.RequestUpdateProductMedia{
+			Caption: optionalForm(c, "caption"),
+			AltText: optionalForm(c, "alt_text"),
+		}
+		if err := validator.New().Struct(meta); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   err.Error(),
+				"comment": "Can't validator",
+			})
+			return
+		}
+		media := module.ProductMedia{
+			Type:      c.PostForm("media_type"),
+			Caption:   meta.Caption,
+			AltText:   meta.AltText,
+			IsPrimary: c.PostForm("is_primary") == "true",
+		}
+
+		var uploadID string
+		switch media.Type {
+		case module.MediaTypeImage:
+			uploaded, ok := uploadImage(c, "file")
+			if !ok {
+				return
+			}
+			if uploaded != nil {
+				media.StorageKey = uploaded.Key
+				media.ImageVariants = uploaded.Variants
+			}
+		case module.MediaTypeVideo:
+			videoKey, id, ok := receiveVideo(c, db, "file", "upload_id")
+			if !ok {
+				return
+			}
+			if videoKey != nil {
+				media.StorageKey = *videoKey
+			}
+			uploadID = id
+		default:
+			respondMediaError(c, module.ErrInvalidMediaType)
+			return
+		}
+		if media.StorageKey == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "file is required",
+				"comment": "Cần gửi file (hoặc upload_id với video)",
+			})
+			return
+		}
+
+		productCtrl := product_service.NewProductController(product_repo.NewSql(db))
+		gallery, err := productCtrl.NewAddProductMedia(c.Request.Context(), idProduct, &media)
+		if err != nil {
+			respondMediaError(c, err)
+			return
+		}
+		consumeUpload(c, db, uploadID)
+		respondGallery(c, socketServer, idProduct, gallery)
+	}
+}
+
+// HandlerUpdateProductMedia: PATCH /product/:product_id/media/:media_id {caption, alt_text}
+func HandlerUpdateProductMedia(db *gorm.DB, socketServer *socket_handler.SocketServer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idProduct := c.Param("product_id")
+		var req req_users.RequestUpdateProductMedia
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validator.New().Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   err.Error(),
+				"comment": "Can't validator",
+			})
+			return
+		}
+		if !scope_handler.CheckProduct(c, db, idProduct) {
+			return
+		}
+		productCtrl := product_service.NewProductController(product_repo.NewSql(db))
+		gallery, err := productCtrl.NewUpdateProductMedia(c.Request.Context(), idProduct, c.Param("media_id"), &req)
+		if err != nil {
+			respondMediaError(c, err)
+			return
+		}
+		respondGallery(c, socketServer, idProduct, gallery)
+	}
+}
+
+// HandlerReorderProductMedia: PUT /product/:product_id/media/order {media_ids: [...]}, gửi đủ mọi media theo thứ tự mới
+func HandlerReorderProductMedia(db *gorm.DB, socketServer *socket_handler.SocketServer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idProduct := c.Param("product_id")
+		var req req_users.RequestReorderProductMedia
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validator.New().Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   err.Error(),
+				"comment": "Can't validator",
+			})
+			return
+		}
+		if !scope_handler.CheckProduct(c, db, idProduct) {
+			return
+		}
+		productCtrl := product_service.NewProductController(product_repo.NewSql(db))
+		gallery, err := productCtrl.NewReorderProductMedia(c.Request.Context(), idProduct, req.MediaIDs)
+		if err != nil {
+			respondMediaError(c, err)
+			return
+		}
+		respondGallery(c, socketServer, idProduct, gallery)
+	}
+}
+
+// HandlerSetPrimaryProductMedia: POST /product/:product_id/media/:media_id/primary
+func HandlerSetPrimaryProductMedia(db *gorm.DB, socketServer *socket_handler.SocketServer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idProduct := c.Param("product_id")
+		if !scope_handler.CheckProduct(c, db, idProduct) {
+			return
+		}
+		productCtrl := product_service.NewProductController(product_repo.NewSql(db))
+		gallery, err := productCtrl.NewSetPrimaryProductMedia(c.Request.Context(), idProduct, c.Param("media_id"))
+		if err != nil {
+			respondMediaError(c, err)
+			return
+		}
+		respondGallery(c, socketServer, idProduct, gallery)
+	}
+}
+
+// HandlerDeleteProductMedia: DELETE /product/:product_id/media/:media_id
+func HandlerDeleteProductMedia(db *gorm.DB, socketServer *socket_handler.SocketServer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idProduct := c.Param("product_id")
+		if !scope_handler.CheckProduct(c, db, idProduct) {
+			return
+		}
+		productCtrl := product_service.NewProductController(product_repo.NewSql(db))
+		gallery, err := productCtrl.NewDeleteProductMedia(c.Request.Context(), idProduct, c.Param("media_id"))
+		if err != nil {
+			respondMediaError(c, err)
+			return
+		}
+		respondGallery(c, socketServer, idProduct, gallery)
+	}
+}
+
+// respondGallery trả gallery mới và phát product:media_updated kèm ảnh chính để danh sách cập nhật ảnh đại diện
+func respondGallery(c *gin.Context, socketServer *socket_handler.SocketServer, idProduct string, gallery []module.ProductMedia) {
+	gallery = withGalleryURLs(c.Request.Context(), gallery)
+	data := gin.H{
+		"product_id": idProduct,
+		"media":      gallery,
+	}
+	for _, media := range gallery {
+		if media.IsPrimary {
+			data["image"] = media.StorageKey
+			data["image_url"] = media.URL
+			data["image_variants"] = media.VariantURLs
+		}
+	}
+	socketServer.BroadcastMessage(socket_handler.Message{
+		Event: "product:media_updated",
+		Data:  data,
+	})
+	c.JSON(http.StatusOK, common.ItemsResponse(gallery))
+}
+
+func respondMediaError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, module.ErrInvalidMediaType), errors.Is(err, module.ErrPrimaryMediaType), errors.Is(err, module.ErrInvalidMediaOrder):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   err.Error(),
+			"comment": "Dữ liệu gallery không hợp lệ",
+		})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   err.Error(),
+			"comment": "Không tìm thấy sản phẩm hoặc media",
+		})
+	case errors.Is(err, module.ErrPrimaryMediaRequired):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   err.Error(),
+			"comment": "Không thể gỡ ảnh cuối cùng của sản phẩm",
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   err.Error(),
+			"comment": "Can't database product media",
+		})
+	}
+}
+
+// optionalForm trả nil khi form không có trường đó, để phân biệt với chuỗi rỗng
+func optionalForm(c *gin.Context, field string) *string {
+	value, ok := c.GetPostForm(field)
+	if !ok {
+		return nil
+	}
+	return &value
+}
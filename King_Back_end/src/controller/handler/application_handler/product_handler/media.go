@@ -0,0 +1,117 @@
+package product_handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"thelastking-blogger.com/src/media"
+	"thelastking-blogger.com/src/module"
+	"thelastking-blogger.com/src/storage"
+)
+
+var saveErrors = map[string]string{
+	storage.KindImage: "Không thể lưu file ảnh",
+	storage.KindVideo: "Không thể lưu file video",
+}
+
+// uploadMedia lưu nguyên file (video) vào storage và trả về key, nil khi request không gửi trường đó
+func uploadMedia(c *gin.Context, field, kind string) (*string, bool) {
+	fileHeader, err := c.FormFile(field)
+	if err != nil || fileHeader == nil {
+		return nil, true
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": saveErrors[kind]})
+		return nil, false
+	}
+	defer file.Close()
+	obj, err := storage.Save(c.Request.Context(), storage.Get(), file, kind)
+	if err != nil {
+		if errors.Is(err, storage.ErrUnsupportedMedia) {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{
+				"error":   err.Error(),
+				"comment": "Chỉ nhận video MP4, WebM, MOV, OGG",
+			})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": saveErrors[kind]})
+		return nil, false
+	}
+	return &obj.Key, true
+}
+
+// uploadImage đưa ảnh qua pipeline xử lý (kiểm tra, xoay, bỏ metadata, tạo biến thể), nil khi request không gửi trường đó
+func uploadImage(c *gin.Context, field string) (*media.Image, bool) {
+	fileHeader, err := c.FormFile(field)
+	if err != nil || fileHeader == nil {
+		return nil, true
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": saveErrors[storage.KindImage]})
+		return nil, false
+	}
+	defer file.Close()
+	img, err := media.ProcessImage(c.Request.Context(), storage.Get(), file)
+	if err != nil {
+		switch {
+		case errors.Is(err, media.ErrInvalidImage):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   err.Error(),
+				"comment": "File không phải là ảnh hợp lệ",
+			})
+		case errors.Is(err, storage.ErrUnsupportedMedia):
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{
+				"error":   err.Error(),
+				"comment": "Chỉ nhận ảnh JPEG, PNG, GIF, WebP",
+			})
+		case errors.Is(err, media.ErrImageTooLarge):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error":   err.Error(),
+				"comment": "Kích thước ảnh quá lớn",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": saveErrors[storage.KindImage]})
+		}
+		return nil, false
+	}
+	return img, true
+}
+
+// withMediaURLs dựng image_url/video_url từ key đang lưu trong Image/Video
+func withMediaURLs(ctx context.Context, products []module.Products) []module.Products {
+	for i := range products {
+		setMediaURLs(ctx, &products[i])
+	}
+	return products
+}
+
+func setMediaURLs(ctx context.Context, p *module.Products) {
+	p.ImageURL = storage.URLFor(ctx, p.Image)
+	p.VideoURL = storage.URLFor(ctx, p.Video)
+	p.ImageVariantURLs = variantURLs(ctx, p.ImageVariants)
+	withGalleryURLs(ctx, p.Media)
+}
+
+// withGalleryURLs dựng url và image_variants cho từng media của gallery
+func withGalleryURLs(ctx context.Context, items []module.ProductMedia) []module.ProductMedia {
+	for i := range items {
+		items[i].URL = storage.URLFor(ctx, &items[i].StorageKey)
+		items[i].VariantURLs = variantURLs(ctx, items[i].ImageVariants)
+	}
+	return items
+}
+
+func variantURLs(ctx context.Context, variants module.ImageVariants) map[string]string {
+	if len(variants) == 0 {
+		return nil
+	}
+	urls := make(map[string]string, len(variants))
+	for name, key := range variants {
+		urls[name] = storage.URLFor(ctx, &key)
+	}
+	return urls
+}
@@ -1,6 +1,7 @@
 package product_handler
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
@@ -8,10 +9,14 @@ import (
 	"github.com/go-playground/validator/v10"
 	"gorm.io/gorm"
 	"thelastking-blogger.com/src/controller/common"
+	"thelastking-blogger.com/src/controller/handler/application_handler/scope_handler"
 	"thelastking-blogger.com/src/controller/handler/socket_handler"
+	auth "thelastking-blogger.com/src/middleware/auth_Middleware"
+	"thelastking-blogger.com/src/module"
 	"thelastking-blogger.com/src/module/req_users"
 	"thelastking-blogger.com/src/repository/product_repo"
 	"thelastking-blogger.com/src/service/product_service"
+	"thelastking-blogger.com/src/storage"
 )
 
 func HandlerCreateProduct(db *gorm.DB, socketServer *socket_handler.SocketServer) gin.HandlerFunc {
@@ -23,28 +28,22 @@ func HandlerCreateProduct(db *gorm.DB, socketServer *socket_handler.SocketServer
 		describe := c.PostForm("describe_product")
 		nameFactory := c.PostForm("name_factory")
 
+		// Nhà máy theo tên được kiểm tra phạm vi trong transaction ghi, sau bước validate
+		scope, ok := scope_handler.CallerScope(c, db)
+		if !ok {
+			return
+		}
+
 		// Nhận file ảnh
-		var imageUrl *string
-		fileImage, err := c.FormFile("image")
-		if err == nil && fileImage != nil {
-			savePath := "uploads/" + fileImage.Filename
-			if err := c.SaveUploadedFile(fileImage, savePath); err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lưu file ảnh"})
-				return
-			}
-			imageUrl = &savePath
+		uploaded, ok := uploadImage(c, "image")
+		if !ok {
+			return
 		}
 
-		// Nhận file video (nếu có)
-		var videoUrl *string
-		fileVideo, err := c.FormFile("video")
-		if err == nil && fileVideo != nil {
-			savePath := "uploads/" + fileVideo.Filename
-			if err := c.SaveUploadedFile(fileVideo, savePath); err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lưu file video"})
-				return
-			}
-			videoUrl = &savePath
+		// Nhận video (nếu có): file gửi kèm form hoặc video_upload_id
+		videoKey, uploadID, ok := receiveVideo(c, db, "video", "video_upload_id")
+		if !ok {
+			return
 		}
 
 		// Parse year_product
@@ -61,14 +60,18 @@ func HandlerCreateProduct(db *gorm.DB, socketServer *socket_handler.SocketServer
 		// Tạo struct ProductInput
 		inputProduct := req_users.ProductInput{
 			Title:       &title,
-			Image:       imageUrl,
-			Video:       videoUrl,
+			Video:       videoKey,
 			Status:      &status,
 			Year:        year,
 			Describe:    describe,
 			NameFactory: &nameFactory,
 		}
 
+		if uploaded != nil {
+			inputProduct.Image = &uploaded.Key
+			inputProduct.ImageVariants = uploaded.Variants
+		}
+
 		// Validate và lưu vào DB như cũ
 		validate := validator.New()
 		if err := validate.Struct(inputProduct); err != nil {
@@ -80,13 +83,24 @@ func HandlerCreateProduct(db *gorm.DB, socketServer *socket_handler.SocketServer
 		}
 
 		productCtrl := product_service.NewProductController(product_repo.NewSql(db))
-		if err := productCtrl.NewCreateProduct(c.Request.Context(), &inputProduct); err != nil {
+		if err := productCtrl.NewCreateProduct(c.Request.Context(), &inputProduct, scope); err != nil {
+			if errors.Is(err, module.ErrStatusTransitionRequired) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":   err.Error(),
+					"comment": "Sản phẩm mới luôn ở trạng thái draft, đổi trạng thái qua POST /product/:product_id/transition",
+				})
+				return
+			}
+			if respondFactoryError(c, err) {
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":   err.Error(),
 				"comment": "Invalid database product",
 			})
 			return
 		}
+		consumeUpload(c, db, uploadID)
 
 		// GỬI WEBSOCKET realtime như cũ
 		socketServer.BroadcastMessage(socket_handler.Message{
@@ -95,6 +109,9 @@ func HandlerCreateProduct(db *gorm.DB, socketServer *socket_handler.SocketServer
 				"title":            inputProduct.Title,
 				"image":            inputProduct.Image,
 				"video":            inputProduct.Video,
+				"image_url":        storage.URLFor(c.Request.Context(), inputProduct.Image),
+				"video_url":        storage.URLFor(c.Request.Context(), inputProduct.Video),
+				"image_variants":   variantURLs(c.Request.Context(), inputProduct.ImageVariants),
 				"status":           inputProduct.Status,
 				"describe_product": inputProduct.Describe,
 				"year":             inputProduct.Year,
@@ -107,6 +124,26 @@ func HandlerCreateProduct(db *gorm.DB, socketServer *socket_handler.SocketServer
 	}
 }
 
+// respondFactoryError trả lỗi khi không dùng được nhà máy theo name_factory, false nếu err không thuộc loại này
+func respondFactoryError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, module.ErrOutOfScope):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "comment": "Nhà máy nằm ngoài phạm vi quản lý của bạn"})
+	case errors.Is(err, module.ErrAmbiguousName):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "comment": "Có nhiều nhà máy trùng tên, hãy đổi tên để phân biệt"})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "comment": "Không tìm thấy nhà máy"})
+	default:
+		return false
+	}
+	return true
+}
+
+// publishedOnly: khách và người không có quyền sửa sản phẩm chỉ thấy sản phẩm đã xuất bản
+func publishedOnly(c *gin.Context) bool {
+	return !auth.HasPermission(c, module.PermProductWrite)
+}
+
 // GET FACTORY
 func HandlerGetProduct(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -118,7 +155,7 @@ func HandlerGetProduct(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 		productCtrl := product_service.NewProductController(product_repo.NewSql(db))
-		dataProduct, err := productCtrl.NewGetProduct(c.Request.Context(), idProduct)
+		dataProduct, err := productCtrl.NewGetProduct(c.Request.Context(), idProduct, publishedOnly(c))
 		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{
 				"error":   err.Error(),
@@ -126,6 +163,7 @@ func HandlerGetProduct(db *gorm.DB) gin.HandlerFunc {
 			})
 			return
 		}
+		setMediaURLs(c.Request.Context(), dataProduct)
 		c.JSON(http.StatusOK, common.ItemsResponse(dataProduct))
 	}
 }
@@ -140,6 +178,9 @@ func HandlerUpdProduct(db *gorm.DB, socketServer *socket_handler.SocketServer) g
 			})
 			return
 		}
+		if !scope_handler.CheckProduct(c, db, idProduct) {
+			return
+		}
 
 		// Lấy các trường text
 		title := c.PostForm("title")
@@ -148,28 +189,22 @@ func HandlerUpdProduct(db *gorm.DB, socketServer *socket_handler.SocketServer) g
 		describe := c.PostForm("describe_product")
 		nameFactory := c.PostForm("name_factory")
 
+		// Chuyển sang nhà máy khác thì nhà máy mới cũng phải thuộc phạm vi (kiểm tra trong transaction ghi)
+		scope, ok := scope_handler.CallerScope(c, db)
+		if !ok {
+			return
+		}
+
 		// Lấy file ảnh (nếu có)
-		var imageUrl *string
-		fileImage, err := c.FormFile("image")
-		if err == nil && fileImage != nil {
-			savePath := "uploads/" + fileImage.Filename
-			if err := c.SaveUploadedFile(fileImage, savePath); err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lưu file ảnh"})
-				return
-			}
-			imageUrl = &savePath
+		uploaded, ok := uploadImage(c, "image")
+		if !ok {
+			return
 		}
 
-		// Lấy file video (nếu có)
-		var videoUrl *string
-		fileVideo, err := c.FormFile("video")
-		if err == nil && fileVideo != nil {
-			savePath := "uploads/" + fileVideo.Filename
-			if err := c.SaveUploadedFile(fileVideo, savePath); err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lưu file video"})
-				return
-			}
-			videoUrl = &savePath
+		// Lấy video (nếu có): file gửi kèm form hoặc video_upload_id
+		videoKey, uploadID, ok := receiveVideo(c, db, "video", "video_upload_id")
+		if !ok {
+			return
 		}
 
 		// Parse year_product
@@ -192,11 +227,12 @@ func HandlerUpdProduct(db *gorm.DB, socketServer *socket_handler.SocketServer) g
 			NameFactory: &nameFactory,
 			UpdatedAt:   func() *time.Time { t := time.Now().UTC(); return &t }(),
 		}
-		if imageUrl != nil {
-			updProduct.Image = imageUrl
+		if uploaded != nil {
+			updProduct.Image = &uploaded.Key
+			updProduct.ImageVariants = uploaded.Variants
 		}
-		if videoUrl != nil {
-			updProduct.Video = videoUrl
+		if videoKey != nil {
+			updProduct.Video = videoKey
 		}
 
 		// Validate nếu cần
@@ -210,13 +246,24 @@ func HandlerUpdProduct(db *gorm.DB, socketServer *socket_handler.SocketServer) g
 		}
 
 		productCtrl := product_service.NewProductController(product_repo.NewSql(db))
-		if err := productCtrl.NewUpdateProduct(c.Request.Context(), idProduct, &updProduct); err != nil {
+		if err := productCtrl.NewUpdateProduct(c.Request.Context(), idProduct, &updProduct, scope); err != nil {
+			if errors.Is(err, module.ErrStatusTransitionRequired) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":   err.Error(),
+					"comment": "Đổi trạng thái qua POST /product/:product_id/transition",
+				})
+				return
+			}
+			if respondFactoryError(c, err) {
+				return
+			}
 			c.JSON(http.StatusNotFound, gin.H{
 				"error":   err.Error(),
 				"comment": "Can't database update",
 			})
 			return
 		}
+		consumeUpload(c, db, uploadID)
 
 		// Gửi WebSocket như cũ
 		socketServer.BroadcastMessage(socket_handler.Message{
@@ -225,6 +272,9 @@ func HandlerUpdProduct(db *gorm.DB, socketServer *socket_handler.SocketServer) g
 				"title":            updProduct.Title,
 				"image":            updProduct.Image,
 				"video":            updProduct.Video,
+				"image_url":        storage.URLFor(c.Request.Context(), updProduct.Image),
+				"video_url":        storage.URLFor(c.Request.Context(), updProduct.Video),
+				"image_variants":   variantURLs(c.Request.Context(), updProduct.ImageVariants),
 				"status":           updProduct.Status,
 				"year_product":     updProduct.Year,
 				"describe_product": updProduct.Describe,
@@ -246,6 +296,9 @@ func HandlerDeletedProduct(db *gorm.DB, socketServer *socket_handler.SocketServe
 			})
 			return
 		}
+		if !scope_handler.CheckProduct(c, db, idProduct) {
+			return
+		}
 		productCtrl := product_service.NewProductController(product_repo.NewSql(db))
 		if err := productCtrl.NewDeleteProduct(c.Request.Context(), idProduct); err != nil {
 			c.JSON(http.StatusNotFound, gin.H{
@@ -275,8 +328,23 @@ func HandlerListProduct(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 		paging.Process()
+		query, err := common.ParseListQuery(c.Request.URL.Query(), product_repo.ListFields)
+		if err == nil {
+			err = paging.ParseCursor(query)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   err.Error(),
+				"comment": "Tham số lọc, sắp xếp hoặc cursor không hợp lệ",
+			})
+			return
+		}
+		scope, ok := scope_handler.ListScope(c, db)
+		if !ok {
+			return
+		}
 		productCtrl := product_service.NewProductController(product_repo.NewSql(db))
-		dataListProduct, err := productCtrl.NewGetProductsList(c.Request.Context(), &paging)
+		dataListProduct, err := productCtrl.NewGetProductsList(c.Request.Context(), &paging, query, scope, publishedOnly(c))
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":   "getList product database faild",
@@ -284,7 +352,54 @@ func HandlerListProduct(db *gorm.DB) gin.HandlerFunc {
 			})
 			return
 		}
-		c.JSON(http.StatusOK, common.ItemsResponse(dataListProduct))
+		c.JSON(http.StatusOK, common.ListResponse(withMediaURLs(c.Request.Context(), dataListProduct), paging))
+	}
+}
+
+// SEARCH: GET /product/search?q=&page=&limit=, xếp theo độ khớp
+func HandlerSearchProduct(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var query module.ProductSearchQuery
+		if err := c.ShouldBindQuery(&query); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   err.Error(),
+				"comment": "Cần từ khóa q (tối đa 200 ký tự)",
+			})
+			return
+		}
+		var paging common.Paggings
+		if err := c.ShouldBindQuery(&paging); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "pagging faild",
+			})
+			return
+		}
+		paging.Process()
+		// Kết quả xếp theo độ khớp nên không phân trang theo keyset được, chỉ nhận page
+		if err := paging.NoCursor(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   err.Error(),
+				"comment": "Cursor không hợp lệ",
+			})
+			return
+		}
+		scope, ok := scope_handler.ListScope(c, db)
+		if !ok {
+			return
+		}
+		productCtrl := product_service.NewProductController(product_repo.NewSql(db))
+		data, err := productCtrl.NewSearchProducts(c.Request.Context(), query.Q, &paging, scope, publishedOnly(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "search product database faild",
+				"details": err.Error(),
+			})
+			return
+		}
+		for i := range data {
+			setMediaURLs(c.Request.Context(), &data[i].Products)
+		}
+		c.JSON(http.StatusOK, common.ListResponse(data, paging))
 	}
 }
 
@@ -296,8 +411,23 @@ func HandlerListProductByFactory(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing factory name"})
 			return
 		}
+		var paging common.Paggings
+		if err := c.ShouldBind(&paging); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "pagging faild",
+			})
+			return
+		}
+		paging.Process()
+		if err := paging.ParseCursor(nil); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   err.Error(),
+				"comment": "Cursor không hợp lệ",
+			})
+			return
+		}
 		productCtrl := product_service.NewProductController(product_repo.NewSql(db))
-		dataListProduct, err := productCtrl.NewGetProductsByFactories(c.Request.Context(), factoryName)
+		dataListProduct, err := productCtrl.NewGetProductsByFactories(c.Request.Context(), factoryName, &paging, publishedOnly(c))
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":   "getList product database faild",
@@ -305,7 +435,7 @@ func HandlerListProductByFactory(db *gorm.DB) gin.HandlerFunc {
 			})
 			return
 		}
-		c.JSON(http.StatusOK, common.ItemsResponse(dataListProduct))
+		c.JSON(http.StatusOK, common.ListResponse(withMediaURLs(c.Request.Context(), dataListProduct), paging))
 
 	}
 }
@@ -318,8 +448,23 @@ func HandlerListProductByLocation(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing location name"})
 			return
 		}
+		var paging common.Paggings
+		if err := c.ShouldBind(&paging); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "pagging faild",
+			})
+			return
+		}
+		paging.Process()
+		if err := paging.ParseCursor(nil); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   err.Error(),
+				"comment": "Cursor không hợp lệ",
+			})
+			return
+		}
 		productCtrl := product_service.NewProductController(product_repo.NewSql(db))
-		dataListProduct, err := productCtrl.NewGetProductsByLocation(c.Request.Context(), locationName)
+		dataListProduct, err := productCtrl.NewGetProductsByLocation(c.Request.Context(), locationName, &paging, publishedOnly(c))
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":   "getList product database faild",
@@ -327,7 +472,89 @@ func HandlerListProductByLocation(db *gorm.DB) gin.HandlerFunc {
 			})
 			return
 		}
-		c.JSON(http.StatusOK, common.ItemsResponse(dataListProduct))
+		c.JSON(http.StatusOK, common.ListResponse(withMediaURLs(c.Request.Context(), dataListProduct), paging))
 
 	}
 }
+
+// TRASH
+func HandlerListTrashProduct(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var paging common.Paggings
+		if err := c.ShouldBind(&paging); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "pagging faild",
+			})
+			return
+		}
+		paging.Process()
+		if err := paging.ParseCursor(nil); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   err.Error(),
+				"comment": "Cursor không hợp lệ",
+			})
+			return
+		}
+		scope, ok := scope_handler.CallerScope(c, db)
+		if !ok {
+			return
+		}
+		productCtrl := product_service.NewProductController(product_repo.NewSql(db))
+		dataList, err := productCtrl.NewListTrashProduct(c.Request.Context(), &paging, scope)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "getList trash product database faild",
+				"details": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, common.ListResponse(withMediaURLs(c.Request.Context(), dataList), paging))
+	}
+}
+
+// RESTORE: nhà máy của sản phẩm phải còn hoạt động
+func HandlerRestoreProduct(db *gorm.DB, socketServer *socket_handler.SocketServer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idProduct := c.Param("product_id")
+		if !scope_handler.CheckProduct(c, db, idProduct) {
+			return
+		}
+		productCtrl := product_service.NewProductController(product_repo.NewSql(db))
+		if err := productCtrl.NewRestoreProduct(c.Request.Context(), idProduct); err != nil {
+			common.RespondTrashError(c, err, productTrashMessages)
+			return
+		}
+		socketServer.BroadcastMessage(socket_handler.Message{
+			Event: "product:restored",
+			Data: gin.H{
+				"product_id": idProduct,
+			},
+		})
+		c.JSON(http.StatusOK, common.ItemsResponse("Restore success!"))
+	}
+}
+
+// PURGE: xóa vĩnh viễn sản phẩm đang nằm trong thùng rác
+func HandlerPurgeProduct(db *gorm.DB, socketServer *socket_handler.SocketServer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idProduct := c.Param("product_id")
+		productCtrl := product_service.NewProductController(product_repo.NewSql(db))
+		if err := productCtrl.NewPurgeProduct(c.Request.Context(), idProduct); err != nil {
+			common.RespondTrashError(c, err, productTrashMessages)
+			return
+		}
+		socketServer.BroadcastMessage(socket_handler.Message{
+			Event: "product:purged",
+			Data: gin.H{
+				"product_id": idProduct,
+			},
+		})
+		c.JSON(http.StatusOK, common.ItemsResponse("Purge success!"))
+	}
+}
+
+var productTrashMessages = common.TrashMessages{
+	NotFound:      "Không tìm thấy sản phẩm trong thùng rác",
+	ParentDeleted: "Nhà máy của sản phẩm đang nằm trong thùng rác, cần khôi phục nhà máy trước",
+	Failed:        "error data product",
+}
@@ -0,0 +1,154 @@
+package product_handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"thelastking-blogger.com/src/controller/common"
+	"thelastking-blogger.com/src/controller/handler/application_handler/scope_handler"
+	"thelastking-blogger.com/src/controller/handler/socket_handler"
+	"thelastking-blogger.com/src/module"
+	"thelastking-blogger.com/src/repository/product_repo"
+	"thelastking-blogger.com/src/service/product_service"
+	"thelastking-blogger.com/src/storage"
+)
+
+// HandlerListProductRevisions: GET /product/:product_id/revisions?page=&limit=
+func HandlerListProductRevisions(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var paging common.Paggings
+		if err := c.ShouldBindQuery(&paging); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "pagging faild",
+			})
+			return
+		}
+		paging.Process()
+		// Phiên bản xếp theo revision_number, phân trang theo page
+		if err := paging.NoCursor(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   err.Error(),
+				"comment": "Cursor không hợp lệ",
+			})
+			return
+		}
+		if !scope_handler.CheckProduct(c, db, c.Param("product_id")) {
+			return
+		}
+		productCtrl := product_service.NewProductController(product_repo.NewSql(db))
+		data, err := productCtrl.NewListProductRevisions(c.Request.Context(), c.Param("product_id"), &paging)
+		if err != nil {
+			respondRevisionError(c, err)
+			return
+		}
+		for i := range data {
+			data[i].ImageURL = storage.URLFor(c.Request.Context(), data[i].Image)
+			data[i].VideoURL = storage.URLFor(c.Request.Context(), data[i].Video)
+			data[i].ImageVariantURLs = variantURLs(c.Request.Context(), data[i].ImageVariants)
+		}
+		c.JSON(http.StatusOK, common.ListResponse(data, paging))
+	}
+}
+
+// HandlerDiffProductRevisions: GET /product/:product_id/revisions/diff?from=&to=
+func HandlerDiffProductRevisions(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var query module.RevisionDiffQuery
+		if err := c.ShouldBindQuery(&query); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   err.Error(),
+				"comment": "Cần from và to là số phiên bản",
+			})
+			return
+		}
+		if !scope_handler.CheckProduct(c, db, c.Param("product_id")) {
+			return
+		}
+		productCtrl := product_service.NewProductController(product_repo.NewSql(db))
+		diff, err := productCtrl.NewDiffProductRevisions(c.Request.Context(), c.Param("product_id"), query.From, query.To)
+		if err != nil {
+			respondRevisionError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, common.ItemsResponse(diff))
+	}
+}
+
+// HandlerRevertProduct: POST /product/:product_id/revisions/:revision/revert, bản khôi phục được ghi thành phiên bản mới
+func HandlerRevertProduct(db *gorm.DB, socketServer *socket_handler.SocketServer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idProduct := c.Param("product_id")
+		revision, err := strconv.Atoi(c.Param("revision"))
+		if err != nil {
+			respondRevisionError(c, module.ErrInvalidRevision)
+			return
+		}
+		if !scope_handler.CheckProduct(c, db, idProduct) {
+			return
+		}
+		// Phiên bản cũ có thể thuộc nhà máy khác, nhà máy đó cũng phải nằm trong phạm vi
+		scope, ok := scope_handler.CallerScope(c, db)
+		if !ok {
+			return
+		}
+		productCtrl := product_service.NewProductController(product_repo.NewSql(db))
+		product, err := productCtrl.NewRevertProduct(c.Request.Context(), idProduct, revision, scope)
+		if err != nil {
+			respondRevisionError(c, err)
+			return
+		}
+		socketServer.BroadcastMessage(socket_handler.Message{
+			Event: "product:updated",
+			Data: gin.H{
+				"product_id":       product.Product_ID,
+				"title":            product.Title,
+				"image":            product.Image,
+				"video":            product.Video,
+				"image_url":        storage.URLFor(c.Request.Context(), product.Image),
+				"video_url":        storage.URLFor(c.Request.Context(), product.Video),
+				"image_variants":   variantURLs(c.Request.Context(), product.ImageVariants),
+				"status":           product.Status,
+				"year_product":     product.Year,
+				"describe_product": product.Describe,
+				"factory_id":       product.Factory_ID,
+				"updated_at":       product.UpdatedAt,
+				"reverted_from":    revision,
+			},
+		})
+		setMediaURLs(c.Request.Context(), product)
+		c.JSON(http.StatusOK, common.ItemsResponse(product))
+	}
+}
+
+func respondRevisionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, module.ErrInvalidRevision):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   err.Error(),
+			"comment": "Số phiên bản phải là số nguyên dương",
+		})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   err.Error(),
+			"comment": "Không tìm thấy sản phẩm hoặc phiên bản",
+		})
+	case errors.Is(err, module.ErrOutOfScope):
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   err.Error(),
+			"comment": "Nhà máy của phiên bản này nằm ngoài phạm vi quản lý của bạn",
+		})
+	case errors.Is(err, module.ErrParentDeleted):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   err.Error(),
+			"comment": "Nhà máy của phiên bản này đang nằm trong thùng rác",
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   err.Error(),
+			"comment": "Can't database product revision",
+		})
+	}
+}
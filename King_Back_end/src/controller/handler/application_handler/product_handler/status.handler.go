@@ -0,0 +1,123 @@
+package product_handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+	"thelastking-blogger.com/src/controller/common"
+	"thelastking-blogger.com/src/controller/handler/application_handler/scope_handler"
+	"thelastking-blogger.com/src/controller/handler/socket_handler"
+	auth "thelastking-blogger.com/src/middleware/auth_Middleware"
+	"thelastking-blogger.com/src/module"
+	"thelastking-blogger.com/src/module/req_users"
+	"thelastking-blogger.com/src/repository/product_repo"
+	"thelastking-blogger.com/src/service/product_service"
+)
+
+// HandlerTransitionProduct: POST /product/:product_id/transition {to, from?, reason?}
+// Quyền cần có tùy theo bước chuyển (policy.ProductTransition), không chỉ quyền của route.
+func HandlerTransitionProduct(db *gorm.DB, socketServer *socket_handler.SocketServer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idProduct := c.Param("product_id")
+		var req req_users.RequestProductTransition
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validator.New().Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   err.Error(),
+				"comment": "Can't validator",
+			})
+			return
+		}
+		if !scope_handler.CheckProduct(c, db, idProduct) {
+			return
+		}
+		productCtrl := product_service.NewProductController(product_repo.NewSql(db))
+		history, err := productCtrl.NewTransitionProduct(c.Request.Context(), idProduct, &req, func(permission string) bool {
+			return auth.HasPermission(c, permission)
+		})
+		if err != nil {
+			respondStatusError(c, err)
+			return
+		}
+		socketServer.BroadcastMessage(socket_handler.Message{
+			Event: "product:status_changed",
+			Data: gin.H{
+				"product_id":  history.Product_ID,
+				"from_status": history.FromStatus,
+				"to_status":   history.ToStatus,
+				"status":      history.ToStatus,
+				"reason":      history.Reason,
+				"actor_id":    history.ActorID,
+				"changed_at":  history.CreatedAt,
+			},
+		})
+		c.JSON(http.StatusOK, common.ItemsResponse(history))
+	}
+}
+
+// HandlerListProductStatusHistory: GET /product/:product_id/status-history?limit=&cursor=
+func HandlerListProductStatusHistory(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var paging common.Paggings
+		if err := c.ShouldBindQuery(&paging); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "pagging faild",
+			})
+			return
+		}
+		paging.Process()
+		if err := paging.ParseCursor(nil); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   err.Error(),
+				"comment": "Cursor không hợp lệ",
+			})
+			return
+		}
+		if !scope_handler.CheckProduct(c, db, c.Param("product_id")) {
+			return
+		}
+		productCtrl := product_service.NewProductController(product_repo.NewSql(db))
+		data, err := productCtrl.NewListProductStatusHistory(c.Request.Context(), c.Param("product_id"), &paging)
+		if err != nil {
+			respondStatusError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, common.ListResponse(data, paging))
+	}
+}
+
+func respondStatusError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, module.ErrInvalidProductStatus), errors.Is(err, module.ErrTransitionReasonRequired):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   err.Error(),
+			"comment": "Trạng thái hoặc lý do không hợp lệ",
+		})
+	case errors.Is(err, module.ErrTransitionForbidden):
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   err.Error(),
+			"comment": "Không có quyền chuyển sản phẩm sang trạng thái này",
+		})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   err.Error(),
+			"comment": "Không tìm thấy sản phẩm",
+		})
+	case errors.Is(err, module.ErrTransitionNotAllowed), errors.Is(err, module.ErrStatusConflict):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   err.Error(),
+			"comment": "Không thể chuyển từ trạng thái hiện tại sang trạng thái này",
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   err.Error(),
+			"comment": "Can't database product status",
+		})
+	}
+}
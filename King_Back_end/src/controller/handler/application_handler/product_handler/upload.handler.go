@@ -0,0 +1,214 @@
+package product_handler
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+	"thelastking-blogger.com/src/config/logger"
+	"thelastking-blogger.com/src/controller/common"
+	"thelastking-blogger.com/src/module"
+	"thelastking-blogger.com/src/module/req_users"
+	"thelastking-blogger.com/src/repository/upload_repo"
+	"thelastking-blogger.com/src/service/upload_service"
+	"thelastking-blogger.com/src/storage"
+)
+
+// Header của giao thức upload theo từng phần, cùng tên với tus để client dễ viết lại
+const (
+	headerUploadOffset   = "Upload-Offset"
+	headerUploadChecksum = "Upload-Checksum" // "sha256 <base64 của sha256 phần đang gửi>"
+)
+
+// HandlerCreateUpload: POST /product/uploads {file_name, size, checksum}
+// Trả về upload_id và chunk_size, client gửi từng phần bằng PATCH /product/uploads/:upload_id.
+func HandlerCreateUpload(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req req_users.RequestCreateUpload
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validator.New().Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   err.Error(),
+				"comment": "Can't validator",
+			})
+			return
+		}
+		uploadCtrl := upload_service.NewUploadController(upload_repo.NewSql(db))
+		upload, err := uploadCtrl.NewCreateUpload(c.Request.Context(), &req)
+		if err != nil {
+			respondUploadError(c, err)
+			return
+		}
+		c.Header("Location", "/product/uploads/"+upload.UploadID)
+		c.Header(headerUploadOffset, strconv.FormatInt(upload.Offset, 10))
+		c.JSON(http.StatusCreated, common.ItemsResponse(gin.H{
+			"upload":     upload,
+			"chunk_size": uploadCtrl.ChunkSize(),
+		}))
+	}
+}
+
+// HandlerGetUpload: GET /product/uploads/:upload_id, client hỏi offset để gửi tiếp sau khi mất kết nối
+func HandlerGetUpload(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uploadCtrl := upload_service.NewUploadController(upload_repo.NewSql(db))
+		upload, err := uploadCtrl.NewGetUpload(c.Request.Context(), c.Param("upload_id"))
+		if err != nil {
+			respondUploadError(c, err)
+			return
+		}
+		c.Header("Cache-Control", "no-store")
+		c.Header(headerUploadOffset, strconv.FormatInt(upload.Offset, 10))
+		c.JSON(http.StatusOK, common.ItemsResponse(upload))
+	}
+}
+
+// HandlerUploadChunk: PATCH /product/uploads/:upload_id, body là các byte bắt đầu tại header Upload-Offset.
+// Phần cuối cùng hoàn tất upload, status chuyển sang completed và upload_id dùng được khi tạo/sửa sản phẩm.
+func HandlerUploadChunk(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uploadID := c.Param("upload_id")
+		offset, err := strconv.ParseInt(c.GetHeader(headerUploadOffset), 10, 64)
+		if err != nil || offset < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid Upload-Offset header",
+				"comment": "Cần header Upload-Offset là số byte đã gửi",
+			})
+			return
+		}
+		chunkSum, ok := parseUploadChecksum(c.GetHeader(headerUploadChecksum))
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid Upload-Checksum header",
+				"comment": "Upload-Checksum có dạng: sha256 <base64>",
+			})
+			return
+		}
+		uploadCtrl := upload_service.NewUploadController(upload_repo.NewSql(db))
+		upload, err := uploadCtrl.NewAppendChunk(c.Request.Context(), uploadID, offset, chunkSum, c.Request.Body)
+		if err != nil {
+			// Trả kèm offset hiện tại để client gửi tiếp đúng chỗ
+			if errors.Is(err, module.ErrUploadOffsetMismatch) {
+				if current, getErr := uploadCtrl.NewGetUpload(c.Request.Context(), uploadID); getErr == nil {
+					c.Header(headerUploadOffset, strconv.FormatInt(current.Offset, 10))
+				}
+			}
+			respondUploadError(c, err)
+			return
+		}
+		c.Header(headerUploadOffset, strconv.FormatInt(upload.Offset, 10))
+		c.JSON(http.StatusOK, common.ItemsResponse(upload))
+	}
+}
+
+// HandlerCancelUpload: DELETE /product/uploads/:upload_id
+func HandlerCancelUpload(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uploadCtrl := upload_service.NewUploadController(upload_repo.NewSql(db))
+		if err := uploadCtrl.NewCancelUpload(c.Request.Context(), c.Param("upload_id")); err != nil {
+			respondUploadError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, common.ItemsResponse("Đã hủy upload"))
+	}
+}
+
+// receiveVideo nhận video gửi kèm form ở fileField, hoặc upload_id (ở uploadField) của một upload theo từng phần đã hoàn tất.
+// uploadID khác rỗng thì gọi consumeUpload sau khi lưu sản phẩm thành công.
+func receiveVideo(c *gin.Context, db *gorm.DB, fileField, uploadField string) (videoKey *string, uploadID string, ok bool) {
+	uploadID = c.PostForm(uploadField)
+	if uploadID == "" {
+		videoKey, ok = uploadMedia(c, fileField, storage.KindVideo)
+		return videoKey, "", ok
+	}
+	if fileHeader, err := c.FormFile(fileField); err == nil && fileHeader != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   fileField + " and " + uploadField + " are mutually exclusive",
+			"comment": "Chỉ gửi file video hoặc " + uploadField,
+		})
+		return nil, "", false
+	}
+	uploadCtrl := upload_service.NewUploadController(upload_repo.NewSql(db))
+	key, err := uploadCtrl.NewResolveUpload(c.Request.Context(), uploadID)
+	if err != nil {
+		respondUploadError(c, err)
+		return nil, "", false
+	}
+	return &key, uploadID, true
+}
+
+// consumeUpload xóa upload đã gắn vào sản phẩm, lỗi chỉ ghi log vì sản phẩm đã được lưu
+// và job dọn dẹp không xóa file đang được sản phẩm dùng
+func consumeUpload(c *gin.Context, db *gorm.DB, uploadID string) {
+	if uploadID == "" {
+		return
+	}
+	uploadCtrl := upload_service.NewUploadController(upload_repo.NewSql(db))
+	if err := uploadCtrl.NewConsumeUpload(c.Request.Context(), uploadID); err != nil {
+		logger.GetLogger().Warnf("Upload %s attached but not consumed: %v", uploadID, err)
+	}
+}
+
+// parseUploadChecksum trả về nil khi không có header, ok=false khi sai định dạng hoặc không phải sha256
+func parseUploadChecksum(value string) ([]byte, bool) {
+	if value == "" {
+		return nil, true
+	}
+	algorithm, encoded, found := strings.Cut(strings.TrimSpace(value), " ")
+	if !found || !strings.EqualFold(algorithm, "sha256") {
+		return nil, false
+	}
+	sum, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(sum) != 32 {
+		return nil, false
+	}
+	return sum, true
+}
+
+func respondUploadError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, module.ErrUploadNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   err.Error(),
+			"comment": "Không tìm thấy upload hoặc upload đã hết hạn",
+		})
+	case errors.Is(err, module.ErrUploadOffsetMismatch):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   err.Error(),
+			"comment": "Upload-Offset phải bằng số byte server đã nhận (xem header Upload-Offset)",
+		})
+	case errors.Is(err, module.ErrUploadCompleted), errors.Is(err, module.ErrUploadIncomplete):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   err.Error(),
+			"comment": "Trạng thái upload không cho phép thao tác này",
+		})
+	case errors.Is(err, module.ErrUploadChecksumMismatch):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   err.Error(),
+			"comment": "Checksum không khớp, dữ liệu bị hỏng trên đường truyền",
+		})
+	case errors.Is(err, module.ErrUploadTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":   err.Error(),
+			"comment": "Vượt quá kích thước cho phép của file hoặc của một phần",
+		})
+	case errors.Is(err, storage.ErrUnsupportedMedia):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"error":   err.Error(),
+			"comment": "Chỉ nhận video MP4, WebM, MOV, OGG",
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   err.Error(),
+			"comment": "Can't database media upload",
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package scope_handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"thelastking-blogger.com/src/controller/common"
+	"thelastking-blogger.com/src/controller/handler/socket_handler"
+	"thelastking-blogger.com/src/controller/handler/users_handler"
+	"thelastking-blogger.com/src/module"
+	"thelastking-blogger.com/src/repository/scope_repo"
+	"thelastking-blogger.com/src/service/scope_service"
+)
+
+// isServiceAccount: request dùng API key bị giới hạn bởi quyền của key, không theo khu vực/nhà máy.
+// Chỉ người có phạm vi toàn cục mới tạo được key và key mất hiệu lực khi người tạo mất phạm vi đó.
+func isServiceAccount(c *gin.Context) bool {
+	return c.GetString("serviceAccountId") != ""
+}
+
+// actor lấy user_id và role do JwtMiddleware đặt vào context
+func actor(c *gin.Context) (string, string, bool) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		return "", "", false
+	}
+	return userID, c.GetString("role"), true
+}
+
+// CheckLocation dừng request với 403 nếu khu vực nằm ngoài phạm vi của user, trả về true khi được phép
+func CheckLocation(c *gin.Context, db *gorm.DB, location map[string]any) bool {
+	if isServiceAccount(c) {
+		return true
+	}
+	userID, role, ok := actor(c)
+	if !ok {
+		respondScopeError(c, module.ErrOutOfScope)
+		return false
+	}
+	buss := scope_service.NewScopeController(scope_repo.NewSql(db))
+	if err := buss.NewCoversLocation(c.Request.Context(), userID, role, location); err != nil {
+		respondScopeError(c, err)
+		return false
+	}
+	return true
+}
+
+// CheckFactory dừng request với 403 nếu nhà máy nằm ngoài phạm vi của user, trả về true khi được phép
+func CheckFactory(c *gin.Context, db *gorm.DB, factory map[string]any) bool {
+	if isServiceAccount(c) {
+		return true
+	}
+	userID, role, ok := actor(c)
+	if !ok {
+		respondScopeError(c, module.ErrOutOfScope)
+		return false
+	}
+	buss := scope_service.NewScopeController(scope_repo.NewSql(db))
+	if err := buss.NewCoversFactory(c.Request.Context(), userID, role, factory); err != nil {
+		respondScopeError(c, err)
+		return false
+	}
+	return true
+}
+
+// CheckProduct dừng request với 403 nếu sản phẩm thuộc nhà máy ngoài phạm vi của user, trả về true khi được phép
+func CheckProduct(c *gin.Context, db *gorm.DB, productID string) bool {
+	if isServiceAccount(c) {
+		return true
+	}
+	userID, role, ok := actor(c)
+	if !ok {
+		respondScopeError(c, module.ErrOutOfScope)
+		return false
+	}
+	buss := scope_service.NewScopeController(scope_repo.NewSql(db))
+	if err := buss.NewCoversProduct(c.Request.Context(), userID, role, productID); err != nil {
+		respondScopeError(c, err)
+		return false
+	}
+	return true
+}
+
+// ListScope đọc tham số ?scope= của các API danh sách.
+// Trả về nil khi không lọc (không truyền scope hoặc user có phạm vi toàn cục), false khi đã trả lỗi cho client.
+func ListScope(c *gin.Context, db *gorm.DB) (*module.UserScope, bool) {
+	switch c.Query("scope") {
+	case "", "all":
+		return nil, true
+	case "mine":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid scope",
+			"comment": "scope chỉ nhận all hoặc mine",
+		})
+		return nil, false
+	}
+	return CallerScope(c, db)
+}
+
+// CallerScope trả về phạm vi của người gọi, dùng cho ?scope=mine và thùng rác (luôn lọc theo phạm vi).
+// nil khi người gọi có phạm vi toàn cục hoặc là service account.
+func CallerScope(c *gin.Context, db *gorm.DB) (*module.UserScope, bool) {
+	if isServiceAccount(c) {
+		return nil, true
+	}
+	userID, role, ok := actor(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"comment": "Cần access token để lọc theo phạm vi",
+		})
+		return nil, false
+	}
+	buss := scope_service.NewScopeController(scope_repo.NewSql(db))
+	scope, err := buss.NewGetScope(c.Request.Context(), userID, role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   err.Error(),
+			"comment": "Không thể lấy phạm vi quản lý",
+		})
+		return nil, false
+	}
+	if scope.Global {
+		return nil, true
+	}
+	return scope, true
+}
+
+// HandlerMyScope trả về phạm vi quản lý của user hiện tại
+func HandlerMyScope(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, role, ok := actor(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized", "comment": "Missing user ID in token"})
+			return
+		}
+		buss := scope_service.NewScopeController(scope_repo.NewSql(db))
+		scope, err := buss.NewGetScope(c.Request.Context(), userID, role)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   err.Error(),
+				"comment": "Không thể lấy phạm vi quản lý",
+			})
+			return
+		}
+		c.JSON(http.StatusOK, common.ItemsResponse(scope))
+	}
+}
+
+// HandlerUserScope trả về phạm vi quản lý của :user_id
+func HandlerUserScope(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		target, ok := users_handler.ManagedUser(c, db, false)
+		if !ok {
+			return
+		}
+		buss := scope_service.NewScopeController(scope_repo.NewSql(db))
+		scope, err := buss.NewGetScope(c.Request.Context(), target.UserID, roleOf(target))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   err.Error(),
+				"comment": "Không thể lấy phạm vi quản lý",
+			})
+			return
+		}
+		c.JSON(http.StatusOK, common.ItemsResponse(scope))
+	}
+}
+
+// HandlerAssignScope gán khu vực hoặc nhà máy :id cho :user_id. Người gán phải quản lý được đối tượng đó.
+func HandlerAssignScope(db *gorm.DB, socketServer *socket_handler.SocketServer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		target, ok := users_handler.ManagedUser(c, db, false)
+		if !ok {
+			return
+		}
+		scopeType, targetID := c.Param("type"), c.Param("id")
+		if !checkScopeTarget(c, db, scopeType, targetID) {
+			return
+		}
+		buss := scope_service.NewScopeController(scope_repo.NewSql(db))
+		if err := buss.NewAssignScope(c.Request.Context(), target.UserID, scopeType, targetID); err != nil {
+			respondScopeError(c, err)
+			return
+		}
+		broadcastScopeChanged(socketServer, target.UserID, scopeType, targetID, "assigned")
+		c.JSON(http.StatusOK, common.ItemsResponse("Assign success!"))
+	}
+}
+
+// HandlerRemoveScope bỏ khu vực hoặc nhà máy :id khỏi phạm vi của :user_id
+func HandlerRemoveScope(db *gorm.DB, socketServer *socket_handler.SocketServer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		target, ok := users_handler.ManagedUser(c, db, false)
+		if !ok {
+			return
+		}
+		scopeType, targetID := c.Param("type"), c.Param("id")
+		if !checkScopeTarget(c, db, scopeType, targetID) {
+			return
+		}
+		buss := scope_service.NewScopeController(scope_repo.NewSql(db))
+		if err := buss.NewRemoveScope(c.Request.Context(), target.UserID, scopeType, targetID); err != nil {
+			respondScopeError(c, err)
+			return
+		}
+		broadcastScopeChanged(socketServer, target.UserID, scopeType, targetID, "removed")
+		c.JSON(http.StatusOK, common.ItemsResponse("Remove success!"))
+	}
+}
+
+// checkScopeTarget: chỉ được gán hoặc bỏ đối tượng nằm trong phạm vi của chính mình
+func checkScopeTarget(c *gin.Context, db *gorm.DB, scopeType, targetID string) bool {
+	switch scopeType {
+	case module.ScopeLocation:
+		return CheckLocation(c, db, map[string]any{"l.location_id": targetID})
+	case module.ScopeFactory:
+		return CheckFactory(c, db, map[string]any{"f.factory_id": targetID})
+	default:
+		respondScopeError(c, module.ErrInvalidScopeType)
+		return false
+	}
+}
+
+func broadcastScopeChanged(socketServer *socket_handler.SocketServer, userID, scopeType, targetID, action string) {
+	socketServer.BroadcastMessage(socket_handler.Message{
+		Event: "users:scope_changed",
+		Data: gin.H{
+			"user_id":    userID,
+			"scope_type": scopeType,
+			"target_id":  targetID,
+			"action":     action,
+		},
+	})
+}
+
+func roleOf(user *module.Users) string {
+	if user.Role == nil {
+		return ""
+	}
+	return *user.Role
+}
+
+func respondScopeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, module.ErrOutOfScope):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "comment": "Đối tượng nằm ngoài phạm vi quản lý của bạn"})
+	case errors.Is(err, module.ErrInvalidScopeType):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "comment": "Loại phạm vi chỉ nhận location hoặc factory"})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "comment": "Không tìm thấy phạm vi"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "comment": "Kiểm tra phạm vi thất bại"})
+	}
+}
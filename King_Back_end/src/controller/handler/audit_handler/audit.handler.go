@@ -0,0 +1,60 @@
+package audit_handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"thelastking-blogger.com/src/controller/common"
+	"thelastking-blogger.com/src/module"
+	"thelastking-blogger.com/src/repository/audit_repo"
+	"thelastking-blogger.com/src/service/audit_service"
+)
+
+// HandlerListAudit: GET /audit?entity_type=&entity_id=&actor_id=&action=&from=&to=&page=&limit=&cursor=
+// from/to theo RFC3339
+func HandlerListAudit(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var paging common.Paggings
+		if err := c.ShouldBindQuery(&paging); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "pagging faild",
+			})
+			return
+		}
+		paging.Process()
+		if err := paging.ParseCursor(nil); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   err.Error(),
+				"comment": "Cursor không hợp lệ",
+			})
+			return
+		}
+		var filter module.AuditFilter
+		if err := c.ShouldBindQuery(&filter); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   err.Error(),
+				"comment": "Bộ lọc không hợp lệ",
+			})
+			return
+		}
+		buss := audit_service.NewAuditController(audit_repo.NewSql(db))
+		data, err := buss.NewListAuditLogs(c.Request.Context(), &filter, &paging)
+		if err != nil {
+			if errors.Is(err, module.ErrInvalidAuditFilter) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":   err.Error(),
+					"comment": "entity_type, action hoặc khoảng thời gian không hợp lệ",
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   err.Error(),
+				"comment": "Không thể lấy nhật ký thay đổi",
+			})
+			return
+		}
+		c.JSON(http.StatusOK, common.ListResponse(data, paging))
+	}
+}
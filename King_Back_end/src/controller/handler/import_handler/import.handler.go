@@ -0,0 +1,166 @@
+package import_handler
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	importconfig "thelastking-blogger.com/src/config/import_config"
+	"thelastking-blogger.com/src/controller/common"
+	"thelastking-blogger.com/src/controller/handler/application_handler/scope_handler"
+	"thelastking-blogger.com/src/controller/handler/socket_handler"
+	"thelastking-blogger.com/src/importer"
+	"thelastking-blogger.com/src/module"
+	"thelastking-blogger.com/src/repository/import_repo"
+	"thelastking-blogger.com/src/service/import_service"
+	"thelastking-blogger.com/src/storage"
+	"thelastking-blogger.com/src/utils"
+)
+
+// importIDPattern: client tự đặt import_id để lọc sự kiện import:progress của mình trước khi nhận response
+var importIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// progressInterval giới hạn số sự kiện import:progress gửi qua socket
+const progressInterval = 250 * time.Millisecond
+
+// HandlerImport: POST /import?dry_run=&entity=&import_id= (multipart, trường file là .csv hoặc .xlsx)
+// Trả về báo cáo từng dòng; có dòng lỗi thì 422 và không ghi gì, dry_run=true chỉ kiểm tra.
+func HandlerImport(db *gorm.DB, socketServer *socket_handler.SocketServer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// File có thể tạo khu vực mới nên chỉ người có phạm vi toàn cục được nhập.
+		// CallerScope trả nil cho API key: key chỉ do người có phạm vi toàn cục tạo và mất hiệu lực khi người đó mất phạm vi
+		// (service_account_service.NewCreateApiKey, NewAuthenticate) nên key hợp lệ luôn có phạm vi toàn cục.
+		scope, ok := scope_handler.CallerScope(c, db)
+		if !ok {
+			return
+		}
+		if scope != nil {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   module.ErrOutOfScope.Error(),
+				"comment": "Chỉ người quản lý toàn bộ khu vực mới được nhập dữ liệu hàng loạt",
+			})
+			return
+		}
+
+		dryRun := false
+		if value := c.Query("dry_run"); value != "" {
+			var err error
+			if dryRun, err = strconv.ParseBool(value); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":   err.Error(),
+					"comment": "dry_run chỉ nhận true hoặc false",
+				})
+				return
+			}
+		}
+		importID := c.Query("import_id")
+		if importID == "" {
+			var err error
+			if importID, err = utils.GenerateUUID(); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error":   err.Error(),
+					"comment": "uuid fails",
+				})
+				return
+			}
+		} else if !importIDPattern.MatchString(importID) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid import_id",
+				"comment": "import_id gồm chữ, số, - hoặc _, tối đa 64 ký tự",
+			})
+			return
+		}
+
+		cfg := importconfig.Get()
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   err.Error(),
+				"comment": "Thiếu file nhập (trường file)",
+			})
+			return
+		}
+		if fileHeader.Size > cfg.MaxSize {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error":   "import file is too large",
+				"comment": "File nhập vượt quá IMPORT_MAX_SIZE",
+			})
+			return
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   err.Error(),
+				"comment": "Không đọc được file nhập",
+			})
+			return
+		}
+		defer file.Close()
+		data, err := importer.Read(fileHeader.Filename, file, fileHeader.Size, c.Query("entity"), cfg.MaxRows)
+		if errors.Is(err, module.ErrImportTooLarge) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   err.Error(),
+				"comment": "File nhập vượt quá IMPORT_MAX_ROWS dòng",
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   err.Error(),
+				"comment": "File nhập không đúng định dạng hoặc sai tên cột",
+			})
+			return
+		}
+
+		progress := importer.Throttle(progressInterval, func(p module.ImportProgress) {
+			socketServer.BroadcastMessage(socket_handler.Message{
+				Event: "import:progress",
+				Data: gin.H{
+					"import_id": p.ImportID,
+					"phase":     p.Phase,
+					"done":      p.Done,
+					"total":     p.Total,
+				},
+			})
+		})
+		importCtrl := import_service.NewImportController(import_repo.NewSql(db), storage.Get())
+		report, err := importCtrl.NewImport(c.Request.Context(), importID, data, dryRun, progress)
+		if err != nil {
+			switch {
+			case errors.Is(err, module.ErrImportInvalid):
+				c.JSON(http.StatusUnprocessableEntity, gin.H{
+					"error":   err.Error(),
+					"comment": "File có dòng không hợp lệ, chưa có dữ liệu nào được ghi",
+					"data":    report,
+				})
+			case errors.Is(err, module.ErrImportEmpty), errors.Is(err, module.ErrImportTooLarge):
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":   err.Error(),
+					"comment": "File nhập trống hoặc vượt quá IMPORT_MAX_ROWS dòng",
+				})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error":   err.Error(),
+					"comment": "Nhập dữ liệu thất bại, toàn bộ thay đổi đã được hoàn tác",
+				})
+			}
+			return
+		}
+
+		if report.Committed {
+			socketServer.BroadcastMessage(socket_handler.Message{
+				Event: "import:completed",
+				Data: gin.H{
+					"import_id":    report.ImportID,
+					"counts":       report.Counts,
+					"completed_at": time.Now().UTC(),
+				},
+			})
+		}
+		c.JSON(http.StatusOK, common.ItemsResponse(report))
+	}
+}
@@ -0,0 +1,16 @@
+package jwks_handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"thelastking-blogger.com/src/security/jwtkeys"
+)
+
+// HandlerJWKS công bố public key để service khác verify token mà không cần chia sẻ secret
+func HandlerJWKS() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, jwtkeys.JWKS())
+	}
+}
@@ -0,0 +1,64 @@
+package permission_handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"thelastking-blogger.com/src/controller/common"
+	"thelastking-blogger.com/src/module"
+	"thelastking-blogger.com/src/repository/permission_repo"
+	"thelastking-blogger.com/src/service/permission_service"
+)
+
+// HandlerListPermissions trả về bảng phân quyền theo role
+func HandlerListPermissions(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		buss := permission_service.NewPermissionController(permission_repo.NewSql(db))
+		data, err := buss.NewListMatrix(c.Request.Context())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   err.Error(),
+				"comment": "Không thể lấy bảng phân quyền",
+			})
+			return
+		}
+		c.JSON(http.StatusOK, common.ItemsResponse(data))
+	}
+}
+
+// HandlerGrantPermission gán quyền :permission cho :role
+func HandlerGrantPermission(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		buss := permission_service.NewPermissionController(permission_repo.NewSql(db))
+		if err := buss.NewGrantPermission(c.Request.Context(), c.Param("role"), c.Param("permission")); err != nil {
+			respondPermissionError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, common.ItemsResponse("Grant success!"))
+	}
+}
+
+// HandlerRevokePermission bỏ quyền :permission khỏi :role
+func HandlerRevokePermission(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		buss := permission_service.NewPermissionController(permission_repo.NewSql(db))
+		if err := buss.NewRevokePermission(c.Request.Context(), c.Param("role"), c.Param("permission")); err != nil {
+			respondPermissionError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, common.ItemsResponse("Revoke success!"))
+	}
+}
+
+func respondPermissionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, module.ErrInvalidRole):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "comment": "Role không hợp lệ hoặc là ROOT"})
+	case errors.Is(err, module.ErrPermissionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "comment": "Không tìm thấy quyền"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "comment": "Cập nhật phân quyền thất bại"})
+	}
+}
@@ -0,0 +1,163 @@
+package service_account_handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+	"thelastking-blogger.com/src/controller/common"
+	"thelastking-blogger.com/src/module"
+	"thelastking-blogger.com/src/module/req_users"
+	"thelastking-blogger.com/src/repository/service_account_repo"
+	"thelastking-blogger.com/src/service/service_account_service"
+)
+
+// HandlerListServiceAccounts trả về các service account cùng API key (không gồm key gốc)
+func HandlerListServiceAccounts(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		buss := service_account_service.NewServiceAccountController(service_account_repo.NewSql(db))
+		data, err := buss.NewListServiceAccounts(c.Request.Context())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   err.Error(),
+				"comment": "Không thể lấy danh sách service account",
+			})
+			return
+		}
+		c.JSON(http.StatusOK, common.ItemsResponse(data))
+	}
+}
+
+func HandlerCreateServiceAccount(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _, ok := humanActor(c)
+		if !ok {
+			return
+		}
+		var input req_users.RequestCreateServiceAccount
+		if !bindAndValidate(c, &input) {
+			return
+		}
+		buss := service_account_service.NewServiceAccountController(service_account_repo.NewSql(db))
+		data, err := buss.NewCreateServiceAccount(c.Request.Context(), input.Name, input.Description, userID)
+		if err != nil {
+			respondServiceAccountError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, common.ItemsResponse(data))
+	}
+}
+
+func HandlerDeleteServiceAccount(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := humanActor(c); !ok {
+			return
+		}
+		buss := service_account_service.NewServiceAccountController(service_account_repo.NewSql(db))
+		if err := buss.NewDeleteServiceAccount(c.Request.Context(), c.Param("id")); err != nil {
+			respondServiceAccountError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, common.ItemsResponse("Delete success!"))
+	}
+}
+
+// HandlerCreateApiKey tạo API key cho service account :id. Key gốc chỉ được trả về trong response này.
+func HandlerCreateApiKey(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, role, ok := humanActor(c)
+		if !ok {
+			return
+		}
+		var input req_users.RequestCreateApiKey
+		if !bindAndValidate(c, &input) {
+			return
+		}
+		if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "expires_at must be in the future",
+				"comment": "Thời điểm hết hạn phải ở tương lai",
+			})
+			return
+		}
+		buss := service_account_service.NewServiceAccountController(service_account_repo.NewSql(db))
+		plain, key, err := buss.NewCreateApiKey(c.Request.Context(), c.Param("id"), input.Name, input.Permissions, input.ExpiresAt, userID, role)
+		if err != nil {
+			respondServiceAccountError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, common.ItemsResponse(gin.H{
+			"api_key": plain,
+			"key":     key,
+			"comment": "Lưu lại api_key ngay, key sẽ không được hiển thị lại",
+		}))
+	}
+}
+
+func HandlerRevokeApiKey(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := humanActor(c); !ok {
+			return
+		}
+		buss := service_account_service.NewServiceAccountController(service_account_repo.NewSql(db))
+		if err := buss.NewRevokeApiKey(c.Request.Context(), c.Param("id"), c.Param("key_id")); err != nil {
+			respondServiceAccountError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, common.ItemsResponse("Revoke success!"))
+	}
+}
+
+// humanActor: service account và API key chỉ được quản lý bởi người dùng đăng nhập bằng access token,
+// một API key không thể tự tạo thêm key
+func humanActor(c *gin.Context) (string, string, bool) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Forbidden",
+			"comment": "Chỉ tài khoản người dùng mới được quản lý service account",
+		})
+		return "", "", false
+	}
+	return userID, c.GetString("role"), true
+}
+
+func bindAndValidate(c *gin.Context, input any) bool {
+	if err := c.ShouldBindJSON(input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Missing or invalid request body",
+			"message": err.Error(),
+		})
+		return false
+	}
+	if err := validator.New().Struct(input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   err.Error(),
+			"comment": "Không thể xác thực",
+		})
+		return false
+	}
+	return true
+}
+
+func respondServiceAccountError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, module.ErrServiceAccountNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "comment": "Không tìm thấy service account"})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "comment": "Không tìm thấy API key hoặc key đã bị thu hồi"})
+	case errors.Is(err, module.ErrServiceAccountNameTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "comment": "Tên service account đã tồn tại"})
+	case errors.Is(err, module.ErrApiKeyPermissionForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "comment": "Không thể cấp quyền mà bạn không có"})
+	case errors.Is(err, module.ErrApiKeyScopeForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "comment": "Chỉ người quản lý toàn bộ khu vực mới được tạo API key"})
+	case errors.Is(err, module.ErrPermissionNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "comment": "Quyền không tồn tại"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "comment": "Thao tác với service account thất bại"})
+	}
+}
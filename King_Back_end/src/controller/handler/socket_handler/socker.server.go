@@ -9,7 +9,9 @@ import (
 
 	"github.com/gorilla/websocket"
 	"gorm.io/gorm"
+	"thelastking-blogger.com/src/repository/access_revocation_repo"
 	"thelastking-blogger.com/src/security"
+	"thelastking-blogger.com/src/service/access_revocation_service"
 )
 
 // Message đại diện cho một sự kiện WebSocket
@@ -179,6 +181,11 @@ func (ss *SocketServer) handleWebSocket(namespace string) http.HandlerFunc {
 			conn.Close()
 			return
 		}
+		if access_revocation_service.GetRevocationController(access_revocation_repo.NewSql(ss.db)).IsRevoked(claims) {
+			log.Printf("[%s] Token revoked for user %s", namespace, claims.UserID)
+			conn.Close()
+			return
+		}
 
 		// Tạo client
 		client := &Client{
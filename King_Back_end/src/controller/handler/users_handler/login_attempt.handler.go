@@ -0,0 +1,172 @@
+package users_handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"thelastking-blogger.com/src/config/logger"
+	"thelastking-blogger.com/src/controller/common"
+	"thelastking-blogger.com/src/controller/handler/socket_handler"
+	"thelastking-blogger.com/src/module"
+	"thelastking-blogger.com/src/module/req_users"
+	"thelastking-blogger.com/src/repository/login_attempt_repo"
+	"thelastking-blogger.com/src/repository/users_repo"
+	"thelastking-blogger.com/src/service/login_attempt_service"
+	"thelastking-blogger.com/src/service/users_service"
+)
+
+// checkSignInLock trả về 429 kèm Retry-After nếu account hoặc IP đang bị khóa
+func checkSignInLock(c *gin.Context, db *gorm.DB, account string) bool {
+	until, err := signInLockedUntil(c, db, account)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   err.Error(),
+			"comment": "Không thể kiểm tra trạng thái khóa đăng nhập",
+		})
+		return false
+	}
+	if until == nil {
+		return true
+	}
+	retryAfter := int(time.Until(*until).Seconds()) + 1
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       "too many failed sign-in attempts",
+		"retry_after": retryAfter,
+		"comment":     "Đăng nhập sai quá nhiều lần, vui lòng thử lại sau",
+	})
+	return false
+}
+
+// signInLockedUntil trả về thời điểm hết khóa của account hoặc IP của request, nil nếu không bị khóa
+func signInLockedUntil(c *gin.Context, db *gorm.DB, account string) (*time.Time, error) {
+	attempts := login_attempt_service.NewLoginAttemptController(login_attempt_repo.NewSql(db))
+	return attempts.NewLockedUntil(c.Request.Context(), account, c.ClientIP())
+}
+
+// recordSignInFailure tăng bộ đếm và phát users:locked khi account hoặc IP vừa bị khóa
+func recordSignInFailure(c *gin.Context, db *gorm.DB, socketServer *socket_handler.SocketServer, account string, userID *string) {
+	attempts := login_attempt_service.NewLoginAttemptController(login_attempt_repo.NewSql(db))
+	locked, err := attempts.NewRecordFailure(c.Request.Context(), account, c.ClientIP(), userID)
+	if err != nil {
+		logger.GetLogger().Errorf("Không thể ghi nhận đăng nhập sai: %v", err)
+		return
+	}
+	for _, attempt := range locked {
+		socketServer.BroadcastMessage(socket_handler.Message{
+			Event: "users:locked",
+			Data: gin.H{
+				"key":          attempt.AttemptKey,
+				"kind":         attempt.Kind,
+				"user_id":      attempt.UserID,
+				"failures":     attempt.Failures,
+				"locked_until": attempt.LockedUntil,
+			},
+		})
+	}
+}
+
+// recordSignInSuccess xóa bộ đếm của account sau khi đăng nhập hoàn tất (kể cả bước 2FA)
+func recordSignInSuccess(c *gin.Context, db *gorm.DB, account string) {
+	attempts := login_attempt_service.NewLoginAttemptController(login_attempt_repo.NewSql(db))
+	if err := attempts.NewRecordSuccess(c.Request.Context(), account); err != nil {
+		logger.GetLogger().Errorf("Không thể xóa bộ đếm đăng nhập sai: %v", err)
+	}
+}
+
+// HandlerListLocked liệt kê account và IP đang bị khóa đăng nhập
+func HandlerListLocked(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		attempts := login_attempt_service.NewLoginAttemptController(login_attempt_repo.NewSql(db))
+		data, err := attempts.NewListLocked(c.Request.Context())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   err.Error(),
+				"comment": "Không thể lấy danh sách tài khoản bị khóa",
+			})
+			return
+		}
+		c.JSON(http.StatusOK, common.ItemsResponse(data))
+	}
+}
+
+// HandlerUnlock mở khóa đăng nhập cho một account hoặc IP, người gọi phải quản lý được tài khoản bị khóa
+func HandlerUnlock(db *gorm.DB, socketServer *socket_handler.SocketServer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input req_users.RequestUnlock
+		if !bindAndValidate(c, &input) {
+			return
+		}
+		actor, ok := currentUser(c, db)
+		if !ok {
+			return
+		}
+		allowed, err := canUnlock(c, db, actor, input.Account)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   err.Error(),
+				"comment": "Không thể kiểm tra quyền mở khóa",
+			})
+			return
+		}
+		if !allowed {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   "Forbidden",
+				"comment": "Bạn không có quyền mở khóa đăng nhập này",
+			})
+			return
+		}
+		key := login_attempt_service.IPKey(input.IP)
+		if input.Account != "" {
+			key = login_attempt_service.AccountKey(input.Account)
+		}
+
+		attempts := login_attempt_service.NewLoginAttemptController(login_attempt_repo.NewSql(db))
+		found, err := attempts.NewUnlock(c.Request.Context(), key)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   err.Error(),
+				"comment": "Mở khóa thất bại",
+			})
+			return
+		}
+		if !found {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "not locked",
+				"comment": "Không có bộ đếm đăng nhập sai cho " + key,
+			})
+			return
+		}
+		socketServer.BroadcastMessage(socket_handler.Message{
+			Event: "users:unlocked",
+			Data: gin.H{
+				"key": key,
+			},
+		})
+		c.JSON(http.StatusOK, common.ItemsResponse("Unlock success!"))
+	}
+}
+
+// canUnlock: account chỉ được mở khóa bởi người quản lý được tài khoản đó theo thứ bậc role.
+// Khóa theo IP hoặc account không thuộc tài khoản nào không gắn với ai để so role nên chỉ ROOT được mở.
+func canUnlock(c *gin.Context, db *gorm.DB, actor *module.Users, account string) (bool, error) {
+	if account != "" {
+		buss := users_service.NewUserController(users_repo.NewSql(db))
+		target, err := buss.NewGetUserByAccount(c.Request.Context(), account)
+		if err == nil {
+			return canManageUser(actor, target), nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, err
+		}
+	}
+	if actor.Role == nil {
+		return false, nil
+	}
+	role, ok := module.ParseRoles(*actor.Role)
+	return ok && role == module.ROOT, nil
+}
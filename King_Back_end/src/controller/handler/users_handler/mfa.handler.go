@@ -0,0 +1,344 @@
+package users_handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+	"thelastking-blogger.com/src/config/logger"
+	mfaconfig "thelastking-blogger.com/src/config/mfa_config"
+	"thelastking-blogger.com/src/controller/common"
+	"thelastking-blogger.com/src/controller/handler/socket_handler"
+	"thelastking-blogger.com/src/module"
+	"thelastking-blogger.com/src/module/req_users"
+	"thelastking-blogger.com/src/repository/access_revocation_repo"
+	"thelastking-blogger.com/src/repository/mfa_repo"
+	"thelastking-blogger.com/src/repository/users_repo"
+	"thelastking-blogger.com/src/security"
+	"thelastking-blogger.com/src/service/access_revocation_service"
+	"thelastking-blogger.com/src/service/mfa_service"
+	"thelastking-blogger.com/src/service/users_service"
+	"thelastking-blogger.com/src/utils"
+	"thelastking-blogger.com/src/validators"
+)
+
+// respondMfaPending trả về mfa_token thay cho access token.
+// mfa_required: nhập mã để đăng nhập; mfa_enrollment_required: role bắt buộc 2FA nhưng user chưa đăng ký.
+func respondMfaPending(c *gin.Context, dataUser *module.Users, enrolled bool) {
+	ttl := mfaconfig.Get().PendingTTL
+	mfaToken, err := utils.GenerateMfaPendingToken(dataUser, ttl)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   err.Error(),
+			"comment": "Không thể tạo mfa token",
+		})
+		return
+	}
+	response := gin.H{
+		"mfa_token":  mfaToken,
+		"expires_in": int(ttl.Seconds()),
+	}
+	if enrolled {
+		response["mfa_required"] = true
+		response["comment"] = "Nhập mã xác thực hai bước"
+	} else {
+		response["mfa_enrollment_required"] = true
+		response["comment"] = "Tài khoản bắt buộc bật xác thực hai bước"
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// HandlerSignInMfa hoàn tất đăng nhập bằng mã TOTP hoặc mã khôi phục
+func HandlerSignInMfa(db *gorm.DB, socketServer *socket_handler.SocketServer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input req_users.RequestSignInMfa
+		if !bindAndValidate(c, &input) {
+			return
+		}
+		dataUser, claims, ok := userFromMfaToken(c, db, input.MfaToken)
+		if !ok || !checkSignInLock(c, db, dataUser.Account) {
+			return
+		}
+
+		mfaService := mfa_service.NewMfaController(mfa_repo.NewSql(db))
+		if err := mfaService.NewVerify(c.Request.Context(), dataUser.UserID, input.Code, input.RecoveryCode); err != nil {
+			if errors.Is(err, module.ErrMfaInvalidCode) {
+				recordSignInFailure(c, db, socketServer, dataUser.Account, &dataUser.UserID)
+			}
+			respondMfaError(c, err)
+			return
+		}
+		if !consumeMfaToken(c, db, claims) {
+			return
+		}
+		recordSignInSuccess(c, db, dataUser.Account)
+		issueSignInTokens(c, db, dataUser, nil)
+	}
+}
+
+// HandlerSignInMfaEnroll bắt đầu đăng ký 2FA cho tài khoản bắt buộc 2FA nhưng chưa đăng ký
+func HandlerSignInMfaEnroll(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input req_users.RequestMfaEnroll
+		if !bindAndValidate(c, &input) {
+			return
+		}
+		dataUser, _, ok := userFromMfaToken(c, db, input.MfaToken)
+		if !ok {
+			return
+		}
+		startMfaEnrollment(c, db, dataUser)
+	}
+}
+
+// HandlerSignInMfaVerify xác nhận mã đầu tiên, bật 2FA và cấp token đăng nhập kèm mã khôi phục
+func HandlerSignInMfaVerify(db *gorm.DB, socketServer *socket_handler.SocketServer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input req_users.RequestMfaEnrollVerify
+		if !bindAndValidate(c, &input) {
+			return
+		}
+		dataUser, claims, ok := userFromMfaToken(c, db, input.MfaToken)
+		if !ok || !checkSignInLock(c, db, dataUser.Account) {
+			return
+		}
+
+		mfaService := mfa_service.NewMfaController(mfa_repo.NewSql(db))
+		codes, err := mfaService.NewConfirmEnrollment(c.Request.Context(), dataUser.UserID, input.Code)
+		if err != nil {
+			if errors.Is(err, module.ErrMfaInvalidCode) {
+				recordSignInFailure(c, db, socketServer, dataUser.Account, &dataUser.UserID)
+			}
+			respondMfaError(c, err)
+			return
+		}
+		if !consumeMfaToken(c, db, claims) {
+			return
+		}
+		recordSignInSuccess(c, db, dataUser.Account)
+		issueSignInTokens(c, db, dataUser, gin.H{"recovery_codes": codes})
+	}
+}
+
+// HandlerMfaStatus
+func HandlerMfaStatus(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		dataUser, ok := currentUser(c, db)
+		if !ok {
+			return
+		}
+		mfaService := mfa_service.NewMfaController(mfa_repo.NewSql(db))
+		status, err := mfaService.NewStatus(c.Request.Context(), dataUser)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   err.Error(),
+				"comment": "Không thể lấy trạng thái xác thực hai bước",
+			})
+			return
+		}
+		c.JSON(http.StatusOK, common.ItemsResponse(status))
+	}
+}
+
+// HandlerMfaEnroll tạo secret mới cho user đang đăng nhập
+func HandlerMfaEnroll(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		dataUser, ok := currentUser(c, db)
+		if !ok {
+			return
+		}
+		startMfaEnrollment(c, db, dataUser)
+	}
+}
+
+// HandlerMfaVerify bật 2FA khi mã đầu tiên đúng, trả về mã khôi phục (chỉ hiển thị một lần)
+func HandlerMfaVerify(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input req_users.RequestMfaVerify
+		if !bindAndValidate(c, &input) {
+			return
+		}
+		dataUser, ok := currentUser(c, db)
+		if !ok {
+			return
+		}
+		mfaService := mfa_service.NewMfaController(mfa_repo.NewSql(db))
+		codes, err := mfaService.NewConfirmEnrollment(c.Request.Context(), dataUser.UserID, input.Code)
+		if err != nil {
+			respondMfaError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, common.ItemsResponse(gin.H{
+			"recovery_codes": codes,
+			"message":        "Đã bật xác thực hai bước",
+		}))
+	}
+}
+
+// HandlerMfaDisable tắt 2FA sau khi xác nhận lại bằng mã TOTP hoặc mã khôi phục
+func HandlerMfaDisable(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input req_users.RequestMfaCode
+		if !bindAndValidate(c, &input) {
+			return
+		}
+		dataUser, ok := currentUser(c, db)
+		if !ok {
+			return
+		}
+		mfaService := mfa_service.NewMfaController(mfa_repo.NewSql(db))
+		if mfaService.NewIsRequired(dataUser.Role) {
+			respondMfaError(c, module.ErrMfaRequired)
+			return
+		}
+		if err := mfaService.NewVerify(c.Request.Context(), dataUser.UserID, input.Code, input.RecoveryCode); err != nil {
+			respondMfaError(c, err)
+			return
+		}
+		if err := mfaService.NewDisable(c.Request.Context(), dataUser); err != nil {
+			respondMfaError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, common.ItemsResponse("Đã tắt xác thực hai bước"))
+	}
+}
+
+// HandlerMfaRecoveryCodes tạo lại bộ mã khôi phục, mã cũ không dùng được nữa
+func HandlerMfaRecoveryCodes(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input req_users.RequestMfaCode
+		if !bindAndValidate(c, &input) {
+			return
+		}
+		dataUser, ok := currentUser(c, db)
+		if !ok {
+			return
+		}
+		mfaService := mfa_service.NewMfaController(mfa_repo.NewSql(db))
+		if err := mfaService.NewVerify(c.Request.Context(), dataUser.UserID, input.Code, input.RecoveryCode); err != nil {
+			respondMfaError(c, err)
+			return
+		}
+		codes, err := mfaService.NewRegenerateRecoveryCodes(c.Request.Context(), dataUser.UserID)
+		if err != nil {
+			respondMfaError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, common.ItemsResponse(gin.H{
+			"recovery_codes": codes,
+		}))
+	}
+}
+
+func startMfaEnrollment(c *gin.Context, db *gorm.DB, dataUser *module.Users) {
+	mfaService := mfa_service.NewMfaController(mfa_repo.NewSql(db))
+	secret, uri, err := mfaService.NewStartEnrollment(c.Request.Context(), dataUser)
+	if err != nil {
+		respondMfaError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, common.ItemsResponse(gin.H{
+		"secret":           secret,
+		"provisioning_uri": uri,
+		"comment":          "Quét mã QR từ provisioning_uri rồi gửi mã 6 số để xác nhận",
+	}))
+}
+
+// userFromMfaToken kiểm tra mfa_token và nạp lại user từ database để dùng role hiện tại
+func userFromMfaToken(c *gin.Context, db *gorm.DB, mfaToken string) (*module.Users, *module.Token, bool) {
+	claims, err := security.ValidateMfaPendingToken(mfaToken)
+	if err == nil && access_revocation_service.GetRevocationController(access_revocation_repo.NewSql(db)).IsRevoked(claims) {
+		err = errors.New("mfa token has been used")
+	}
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   err.Error(),
+			"comment": "Vui lòng đăng nhập lại",
+		})
+		return nil, nil, false
+	}
+	buss := users_service.NewUserController(users_repo.NewSql(db))
+	dataUser, err := buss.NewProfileUsers(c.Request.Context(), claims.UserID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   err.Error(),
+			"comment": "Không tìm thấy tài khoản",
+		})
+		return nil, nil, false
+	}
+	return dataUser, claims, true
+}
+
+// consumeMfaToken thu hồi mfa_token sau khi dùng để không đổi lấy token đăng nhập lần thứ hai
+func consumeMfaToken(c *gin.Context, db *gorm.DB, claims *module.Token) bool {
+	revocations := access_revocation_service.GetRevocationController(access_revocation_repo.NewSql(db))
+	if err := revocations.NewRevokeToken(c.Request.Context(), claims, access_revocation_service.ReasonMfaCompleted); err != nil {
+		logger.GetLogger().Errorf("Không thể thu hồi mfa token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   err.Error(),
+			"comment": "Không thể hoàn tất đăng nhập",
+		})
+		return false
+	}
+	return true
+}
+
+// currentUser nạp user của access token hiện tại
+func currentUser(c *gin.Context, db *gorm.DB) (*module.Users, bool) {
+	userID, exists := c.Get("userId")
+	claims, ok := userID.(string)
+	if !exists || !ok || claims == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"comment": "Missing user ID in token",
+		})
+		return nil, false
+	}
+	buss := users_service.NewUserController(users_repo.NewSql(db))
+	dataUser, err := buss.NewProfileUsers(c.Request.Context(), claims)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   err.Error(),
+			"comment": "Không thể lấy thông tin người dùng hiện tại",
+		})
+		return nil, false
+	}
+	return dataUser, true
+}
+
+func bindAndValidate(c *gin.Context, input any) bool {
+	if err := c.ShouldBindJSON(input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Missing or invalid request body",
+			"message": err.Error(),
+		})
+		return false
+	}
+	validate := validator.New()
+	validators.RegisterCustomValidations(validate)
+	if err := validate.Struct(input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   err.Error(),
+			"comment": "Không thể xác thực",
+		})
+		return false
+	}
+	return true
+}
+
+func respondMfaError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, module.ErrMfaInvalidCode):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error(), "comment": "Mã xác thực không đúng"})
+	case errors.Is(err, module.ErrMfaNotEnabled):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "comment": "Tài khoản chưa bật xác thực hai bước"})
+	case errors.Is(err, module.ErrMfaAlreadyEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "comment": "Tài khoản đã bật xác thực hai bước"})
+	case errors.Is(err, module.ErrMfaRequired):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "comment": "Vai trò của bạn bắt buộc xác thực hai bước"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "comment": "Xác thực hai bước thất bại"})
+	}
+}
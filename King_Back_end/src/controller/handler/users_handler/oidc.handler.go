@@ -0,0 +1,209 @@
+package users_handler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	mfaconfig "thelastking-blogger.com/src/config/mfa_config"
+	oidcconfig "thelastking-blogger.com/src/config/oidc_config"
+	"thelastking-blogger.com/src/controller/handler/socket_handler"
+	"thelastking-blogger.com/src/module"
+	"thelastking-blogger.com/src/repository/mfa_repo"
+	"thelastking-blogger.com/src/repository/oidc_repo"
+	"thelastking-blogger.com/src/security/oidc"
+	"thelastking-blogger.com/src/service/access_revocation_service"
+	"thelastking-blogger.com/src/service/mfa_service"
+	"thelastking-blogger.com/src/service/oidc_service"
+	"thelastking-blogger.com/src/utils"
+)
+
+// HandlerOidcLogin chuyển trình duyệt sang trang đăng nhập của IdP (authorization code + PKCE)
+func HandlerOidcLogin(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		buss := oidc_service.NewOidcController(oidc_repo.NewSql(db))
+		authURL, state, err := buss.NewStartLogin(c.Request.Context())
+		if err != nil {
+			respondOidcError(c, err)
+			return
+		}
+		utils.SetOidcStateCookie(c, state, oidcconfig.Get().StateTTL)
+		c.Redirect(http.StatusFound, authURL)
+	}
+}
+
+// HandlerOidcCallback nhận code từ IdP, liên kết hoặc tạo tài khoản rồi đưa trình duyệt về SPA.
+// Đăng nhập xong thì refresh token nằm trong cookie và SPA lấy access token qua /refresh-token,
+// lỗi được gửi qua ?sso_error= của trang đăng nhập, bước 2FA nhận mfa_token qua fragment của URL đó.
+func HandlerOidcCallback(db *gorm.DB, socketServer *socket_handler.SocketServer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if idpErr := c.Query("error"); idpErr != "" {
+			log.Printf("IdP từ chối đăng nhập: %s %s", idpErr, c.Query("error_description"))
+			redirectOidcError(c, idpErr)
+			return
+		}
+		code, state := c.Query("code"), c.Query("state")
+		cookieState, err := c.Cookie("oidc_state")
+		utils.ClearOidcStateCookie(c)
+		// State phải khớp cookie của chính trình duyệt này, chặn việc đưa callback của người khác vào phiên mình
+		if code == "" || state == "" || err != nil || subtle.ConstantTimeCompare([]byte(state), []byte(cookieState)) != 1 {
+			redirectOidcError(c, oidcErrorCode(module.ErrOidcStateInvalid))
+			return
+		}
+
+		buss := oidc_service.NewOidcController(oidc_repo.NewSql(db))
+		result, err := buss.NewCompleteLogin(c.Request.Context(), code, state)
+		if err != nil {
+			log.Printf("Đăng nhập SSO thất bại: %v", err)
+			redirectOidcError(c, oidcErrorCode(err))
+			return
+		}
+		dataUser := result.User
+
+		// Role đổi theo nhóm bên IdP thì token cũ mang role cũ phải bị thu hồi
+		if result.RoleChanged {
+			if err := revokeUserSessions(c.Request.Context(), db, dataUser.UserID, access_revocation_service.ReasonRoleChanged); err != nil {
+				log.Printf("Không thể thu hồi phiên sau khi đổi role qua SSO: UserID=%s, Lỗi=%v", dataUser.UserID, err)
+			}
+			socketServer.BroadcastMessage(socket_handler.Message{
+				Event: "users:updatedbyrole",
+				Data: gin.H{
+					"user_id":    dataUser.UserID,
+					"role_user":  dataUser.Role,
+					"updated_at": dataUser.UpdatedAt,
+				},
+			})
+		}
+		if result.Provisioned {
+			socketServer.BroadcastMessage(socket_handler.Message{
+				Event: "users:created",
+				Data: gin.H{
+					"user_id":    dataUser.UserID,
+					"full_name":  dataUser.FullName,
+					"account":    dataUser.Account,
+					"tag":        dataUser.Tag,
+					"role_user":  dataUser.Role,
+					"created_at": dataUser.CreatedAt,
+				},
+			})
+		}
+
+		// Account hoặc IP đang bị khóa vì đăng nhập sai thì SSO cũng không được cấp token
+		until, err := signInLockedUntil(c, db, dataUser.Account)
+		if err != nil {
+			log.Printf("Không thể kiểm tra trạng thái khóa đăng nhập: UserID=%s, Lỗi=%v", dataUser.UserID, err)
+			redirectOidcError(c, "server_error")
+			return
+		}
+		if until != nil {
+			redirectOidcError(c, "locked")
+			return
+		}
+
+		// SSO không bỏ qua 2FA của hệ thống
+		mfaService := mfa_service.NewMfaController(mfa_repo.NewSql(db))
+		mfaEnabled, err := mfaService.NewIsEnabled(c.Request.Context(), dataUser.UserID)
+		if err != nil {
+			log.Printf("Không thể kiểm tra xác thực hai bước: UserID=%s, Lỗi=%v", dataUser.UserID, err)
+			redirectOidcError(c, "server_error")
+			return
+		}
+		if mfaEnabled || mfaService.NewIsRequired(dataUser.Role) {
+			mfaToken, err := utils.GenerateMfaPendingToken(dataUser, mfaconfig.Get().PendingTTL)
+			if err != nil {
+				log.Printf("Không thể tạo mfa token: UserID=%s, Lỗi=%v", dataUser.UserID, err)
+				redirectOidcError(c, "server_error")
+				return
+			}
+			// Fragment không được trình duyệt gửi lên server nên mfa_token không lọt vào log của proxy
+			fragment := url.Values{"mfa_token": {mfaToken}}
+			if mfaEnabled {
+				fragment.Set("mfa_required", "true")
+			} else {
+				fragment.Set("mfa_enrollment_required", "true")
+			}
+			c.Redirect(http.StatusFound, oidcconfig.Get().LoginURL+"#"+fragment.Encode())
+			return
+		}
+
+		recordSignInSuccess(c, db, dataUser.Account)
+		if _, err := setSignInTokens(c, db, dataUser); err != nil {
+			redirectOidcError(c, "server_error")
+			return
+		}
+		c.Redirect(http.StatusFound, oidcconfig.Get().SuccessURL)
+	}
+}
+
+// redirectOidcError đưa trình duyệt về trang đăng nhập của SPA kèm mã lỗi
+func redirectOidcError(c *gin.Context, code string) {
+	target, err := url.Parse(oidcconfig.Get().LoginURL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   err.Error(),
+			"comment": "OIDC_LOGIN_URL không hợp lệ",
+		})
+		return
+	}
+	query := target.Query()
+	query.Set("sso_error", code)
+	target.RawQuery = query.Encode()
+	c.Redirect(http.StatusFound, target.String())
+}
+
+// oidcErrorCode là mã lỗi gửi cho SPA, cùng cách phân loại với respondOidcError
+func oidcErrorCode(err error) string {
+	switch {
+	case errors.Is(err, module.ErrOidcDisabled):
+		return "disabled"
+	case errors.Is(err, module.ErrOidcStateInvalid):
+		return "state_invalid"
+	case errors.Is(err, oidc.ErrInvalidIDToken):
+		return "invalid_id_token"
+	case errors.Is(err, module.ErrOidcEmailNotAllowed):
+		return "email_not_allowed"
+	case errors.Is(err, module.ErrOidcNotProvisioned), errors.Is(err, module.ErrOidcAccountDeleted):
+		return "not_provisioned"
+	default:
+		return "idp_error"
+	}
+}
+
+func respondOidcError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, module.ErrOidcDisabled):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   err.Error(),
+			"comment": "Đăng nhập SSO chưa được cấu hình",
+		})
+	case errors.Is(err, module.ErrOidcStateInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   err.Error(),
+			"comment": "Phiên đăng nhập SSO không hợp lệ hoặc đã hết hạn, vui lòng thử lại",
+		})
+	case errors.Is(err, oidc.ErrInvalidIDToken):
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   err.Error(),
+			"comment": "Không thể xác thực id_token từ IdP",
+		})
+	case errors.Is(err, module.ErrOidcEmailNotAllowed):
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   err.Error(),
+			"comment": "Email chưa xác minh hoặc không thuộc domain được phép",
+		})
+	case errors.Is(err, module.ErrOidcNotProvisioned), errors.Is(err, module.ErrOidcAccountDeleted):
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   err.Error(),
+			"comment": "Tài khoản chưa được cấp quyền truy cập hệ thống",
+		})
+	default:
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error":   err.Error(),
+			"comment": "Không thể đăng nhập qua IdP",
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package users_handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"thelastking-blogger.com/src/controller/common"
+	"thelastking-blogger.com/src/controller/handler/socket_handler"
+	"thelastking-blogger.com/src/module"
+	"thelastking-blogger.com/src/policy"
+	"thelastking-blogger.com/src/repository/access_revocation_repo"
+	"thelastking-blogger.com/src/repository/refresh_token_repo"
+	"thelastking-blogger.com/src/repository/users_repo"
+	"thelastking-blogger.com/src/security"
+	"thelastking-blogger.com/src/service/access_revocation_service"
+	"thelastking-blogger.com/src/service/refresh_token_service"
+	"thelastking-blogger.com/src/service/users_service"
+	"thelastking-blogger.com/src/utils"
+)
+
+// maxUserAgentLength khớp với độ dài cột refresh_tokens.user_agent, VARCHAR tính theo ký tự
+const maxUserAgentLength = 255
+
+// sessionClient lấy thông tin thiết bị của request để lưu cùng refresh token.
+// User-Agent được cắt theo ký tự và bỏ byte UTF-8 lỗi, cắt theo byte có thể chia đôi một ký tự nhiều byte.
+func sessionClient(c *gin.Context) module.SessionClient {
+	userAgent := strings.ToValidUTF8(c.Request.UserAgent(), "")
+	if runes := []rune(userAgent); len(runes) > maxUserAgentLength {
+		userAgent = string(runes[:maxUserAgentLength])
+	}
+	return module.SessionClient{
+		UserAgent: userAgent,
+		IPAddress: c.ClientIP(),
+	}
+}
+
+// canManageUser áp dụng thứ bậc role của policy.CanManage cho hai tài khoản
+func canManageUser(actor, target *module.Users) bool {
+	if actor.Role == nil || target.Role == nil {
+		return false
+	}
+	return policy.CanManage(*actor.Role, *target.Role)
+}
+
+// currentSessionID trả về family của refresh token trong cookie, rỗng nếu không có
+func currentSessionID(c *gin.Context, db *gorm.DB) string {
+	refreshToken, err := c.Cookie("refresh_token")
+	if err != nil || refreshToken == "" {
+		return ""
+	}
+	tokenService := refresh_token_service.NewRefreshTokenController(refresh_token_repo.NewSql(db))
+	dbToken, err := tokenService.NewGetRefreshTokenByHash(c.Request.Context(), security.HashToken(refreshToken))
+	if err != nil {
+		return ""
+	}
+	return dbToken.FamilyID
+}
+
+// HandlerListSessions liệt kê các phiên đăng nhập còn hiệu lực của user hiện tại
+func HandlerListSessions(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		dataUser, ok := currentUser(c, db)
+		if !ok {
+			return
+		}
+		tokenService := refresh_token_service.NewRefreshTokenController(refresh_token_repo.NewSql(db))
+		data, err := tokenService.NewListSessions(c.Request.Context(), dataUser.UserID, currentSessionID(c, db))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   err.Error(),
+				"comment": "Không thể lấy danh sách phiên đăng nhập",
+			})
+			return
+		}
+		c.JSON(http.StatusOK, common.ItemsResponse(data))
+	}
+}
+
+// HandlerRevokeSession đăng xuất một phiên của user hiện tại
+func HandlerRevokeSession(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		dataUser, ok := currentUser(c, db)
+		if !ok {
+			return
+		}
+		sessionID := c.Param("id")
+		current := currentSessionID(c, db)
+		if !revokeSession(c, db, dataUser.UserID, sessionID) {
+			return
+		}
+		if sessionID == current {
+			utils.ClearRefreshTokenCookie(c)
+		}
+		c.JSON(http.StatusOK, common.ItemsResponse("Revoke session success!"))
+	}
+}
+
+// HandlerRevokeOtherSessions đăng xuất mọi thiết bị khác, giữ lại phiên đang gửi request
+func HandlerRevokeOtherSessions(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		dataUser, ok := currentUser(c, db)
+		if !ok {
+			return
+		}
+		current := currentSessionID(c, db)
+		if current == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Thiếu refresh token",
+				"comment": "Không xác định được phiên hiện tại",
+			})
+			return
+		}
+		tokenService := refresh_token_service.NewRefreshTokenController(refresh_token_repo.NewSql(db))
+		families, err := tokenService.NewRevokeOtherSessions(c.Request.Context(), dataUser.UserID, current)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   err.Error(),
+				"comment": "Không thể đăng xuất các phiên khác",
+			})
+			return
+		}
+		revocations := access_revocation_service.GetRevocationController(access_revocation_repo.NewSql(db))
+		for _, family := range families {
+			if err := revocations.NewRevokeSessionTokens(c.Request.Context(), dataUser.UserID, family, access_revocation_service.ReasonSessionRevoked); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error":   err.Error(),
+					"comment": "Không thể thu hồi access token của các phiên khác",
+				})
+				return
+			}
+		}
+		c.JSON(http.StatusOK, common.ItemsResponse("Revoke other sessions success!"))
+	}
+}
+
+// HandlerListUserSessions cho ADMIN/ROOT xem phiên đăng nhập của tài khoản mình quản lý
+func HandlerListUserSessions(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		target, ok := ManagedUser(c, db, true)
+		if !ok {
+			return
+		}
+		// Cookie là phiên của người gọi nên chỉ đánh dấu current khi họ xem phiên của chính mình
+		current := ""
+		if userID, _ := c.Get("userId"); userID == target.UserID {
+			current = currentSessionID(c, db)
+		}
+		tokenService := refresh_token_service.NewRefreshTokenController(refresh_token_repo.NewSql(db))
+		data, err := tokenService.NewListSessions(c.Request.Context(), target.UserID, current)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   err.Error(),
+				"comment": "Không thể lấy danh sách phiên đăng nhập",
+			})
+			return
+		}
+		c.JSON(http.StatusOK, common.ItemsResponse(data))
+	}
+}
+
+// HandlerRevokeUserSession cho ADMIN/ROOT đăng xuất một phiên của tài khoản mình quản lý
+func HandlerRevokeUserSession(db *gorm.DB, socketServer *socket_handler.SocketServer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		target, ok := ManagedUser(c, db, true)
+		if !ok {
+			return
+		}
+		sessionID := c.Param("id")
+		if !revokeSession(c, db, target.UserID, sessionID) {
+			return
+		}
+		socketServer.BroadcastMessage(socket_handler.Message{
+			Event: "users:session_revoked",
+			Data: gin.H{
+				"user_id":    target.UserID,
+				"session_id": sessionID,
+			},
+		})
+		c.JSON(http.StatusOK, common.ItemsResponse("Revoke session success!"))
+	}
+}
+
+// revokeSession thu hồi refresh token của phiên và mọi access token mang sid của phiên đó
+func revokeSession(c *gin.Context, db *gorm.DB, userID, sessionID string) bool {
+	tokenService := refresh_token_service.NewRefreshTokenController(refresh_token_repo.NewSql(db))
+	found, err := tokenService.NewRevokeSession(c.Request.Context(), userID, sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   err.Error(),
+			"comment": "Không thể đăng xuất phiên",
+		})
+		return false
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "session not found",
+			"comment": "Không tìm thấy phiên đăng nhập",
+		})
+		return false
+	}
+	revocations := access_revocation_service.GetRevocationController(access_revocation_repo.NewSql(db))
+	if err := revocations.NewRevokeSessionTokens(c.Request.Context(), userID, sessionID, access_revocation_service.ReasonSessionRevoked); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   err.Error(),
+			"comment": "Không thể thu hồi access token của phiên",
+		})
+		return false
+	}
+	return true
+}
+
+// ManagedUser nạp tài khoản trong :user_id và kiểm tra người gọi quản lý được tài khoản đó theo thứ bậc role.
+// Role của người gọi đọc lại từ database vì role trong token có thể đã bị hạ từ lúc cấp token.
+// allowSelf cho phép thao tác trên chính tài khoản của người gọi.
+func ManagedUser(c *gin.Context, db *gorm.DB, allowSelf bool) (*module.Users, bool) {
+	actor, ok := currentUser(c, db)
+	if !ok {
+		return nil, false
+	}
+	buss := users_service.NewUserController(users_repo.NewSql(db))
+	target, err := buss.NewProfileUsers(c.Request.Context(), c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   err.Error(),
+			"comment": "Không tìm thấy tài khoản mục tiêu",
+		})
+		return nil, false
+	}
+	self := actor.UserID == target.UserID
+	if (self && !allowSelf) || (!self && !canManageUser(actor, target)) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Forbidden",
+			"comment": "Bạn chỉ có thể quản lý tài khoản có vai trò thấp hơn mình",
+		})
+		return nil, false
+	}
+	return target, true
+}
@@ -0,0 +1,136 @@
+package users_handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"thelastking-blogger.com/src/controller/common"
+	"thelastking-blogger.com/src/controller/handler/socket_handler"
+	"thelastking-blogger.com/src/module"
+	"thelastking-blogger.com/src/policy"
+	"thelastking-blogger.com/src/repository/users_repo"
+	"thelastking-blogger.com/src/service/users_service"
+)
+
+// HandlerListTrashUsers liệt kê tài khoản đã bị xóa mềm mà người gọi có quyền quản lý
+func HandlerListTrashUsers(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		dataUser, ok := currentUser(c, db)
+		if !ok {
+			return
+		}
+		roles := []string{}
+		if dataUser.Role != nil {
+			roles = policy.ManageableRoles(*dataUser.Role)
+		}
+		var paging common.Paggings
+		if err := c.ShouldBind(&paging); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "pagging faild",
+			})
+			return
+		}
+		paging.Process()
+		if err := paging.ParseCursor(nil); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   err.Error(),
+				"comment": "Cursor không hợp lệ",
+			})
+			return
+		}
+		userCtrl := users_service.NewUserController(users_repo.NewSql(db))
+		dataListUsers, err := userCtrl.NewListTrashUser(c.Request.Context(), roles, &paging)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "getList trash users database faild",
+				"details": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, common.ListResponse(dataListUsers, paging))
+	}
+}
+
+// HandlerRestoreUser khôi phục tài khoản, chỉ với tài khoản có vai trò thấp hơn mình
+func HandlerRestoreUser(db *gorm.DB, socketServer *socket_handler.SocketServer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		targetUserID, ok := deletedUserManagedByActor(c, db)
+		if !ok {
+			return
+		}
+		buss := users_service.NewUserController(users_repo.NewSql(db))
+		if err := buss.NewRestoreUser(c.Request.Context(), targetUserID); err != nil {
+			respondUserTrashError(c, err)
+			return
+		}
+		socketServer.BroadcastMessage(socket_handler.Message{
+			Event: "users:restored",
+			Data: gin.H{
+				"user_id": targetUserID,
+			},
+		})
+		c.JSON(http.StatusOK, common.ItemsResponse("Restore success!"))
+	}
+}
+
+// HandlerPurgeUser xóa vĩnh viễn tài khoản đang nằm trong thùng rác
+func HandlerPurgeUser(db *gorm.DB, socketServer *socket_handler.SocketServer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		targetUserID, ok := deletedUserManagedByActor(c, db)
+		if !ok {
+			return
+		}
+		buss := users_service.NewUserController(users_repo.NewSql(db))
+		if err := buss.NewPurgeUser(c.Request.Context(), targetUserID); err != nil {
+			respondUserTrashError(c, err)
+			return
+		}
+		socketServer.BroadcastMessage(socket_handler.Message{
+			Event: "users:purged",
+			Data: gin.H{
+				"user_id": targetUserID,
+			},
+		})
+		c.JSON(http.StatusOK, common.ItemsResponse("Purge success!"))
+	}
+}
+
+// deletedUserManagedByActor tìm tài khoản trong thùng rác theo :id và kiểm tra người gọi có quyền quản lý nó
+func deletedUserManagedByActor(c *gin.Context, db *gorm.DB) (string, bool) {
+	dataUser, ok := currentUser(c, db)
+	if !ok {
+		return "", false
+	}
+	buss := users_service.NewUserController(users_repo.NewSql(db))
+	dataUserDel, err := buss.NewGetDeletedUser(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondUserTrashError(c, err)
+		return "", false
+	}
+	if !canManageUser(dataUser, dataUserDel) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Forbidden",
+			"comment": "Bạn chỉ có thể quản lý tài khoản có vai trò thấp hơn mình",
+		})
+		return "", false
+	}
+	return dataUserDel.UserID, true
+}
+
+var userTrashMessages = common.TrashMessages{
+	NotFound: "Không tìm thấy tài khoản trong thùng rác",
+	Failed:   "Không thể cập nhật tài khoản",
+}
+
+func respondUserTrashError(c *gin.Context, err error) {
+	if errors.Is(err, module.ErrAccountTaken) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   err.Error(),
+			"comment": "Tên đăng nhập đã được tài khoản khác sử dụng",
+		})
+		return
+	}
+	common.RespondTrashError(c, err, userTrashMessages)
+}
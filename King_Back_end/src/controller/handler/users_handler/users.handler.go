@@ -1,22 +1,34 @@
 package users_handler
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 
 	"gorm.io/gorm"
+	jwtconfig "thelastking-blogger.com/src/config/jwt_config"
 	"thelastking-blogger.com/src/config/logger"
 	"thelastking-blogger.com/src/controller/common"
 	"thelastking-blogger.com/src/controller/handler/socket_handler"
+	"thelastking-blogger.com/src/mailer"
 	"thelastking-blogger.com/src/module"
 	"thelastking-blogger.com/src/module/req_users"
+	"thelastking-blogger.com/src/policy"
+	"thelastking-blogger.com/src/repository/access_revocation_repo"
+	"thelastking-blogger.com/src/repository/mfa_repo"
+	"thelastking-blogger.com/src/repository/password_reset_repo"
 	"thelastking-blogger.com/src/repository/refresh_token_repo"
 	"thelastking-blogger.com/src/repository/users_repo"
 	"thelastking-blogger.com/src/security"
+	"thelastking-blogger.com/src/service/access_revocation_service"
+	"thelastking-blogger.com/src/service/mfa_service"
+	"thelastking-blogger.com/src/service/password_reset_service"
 	"thelastking-blogger.com/src/service/refresh_token_service"
 	"thelastking-blogger.com/src/service/users_service"
 	"thelastking-blogger.com/src/utils"
@@ -45,6 +57,17 @@ func HandlerCreateUser(db *gorm.DB, socketServer *socket_handler.SocketServer) g
 			return
 		}
 
+		// Đăng ký công khai luôn là USER, role khác phải do người đã đăng nhập tạo qua /users/createUser (policy.CanAssignRole)
+		if dataUser.Role != nil && *dataUser.Role != "" {
+			if role, ok := module.ParseRoles(*dataUser.Role); !ok || role != module.USER {
+				c.JSON(http.StatusForbidden, gin.H{
+					"error":   "Forbidden",
+					"comment": "Đăng ký công khai chỉ tạo được tài khoản " + module.USER.String(),
+				})
+				return
+			}
+		}
+
 		idUser, err := utils.GenerateUUID()
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
@@ -54,15 +77,16 @@ func HandlerCreateUser(db *gorm.DB, socketServer *socket_handler.SocketServer) g
 			return
 		}
 		times := time.Now().UTC()
-		newpwd := security.HashAndSalt([]byte(dataUser.Password_user))
-		var rolePtr *string
-		if dataUser.Role != nil && *dataUser.Role != "" {
-			roleValue := utils.ParseRole(*dataUser.Role)
-			rolePtr = &roleValue
-		} else {
-			defaultRole := "USER"
-			rolePtr = &defaultRole
+		newpwd, err := security.HashPassword([]byte(dataUser.Password_user))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   err.Error(),
+				"comment": "Không thể băm mật khẩu",
+			})
+			return
 		}
+		role := module.USER.String()
+		rolePtr := &role
 		newUsers := &module.Users{
 			UserID:        idUser,
 			FullName:      dataUser.FullName,
@@ -81,33 +105,24 @@ func HandlerCreateUser(db *gorm.DB, socketServer *socket_handler.SocketServer) g
 			})
 			return
 		}
-		accessToken, refreshToken, err := utils.GenerateTokens(newUsers)
+		sessionID, err := utils.GenerateUUID()
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{
+			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":   err.Error(),
 				"comment": "Không thể tạo token",
 			})
 			return
 		}
-		claims, err := utils.ParseToken(refreshToken)
+		accessToken, refreshToken, err := utils.GenerateTokens(newUsers, sessionID)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error":   err.Error(),
-				"comment": "Không thể phân tích refresh token",
+				"comment": "Không thể tạo token",
 			})
 			return
 		}
-		expiresAt := time.Unix(claims.ExpiresAt, 0)
-		newRefreshToken := &module.RefreshToken{
-			Token:     refreshToken,
-			UserID:    newUsers.UserID,
-			ExpiresAt: expiresAt,
-			Revoked:   false,
-			CreatedAt: times,
-		}
-		busToken := refresh_token_service.NewRefreshTokenController(refresh_token_repo.NewSql(db))
-		if err := busToken.NewCreateRefreshToken(c.Request.Context(), newRefreshToken); err != nil {
-			log.Printf("Lỗi khi lưu refresh token: Token=%s, UserID=%s, Lỗi=%v", refreshToken, newUsers.UserID, err)
+		if err := saveRefreshToken(c.Request.Context(), db, newUsers.UserID, refreshToken, sessionClient(c)); err != nil {
+			log.Printf("Lỗi khi lưu refresh token: UserID=%s, Lỗi=%v", newUsers.UserID, err)
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":   err.Error(),
 				"comment": "Không thể lưu refresh token",
@@ -131,7 +146,7 @@ func HandlerCreateUser(db *gorm.DB, socketServer *socket_handler.SocketServer) g
 }
 
 // HandlerSignIn
-func HandlerSignIn(db *gorm.DB) gin.HandlerFunc {
+func HandlerSignIn(db *gorm.DB, socketServer *socket_handler.SocketServer) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var userSignIn req_users.RequestSignIn
 		if err := c.ShouldBindJSON(&userSignIn); err != nil {
@@ -152,94 +167,129 @@ func HandlerSignIn(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		if !checkSignInLock(c, db, userSignIn.Account) {
+			return
+		}
+
 		buss := users_service.NewUserController(users_repo.NewSql(db))
 		dataUser, err := buss.NewSignIn(c.Request.Context(), &userSignIn)
 		if err != nil {
+			recordSignInFailure(c, db, socketServer, userSignIn.Account, nil)
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"comment": "Không tìm thấy tài khoản",
 			})
 			return
 		}
 
-		if !security.ComparePasswords(dataUser.Password_user, []byte(userSignIn.Password)) {
+		match, needsRehash, err := security.ComparePasswords(dataUser.Password_user, []byte(userSignIn.Password))
+		if err != nil {
+			log.Printf("Không thể kiểm tra mật khẩu: UserID=%s, Lỗi=%v", dataUser.UserID, err)
+		}
+		if !match {
+			recordSignInFailure(c, db, socketServer, userSignIn.Account, &dataUser.UserID)
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"comment": "Email hoặc mật khẩu không đúng",
 			})
 			return
 		}
-
-		var accessToken, refreshToken string
-		refreshTokenCookie, err := c.Cookie("refresh_token")
-		validOldToken := false
-
-		if err == nil && refreshTokenCookie != "" {
-			claims, validateErr := security.ValidateCookieToken(c.Request.Context(), c, db)
-			if validateErr == nil && claims.UserID == dataUser.UserID {
-				newAccessToken, updateErr := security.UpdateToken(c.Request.Context(), db, refreshTokenCookie)
-				if updateErr == nil {
-					accessToken = newAccessToken
-					refreshToken = refreshTokenCookie
-					validOldToken = true
-					log.Printf("Sử dụng refresh token cũ: Token=%s, UserID=%s", refreshToken, dataUser.UserID)
-				} else {
-					log.Printf("Không thể cập nhật token: %v", updateErr)
-					utils.ClearRefreshTokenCookie(c)
+		// Hash cũ yếu hơn chính sách hiện tại: băm lại khi đang có mật khẩu gốc, lỗi không chặn đăng nhập
+		if needsRehash {
+			if newHash, err := security.HashPassword([]byte(userSignIn.Password)); err == nil {
+				if err := buss.NewUpdatePasswordHash(c.Request.Context(), dataUser.UserID, dataUser.Password_user, newHash); err != nil {
+					log.Printf("Không thể lưu mật khẩu đã băm lại: UserID=%s, Lỗi=%v", dataUser.UserID, err)
 				}
 			} else {
-				log.Printf("Xác thực refresh token cũ thất bại: %v", validateErr)
-				utils.ClearRefreshTokenCookie(c)
+				log.Printf("Không thể băm lại mật khẩu: UserID=%s, Lỗi=%v", dataUser.UserID, err)
 			}
 		}
 
-		if !validOldToken {
-			accessToken, refreshToken, err = utils.GenerateTokens(dataUser)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error":   err.Error(),
-					"comment": "Không thể tạo token mới",
-				})
-				return
-			}
+		// Tài khoản đã bật 2FA hoặc thuộc role bắt buộc 2FA phải qua bước thứ hai trước khi nhận token
+		mfaService := mfa_service.NewMfaController(mfa_repo.NewSql(db))
+		mfaEnabled, err := mfaService.NewIsEnabled(c.Request.Context(), dataUser.UserID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   err.Error(),
+				"comment": "Không thể kiểm tra xác thực hai bước",
+			})
+			return
+		}
+		// Bộ đếm chỉ được xóa khi đăng nhập hoàn tất, nếu không thì đúng mật khẩu sẽ mở lại lượt đoán mã 2FA
+		if mfaEnabled || mfaService.NewIsRequired(dataUser.Role) {
+			respondMfaPending(c, dataUser, mfaEnabled)
+			return
+		}
 
-			claims, err := utils.ParseToken(refreshToken)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error":   err.Error(),
-					"comment": "Không thể phân tích claims của refresh token",
-				})
-				return
-			}
+		recordSignInSuccess(c, db, dataUser.Account)
+		issueSignInTokens(c, db, dataUser, nil)
+	}
+}
 
-			expiresAt := time.Unix(claims.ExpiresAt, 0)
-			newRefreshToken := &module.RefreshToken{
-				Token:     refreshToken,
-				UserID:    dataUser.UserID,
-				ExpiresAt: expiresAt,
-				Revoked:   false,
-				CreatedAt: time.Now().UTC(),
-			}
-			refreshService := refresh_token_service.NewRefreshTokenController(refresh_token_repo.NewSql(db))
-			if err := refreshService.NewRevokeRefreshTokenByUserID(c.Request.Context(), dataUser.UserID); err != nil {
-				log.Printf("Lỗi khi thu hồi token cũ cho UserID=%s: %v", dataUser.UserID, err)
-			}
-			log.Printf("Đã lưu refresh token: Token=%s, UserID=%s", refreshToken, dataUser.UserID)
-			if err := refreshService.NewCreateRefreshToken(c.Request.Context(), newRefreshToken); err != nil {
-				log.Printf("Lỗi khi lưu refresh token: Token=%s, UserID=%s, Lỗi=%v", refreshToken, dataUser.UserID, err)
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error":   err.Error(),
-					"comment": "Không thể lưu refresh token mới",
-				})
-				return
+// issueSignInTokens cấp access token và refresh token sau khi user đã qua mọi bước xác thực
+func issueSignInTokens(c *gin.Context, db *gorm.DB, dataUser *module.Users, extra gin.H) {
+	accessToken, err := setSignInTokens(c, db, dataUser)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   err.Error(),
+			"comment": "Không thể tạo token đăng nhập",
+		})
+		return
+	}
+	response := gin.H{
+		"access_token": accessToken,
+		"expires_in":   int(jwtconfig.Get().AccessTTL.Seconds()), // Thời gian hết hạn token (giây)
+		"comment":      "Đăng nhập thành công",
+	}
+	for key, value := range extra {
+		response[key] = value
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// setSignInTokens tạo access token, lưu refresh token và đặt cookie refresh_token.
+// Nếu cookie refresh token của chính user còn hợp lệ thì xoay vòng family đó thay vì mở family mới.
+func setSignInTokens(c *gin.Context, db *gorm.DB, dataUser *module.Users) (string, error) {
+	var accessToken, refreshToken string
+	refreshTokenCookie, err := c.Cookie("refresh_token")
+	validOldToken := false
+
+	if err == nil && refreshTokenCookie != "" {
+		claims, validateErr := security.ValidateCookieToken(c.Request.Context(), c, db)
+		if validateErr == nil && claims.UserID == dataUser.UserID {
+			newAccessToken, newRefreshToken, updateErr := security.UpdateToken(c.Request.Context(), db, refreshTokenCookie, sessionClient(c))
+			if updateErr == nil {
+				accessToken = newAccessToken
+				refreshToken = newRefreshToken
+				validOldToken = true
+				log.Printf("Xoay vòng refresh token của phiên hiện tại: UserID=%s", dataUser.UserID)
+			} else {
+				log.Printf("Không thể cập nhật token: %v", updateErr)
+				utils.ClearRefreshTokenCookie(c)
 			}
+		} else {
+			log.Printf("Xác thực refresh token cũ thất bại: %v", validateErr)
+			utils.ClearRefreshTokenCookie(c)
 		}
+	}
 
-		utils.SetRefreshTokenCookie(c, refreshToken, 60*60*24*7)
-		c.JSON(http.StatusOK, gin.H{
-			"access_token": accessToken,
-			"expires_in":   60 * 60, // Thời gian hết hạn token (giây)
-			"comment":      "Đăng nhập thành công",
-		})
+	if !validOldToken {
+		sessionID, err := utils.GenerateUUID()
+		if err != nil {
+			return "", err
+		}
+		accessToken, refreshToken, err = utils.GenerateTokens(dataUser, sessionID)
+		if err != nil {
+			return "", err
+		}
+
+		// Mỗi lần đăng nhập mở một phiên mới, các phiên trên thiết bị khác vẫn giữ nguyên
+		if err := saveRefreshToken(c.Request.Context(), db, dataUser.UserID, refreshToken, sessionClient(c)); err != nil {
+			log.Printf("Lỗi khi lưu refresh token: UserID=%s, Lỗi=%v", dataUser.UserID, err)
+			return "", err
+		}
 	}
+
+	utils.SetRefreshTokenCookie(c, refreshToken, 60*60*24*7)
+	return accessToken, nil
 }
 
 // HandlerSignOut
@@ -254,16 +304,40 @@ func HandlerSignOut(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		// Thu hồi cả family của refresh token hiện tại
 		tokenService := refresh_token_service.NewRefreshTokenController(refresh_token_repo.NewSql(db))
-		if err := tokenService.NewDeleteRefreshTokenByTokenID(c.Request.Context(), refreshToken); err != nil {
-			log.Printf("Không thể xóa refresh token: Token=%s, Lỗi=%v", refreshToken, err)
+		dbToken, err := tokenService.NewGetRefreshTokenByHash(c.Request.Context(), security.HashToken(refreshToken))
+		if err == nil {
+			if err := tokenService.NewRevokeRefreshTokenFamily(c.Request.Context(), dbToken.FamilyID); err != nil {
+				log.Printf("Không thể thu hồi refresh token: Family=%s, Lỗi=%v", dbToken.FamilyID, err)
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error":   err.Error(),
+					"comment": "Không thể thu hồi refresh token",
+				})
+				return
+			}
+		} else if !errors.Is(err, module.ErrRefreshTokenNotFound) {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":   err.Error(),
-				"comment": "Không thể xóa refresh token",
+				"comment": "Không thể thu hồi refresh token",
 			})
 			return
 		}
 
+		// Thu hồi luôn access token đang dùng nếu client gửi kèm
+		if accessToken, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+			if accessClaims, err := security.ValidateAccessToken(accessToken); err == nil {
+				revocations := access_revocation_service.GetRevocationController(access_revocation_repo.NewSql(db))
+				if err := revocations.NewRevokeToken(c.Request.Context(), accessClaims, access_revocation_service.ReasonSignOut); err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{
+						"error":   err.Error(),
+						"comment": "Không thể thu hồi access token",
+					})
+					return
+				}
+			}
+		}
+
 		utils.ClearRefreshTokenCookie(c)
 		c.JSON(http.StatusOK, gin.H{
 			"message": "Đăng xuất thành công",
@@ -406,20 +480,11 @@ func HandlerDeletedUser(db *gorm.DB, socketServer *socket_handler.SocketServer)
 			})
 			return
 		}
-		if *dataUser.Role == "ROOT" {
-			// ROOT có thể xóa tất cả (đã kiểm tra tự xóa ở trên)
-		} else if *dataUser.Role == "ADMIN" {
-			if *dataUserDel.Role != "USER" {
-				c.JSON(http.StatusForbidden, gin.H{
-					"error":   "Forbidden",
-					"comment": "Admin chỉ có thể xóa tài khoản có vai trò USER",
-				})
-				return
-			}
-		} else {
+		// ROOT có thể xóa tất cả (đã kiểm tra tự xóa ở trên), role khác chỉ xóa được role thấp hơn
+		if !canManageUser(dataUser, dataUserDel) {
 			c.JSON(http.StatusForbidden, gin.H{
 				"error":   "Forbidden",
-				"comment": "Bạn không có quyền xóa tài khoản",
+				"comment": "Bạn chỉ có thể xóa tài khoản có vai trò thấp hơn mình",
 			})
 			return
 		}
@@ -430,6 +495,13 @@ func HandlerDeletedUser(db *gorm.DB, socketServer *socket_handler.SocketServer)
 			})
 			return
 		}
+		if err := revokeUserSessions(c.Request.Context(), db, targetUserID, access_revocation_service.ReasonUserDeleted); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   err.Error(),
+				"comment": "Không thể thu hồi phiên đăng nhập của tài khoản đã xóa",
+			})
+			return
+		}
 		socketServer.BroadcastMessage(socket_handler.Message{
 			Event: "users:deleted",
 			Data: gin.H{
@@ -451,8 +523,19 @@ func HandlerListUsers(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 		paging.Process()
+		query, err := common.ParseListQuery(c.Request.URL.Query(), users_repo.ListFields)
+		if err == nil {
+			err = paging.ParseCursor(query)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   err.Error(),
+				"comment": "Tham số lọc, sắp xếp hoặc cursor không hợp lệ",
+			})
+			return
+		}
 		userCtrl := users_service.NewUserController(users_repo.NewSql(db))
-		dataListUsers, err := userCtrl.NewListUser(c.Request.Context(), &paging)
+		dataListUsers, err := userCtrl.NewListUser(c.Request.Context(), &paging, query)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":   "getList users database faild",
@@ -460,7 +543,7 @@ func HandlerListUsers(db *gorm.DB) gin.HandlerFunc {
 			})
 			return
 		}
-		c.JSON(http.StatusOK, common.ItemsResponse(dataListUsers))
+		c.JSON(http.StatusOK, common.ListResponse(dataListUsers, paging))
 	}
 }
 func HandlerChanrgePwd(db *gorm.DB) gin.HandlerFunc {
@@ -507,6 +590,14 @@ func HandlerChanrgePwd(db *gorm.DB) gin.HandlerFunc {
 			})
 			return
 		}
+		if err := revokeUserSessions(c.Request.Context(), db, claims, access_revocation_service.ReasonPasswordChanged); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to revoke sessions",
+				"message": err.Error(),
+			})
+			return
+		}
+		utils.ClearRefreshTokenCookie(c)
 
 		c.JSON(http.StatusOK, gin.H{
 			"message": "Password changed successfully",
@@ -514,35 +605,87 @@ func HandlerChanrgePwd(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
+// HandlerForgotPwd gửi link đặt lại mật khẩu qua email.
+// Luôn trả về cùng một phản hồi để không lộ tài khoản nào tồn tại.
 func HandlerForgotPwd(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-
-		var forgotPwd req_users.ForgorPwd
-		if err := c.ShouldBindJSON(&forgotPwd); err != nil {
+		var input req_users.RequestForgotPwd
+		if err := c.ShouldBindJSON(&input); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error":   "Missing or invalid request body",
 				"message": err.Error(),
 			})
 			return
 		}
-
-		if forgotPwd.NewPassword != forgotPwd.ConfirmPassword {
+		validate := validator.New()
+		validators.RegisterCustomValidations(validate)
+		if err := validate.Struct(input); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
-				"error":   "Password confirmation does not match",
-				"message": "New password and confirmation must be the same",
+				"error":   err.Error(),
+				"comment": "Không thể xác thực",
 			})
 			return
 		}
-		hashedPassword := security.HashAndSalt([]byte(forgotPwd.NewPassword))
-		NewForgotPwd := &req_users.ForgorPwd{
-			Account:     forgotPwd.Account,
-			NewPassword: hashedPassword,
-		}
+
 		buss := users_service.NewUserController(users_repo.NewSql(db))
-		if err := buss.NewForgotPwd(c.Request.Context(), NewForgotPwd); err != nil {
+		dataUser, err := buss.NewSignIn(c.Request.Context(), &req_users.RequestSignIn{Account: input.Account})
+		if err == nil {
+			resetService := password_reset_service.NewPasswordResetController(password_reset_repo.NewSql(db), mailer.Get())
+			// Tạo token và gửi mail chạy nền để thời gian phản hồi không cho biết tài khoản có tồn tại,
+			// lỗi chỉ ghi log vì trả lỗi khác đi cũng làm lộ điều đó
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request.Context()), time.Minute)
+			go func() {
+				defer cancel()
+				if err := resetService.NewRequestPasswordReset(ctx, dataUser); err != nil {
+					logger.GetLogger().Errorf("Không thể gửi email đặt lại mật khẩu: %v", err)
+				}
+			}()
+		}
+		c.JSON(http.StatusOK, common.ItemsResponse("Nếu tài khoản tồn tại, link đặt lại mật khẩu đã được gửi tới email"))
+	}
+}
+
+// HandlerConfirmForgotPwd đặt mật khẩu mới bằng token trong email và thu hồi mọi phiên đăng nhập của user
+func HandlerConfirmForgotPwd(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input req_users.ConfirmForgotPwd
+		if err := c.ShouldBindJSON(&input); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
-				"error":   "Password confirmation does not match",
-				"message": "New password and confirmation must be the same",
+				"error":   "Missing or invalid request body",
+				"message": err.Error(),
+			})
+			return
+		}
+		validate := validator.New()
+		validators.RegisterCustomValidations(validate)
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   err.Error(),
+				"comment": "Không thể xác thực",
+			})
+			return
+		}
+
+		resetService := password_reset_service.NewPasswordResetController(password_reset_repo.NewSql(db), mailer.Get())
+		userID, err := resetService.NewConfirmPasswordReset(c.Request.Context(), input.Token, input.NewPassword)
+		if err != nil {
+			if errors.Is(err, module.ErrPasswordResetInvalid) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":   err.Error(),
+					"comment": "Link đặt lại mật khẩu không hợp lệ hoặc đã hết hạn",
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to reset password",
+				"message": err.Error(),
+			})
+			return
+		}
+		if err := revokeUserSessions(c.Request.Context(), db, userID, access_revocation_service.ReasonPasswordChanged); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to revoke sessions",
+				"message": err.Error(),
 			})
 			return
 		}
@@ -567,35 +710,30 @@ func HandlerRefreshToken(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// 2. Xác thực refresh token (kiểm tra database)
-		claims, err := security.ValidateToken(ctx, db, refreshTokenString)
+		// 2. Xoay vòng refresh token: token cũ bị thu hồi, token mới cùng family được cấp
+		newAccessToken, newRefreshToken, err := security.UpdateToken(ctx, db, refreshTokenString, sessionClient(c))
 		if err != nil {
 			log.Errorf("Refresh token không hợp lệ hoặc đã hết hạn: %v", err)
 			utils.ClearRefreshTokenCookie(c) // Xóa cookie nếu token không hợp lệ
+			comment := "Vui lòng đăng nhập lại"
+			if errors.Is(err, module.ErrRefreshTokenReused) {
+				comment = "Phát hiện refresh token bị dùng lại, mọi phiên liên quan đã bị thu hồi"
+			}
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error":   "Refresh token không hợp lệ",
-				"comment": "Vui lòng đăng nhập lại",
+				"comment": comment,
 			})
 			return
 		}
 
-		// 3. Tạo access token mới
-		newAccessToken, err := security.UpdateToken(ctx, db, refreshTokenString)
-		if err != nil {
-			log.Errorf("Lỗi khi tạo access token mới: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":   "Không thể tạo token mới",
-				"comment": "Làm mới phiên thất bại",
-			})
-			return
-		}
-
-		log.Infof("Làm mới access token thành công cho user: %s", claims.UserID)
+		// 3. Ghi đè cookie bằng refresh token mới
+		utils.SetRefreshTokenCookie(c, newRefreshToken, 60*60*24*7)
+		log.Infof("Làm mới access token thành công")
 
 		// 4. Trả về access token mới cho frontend
 		c.JSON(http.StatusOK, gin.H{
 			"access_token": newAccessToken,
-			"expires_in":   60 * 60, // Thời gian hết hạn token (giây)
+			"expires_in":   int(jwtconfig.Get().AccessTTL.Seconds()), // Thời gian hết hạn token (giây)
 			"comment":      "Access token đã được làm mới",
 		})
 	}
@@ -651,9 +789,23 @@ func HandlerCreateUserByRole(db *gorm.DB, socketServer *socket_handler.SocketSer
 			return
 		}
 		times := time.Now().UTC()
-		newpwd := security.HashAndSalt([]byte(input.Password_user))
+		newpwd, err := security.HashPassword([]byte(input.Password_user))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   err.Error(),
+				"comment": "Không thể băm mật khẩu",
+			})
+			return
+		}
 		var rolePtr *string
 		if input.Role != nil && *input.Role != "" {
+			if _, ok := module.ParseRoles(*input.Role); !ok {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":   module.ErrInvalidRole.Error(),
+					"comment": "Vai trò không hợp lệ",
+				})
+				return
+			}
 			roleValue := utils.ParseRole(*input.Role)
 			rolePtr = &roleValue
 		} else {
@@ -681,18 +833,11 @@ func HandlerCreateUserByRole(db *gorm.DB, socketServer *socket_handler.SocketSer
 			return
 		}
 
-		// Role-based access control
-		if *dataUser.Role != "ROOT" && *dataUser.Role != "ADMIN" {
+		// Chỉ được tạo tài khoản có role thấp hơn mình (ROOT tạo được mọi role)
+		if dataUser.Role == nil || !policy.CanAssignRole(*dataUser.Role, *rolePtr) {
 			c.JSON(http.StatusForbidden, gin.H{
 				"error":   "Forbidden",
-				"comment": "Bạn không có quyền tạo tài khoản",
-			})
-			return
-		}
-		if *dataUser.Role == "ADMIN" && *input.Role != "USER" {
-			c.JSON(http.StatusForbidden, gin.H{
-				"error":   "Forbidden",
-				"comment": "Admin chỉ có thể tạo tài khoản có vai trò USER",
+				"comment": "Bạn không có quyền tạo tài khoản với vai trò " + *rolePtr,
 			})
 			return
 		}
@@ -711,7 +856,7 @@ func HandlerCreateUserByRole(db *gorm.DB, socketServer *socket_handler.SocketSer
 				"full_name":  input.FullName,
 				"account":    input.Account,
 				"tag":        input.Tag,
-				"role_user":  input.Role,
+				"role_user":  rolePtr,
 				"created_at": input.CreatedAt,
 			},
 		})
@@ -778,23 +923,30 @@ func HandlerUpdateUser(db *gorm.DB, socketServer *socket_handler.SocketServer) g
 			return
 		}
 
-		// Role-based access control
-		if *dataUser.Role == "ROOT" {
-			// ROOT can update all users
-		} else if *dataUser.Role == "ADMIN" {
-			if *dataUserUpdate.Role != "USER" {
+		if !canManageUser(dataUser, dataUserUpdate) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   "Forbidden",
+				"comment": "Bạn chỉ có thể cập nhật tài khoản có vai trò thấp hơn mình",
+			})
+			return
+		}
+		if input.Role != nil && *input.Role != "" {
+			if _, ok := module.ParseRoles(*input.Role); !ok {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":   module.ErrInvalidRole.Error(),
+					"comment": "Vai trò không hợp lệ",
+				})
+				return
+			}
+			roleValue := utils.ParseRole(*input.Role)
+			input.Role = &roleValue
+			if !policy.CanAssignRole(*dataUser.Role, roleValue) {
 				c.JSON(http.StatusForbidden, gin.H{
 					"error":   "Forbidden",
-					"comment": "Admin chỉ có thể cập nhật tài khoản có vai trò USER",
+					"comment": "Bạn không có quyền gán vai trò " + roleValue,
 				})
 				return
 			}
-		} else {
-			c.JSON(http.StatusForbidden, gin.H{
-				"error":   "Forbidden",
-				"comment": "Bạn không có quyền cập nhật tài khoản",
-			})
-			return
 		}
 
 		if err := buss.NewUpdatedUsersByID(c.Request.Context(), &input, targetUserID); err != nil {
@@ -804,6 +956,16 @@ func HandlerUpdateUser(db *gorm.DB, socketServer *socket_handler.SocketServer) g
 			})
 			return
 		}
+		// Token cũ mang role cũ trong claims nên phải thu hồi khi role thay đổi
+		if input.Role != nil && *input.Role != "" && *input.Role != *dataUserUpdate.Role {
+			if err := revokeUserSessions(c.Request.Context(), db, targetUserID, access_revocation_service.ReasonRoleChanged); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error":   err.Error(),
+					"comment": "Không thể thu hồi phiên đăng nhập sau khi đổi vai trò",
+				})
+				return
+			}
+		}
 
 		socketServer.BroadcastMessage(socket_handler.Message{
 			Event: "users:updatedbyrole",
@@ -817,3 +979,39 @@ func HandlerUpdateUser(db *gorm.DB, socketServer *socket_handler.SocketServer) g
 		}))
 	}
 }
+
+// saveRefreshToken lưu hash của refresh token vừa cấp khi đăng nhập, mở ra family mới là sid của token
+func saveRefreshToken(ctx context.Context, db *gorm.DB, userID, refreshToken string, client module.SessionClient) error {
+	claims, err := utils.ParseToken(refreshToken)
+	if err != nil {
+		return err
+	}
+	if claims.SessionID == "" {
+		return errors.New("refresh token has no session id")
+	}
+	now := time.Now().UTC()
+	newRefreshToken := &module.RefreshToken{
+		TokenHash:  security.HashToken(refreshToken),
+		FamilyID:   claims.SessionID,
+		UserID:     userID,
+		ExpiresAt:  claims.ExpiresAt.Time.UTC(),
+		Revoked:    false,
+		CreatedAt:  now,
+		UserAgent:  client.UserAgent,
+		IPAddress:  client.IPAddress,
+		LastUsedAt: &now,
+	}
+	busToken := refresh_token_service.NewRefreshTokenController(refresh_token_repo.NewSql(db))
+	return busToken.NewCreateRefreshToken(ctx, newRefreshToken)
+}
+
+// revokeUserSessions thu hồi mọi access token và refresh token của user,
+// dùng khi user bị xóa, đổi role hoặc đổi mật khẩu
+func revokeUserSessions(ctx context.Context, db *gorm.DB, userID, reason string) error {
+	revocations := access_revocation_service.GetRevocationController(access_revocation_repo.NewSql(db))
+	if err := revocations.NewRevokeUserTokens(ctx, userID, reason); err != nil {
+		return err
+	}
+	busToken := refresh_token_service.NewRefreshTokenController(refresh_token_repo.NewSql(db))
+	return busToken.NewRevokeRefreshTokenByUserID(ctx, userID)
+}
@@ -0,0 +1,4 @@
+-- +migrate Down
+
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS permissions;
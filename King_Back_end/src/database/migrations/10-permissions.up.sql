@@ -0,0 +1,57 @@
+-- +migrate Up
+
+CREATE TABLE permissions (
+    permission_key VARCHAR(100) PRIMARY KEY,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- ROOT luôn có mọi quyền trong policy nên không cần dòng nào cho ROOT
+CREATE TABLE role_permissions (
+    role_user VARCHAR(20) NOT NULL,
+    permission_key VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (role_user, permission_key),
+    CONSTRAINT fk_role_permissions_permission
+        FOREIGN KEY (permission_key)
+        REFERENCES permissions(permission_key)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+
+INSERT INTO permissions (permission_key, description) VALUES
+    ('user:self', 'Quản lý hồ sơ, mật khẩu, 2FA và phiên đăng nhập của chính mình'),
+    ('user:create', 'Tạo tài khoản'),
+    ('user:update', 'Cập nhật tài khoản khác'),
+    ('user:delete', 'Xóa tài khoản'),
+    ('user:sessions', 'Xem và đăng xuất phiên của tài khoản khác'),
+    ('user:unlock', 'Xem và mở khóa đăng nhập'),
+    ('product:write', 'Tạo và cập nhật sản phẩm'),
+    ('product:delete', 'Xóa sản phẩm'),
+    ('factory:write', 'Tạo và cập nhật nhà máy'),
+    ('factory:delete', 'Xóa nhà máy'),
+    ('location:write', 'Tạo và cập nhật khu vực'),
+    ('location:delete', 'Xóa khu vực'),
+    ('permission:manage', 'Sửa bảng phân quyền theo role');
+
+-- Giữ nguyên hành vi trước đây: mọi user đã đăng nhập được sửa dữ liệu, quản lý tài khoản dành cho ADMIN
+INSERT INTO role_permissions (role_user, permission_key) VALUES
+    ('USER', 'user:self'),
+    ('USER', 'product:write'),
+    ('USER', 'product:delete'),
+    ('USER', 'factory:write'),
+    ('USER', 'factory:delete'),
+    ('USER', 'location:write'),
+    ('USER', 'location:delete'),
+    ('ADMIN', 'user:self'),
+    ('ADMIN', 'user:create'),
+    ('ADMIN', 'user:update'),
+    ('ADMIN', 'user:delete'),
+    ('ADMIN', 'user:sessions'),
+    ('ADMIN', 'user:unlock'),
+    ('ADMIN', 'product:write'),
+    ('ADMIN', 'product:delete'),
+    ('ADMIN', 'factory:write'),
+    ('ADMIN', 'factory:delete'),
+    ('ADMIN', 'location:write'),
+    ('ADMIN', 'location:delete');
@@ -0,0 +1,5 @@
+-- +migrate Down
+
+DELETE FROM permissions WHERE permission_key = 'scope:manage';
+DROP TABLE IF EXISTS user_factory_scopes;
+DROP TABLE IF EXISTS user_location_scopes;
@@ -0,0 +1,43 @@
+-- +migrate Up
+
+-- Phạm vi quản lý của user: được gán khu vực thì quản lý mọi nhà máy trong khu vực đó.
+-- ROOT không cần dòng nào vì luôn có phạm vi toàn cục.
+CREATE TABLE user_location_scopes (
+    user_id VARCHAR NOT NULL,
+    location_id VARCHAR NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, location_id),
+    CONSTRAINT fk_user_location_scopes_user
+        FOREIGN KEY (user_id)
+        REFERENCES users(user_id)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE,
+    CONSTRAINT fk_user_location_scopes_location
+        FOREIGN KEY (location_id)
+        REFERENCES locations(location_id)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+
+CREATE TABLE user_factory_scopes (
+    user_id VARCHAR NOT NULL,
+    factory_id VARCHAR NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, factory_id),
+    CONSTRAINT fk_user_factory_scopes_user
+        FOREIGN KEY (user_id)
+        REFERENCES users(user_id)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE,
+    CONSTRAINT fk_user_factory_scopes_factory
+        FOREIGN KEY (factory_id)
+        REFERENCES factories(factory_id)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+
+CREATE INDEX idx_user_factory_scopes_factory ON user_factory_scopes(factory_id);
+CREATE INDEX idx_user_location_scopes_location ON user_location_scopes(location_id);
+
+INSERT INTO permissions (permission_key, description) VALUES
+    ('scope:manage', 'Gán khu vực và nhà máy cho tài khoản');
@@ -0,0 +1,6 @@
+-- +migrate Down
+
+DELETE FROM permissions WHERE permission_key = 'service_account:manage';
+DROP TABLE IF EXISTS api_key_permissions;
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS service_accounts;
@@ -0,0 +1,63 @@
+-- +migrate Up
+
+-- Tài khoản máy (ERP sync, ...) đăng nhập bằng API key thay vì mật khẩu
+CREATE TABLE service_accounts (
+    service_account_id VARCHAR PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    created_by VARCHAR,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT fk_service_accounts_created_by
+        FOREIGN KEY (created_by)
+        REFERENCES users(user_id)
+        ON UPDATE CASCADE
+        ON DELETE SET NULL
+);
+
+-- Chỉ lưu SHA-256 của key, prefix là phần đầu của key để nhận diện trên giao diện
+CREATE TABLE api_keys (
+    key_id VARCHAR PRIMARY KEY,
+    service_account_id VARCHAR NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(32) NOT NULL,
+    key_hash VARCHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    last_used_ip VARCHAR(64) NOT NULL DEFAULT '',
+    revoked_at TIMESTAMP,
+    created_by VARCHAR,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT fk_api_keys_service_account
+        FOREIGN KEY (service_account_id)
+        REFERENCES service_accounts(service_account_id)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE,
+    CONSTRAINT fk_api_keys_created_by
+        FOREIGN KEY (created_by)
+        REFERENCES users(user_id)
+        ON UPDATE CASCADE
+        ON DELETE SET NULL
+);
+
+CREATE INDEX idx_api_keys_service_account ON api_keys(service_account_id);
+
+-- Quyền của từng key, luôn là tập con quyền của người tạo key
+CREATE TABLE api_key_permissions (
+    key_id VARCHAR NOT NULL,
+    permission_key VARCHAR(100) NOT NULL,
+    PRIMARY KEY (key_id, permission_key),
+    CONSTRAINT fk_api_key_permissions_key
+        FOREIGN KEY (key_id)
+        REFERENCES api_keys(key_id)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE,
+    CONSTRAINT fk_api_key_permissions_permission
+        FOREIGN KEY (permission_key)
+        REFERENCES permissions(permission_key)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+
+INSERT INTO permissions (permission_key, description) VALUES
+    ('service_account:manage', 'Quản lý service account và API key');
@@ -0,0 +1,4 @@
+-- +migrate Down
+
+DROP TABLE IF EXISTS user_identities;
+DROP TABLE IF EXISTS oidc_login_states;
@@ -0,0 +1,30 @@
+-- +migrate Up
+
+-- Phiên đăng nhập OIDC đang chờ IdP trả về, chỉ lưu SHA-256 của state
+CREATE TABLE oidc_login_states (
+    state_hash VARCHAR PRIMARY KEY,
+    code_verifier VARCHAR(128) NOT NULL,
+    nonce VARCHAR(128) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_oidc_login_states_expires_at ON oidc_login_states(expires_at);
+
+-- Liên kết tài khoản nội bộ với định danh (issuer, sub) bên IdP
+CREATE TABLE user_identities (
+    issuer VARCHAR NOT NULL,
+    subject VARCHAR NOT NULL,
+    user_id VARCHAR NOT NULL,
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_login_at TIMESTAMP,
+    PRIMARY KEY (issuer, subject),
+    CONSTRAINT fk_user_identities_user
+        FOREIGN KEY (user_id)
+        REFERENCES users(user_id)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+
+CREATE INDEX idx_user_identities_user_id ON user_identities(user_id);
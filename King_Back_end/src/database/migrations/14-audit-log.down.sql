@@ -0,0 +1,4 @@
+-- +migrate Down
+
+DELETE FROM permissions WHERE permission_key = 'audit:read';
+DROP TABLE IF EXISTS audit_log;
@@ -0,0 +1,26 @@
+-- +migrate Up
+
+-- Nhật ký mọi thay đổi trên khu vực, nhà máy, sản phẩm và tài khoản.
+-- Không có khóa ngoại tới users để log vẫn còn sau khi người thực hiện bị xóa.
+CREATE TABLE audit_log (
+    audit_id VARCHAR PRIMARY KEY,
+    actor_id VARCHAR,
+    actor_role VARCHAR(20) NOT NULL DEFAULT '',
+    service_account_id VARCHAR,
+    ip_address VARCHAR(64) NOT NULL DEFAULT '',
+    entity_type VARCHAR(30) NOT NULL,
+    entity_id VARCHAR NOT NULL,
+    action VARCHAR(20) NOT NULL,
+    changes JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_audit_log_entity ON audit_log(entity_type, entity_id, created_at DESC);
+CREATE INDEX idx_audit_log_actor ON audit_log(actor_id, created_at DESC);
+CREATE INDEX idx_audit_log_created_at ON audit_log(created_at DESC);
+
+INSERT INTO permissions (permission_key, description) VALUES
+    ('audit:read', 'Xem nhật ký thay đổi');
+
+INSERT INTO role_permissions (role_user, permission_key) VALUES
+    ('ADMIN', 'audit:read');
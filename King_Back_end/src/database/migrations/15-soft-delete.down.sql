@@ -0,0 +1,11 @@
+-- +migrate Down
+
+DELETE FROM permissions WHERE permission_key = 'trash:purge';
+DROP INDEX IF EXISTS idx_users_deleted_at;
+DROP INDEX IF EXISTS idx_products_deleted_at;
+DROP INDEX IF EXISTS idx_factories_deleted_at;
+DROP INDEX IF EXISTS idx_locations_deleted_at;
+ALTER TABLE users DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE products DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE factories DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE locations DROP COLUMN IF EXISTS deleted_at;
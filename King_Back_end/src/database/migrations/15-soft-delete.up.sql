@@ -0,0 +1,16 @@
+-- +migrate Up
+
+-- Xóa mềm: xóa cha thì con bị đánh dấu cùng một deleted_at để khôi phục đúng những dòng bị xóa theo
+ALTER TABLE locations ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE factories ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE products ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX idx_locations_deleted_at ON locations(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_factories_deleted_at ON factories(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_products_deleted_at ON products(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_users_deleted_at ON users(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Chỉ ROOT (luôn có mọi quyền) được xóa vĩnh viễn
+INSERT INTO permissions (permission_key, description) VALUES
+    ('trash:purge', 'Xóa vĩnh viễn dữ liệu trong thùng rác');
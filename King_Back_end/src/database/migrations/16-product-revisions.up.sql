@@ -0,0 +1,32 @@
+-- +migrate Up
+
+-- Ảnh chụp sản phẩm sau mỗi lần tạo, sửa hoặc khôi phục phiên bản, revision_number tăng dần theo từng sản phẩm.
+-- reverted_from là số phiên bản được dùng để khôi phục, NULL với tạo/sửa thông thường.
+CREATE TABLE product_revisions (
+    revision_id VARCHAR PRIMARY KEY,
+    product_id VARCHAR NOT NULL,
+    revision_number INT NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    image VARCHAR NOT NULL,
+    video VARCHAR,
+    status VARCHAR(50) NOT NULL,
+    year_product DATE NOT NULL,
+    describe_product TEXT NOT NULL,
+    factory_id VARCHAR,
+    actor_id VARCHAR,
+    service_account_id VARCHAR,
+    reverted_from INT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT uq_product_revision UNIQUE (product_id, revision_number),
+    CONSTRAINT fk_product_revision FOREIGN KEY (product_id)
+        REFERENCES products(product_id)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+
+-- Sản phẩm đã có trước bảng này lấy trạng thái hiện tại làm phiên bản 1
+INSERT INTO product_revisions (revision_id, product_id, revision_number, title, image, video, status,
+    year_product, describe_product, factory_id, created_at)
+SELECT gen_random_uuid()::VARCHAR, product_id, 1, title, image, video, status,
+    year_product, describe_product, factory_id, COALESCE(updated_at, created_at, NOW())
+FROM products;
@@ -0,0 +1,11 @@
+-- +migrate Down
+
+-- Chỉ đúng với STORAGE_DRIVER=local, URL tuyệt đối được giữ nguyên
+UPDATE product_revisions SET video = 'uploads/' || video
+WHERE video <> '' AND video NOT LIKE 'http://%' AND video NOT LIKE 'https://%';
+UPDATE product_revisions SET image = 'uploads/' || image
+WHERE image <> '' AND image NOT LIKE 'http://%' AND image NOT LIKE 'https://%';
+UPDATE products SET video = 'uploads/' || video
+WHERE video <> '' AND video NOT LIKE 'http://%' AND video NOT LIKE 'https://%';
+UPDATE products SET image = 'uploads/' || image
+WHERE image <> '' AND image NOT LIKE 'http://%' AND image NOT LIKE 'https://%';
@@ -0,0 +1,8 @@
+-- +migrate Up
+
+-- image/video giờ lưu key trong storage thay vì đường dẫn trên đĩa.
+-- File cũ nằm ở uploads/<tên file> nên key là <tên file> với STORAGE_LOCAL_DIR=uploads.
+UPDATE products SET image = SUBSTRING(image FROM 9) WHERE image LIKE 'uploads/%';
+UPDATE products SET video = SUBSTRING(video FROM 9) WHERE video LIKE 'uploads/%';
+UPDATE product_revisions SET image = SUBSTRING(image FROM 9) WHERE image LIKE 'uploads/%';
+UPDATE product_revisions SET video = SUBSTRING(video FROM 9) WHERE video LIKE 'uploads/%';
@@ -0,0 +1,4 @@
+-- +migrate Down
+
+ALTER TABLE product_revisions DROP COLUMN IF EXISTS image_variants;
+ALTER TABLE products DROP COLUMN IF EXISTS image_variants;
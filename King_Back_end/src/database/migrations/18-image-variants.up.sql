@@ -0,0 +1,6 @@
+-- +migrate Up
+
+-- Biến thể của ảnh sản phẩm (thumb, medium, large, webp...) dạng {"tên": "key trong storage"}.
+-- NULL với ảnh upload trước khi có pipeline xử lý ảnh: API chỉ trả về ảnh gốc, biến thể được tạo khi ảnh được thay mới.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS image_variants JSONB;
+ALTER TABLE product_revisions ADD COLUMN IF NOT EXISTS image_variants JSONB;
@@ -0,0 +1,24 @@
+-- +migrate Up
+
+-- Upload video theo từng phần, phần đang nhận nằm trong file tạm upload_id.part ở UPLOAD_TMP_DIR.
+-- Khi nhận đủ size byte file được chuyển vào storage (storage_key) và chờ gắn vào sản phẩm.
+-- Dòng bị xóa khi đã gắn vào sản phẩm, bị hủy, hoặc quá expires_at (job dọn dẹp).
+CREATE TABLE media_uploads (
+    upload_id VARCHAR PRIMARY KEY,
+    kind VARCHAR(20) NOT NULL,
+    file_name VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    upload_offset BIGINT NOT NULL DEFAULT 0,
+    checksum VARCHAR(64),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    storage_key VARCHAR,
+    content_type VARCHAR(100),
+    user_id VARCHAR,
+    service_account_id VARCHAR,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT chk_media_upload_offset CHECK (upload_offset >= 0 AND upload_offset <= size)
+);
+
+CREATE INDEX idx_media_uploads_expires_at ON media_uploads (expires_at);
@@ -0,0 +1,5 @@
+-- +migrate Down
+
+ALTER TABLE products DROP COLUMN IF EXISTS video;
+ALTER TABLE refresh_tokens RENAME COLUMN token TO token_id;
+ALTER TABLE refresh_tokens RENAME TO refresh_token;
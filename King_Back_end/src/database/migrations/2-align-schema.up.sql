@@ -0,0 +1,16 @@
+-- +migrate Up
+
+-- Đồng bộ tên bảng/cột với refresh_token_repo và product_repo
+ALTER TABLE IF EXISTS refresh_token RENAME TO refresh_tokens;
+
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'refresh_tokens' AND column_name = 'token_id'
+    ) THEN
+        ALTER TABLE refresh_tokens RENAME COLUMN token_id TO token;
+    END IF;
+END $$;
+
+ALTER TABLE products ADD COLUMN IF NOT EXISTS video VARCHAR;
@@ -0,0 +1,3 @@
+-- +migrate Down
+
+DROP TABLE IF EXISTS product_media;
@@ -0,0 +1,33 @@
+-- +migrate Up
+
+-- Gallery ảnh/video của sản phẩm, sort_order là thứ tự hiển thị.
+-- Ảnh chính (is_primary) được chép sang products.image/image_variants để danh sách chỉ cần đọc bảng products.
+CREATE TABLE product_media (
+    media_id VARCHAR PRIMARY KEY,
+    product_id VARCHAR NOT NULL,
+    media_type VARCHAR(20) NOT NULL,
+    storage_key VARCHAR NOT NULL,
+    image_variants JSONB,
+    caption VARCHAR(255),
+    alt_text VARCHAR(255),
+    sort_order INT NOT NULL DEFAULT 0,
+    is_primary BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT chk_product_media_type CHECK (media_type IN ('image', 'video')),
+    CONSTRAINT chk_product_media_primary CHECK (NOT is_primary OR media_type = 'image'),
+    CONSTRAINT fk_product_media FOREIGN KEY (product_id)
+        REFERENCES products(product_id)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+
+CREATE INDEX idx_product_media_product ON product_media (product_id, sort_order);
+CREATE UNIQUE INDEX uq_product_media_primary ON product_media (product_id) WHERE is_primary;
+
+-- Ảnh hiện tại của mỗi sản phẩm trở thành ảnh chính của gallery
+INSERT INTO product_media (media_id, product_id, media_type, storage_key, image_variants, sort_order, is_primary, created_at, updated_at)
+SELECT gen_random_uuid()::VARCHAR, product_id, 'image', image, image_variants, 0, TRUE,
+    COALESCE(created_at, NOW()), COALESCE(updated_at, created_at, NOW())
+FROM products
+WHERE image IS NOT NULL AND image <> '';
@@ -0,0 +1,7 @@
+-- +migrate Down
+
+DROP TRIGGER IF EXISTS trg_products_search_vector ON products;
+DROP FUNCTION IF EXISTS products_search_vector_update();
+DROP INDEX IF EXISTS idx_products_search_vector;
+ALTER TABLE products DROP COLUMN IF EXISTS search_vector;
+DROP TEXT SEARCH CONFIGURATION IF EXISTS vn_unaccent;
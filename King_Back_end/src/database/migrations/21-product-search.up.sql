@@ -0,0 +1,31 @@
+-- +migrate Up
+
+-- Tìm kiếm sản phẩm không phân biệt dấu: cấu hình vn_unaccent tách từ như simple (không có bộ stem tiếng Việt)
+-- rồi bỏ dấu từng từ, nên "cay" khớp "cây" và "dong nai" khớp "Đồng Nai".
+CREATE EXTENSION IF NOT EXISTS unaccent;
+
+CREATE TEXT SEARCH CONFIGURATION vn_unaccent (COPY = simple);
+ALTER TEXT SEARCH CONFIGURATION vn_unaccent
+    ALTER MAPPING FOR hword, hword_part, word WITH unaccent, simple;
+
+-- Tiêu đề (A) được xếp hạng cao hơn mô tả (B)
+ALTER TABLE products ADD COLUMN search_vector TSVECTOR;
+
+CREATE FUNCTION products_search_vector_update() RETURNS trigger AS $$
+BEGIN
+    NEW.search_vector :=
+        setweight(to_tsvector('vn_unaccent', COALESCE(NEW.title, '')), 'A') ||
+        setweight(to_tsvector('vn_unaccent', COALESCE(NEW.describe_product, '')), 'B');
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_products_search_vector
+    BEFORE INSERT OR UPDATE OF title, describe_product ON products
+    FOR EACH ROW EXECUTE FUNCTION products_search_vector_update();
+
+UPDATE products SET search_vector =
+    setweight(to_tsvector('vn_unaccent', COALESCE(title, '')), 'A') ||
+    setweight(to_tsvector('vn_unaccent', COALESCE(describe_product, '')), 'B');
+
+CREATE INDEX idx_products_search_vector ON products USING GIN (search_vector);
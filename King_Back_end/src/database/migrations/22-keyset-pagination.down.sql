@@ -0,0 +1,10 @@
+-- +migrate Down
+
+DROP INDEX IF EXISTS idx_users_keyset;
+DROP INDEX IF EXISTS idx_products_keyset;
+DROP INDEX IF EXISTS idx_factories_keyset;
+DROP INDEX IF EXISTS idx_locations_keyset;
+ALTER TABLE users ALTER COLUMN created_at DROP NOT NULL;
+ALTER TABLE products ALTER COLUMN created_at DROP NOT NULL;
+ALTER TABLE factories ALTER COLUMN created_at DROP NOT NULL;
+ALTER TABLE locations ALTER COLUMN created_at DROP NOT NULL;
@@ -0,0 +1,17 @@
+-- +migrate Up
+
+-- Phân trang cursor đi theo (created_at, khóa chính) nên created_at không được NULL
+UPDATE locations SET created_at = COALESCE(updated_at, NOW()) WHERE created_at IS NULL;
+UPDATE factories SET created_at = COALESCE(updated_at, NOW()) WHERE created_at IS NULL;
+UPDATE products SET created_at = COALESCE(updated_at, NOW()) WHERE created_at IS NULL;
+UPDATE users SET created_at = COALESCE(updated_at, NOW()) WHERE created_at IS NULL;
+
+ALTER TABLE locations ALTER COLUMN created_at SET NOT NULL;
+ALTER TABLE factories ALTER COLUMN created_at SET NOT NULL;
+ALTER TABLE products ALTER COLUMN created_at SET NOT NULL;
+ALTER TABLE users ALTER COLUMN created_at SET NOT NULL;
+
+CREATE INDEX idx_locations_keyset ON locations(created_at DESC, location_id DESC) WHERE deleted_at IS NULL;
+CREATE INDEX idx_factories_keyset ON factories(created_at DESC, factory_id DESC) WHERE deleted_at IS NULL;
+CREATE INDEX idx_products_keyset ON products(created_at DESC, product_id DESC) WHERE deleted_at IS NULL;
+CREATE INDEX idx_users_keyset ON users(created_at DESC, user_id DESC) WHERE deleted_at IS NULL;
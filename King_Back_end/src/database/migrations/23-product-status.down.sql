@@ -0,0 +1,14 @@
+-- +migrate Down
+
+DELETE FROM permissions WHERE permission_key IN ('product:review', 'product:archive');
+DROP TABLE IF EXISTS product_status_history;
+ALTER TABLE products DROP CONSTRAINT IF EXISTS chk_products_status;
+ALTER TABLE products ALTER COLUMN status DROP DEFAULT;
+
+-- Trả lại giá trị cũ cho các sản phẩm chưa đổi trạng thái kể từ lúc migrate up
+UPDATE products AS p SET status = b.status
+FROM product_status_backup AS b
+WHERE b.product_id = p.product_id
+    AND p.status = CASE WHEN LOWER(b.status) IN ('draft', 'in_review', 'published', 'archived')
+        THEN LOWER(b.status) ELSE 'published' END;
+DROP TABLE IF EXISTS product_status_backup;
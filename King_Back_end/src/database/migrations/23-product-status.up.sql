@@ -0,0 +1,50 @@
+-- +migrate Up
+
+-- Lưu giá trị cũ của các dòng sắp bị đổi để migrate down trả lại được
+CREATE TABLE product_status_backup (
+    product_id VARCHAR PRIMARY KEY,
+    status VARCHAR(50) NOT NULL,
+    CONSTRAINT fk_product_status_backup FOREIGN KEY (product_id)
+        REFERENCES products(product_id)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+
+INSERT INTO product_status_backup (product_id, status)
+SELECT product_id, status FROM products
+WHERE status NOT IN ('draft', 'in_review', 'published', 'archived');
+
+-- Trạng thái trước đây là chuỗi tự do: giá trị đã thuộc tập mới thì giữ, còn lại là sản phẩm đang hiển thị nên coi như đã xuất bản
+UPDATE products SET status = LOWER(status) WHERE LOWER(status) IN ('draft', 'in_review', 'published', 'archived');
+UPDATE products SET status = 'published' WHERE status NOT IN ('draft', 'in_review', 'published', 'archived');
+ALTER TABLE products ALTER COLUMN status SET DEFAULT 'draft';
+ALTER TABLE products ADD CONSTRAINT chk_products_status
+    CHECK (status IN ('draft', 'in_review', 'published', 'archived'));
+
+-- Mỗi lần chuyển trạng thái qua POST /product/:product_id/transition
+CREATE TABLE product_status_history (
+    history_id VARCHAR PRIMARY KEY,
+    product_id VARCHAR NOT NULL,
+    from_status VARCHAR(50) NOT NULL,
+    to_status VARCHAR(50) NOT NULL,
+    reason VARCHAR(500),
+    actor_id VARCHAR,
+    actor_role VARCHAR(20) NOT NULL DEFAULT '',
+    service_account_id VARCHAR,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT fk_product_status_history FOREIGN KEY (product_id)
+        REFERENCES products(product_id)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+
+CREATE INDEX idx_product_status_history_product ON product_status_history(product_id, created_at DESC, history_id DESC);
+
+-- Gửi duyệt (draft -> in_review) chỉ cần product:write, các bước còn lại dành cho ADMIN
+INSERT INTO permissions (permission_key, description) VALUES
+    ('product:review', 'Duyệt sản phẩm: xuất bản hoặc trả về bản nháp'),
+    ('product:archive', 'Gỡ xuống, lưu trữ và mở lại sản phẩm đã xuất bản');
+
+INSERT INTO role_permissions (role_user, permission_key) VALUES
+    ('ADMIN', 'product:review'),
+    ('ADMIN', 'product:archive');
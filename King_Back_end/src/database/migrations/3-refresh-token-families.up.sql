@@ -0,0 +1,12 @@
+-- +migrate Up
+
+-- Refresh token cũ được lưu dạng plain text: xóa hết, người dùng đăng nhập lại để nhận token đã băm
+DELETE FROM refresh_tokens;
+
+ALTER TABLE refresh_tokens RENAME COLUMN token TO token_hash;
+ALTER TABLE refresh_tokens ADD COLUMN family_id VARCHAR NOT NULL;
+ALTER TABLE refresh_tokens ADD COLUMN replaced_by VARCHAR;
+ALTER TABLE refresh_tokens ADD COLUMN revoked_at TIMESTAMP;
+
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens(family_id);
+CREATE INDEX idx_refresh_tokens_user_id ON refresh_tokens(user_id);
@@ -0,0 +1,11 @@
+-- +migrate Up
+
+CREATE TABLE jwt_keys (
+    kid VARCHAR PRIMARY KEY,
+    algorithm VARCHAR(20) NOT NULL,
+    private_key TEXT NOT NULL,
+    public_key TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    retire_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
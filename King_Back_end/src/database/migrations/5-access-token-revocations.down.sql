@@ -0,0 +1,3 @@
+-- +migrate Down
+
+DROP TABLE IF EXISTS access_token_revocations;
@@ -0,0 +1,16 @@
+-- +migrate Up
+
+-- Mỗi dòng thu hồi một access token (jti) hoặc mọi access token của user phát hành trước revoked_before
+CREATE TABLE access_token_revocations (
+    revocation_id VARCHAR PRIMARY KEY,
+    jti VARCHAR,
+    user_id VARCHAR NOT NULL,
+    revoked_before TIMESTAMP,
+    reason VARCHAR(50) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT chk_revocation_target CHECK (jti IS NOT NULL OR revoked_before IS NOT NULL)
+);
+
+CREATE UNIQUE INDEX idx_access_token_revocations_jti ON access_token_revocations(jti) WHERE jti IS NOT NULL;
+CREATE INDEX idx_access_token_revocations_expires_at ON access_token_revocations(expires_at);
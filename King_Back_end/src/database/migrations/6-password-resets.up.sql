@@ -0,0 +1,18 @@
+-- +migrate Up
+
+-- Chỉ lưu SHA-256 của token đặt lại mật khẩu, mỗi token dùng được một lần
+CREATE TABLE password_resets (
+    token_hash VARCHAR PRIMARY KEY,
+    user_id VARCHAR NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT fk_password_resets_user
+        FOREIGN KEY (user_id)
+        REFERENCES users(user_id)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+
+CREATE INDEX idx_password_resets_user_id ON password_resets(user_id);
+CREATE INDEX idx_password_resets_expires_at ON password_resets(expires_at);
@@ -0,0 +1,31 @@
+-- +migrate Up
+
+-- Secret TOTP của user; enabled = false khi user mới bắt đầu đăng ký và chưa xác nhận mã đầu tiên
+CREATE TABLE user_mfa (
+    user_id VARCHAR PRIMARY KEY,
+    secret VARCHAR NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    last_used_step BIGINT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    enabled_at TIMESTAMP,
+    CONSTRAINT fk_user_mfa_user
+        FOREIGN KEY (user_id)
+        REFERENCES users(user_id)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+
+-- Mã khôi phục chỉ lưu SHA-256, mỗi mã dùng được một lần
+CREATE TABLE mfa_recovery_codes (
+    code_hash VARCHAR PRIMARY KEY,
+    user_id VARCHAR NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT fk_mfa_recovery_codes_user
+        FOREIGN KEY (user_id)
+        REFERENCES users(user_id)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+
+CREATE INDEX idx_mfa_recovery_codes_user_id ON mfa_recovery_codes(user_id);
@@ -0,0 +1,19 @@
+-- +migrate Up
+
+-- Bộ đếm đăng nhập sai theo tài khoản (account:<email>) và theo IP (ip:<addr>)
+CREATE TABLE login_attempts (
+    attempt_key VARCHAR PRIMARY KEY,
+    kind VARCHAR(20) NOT NULL,
+    user_id VARCHAR,
+    failures INT NOT NULL DEFAULT 0,
+    last_failed_at TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT fk_login_attempts_user
+        FOREIGN KEY (user_id)
+        REFERENCES users(user_id)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+
+CREATE INDEX idx_login_attempts_locked_until ON login_attempts(locked_until);
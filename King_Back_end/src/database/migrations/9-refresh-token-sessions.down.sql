@@ -0,0 +1,10 @@
+-- +migrate Down
+
+DELETE FROM access_token_revocations WHERE jti IS NULL AND revoked_before IS NULL;
+ALTER TABLE access_token_revocations DROP CONSTRAINT IF EXISTS chk_revocation_target;
+ALTER TABLE access_token_revocations ADD CONSTRAINT chk_revocation_target CHECK (jti IS NOT NULL OR revoked_before IS NOT NULL);
+ALTER TABLE access_token_revocations DROP COLUMN IF EXISTS session_id;
+
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS last_used_at;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS ip_address;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS user_agent;
@@ -0,0 +1,14 @@
+-- +migrate Up
+
+-- Mỗi family refresh token là một phiên đăng nhập; lưu thiết bị và lần dùng cuối để user tự quản lý phiên
+ALTER TABLE refresh_tokens ADD COLUMN user_agent VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE refresh_tokens ADD COLUMN ip_address VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE refresh_tokens ADD COLUMN last_used_at TIMESTAMP;
+
+UPDATE refresh_tokens SET last_used_at = created_at WHERE last_used_at IS NULL;
+
+-- Thu hồi mọi access token của một phiên đăng nhập (sid trong token, là family của refresh token)
+ALTER TABLE access_token_revocations ADD COLUMN session_id VARCHAR;
+ALTER TABLE access_token_revocations DROP CONSTRAINT IF EXISTS chk_revocation_target;
+ALTER TABLE access_token_revocations ADD CONSTRAINT chk_revocation_target
+    CHECK (jti IS NOT NULL OR revoked_before IS NOT NULL OR session_id IS NOT NULL);
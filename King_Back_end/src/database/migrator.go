@@ -0,0 +1,267 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"thelastking-blogger.com/src/config/logger"
+	"thelastking-blogger.com/src/database/migrations"
+)
+
+// migrationLockKey là khóa advisory lock của Postgres, giữ cho chỉ một tiến trình chạy migration tại một thời điểm
+const migrationLockKey int64 = 72025001
+
+type Migrator struct {
+	db         *gorm.DB
+	migrations []migrations.Migration
+	log        logger.Logger
+}
+
+// MigrationStatus mô tả trạng thái của một migration trong status
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+type appliedMigration struct {
+	Version   int64     `gorm:"column:version;"`
+	Name      string    `gorm:"column:name;"`
+	AppliedAt time.Time `gorm:"column:applied_at;"`
+}
+
+func NewMigrator(db *gorm.DB) (*Migrator, error) {
+	list, err := migrations.Load()
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		db:         db,
+		migrations: list,
+		log:        logger.GetLogger(),
+	}, nil
+}
+
+// Latest trả về version mới nhất được nhúng trong binary
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up áp dụng tất cả migration chưa chạy
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.To(ctx, m.Latest())
+}
+
+// Down hoàn tác steps migration gần nhất
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	if steps < 1 {
+		return errors.New("steps must be at least 1")
+	}
+	return m.withLock(ctx, func(conn *gorm.DB) error {
+		applied, err := m.applied(conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+			if err := m.revert(conn, mig); err != nil {
+				return err
+			}
+			steps--
+		}
+		return nil
+	})
+}
+
+// To đưa schema về đúng version: áp dụng các migration <= version và hoàn tác các migration > version
+func (m *Migrator) To(ctx context.Context, version int64) error {
+	if version != 0 && !m.known(version) {
+		return fmt.Errorf("unknown migration version %d", version)
+	}
+	return m.withLock(ctx, func(conn *gorm.DB) error {
+		applied, err := m.applied(conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; ok && mig.Version > version {
+				if err := m.revert(conn, mig); err != nil {
+					return err
+				}
+			}
+		}
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; !ok && mig.Version <= version {
+				if err := m.apply(conn, mig); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Status liệt kê các migration được nhúng cùng trạng thái đã áp dụng hay chưa
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var result []MigrationStatus
+	err := m.withLock(ctx, func(conn *gorm.DB) error {
+		applied, err := m.applied(conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			status := MigrationStatus{Version: mig.Version, Name: mig.Name}
+			if row, ok := applied[mig.Version]; ok {
+				appliedAt := row.AppliedAt
+				status.Applied = true
+				status.AppliedAt = &appliedAt
+			}
+			result = append(result, status)
+		}
+		for version, row := range applied {
+			if !m.known(version) {
+				m.log.Warnf("Database has migration %d-%s which is not embedded in this binary", version, row.Name)
+			}
+		}
+		return nil
+	})
+	return result, err
+}
+
+// Pending trả về số migration chưa được áp dụng
+func (m *Migrator) Pending(ctx context.Context) (int, error) {
+	status, err := m.Status(ctx)
+	if err != nil {
+		return 0, err
+	}
+	pending := 0
+	for _, s := range status {
+		if !s.Applied {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+func (m *Migrator) known(version int64) bool {
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// withLock giữ advisory lock trên một kết nối riêng trong suốt quá trình migration
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *gorm.DB) error) error {
+	return m.db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("SELECT pg_advisory_lock(?)", migrationLockKey).Error; err != nil {
+			return fmt.Errorf("acquire migration lock: %w", err)
+		}
+		defer func() {
+			if err := conn.Exec("SELECT pg_advisory_unlock(?)", migrationLockKey).Error; err != nil {
+				m.log.Errorf("Failed to release migration lock: %v", err)
+			}
+		}()
+
+		if err := m.ensureTable(conn); err != nil {
+			return err
+		}
+		return fn(conn)
+	})
+}
+
+func (m *Migrator) ensureTable(conn *gorm.DB) error {
+	if err := conn.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name VARCHAR NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT NOW()
+	)`).Error; err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	// Database được tạo thủ công từ 1-init.up.sql trước khi có migrator: đánh dấu version 1 là đã chạy
+	var count int64
+	if err := conn.Table("schema_migrations").Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 || len(m.migrations) == 0 {
+		return nil
+	}
+	var legacy bool
+	if err := conn.Raw(`SELECT EXISTS (
+		SELECT 1 FROM information_schema.tables
+		WHERE table_schema = current_schema() AND table_name = 'locations'
+	)`).Scan(&legacy).Error; err != nil {
+		return err
+	}
+	if legacy {
+		first := m.migrations[0]
+		m.log.Warnf("Existing schema without schema_migrations found, baselining at %d-%s", first.Version, first.Name)
+		return conn.Table("schema_migrations").Create(&appliedMigration{
+			Version:   first.Version,
+			Name:      first.Name,
+			AppliedAt: time.Now().UTC(),
+		}).Error
+	}
+	return nil
+}
+
+func (m *Migrator) applied(conn *gorm.DB) (map[int64]appliedMigration, error) {
+	var rows []appliedMigration
+	if err := conn.Table("schema_migrations").Order("version").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	result := make(map[int64]appliedMigration, len(rows))
+	for _, row := range rows {
+		result[row.Version] = row
+	}
+	return result, nil
+}
+
+func (m *Migrator) apply(conn *gorm.DB, mig migrations.Migration) error {
+	m.log.Infof("Applying migration %d-%s", mig.Version, mig.Name)
+	err := conn.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(mig.Up).Error; err != nil {
+			return err
+		}
+		return tx.Table("schema_migrations").Create(&appliedMigration{
+			Version:   mig.Version,
+			Name:      mig.Name,
+			AppliedAt: time.Now().UTC(),
+		}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migration %d-%s up: %w", mig.Version, mig.Name, err)
+	}
+	return nil
+}
+
+func (m *Migrator) revert(conn *gorm.DB, mig migrations.Migration) error {
+	if mig.Down == "" {
+		return fmt.Errorf("migration %d-%s has no down script", mig.Version, mig.Name)
+	}
+	m.log.Infof("Reverting migration %d-%s", mig.Version, mig.Name)
+	err := conn.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(mig.Down).Error; err != nil {
+			return err
+		}
+		return tx.Exec("DELETE FROM schema_migrations WHERE version = ?", mig.Version).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migration %d-%s down: %w", mig.Version, mig.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// expectedSchema liệt kê các bảng và cột mà repository đang truy vấn.
+// Khi thêm migration mới mà repository dùng tới cột mới thì cập nhật danh sách này.
+var expectedSchema = map[string][]string{
+	"locations":                {"location_id", "name_local", "created_at", "updated_at", "deleted_at"},
+	"factories":                {"factory_id", "name_factory", "location_id", "created_at", "updated_at", "deleted_at"},
+	"products":                 {"product_id", "title", "image", "video", "status", "year_product", "describe_product", "factory_id", "created_at", "updated_at", "deleted_at", "image_variants", "search_vector"},
+	"users":                    {"user_id", "full_name", "account", "password_user", "tag", "role_user", "created_at", "updated_at", "deleted_at"},
+	"access_token_revocations": {"revocation_id", "jti", "user_id", "revoked_before", "session_id", "reason", "expires_at", "created_at"},
+	"password_resets":          {"token_hash", "user_id", "expires_at", "used_at", "created_at"},
+	"user_mfa":                 {"user_id", "secret", "enabled", "last_used_step", "created_at", "enabled_at"},
+	"mfa_recovery_codes":       {"code_hash", "user_id", "used_at", "created_at"},
+	"login_attempts":           {"attempt_key", "kind", "user_id", "failures", "last_failed_at", "locked_until", "updated_at"},
+	"permissions":              {"permission_key", "description", "created_at"},
+	"role_permissions":         {"role_user", "permission_key", "created_at"},
+	"user_location_scopes":     {"user_id", "location_id", "created_at"},
+	"user_factory_scopes":      {"user_id", "factory_id", "created_at"},
+	"service_accounts":         {"service_account_id", "name", "description", "created_by", "created_at", "updated_at"},
+	"api_keys":                 {"key_id", "service_account_id", "name", "prefix", "key_hash", "expires_at", "last_used_at", "last_used_ip", "revoked_at", "created_by", "created_at"},
+	"api_key_permissions":      {"key_id", "permission_key"},
+	"oidc_login_states":        {"state_hash", "code_verifier", "nonce", "expires_at", "created_at"},
+	"user_identities":          {"issuer", "subject", "user_id", "email", "created_at", "last_login_at"},
+	"product_revisions":        {"revision_id", "product_id", "revision_number", "title", "image", "video", "status", "year_product", "describe_product", "factory_id", "actor_id", "service_account_id", "reverted_from", "created_at", "image_variants"},
+	"media_uploads":            {"upload_id", "kind", "file_name", "size", "upload_offset", "checksum", "status", "storage_key", "content_type", "user_id", "service_account_id", "expires_at", "created_at", "updated_at"},
+	"product_media":            {"media_id", "product_id", "media_type", "storage_key", "image_variants", "caption", "alt_text", "sort_order", "is_primary", "created_at", "updated_at"},
+	"product_status_history":   {"history_id", "product_id", "from_status", "to_status", "reason", "actor_id", "actor_role", "service_account_id", "created_at"},
+	"audit_log":                {"audit_id", "actor_id", "actor_role", "service_account_id", "ip_address", "entity_type", "entity_id", "action", "changes", "created_at"},
+	"jwt_keys":                 {"kid", "algorithm", "private_key", "public_key", "created_at", "retire_at", "expires_at"},
+	"refresh_tokens":           {"token_hash", "family_id", "user_id", "expires_at", "revoked", "replaced_by", "revoked_at", "created_at", "user_agent", "ip_address", "last_used_at"},
+}
+
+type schemaColumn struct {
+	TableName  string `gorm:"column:table_name;"`
+	ColumnName string `gorm:"column:column_name;"`
+}
+
+// VerifySchema so sánh schema thực tế với expectedSchema và trả về lỗi liệt kê các bảng/cột còn thiếu
+func VerifySchema(ctx context.Context, db *gorm.DB) error {
+	tables := make([]string, 0, len(expectedSchema))
+	for table := range expectedSchema {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	var rows []schemaColumn
+	if err := db.WithContext(ctx).Raw(`SELECT table_name, column_name FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name IN ?`, tables).Scan(&rows).Error; err != nil {
+		return fmt.Errorf("read information_schema: %w", err)
+	}
+
+	actual := make(map[string]map[string]bool)
+	for _, row := range rows {
+		if actual[row.TableName] == nil {
+			actual[row.TableName] = make(map[string]bool)
+		}
+		actual[row.TableName][row.ColumnName] = true
+	}
+
+	var problems []string
+	for _, table := range tables {
+		columns, ok := actual[table]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("missing table %s", table))
+			continue
+		}
+		for _, column := range expectedSchema[table] {
+			if !columns[column] {
+				problems = append(problems, fmt.Sprintf("missing column %s.%s", table, column))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("database schema does not match repositories: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
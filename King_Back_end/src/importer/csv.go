@@ -0,0 +1,54 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+
+	"thelastking-blogger.com/src/module"
+)
+
+// readCSV đọc toàn bộ file CSV. Excel ở máy dùng locale Việt Nam lưu CSV ngăn cách bằng dấu chấm phẩy
+// nên dấu ngăn cách được đoán từ dòng tiêu đề. Quá maxRows dòng dữ liệu thì dừng đọc và trả về ErrImportTooLarge.
+func readCSV(r io.Reader, maxRows int) (table, error) {
+	br := bufio.NewReader(r)
+	reader := csv.NewReader(br)
+	reader.Comma = delimiter(br)
+	reader.FieldsPerRecord = -1
+
+	var t table
+	rows := 0
+	for {
+		cells, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return t, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", module.ErrImportFormat, err)
+		}
+		if len(t) > 0 && !blank(cells) {
+			if rows++; rows > maxRows {
+				return nil, fmt.Errorf("%w: more than %d rows", module.ErrImportTooLarge, maxRows)
+			}
+		}
+		line, _ := reader.FieldPos(0)
+		t = append(t, record{line: line, cells: cells})
+	}
+}
+
+// delimiter chọn dấu ngăn cách xuất hiện trong dòng đầu, mặc định là dấu phẩy
+func delimiter(br *bufio.Reader) rune {
+	head, _ := br.Peek(4096)
+	if i := bytes.IndexByte(head, '\n'); i >= 0 {
+		head = head[:i]
+	}
+	for _, comma := range []byte{',', ';', '\t'} {
+		if bytes.IndexByte(head, comma) >= 0 {
+			return rune(comma)
+		}
+	}
+	return ','
+}
@@ -0,0 +1,265 @@
+package importer
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"thelastking-blogger.com/src/module"
+	"thelastking-blogger.com/src/module/req_users"
+)
+
+// Row là một dòng dữ liệu đã đọc, Errors là lỗi đọc ô (ngày sai định dạng...) trước khi kiểm tra nghiệp vụ
+type Row[T any] struct {
+	Line   int
+	Input  T
+	Errors []string
+}
+
+// File là nội dung file nhập theo từng loại, khi commit được tạo theo thứ tự khu vực -> nhà máy -> sản phẩm
+type File struct {
+	Locations []Row[module.Locations]
+	Factories []Row[req_users.FactoriesInput]
+	Products  []Row[req_users.ProductInput]
+}
+
+// Len là tổng số dòng dữ liệu của mọi loại
+func (f *File) Len() int {
+	return len(f.Locations) + len(f.Factories) + len(f.Products)
+}
+
+// columns là các cột của từng loại, đặt tên theo tag json của Locations/FactoriesInput/ProductInput.
+// true là cột bắt buộc phải có trong dòng tiêu đề.
+var columns = map[string]map[string]bool{
+	module.ImportEntityLocation: {"name_local": true},
+	module.ImportEntityFactory:  {"name_factory": true, "name_local": true},
+	module.ImportEntityProduct: {
+		"title":            true,
+		"image":            true, // key trong storage hoặc URL tuyệt đối
+		"year_product":     true,
+		"describe_product": true,
+		"name_factory":     true,
+		"video":            false,
+		"status":           false, // bỏ trống hoặc draft, sản phẩm mới luôn là bản nháp
+	},
+}
+
+// record là một dòng của bảng, line là số dòng trong file để báo lỗi
+type record struct {
+	line  int
+	cells []string
+}
+
+// table là các dòng của một sheet hoặc file CSV, dòng đầu là tiêu đề
+type table []record
+
+// Read đọc file CSV hoặc XLSX theo đuôi tên file.
+// XLSX lấy các sheet tên locations, factories, products; CSV chỉ chứa một loại, lấy theo entity hoặc đoán từ dòng tiêu đề.
+// entity khác rỗng thì chỉ đọc loại đó. Một bảng có quá maxRows dòng dữ liệu thì dừng đọc ngay với ErrImportTooLarge.
+func Read(name string, r io.ReaderAt, size int64, entity string, maxRows int) (*File, error) {
+	if entity != "" && columns[entity] == nil {
+		return nil, fmt.Errorf("%w: %q", module.ErrImportEntity, entity)
+	}
+	var tables map[string]table
+	switch strings.ToLower(path.Ext(name)) {
+	case ".csv":
+		t, err := readCSV(io.NewSectionReader(r, 0, size), maxRows)
+		if err != nil {
+			return nil, err
+		}
+		if entity == "" && len(t) > 0 {
+			if entity = guessEntity(t[0].cells); entity == "" {
+				return nil, fmt.Errorf("%w: cannot detect entity from the CSV header", module.ErrImportEntity)
+			}
+		}
+		tables = map[string]table{entity: t}
+	case ".xlsx":
+		sheets, err := readXLSX(r, size, maxRows)
+		if err != nil {
+			return nil, err
+		}
+		tables = sheets
+		if entity != "" {
+			tables = map[string]table{entity: sheets[entity]}
+		}
+	default:
+		return nil, module.ErrImportFormat
+	}
+
+	file := &File{}
+	for _, entity := range []string{module.ImportEntityLocation, module.ImportEntityFactory, module.ImportEntityProduct} {
+		if err := file.add(entity, tables[entity]); err != nil {
+			return nil, err
+		}
+	}
+	return file, nil
+}
+
+// add chuyển các dòng của một bảng thành Row, dòng trống bị bỏ qua
+func (f *File) add(entity string, t table) error {
+	if len(t) == 0 {
+		return nil
+	}
+	index, err := header(entity, t[0].cells)
+	if err != nil {
+		return err
+	}
+	for _, rec := range t[1:] {
+		if blank(rec.cells) {
+			continue
+		}
+		get := func(column string) string {
+			i, ok := index[column]
+			if !ok || i >= len(rec.cells) {
+				return ""
+			}
+			return strings.TrimSpace(rec.cells[i])
+		}
+		switch entity {
+		case module.ImportEntityLocation:
+			f.Locations = append(f.Locations, Row[module.Locations]{
+				Line:  rec.line,
+				Input: module.Locations{NameLocal: optional(get("name_local"))},
+			})
+		case module.ImportEntityFactory:
+			f.Factories = append(f.Factories, Row[req_users.FactoriesInput]{
+				Line: rec.line,
+				Input: req_users.FactoriesInput{
+					NameFactory: optional(get("name_factory")),
+					NameLocal:   optional(get("name_local")),
+				},
+			})
+		case module.ImportEntityProduct:
+			row := Row[req_users.ProductInput]{
+				Line: rec.line,
+				Input: req_users.ProductInput{
+					Title:       optional(get("title")),
+					Image:       optional(get("image")),
+					Video:       optional(get("video")),
+					Status:      optional(get("status")),
+					Describe:    get("describe_product"),
+					NameFactory: optional(get("name_factory")),
+				},
+			}
+			if value := get("year_product"); value != "" {
+				if year, err := parseYear(value); err != nil {
+					row.Errors = append(row.Errors, "year_product: "+err.Error())
+				} else {
+					row.Input.Year = year
+				}
+			}
+			f.Products = append(f.Products, row)
+		}
+	}
+	return nil
+}
+
+// header đối chiếu dòng tiêu đề với columns và trả về vị trí của từng cột
+func header(entity string, cells []string) (map[string]int, error) {
+	index := make(map[string]int)
+	var unknown []string
+	for i, cell := range cells {
+		name := normalize(cell)
+		if name == "" {
+			continue
+		}
+		if _, ok := columns[entity][name]; !ok {
+			unknown = append(unknown, cell)
+			continue
+		}
+		if _, ok := index[name]; ok {
+			return nil, fmt.Errorf("%w: %s: duplicate column %q", module.ErrImportColumns, entity, name)
+		}
+		index[name] = i
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("%w: %s: unknown columns %q", module.ErrImportColumns, entity, unknown)
+	}
+	var missing []string
+	for name, required := range columns[entity] {
+		if _, ok := index[name]; required && !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		slices.Sort(missing)
+		return nil, fmt.Errorf("%w: %s: missing columns %q", module.ErrImportColumns, entity, missing)
+	}
+	return index, nil
+}
+
+// guessEntity đoán loại dữ liệu của file CSV theo cột đặc trưng của từng loại
+func guessEntity(cells []string) string {
+	names := make(map[string]bool, len(cells))
+	for _, cell := range cells {
+		names[normalize(cell)] = true
+	}
+	switch {
+	case names["title"]:
+		return module.ImportEntityProduct
+	case names["name_factory"]:
+		return module.ImportEntityFactory
+	case names["name_local"]:
+		return module.ImportEntityLocation
+	}
+	return ""
+}
+
+// normalize cho phép tiêu đề viết hoa hoặc dùng dấu cách thay cho gạch dưới ("Name Local")
+func normalize(cell string) string {
+	cell = strings.TrimSpace(strings.TrimPrefix(cell, "\ufeff"))
+	return strings.ReplaceAll(strings.ToLower(cell), " ", "_")
+}
+
+func blank(cells []string) bool {
+	for _, cell := range cells {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// optional: ô trống là nil để validator báo thiếu trường bắt buộc
+func optional(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// yearLayouts là các định dạng ngày được nhận, ngày/tháng/năm theo cách viết của Việt Nam
+var yearLayouts = []string{time.RFC3339, "2006-01-02", "02/01/2006", "2006"}
+
+// excelEpoch là mốc của ngày dạng số trong XLSX (ô định dạng ngày lưu số ngày kể từ mốc này)
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// parseYear đọc year_product. Số có 4 chữ số là năm, số khác là ngày dạng số của XLSX.
+func parseYear(value string) (*time.Time, error) {
+	for _, layout := range yearLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return &t, nil
+		}
+	}
+	if serial, err := strconv.ParseFloat(value, 64); err == nil && serial >= 1 && serial < 2958466 {
+		t := excelEpoch.AddDate(0, 0, int(serial))
+		return &t, nil
+	}
+	return nil, fmt.Errorf("invalid date %q, expected YYYY-MM-DD, DD/MM/YYYY or YYYY", value)
+}
+
+// Throttle gọi fn không quá một lần mỗi interval, lần cuối của mỗi giai đoạn (Done == Total) luôn được gọi
+func Throttle(interval time.Duration, fn func(module.ImportProgress)) func(module.ImportProgress) {
+	var last time.Time
+	return func(p module.ImportProgress) {
+		if p.Done < p.Total && time.Since(last) < interval {
+			return
+		}
+		last = time.Now()
+		fn(p)
+	}
+}
@@ -0,0 +1,281 @@
+package importer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"thelastking-blogger.com/src/module"
+)
+
+func TestParseYear(t *testing.T) {
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{value: "2020", want: day(2020, 1, 1)},
+		{value: "2020-06-15", want: day(2020, 6, 15)},
+		{value: "15/06/2020", want: day(2020, 6, 15)},
+		{value: "2020-06-15T00:00:00Z", want: day(2020, 6, 15)},
+		// Ô ngày trong XLSX được đọc dưới dạng số ngày kể từ 1899-12-30
+		{value: "44927", want: day(2023, 1, 1)},
+		{value: "44927.75", want: day(2023, 1, 1)},
+		{value: "1", want: day(1899, 12, 31)},
+		{value: "2958465", want: day(9999, 12, 31)},
+		{value: "0", wantErr: true},
+		{value: "-5", wantErr: true},
+		{value: "2958466", wantErr: true},
+		{value: "06/15/2020", wantErr: true},
+		{value: "2020-02-30", wantErr: true},
+		{value: "năm 2020", wantErr: true},
+		{value: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseYear(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseYear(%q) = %v, want error", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseYear(%q) error: %v", tt.value, err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("parseYear(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		entity  string
+		cells   []string
+		want    map[string]int
+		wantErr bool
+	}{
+		{
+			name:   "exact names",
+			entity: module.ImportEntityFactory,
+			cells:  []string{"name_factory", "name_local"},
+			want:   map[string]int{"name_factory": 0, "name_local": 1},
+		},
+		{
+			name:   "case, spaces, BOM and empty columns",
+			entity: module.ImportEntityFactory,
+			cells:  []string{"\ufeffName Local", "", " NAME_FACTORY "},
+			want:   map[string]int{"name_local": 0, "name_factory": 2},
+		},
+		{
+			name:   "optional columns may be missing",
+			entity: module.ImportEntityProduct,
+			cells:  []string{"title", "image", "year_product", "describe_product", "name_factory"},
+			want:   map[string]int{"title": 0, "image": 1, "year_product": 2, "describe_product": 3, "name_factory": 4},
+		},
+		{
+			name:    "missing required column",
+			entity:  module.ImportEntityFactory,
+			cells:   []string{"name_factory"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown column",
+			entity:  module.ImportEntityLocation,
+			cells:   []string{"name_local", "owner"},
+			wantErr: true,
+		},
+		{
+			name:    "duplicate column",
+			entity:  module.ImportEntityLocation,
+			cells:   []string{"name_local", "Name Local"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := header(tt.entity, tt.cells)
+			if tt.wantErr {
+				if !errors.Is(err, module.ErrImportColumns) {
+					t.Errorf("error = %v, want ErrImportColumns", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("header() = %v, want %v", got, tt.want)
+			}
+			for name, i := range tt.want {
+				if got[name] != i {
+					t.Errorf("header()[%s] = %d, want %d", name, got[name], i)
+				}
+			}
+		})
+	}
+}
+
+func TestGuessEntity(t *testing.T) {
+	tests := []struct {
+		cells []string
+		want  string
+	}{
+		{[]string{"Title", "image", "name_factory"}, module.ImportEntityProduct},
+		{[]string{"name_factory", "name_local"}, module.ImportEntityFactory},
+		{[]string{"Name Local"}, module.ImportEntityLocation},
+		{[]string{"owner"}, ""},
+	}
+	for _, tt := range tests {
+		if got := guessEntity(tt.cells); got != tt.want {
+			t.Errorf("guessEntity(%v) = %q, want %q", tt.cells, got, tt.want)
+		}
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	tests := []struct {
+		name          string
+		entity        string
+		content       string
+		wantLocations []string
+		wantFactories [][2]string
+		wantErr       error
+	}{
+		{
+			name:          "comma, entity guessed from header",
+			content:       "name_local\nHà Nội\n\n  Đà Nẵng  \n",
+			wantLocations: []string{"Hà Nội", "Đà Nẵng"},
+		},
+		{
+			name:          "semicolon from Vietnamese Excel",
+			content:       "\ufeffName Factory;Name Local\r\nNhà máy A;Hà Nội\r\n;;\r\n",
+			wantFactories: [][2]string{{"Nhà máy A", "Hà Nội"}},
+		},
+		{
+			name:          "tab separated with quoted comma",
+			entity:        module.ImportEntityFactory,
+			content:       "name_factory\tname_local\n\"A, B\"\tHuế\n",
+			wantFactories: [][2]string{{"A, B", "Huế"}},
+		},
+		{
+			name:          "short row leaves missing cells empty",
+			content:       "name_factory,name_local\nNhà máy C\n",
+			wantFactories: [][2]string{{"Nhà máy C", ""}},
+		},
+		{
+			name:    "header only",
+			content: "name_local\n",
+		},
+		{
+			name:    "unknown entity in header",
+			content: "owner\nx\n",
+			wantErr: module.ErrImportEntity,
+		},
+		{
+			name:    "entity parameter must be known",
+			entity:  "users",
+			content: "name_local\nx\n",
+			wantErr: module.ErrImportEntity,
+		},
+		{
+			name:    "header does not match entity",
+			entity:  module.ImportEntityProduct,
+			content: "name_local\nx\n",
+			wantErr: module.ErrImportColumns,
+		},
+		{
+			name:    "broken quotes",
+			content: "name_local\n\"unterminated\n",
+			wantErr: module.ErrImportFormat,
+		},
+		{
+			name:    "more than maxRows data rows",
+			content: "name_local\na\nb\nc\nd\n",
+			wantErr: module.ErrImportTooLarge,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := Read("data.CSV", strings.NewReader(tt.content), int64(len(tt.content)), tt.entity, 3)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var locations []string
+			for _, row := range file.Locations {
+				locations = append(locations, deref(row.Input.NameLocal))
+			}
+			if strings.Join(locations, "|") != strings.Join(tt.wantLocations, "|") {
+				t.Errorf("locations = %q, want %q", locations, tt.wantLocations)
+			}
+			if len(file.Factories) != len(tt.wantFactories) {
+				t.Fatalf("got %d factories, want %d", len(file.Factories), len(tt.wantFactories))
+			}
+			for i, row := range file.Factories {
+				got := [2]string{deref(row.Input.NameFactory), deref(row.Input.NameLocal)}
+				if got != tt.wantFactories[i] {
+					t.Errorf("factory %d = %q, want %q", i, got, tt.wantFactories[i])
+				}
+			}
+		})
+	}
+}
+
+func TestReadCSVProducts(t *testing.T) {
+	content := "title,image,year_product,describe_product,name_factory,status\n" +
+		"Bàn gỗ,products/ban.jpg,15/06/2020,Mô tả,Nhà máy A,\n" +
+		"Ghế,products/ghe.jpg,giữa năm,Mô tả,Nhà máy A,draft\n"
+	file, err := Read("products.csv", strings.NewReader(content), int64(len(content)), "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(file.Products) != 2 || file.Len() != 2 {
+		t.Fatalf("got %d products, want 2", len(file.Products))
+	}
+
+	first := file.Products[0]
+	if first.Line != 2 || len(first.Errors) != 0 {
+		t.Errorf("first row line=%d errors=%v", first.Line, first.Errors)
+	}
+	if deref(first.Input.Title) != "Bàn gỗ" || first.Input.Describe != "Mô tả" || first.Input.Status != nil {
+		t.Errorf("first row input = %+v", first.Input)
+	}
+	if first.Input.Year == nil || !first.Input.Year.Equal(time.Date(2020, 6, 15, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("first row year = %v", first.Input.Year)
+	}
+
+	second := file.Products[1]
+	if second.Line != 3 || len(second.Errors) != 1 || !strings.HasPrefix(second.Errors[0], "year_product:") {
+		t.Errorf("second row line=%d errors=%v", second.Line, second.Errors)
+	}
+	if deref(second.Input.Status) != "draft" || second.Input.Year != nil {
+		t.Errorf("second row input = %+v", second.Input)
+	}
+}
+
+func TestReadUnknownExtension(t *testing.T) {
+	content := "name_local\nx\n"
+	if _, err := Read("data.txt", strings.NewReader(content), int64(len(content)), "", 10); !errors.Is(err, module.ErrImportFormat) {
+		t.Errorf("error = %v, want ErrImportFormat", err)
+	}
+}
+
+func deref(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
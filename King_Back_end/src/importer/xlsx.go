@@ -0,0 +1,251 @@
+package importer
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	"thelastking-blogger.com/src/module"
+)
+
+// maxPartSize giới hạn kích thước sau giải nén của mỗi file XML trong XLSX để chặn zip bomb
+const maxPartSize = 64 << 20
+
+// Chỉ đọc các phần cần thiết của định dạng SpreadsheetML: danh sách sheet, chuỗi dùng chung và giá trị ô.
+// Định dạng ô bị bỏ qua nên ngày được đọc dưới dạng số ngày (xem parseYear).
+type xlsxWorkbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		RID  string `xml:"id,attr"` // r:id, khớp cả namespace transitional lẫn strict
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Items []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// xlsxText là chuỗi thường (<t>) hoặc chuỗi nhiều định dạng (các <r><t>)
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.T
+	}
+	var b strings.Builder
+	for _, run := range t.Runs {
+		b.WriteString(run.T)
+	}
+	return b.String()
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+// xlsxRow là một <row> trong sheetData, sheet được đọc lần lượt từng dòng thay vì giải mã cả file
+type xlsxRow struct {
+	R     int `xml:"r,attr"`
+	Cells []struct {
+		Ref    string   `xml:"r,attr"`
+		Type   string   `xml:"t,attr"`
+		Value  string   `xml:"v"`
+		Inline xlsxText `xml:"is"`
+	} `xml:"c"`
+}
+
+// readXLSX trả về các sheet có tên là một loại dữ liệu (locations, factories, products), không phân biệt hoa thường.
+// Sheet có quá maxRows dòng dữ liệu thì dừng đọc và trả về ErrImportTooLarge.
+func readXLSX(r io.ReaderAt, size int64, maxRows int) (map[string]table, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", module.ErrImportFormat, err)
+	}
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+
+	var workbook xlsxWorkbook
+	if err := decodePart(files, "xl/workbook.xml", &workbook); err != nil {
+		return nil, err
+	}
+	var rels xlsxRelationships
+	if err := decodePart(files, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+	targets := make(map[string]string, len(rels.Items))
+	for _, rel := range rels.Items {
+		if strings.HasPrefix(rel.Target, "/") {
+			targets[rel.ID] = strings.TrimPrefix(rel.Target, "/")
+		} else {
+			targets[rel.ID] = path.Join("xl", rel.Target)
+		}
+	}
+	// File chỉ có số hoặc chuỗi inline thì không có sharedStrings.xml
+	var shared xlsxSharedStrings
+	if files["xl/sharedStrings.xml"] != nil {
+		if err := decodePart(files, "xl/sharedStrings.xml", &shared); err != nil {
+			return nil, err
+		}
+	}
+
+	tables := make(map[string]table)
+	for _, sheet := range workbook.Sheets {
+		entity := strings.ToLower(strings.TrimSpace(sheet.Name))
+		if columns[entity] == nil {
+			continue
+		}
+		if _, ok := tables[entity]; ok {
+			return nil, fmt.Errorf("%w: duplicate sheet %q", module.ErrImportFormat, sheet.Name)
+		}
+		rc, err := openPart(files, targets[sheet.RID])
+		if err != nil {
+			return nil, err
+		}
+		t, err := sheetTable(rc, shared.Items, maxRows)
+		rc.Close()
+		if errors.Is(err, module.ErrImportTooLarge) {
+			return nil, fmt.Errorf("%w: sheet %q has more than %d rows", err, sheet.Name, maxRows)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: sheet %q: %v", module.ErrImportFormat, sheet.Name, err)
+		}
+		tables[entity] = t
+	}
+	return tables, nil
+}
+
+// sheetTable chuyển các ô về dạng bảng. Dòng và ô trống không được lưu trong XLSX nên vị trí lấy theo thuộc tính r.
+// Chỉ giữ các cột có tiêu đề, ô nằm ngoài các cột đó bị bỏ nên một ô lạ ở cột XFD không làm phình mọi dòng.
+func sheetTable(r io.Reader, shared []xlsxText, maxRows int) (table, error) {
+	decoder := xml.NewDecoder(r)
+	var t table
+	var pos map[int]int // cột trong sheet -> vị trí trong record, lấy theo dòng tiêu đề
+	line, rows := 0, 0
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return t, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "row" {
+			continue
+		}
+		var row xlsxRow
+		if err := decoder.DecodeElement(&row, &start); err != nil {
+			return nil, err
+		}
+		line++
+		if row.R > 0 {
+			line = row.R
+		}
+
+		// Dòng đầu là tiêu đề, ô tiêu đề trống không tạo cột
+		header := pos == nil
+		var cells []string
+		if header {
+			pos = make(map[int]int)
+		} else {
+			cells = make([]string, len(pos))
+		}
+		for i, cell := range row.Cells {
+			col := i
+			if cell.Ref != "" {
+				if col, err = column(cell.Ref); err != nil {
+					return nil, err
+				}
+			}
+			var value string
+			switch cell.Type {
+			case "s":
+				n, err := strconv.Atoi(cell.Value)
+				if err != nil || n < 0 || n >= len(shared) {
+					return nil, fmt.Errorf("cell %s: invalid shared string %q", cell.Ref, cell.Value)
+				}
+				value = shared[n].String()
+			case "inlineStr":
+				value = cell.Inline.String()
+			default:
+				value = cell.Value
+			}
+			switch j, ok := pos[col]; {
+			case header && strings.TrimSpace(value) != "":
+				pos[col] = len(cells)
+				cells = append(cells, value)
+			case !header && ok:
+				cells[j] = value
+			}
+		}
+		if !header && !blank(cells) {
+			if rows++; rows > maxRows {
+				return nil, module.ErrImportTooLarge
+			}
+		}
+		t = append(t, record{line: line, cells: cells})
+	}
+}
+
+// column đổi tham chiếu ô như "C12" thành chỉ số cột bắt đầu từ 0
+func column(ref string) (int, error) {
+	col := 0
+	n := 0
+	for _, ch := range ref {
+		if ch < 'A' || ch > 'Z' {
+			break
+		}
+		col = col*26 + int(ch-'A'+1)
+		n++
+	}
+	// Excel có tối đa 16384 cột (XFD)
+	if n == 0 || col > 16384 {
+		return 0, fmt.Errorf("invalid cell reference %q", ref)
+	}
+	return col - 1, nil
+}
+
+// openPart mở một file trong XLSX, phần đọc được giới hạn ở maxPartSize
+func openPart(files map[string]*zip.File, name string) (io.ReadCloser, error) {
+	f := files[name]
+	if f == nil {
+		return nil, fmt.Errorf("%w: missing %s", module.ErrImportFormat, name)
+	}
+	if f.UncompressedSize64 > maxPartSize {
+		return nil, fmt.Errorf("%w: %s is too large", module.ErrImportFormat, name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", module.ErrImportFormat, err)
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(rc, maxPartSize), rc}, nil
+}
+
+func decodePart(files map[string]*zip.File, name string, v any) error {
+	rc, err := openPart(files, name)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if err := xml.NewDecoder(rc).Decode(v); err != nil {
+		return fmt.Errorf("%w: %s: %v", module.ErrImportFormat, name, err)
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"thelastking-blogger.com/src/module"
+)
+
+type testSheet struct {
+	name string
+	rows string // nội dung của <sheetData>
+}
+
+// buildXLSX tạo file XLSX tối thiểu gồm workbook, quan hệ, chuỗi dùng chung và các sheet
+func buildXLSX(t *testing.T, shared []string, sheets ...testSheet) []byte {
+	t.Helper()
+	var workbook, rels strings.Builder
+	files := map[string]string{}
+	for i, sheet := range sheets {
+		fmt.Fprintf(&workbook, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, sheet.name, i+1, i+1)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Target="worksheets/sheet%d.xml"/>`, i+1, i+1)
+		files[fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1)] = `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` +
+			sheet.rows + `</sheetData></worksheet>`
+	}
+	files["xl/workbook.xml"] = `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>` + workbook.String() + `</sheets></workbook>`
+	files["xl/_rels/workbook.xml.rels"] = `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` + rels.String() + `</Relationships>`
+	if shared != nil {
+		var sst strings.Builder
+		for _, s := range shared {
+			fmt.Fprintf(&sst, `<si><t>%s</t></si>`, s)
+		}
+		files["xl/sharedStrings.xml"] = `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` + sst.String() + `</sst>`
+	}
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func readTestXLSX(data []byte, entity string, maxRows int) (*File, error) {
+	return Read("data.xlsx", bytes.NewReader(data), int64(len(data)), entity, maxRows)
+}
+
+func TestReadXLSX(t *testing.T) {
+	data := buildXLSX(t, []string{"name_local", "Hà Nội", "Huế"},
+		testSheet{name: "Locations", rows: `<row r="1"><c r="A1" t="s"><v>0</v></c></row>` +
+			`<row r="2"><c r="A2" t="s"><v>1</v></c></row>` +
+			// Dòng 3 trống không được lưu, dòng 4 dùng chuỗi nhiều định dạng
+			`<row r="4"><c r="A4" t="inlineStr"><is><r><t>Đà </t></r><r><t>Nẵng</t></r></is></c></row>` +
+			`<row r="5"><c r="A5" t="s"><v>2</v></c></row>`},
+		testSheet{name: "factories", rows: `<row r="1"><c r="A1" t="inlineStr"><is><t>Name Factory</t></is></c>` +
+			`<c r="C1" t="inlineStr"><is><t>name_local</t></is></c></row>` +
+			// Ô ở cột B không có tiêu đề và ô ở cột XFD bị bỏ
+			`<row r="2"><c r="A2" t="inlineStr"><is><t>Nhà máy A</t></is></c><c r="B2"><v>99</v></c>` +
+			`<c r="C2" t="s"><v>1</v></c><c r="XFD2"><v>1</v></c></row>` +
+			`<row r="3"><c r="C3" t="s"><v>2</v></c></row>`},
+		testSheet{name: "notes", rows: `<row r="1"><c r="A1"><v>ignored</v></c></row>`},
+	)
+
+	file, err := readTestXLSX(data, "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantLocations := []struct {
+		line int
+		name string
+	}{{2, "Hà Nội"}, {4, "Đà Nẵng"}, {5, "Huế"}}
+	if len(file.Locations) != len(wantLocations) {
+		t.Fatalf("got %d locations, want %d", len(file.Locations), len(wantLocations))
+	}
+	for i, want := range wantLocations {
+		got := file.Locations[i]
+		if got.Line != want.line || deref(got.Input.NameLocal) != want.name {
+			t.Errorf("location %d = (%d, %q), want (%d, %q)", i, got.Line, deref(got.Input.NameLocal), want.line, want.name)
+		}
+	}
+
+	wantFactories := [][2]string{{"Nhà máy A", "Hà Nội"}, {"", "Huế"}}
+	if len(file.Factories) != len(wantFactories) {
+		t.Fatalf("got %d factories, want %d", len(file.Factories), len(wantFactories))
+	}
+	for i, want := range wantFactories {
+		got := [2]string{deref(file.Factories[i].Input.NameFactory), deref(file.Factories[i].Input.NameLocal)}
+		if got != want {
+			t.Errorf("factory %d = %q, want %q", i, got, want)
+		}
+	}
+
+	only, err := readTestXLSX(data, module.ImportEntityFactory, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(only.Locations) != 0 || len(only.Factories) != 2 {
+		t.Errorf("entity filter read %d locations and %d factories", len(only.Locations), len(only.Factories))
+	}
+}
+
+func TestReadXLSXProductDate(t *testing.T) {
+	// Ô ngày không có kiểu chuỗi nên giá trị là số ngày, 44927 là 2023-01-01
+	data := buildXLSX(t, nil, testSheet{name: "products", rows: `<row r="1">` +
+		`<c r="A1" t="inlineStr"><is><t>title</t></is></c><c r="B1" t="inlineStr"><is><t>image</t></is></c>` +
+		`<c r="C1" t="inlineStr"><is><t>year_product</t></is></c><c r="D1" t="inlineStr"><is><t>describe_product</t></is></c>` +
+		`<c r="E1" t="inlineStr"><is><t>name_factory</t></is></c></row>` +
+		`<row r="2"><c r="A2" t="inlineStr"><is><t>Bàn</t></is></c><c r="B2" t="inlineStr"><is><t>a.jpg</t></is></c>` +
+		`<c r="C2"><v>44927</v></c><c r="D2" t="inlineStr"><is><t>Mô tả</t></is></c>` +
+		`<c r="E2" t="inlineStr"><is><t>Nhà máy A</t></is></c></row>`})
+	file, err := readTestXLSX(data, "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(file.Products) != 1 {
+		t.Fatalf("got %d products, want 1", len(file.Products))
+	}
+	year := file.Products[0].Input.Year
+	if year == nil || !year.Equal(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("year = %v, want 2023-01-01", year)
+	}
+}
+
+func TestReadXLSXErrors(t *testing.T) {
+	header := `<row r="1"><c r="A1" t="inlineStr"><is><t>name_local</t></is></c></row>`
+	row := func(n int) string {
+		return fmt.Sprintf(`<row r="%d"><c r="A%d"><v>%d</v></c></row>`, n, n, n)
+	}
+	var tooMany strings.Builder
+	tooMany.WriteString(header)
+	for i := 2; i <= 5; i++ {
+		tooMany.WriteString(row(i))
+	}
+	// Dòng trống không tính vào giới hạn
+	var blankRows strings.Builder
+	blankRows.WriteString(header + row(2) + row(3) + row(4))
+	for i := 5; i <= 20; i++ {
+		fmt.Fprintf(&blankRows, `<row r="%d"><c r="B%d"><v>x</v></c></row>`, i, i)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{"not a zip file", []byte("name_local\nx\n"), module.ErrImportFormat},
+		{"more than maxRows data rows", buildXLSX(t, nil, testSheet{"locations", tooMany.String()}), module.ErrImportTooLarge},
+		{"cells outside the header do not count", buildXLSX(t, nil, testSheet{"locations", blankRows.String()}), nil},
+		{"shared string out of range", buildXLSX(t, []string{"name_local"}, testSheet{"locations", `<row r="1"><c r="A1" t="s"><v>5</v></c></row>`}), module.ErrImportFormat},
+		{"invalid cell reference", buildXLSX(t, nil, testSheet{"locations", `<row r="1"><c r="1A"><v>x</v></c></row>`}), module.ErrImportFormat},
+		{"column past XFD", buildXLSX(t, nil, testSheet{"locations", `<row r="1"><c r="XFE1"><v>x</v></c></row>`}), module.ErrImportFormat},
+		{"duplicate sheet", buildXLSX(t, nil, testSheet{"locations", header}, testSheet{"LOCATIONS", header}), module.ErrImportFormat},
+		{"unknown header column", buildXLSX(t, nil, testSheet{"locations", `<row r="1"><c r="A1" t="inlineStr"><is><t>owner</t></is></c></row>`}), module.ErrImportColumns},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readTestXLSX(tt.data, "", 3)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadXLSXMissingWorkbook(t *testing.T) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	if _, err := w.Create("docProps/app.xml"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readTestXLSX(buf.Bytes(), "", 10); !errors.Is(err, module.ErrImportFormat) {
+		t.Errorf("error = %v, want ErrImportFormat", err)
+	}
+}
+
+func TestColumn(t *testing.T) {
+	tests := []struct {
+		ref     string
+		want    int
+		wantErr bool
+	}{
+		{ref: "A1", want: 0},
+		{ref: "Z9", want: 25},
+		{ref: "AA10", want: 26},
+		{ref: "AZ1", want: 51},
+		{ref: "XFD1048576", want: 16383},
+		{ref: "XFE1", wantErr: true},
+		{ref: "1", wantErr: true},
+		{ref: "a1", wantErr: true},
+		{ref: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			got, err := column(tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("column(%q) error = %v, wantErr %v", tt.ref, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("column(%q) = %d, want %d", tt.ref, got, tt.want)
+			}
+		})
+	}
+}
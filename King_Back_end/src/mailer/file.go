@@ -0,0 +1,42 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"thelastking-blogger.com/src/config/logger"
+)
+
+// fileMailer không gửi email thật: nội dung được ghi vào file (nếu có) và log, dùng khi phát triển local
+type fileMailer struct {
+	mu   sync.Mutex
+	path string
+	log  logger.Logger
+}
+
+func NewFileMailer(path string) *fileMailer {
+	return &fileMailer{
+		path: path,
+		log:  logger.GetLogger(),
+	}
+}
+
+func (m *fileMailer) Send(ctx context.Context, msg Message) error {
+	m.log.Infof("Mail to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	if m.path == "" {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, err := os.OpenFile(m.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintf(f, "Date: %s\nTo: %s\nSubject: %s\n\n%s\n\n", time.Now().Format(time.RFC1123Z), msg.To, msg.Subject, msg.Body)
+	return err
+}
@@ -0,0 +1,39 @@
+package mailer
+
+import (
+	"context"
+	"sync"
+
+	mailconfig "thelastking-blogger.com/src/config/mail_config"
+)
+
+// Message là một email dạng text
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer gửi email; handler chỉ phụ thuộc vào interface này để đổi được nơi gửi
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+var (
+	once     sync.Once
+	instance Mailer
+)
+
+// Get trả về Mailer theo MAIL_DRIVER: smtp khi chạy thật, file cho môi trường local
+func Get() Mailer {
+	once.Do(func() {
+		cfg := mailconfig.Get()
+		switch cfg.Driver {
+		case "smtp":
+			instance = NewSMTPMailer(cfg)
+		default:
+			instance = NewFileMailer(cfg.FilePath)
+		}
+	})
+	return instance
+}
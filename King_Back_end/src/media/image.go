@@ -0,0 +1,123 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/disintegration/imaging"
+	_ "golang.org/x/image/webp" // đăng ký bộ giải mã WebP cho image.DecodeConfig
+	imageconfig "thelastking-blogger.com/src/config/image_config"
+	"thelastking-blogger.com/src/module"
+	"thelastking-blogger.com/src/storage"
+)
+
+var (
+	ErrInvalidImage  = errors.New("invalid image")
+	ErrImageTooLarge = errors.New("image dimensions too large")
+)
+
+// formats là các định dạng ảnh được nhận, xác định bằng cách giải mã chứ không theo tên file
+var formats = map[string]bool{
+	"jpeg": true,
+	"png":  true,
+	"gif":  true,
+	"webp": true,
+}
+
+// formatPNG chỉ dùng cho ảnh gốc PNG để không nén mất dữ liệu
+const formatPNG = "png"
+
+// Image là ảnh gốc đã chuẩn hóa cùng các biến thể, giá trị là key trong storage
+type Image struct {
+	Key      string
+	Variants module.ImageVariants
+}
+
+// ProcessImage giải mã ảnh để chắc chắn đây là ảnh thật, xoay theo EXIF orientation rồi mã hóa lại
+// (bỏ toàn bộ metadata như EXIF/GPS) và tạo các biến thể trong IMAGE_VARIANTS.
+// GIF được giữ nguyên file gốc để không mất ảnh động, GIF không chứa EXIF.
+func ProcessImage(ctx context.Context, s storage.Storage, r io.ReadSeeker) (*Image, error) {
+	cfg := imageconfig.Get()
+	header, format, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	if !formats[format] {
+		return nil, fmt.Errorf("%w: %s", storage.ErrUnsupportedMedia, format)
+	}
+	if header.Width*header.Height > cfg.MaxPixels {
+		return nil, ErrImageTooLarge
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	img, err := imaging.Decode(r, imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+
+	var original *storage.Object
+	switch format {
+	case "gif":
+		original, err = storage.Store(ctx, s, r, "image/gif", ".gif")
+	case "png":
+		original, err = store(ctx, s, img, formatPNG, cfg.JPEGQuality)
+	default:
+		original, err = store(ctx, s, img, imageconfig.FormatAuto, cfg.JPEGQuality)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	variants := make(module.ImageVariants, len(cfg.Variants))
+	for _, v := range cfg.Variants {
+		obj, err := store(ctx, s, fit(img, v.MaxSize), v.Format, cfg.JPEGQuality)
+		if err != nil {
+			return nil, err
+		}
+		variants[v.Name] = obj.Key
+	}
+	return &Image{Key: original.Key, Variants: variants}, nil
+}
+
+// fit thu nhỏ để cạnh dài nhất không vượt quá maxSize, không phóng to ảnh nhỏ
+func fit(img image.Image, maxSize int) image.Image {
+	bounds := img.Bounds()
+	if bounds.Dx() <= maxSize && bounds.Dy() <= maxSize {
+		return img
+	}
+	return imaging.Fit(img, maxSize, maxSize, imaging.Lanczos)
+}
+
+// store mã hóa ảnh theo format rồi lưu với key theo nội dung
+func store(ctx context.Context, s storage.Storage, img image.Image, format string, quality int) (*storage.Object, error) {
+	var buf bytes.Buffer
+	var err error
+	contentType, ext := "image/jpeg", ".jpg"
+	switch {
+	case format == imageconfig.FormatWebP:
+		contentType, ext = "image/webp", ".webp"
+		err = nativewebp.Encode(&buf, img, nil)
+	case format == formatPNG || !isOpaque(img):
+		contentType, ext = "image/png", ".png"
+		err = imaging.Encode(&buf, img, imaging.PNG)
+	default:
+		err = imaging.Encode(&buf, img, imaging.JPEG, imaging.JPEGQuality(quality))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return storage.Store(ctx, s, bytes.NewReader(buf.Bytes()), contentType, ext)
+}
+
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
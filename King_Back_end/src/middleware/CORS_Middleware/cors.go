@@ -11,8 +11,8 @@ func CORSMiddleWare() gin.HandlerFunc {
 	config := cors.Config{
 		AllowOrigins:     []string{"http://localhost:5173"},
 		AllowMethods:     []string{"PUT", "PATCH", "POST", "GET", "OPTIONS", "DELETE"},
-		AllowHeaders:     []string{"Origin", "Authorization", "Content-Type", "Accept", "Sec-WebSocket-Protocol"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Authorization", "Content-Type", "Accept", "Sec-WebSocket-Protocol", "Upload-Offset", "Upload-Checksum"},
+		ExposeHeaders:    []string{"Content-Length", "Location", "Upload-Offset"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}
@@ -0,0 +1,15 @@
+package auditmiddleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"thelastking-blogger.com/src/audit"
+)
+
+// AuditActor gắn IP của request vào context để audit_log ghi được cả các API công khai (đăng ký, quên mật khẩu).
+// JwtMiddleware bổ sung user và role sau khi xác thực.
+func AuditActor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(audit.WithActor(c.Request.Context(), audit.Actor{IPAddress: c.ClientIP()}))
+		c.Next()
+	}
+}
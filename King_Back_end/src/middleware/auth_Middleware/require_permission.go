@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"thelastking-blogger.com/src/policy"
+)
+
+// RequirePermission chỉ cho qua khi role trong token, hoặc API key của service account, có đủ mọi quyền được liệt kê
+func RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if granted, ok := c.Get("permissions"); ok {
+			requireKeyPermissions(c, granted, permissions)
+			return
+		}
+
+		roleInterface, exists := c.Get("role")
+		if !exists {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "Missing role in token",
+			})
+			return
+		}
+
+		userRole, ok := roleInterface.(string)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Invalid role format",
+			})
+			return
+		}
+
+		for _, permission := range permissions {
+			if !policy.Can(userRole, permission) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+					"error":      "Forbidden: insufficient permissions",
+					"permission": permission,
+				})
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// HasPermission kiểm tra quyền ngay trong handler, dùng khi quyền cần có phụ thuộc dữ liệu của request
+func HasPermission(c *gin.Context, permission string) bool {
+	if granted, ok := c.Get("permissions"); ok {
+		keyPermissions, ok := granted.([]string)
+		return ok && slices.Contains(keyPermissions, permission)
+	}
+	role, _ := c.Get("role")
+	userRole, ok := role.(string)
+	return ok && policy.Can(userRole, permission)
+}
+
+// requireKeyPermissions kiểm tra danh sách quyền gắn với API key, không dùng bảng phân quyền theo role
+func requireKeyPermissions(c *gin.Context, granted any, permissions []string) {
+	keyPermissions, ok := granted.([]string)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "Invalid permissions format",
+		})
+		return
+	}
+	for _, permission := range permissions {
+		if !slices.Contains(keyPermissions, permission) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":      "Forbidden: insufficient permissions",
+				"permission": permission,
+			})
+			return
+		}
+	}
+	c.Next()
+}
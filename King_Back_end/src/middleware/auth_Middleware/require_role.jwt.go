@@ -1,38 +0,0 @@
-package auth
-
-import (
-	"net/http"
-
-	"github.com/gin-gonic/gin"
-)
-
-func RequireRole(roles ...string) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		roleInterface, exists := c.Get("role")
-		if !exists {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "Missing role in token",
-			})
-			return
-		}
-
-		userRole, ok := roleInterface.(string)
-		if !ok {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-				"error": "Invalid role format",
-			})
-			return
-		}
-
-		for _, allowed := range roles {
-			if userRole == allowed {
-				c.Next()
-				return
-			}
-		}
-
-		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
-			"error": "Forbidden: insufficient permissions",
-		})
-	}
-}
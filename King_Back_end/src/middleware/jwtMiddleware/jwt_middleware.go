@@ -1,14 +1,34 @@
 package jwtmiddleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"thelastking-blogger.com/src/audit"
+	"thelastking-blogger.com/src/module"
+	"thelastking-blogger.com/src/repository/access_revocation_repo"
+	"thelastking-blogger.com/src/repository/service_account_repo"
 	"thelastking-blogger.com/src/security"
+	"thelastking-blogger.com/src/service/access_revocation_service"
+	"thelastking-blogger.com/src/service/service_account_service"
 )
 
+// OptionalJwtMiddleware cho các API công khai: không có Authorization thì đi tiếp như khách,
+// có thì token phải hợp lệ như JwtMiddleware (dùng cho ?scope=mine)
+func OptionalJwtMiddleware(db *gorm.DB) gin.HandlerFunc {
+	required := JwtMiddleware(db)
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			c.Next()
+			return
+		}
+		required(c)
+	}
+}
+
 // JwtMiddleware validates the access token from the Authorization header
 func JwtMiddleware(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -16,12 +36,18 @@ func JwtMiddleware(db *gorm.DB) gin.HandlerFunc {
 		const bearerPrefix = "Bearer "
 		tokenString := ""
 
+		// Service account dùng API key thay cho access token
+		if strings.HasPrefix(authHeader, apiKeyPrefix) {
+			authenticateApiKey(c, db, strings.TrimPrefix(authHeader, apiKeyPrefix))
+			return
+		}
+
 		// Check for the "Bearer " prefix and extract the token string
 		if strings.HasPrefix(authHeader, bearerPrefix) {
 			tokenString = strings.TrimPrefix(authHeader, bearerPrefix)
 		} else {
 			// If it doesn't have the Bearer prefix, it's not a valid access token format
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header format must be Bearer <token>"})
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header format must be Bearer <token> or ApiKey <key>"})
 			return
 		}
 
@@ -34,13 +60,50 @@ func JwtMiddleware(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		// Token bị thu hồi khi đăng xuất, xóa user, đổi role hoặc đổi mật khẩu
+		revocations := access_revocation_service.GetRevocationController(access_revocation_repo.NewSql(db))
+		if revocations.IsRevoked(claims) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid access token: access token has been revoked"})
+			return
+		}
+
 		// Set user ID and role in context for subsequent handlers
 		c.Set("userId", claims.UserID)
+		role := ""
 		if claims.Role != nil {
-			c.Set("role", *claims.Role)
-		} else {
-			c.Set("role", "")
+			role = *claims.Role
 		}
+		c.Set("role", role)
+		c.Request = c.Request.WithContext(audit.WithActor(c.Request.Context(), audit.Actor{
+			UserID:    claims.UserID,
+			Role:      role,
+			IPAddress: c.ClientIP(),
+		}))
 		c.Next()
 	}
 }
+
+const apiKeyPrefix = "ApiKey "
+
+// authenticateApiKey đặt danh tính service account và danh sách quyền của key vào context.
+// Request dùng API key không có userId/role, auth.RequirePermission kiểm tra theo "permissions".
+func authenticateApiKey(c *gin.Context, db *gorm.DB, key string) {
+	buss := service_account_service.NewServiceAccountController(service_account_repo.NewSql(db))
+	identity, err := buss.NewAuthenticate(c.Request.Context(), strings.TrimSpace(key), c.ClientIP())
+	if err != nil {
+		if errors.Is(err, module.ErrApiKeyInvalid) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid api key"})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify api key"})
+		return
+	}
+	c.Set("serviceAccountId", identity.ServiceAccountID)
+	c.Set("apiKeyId", identity.KeyID)
+	c.Set("permissions", identity.Permissions)
+	c.Request = c.Request.WithContext(audit.WithActor(c.Request.Context(), audit.Actor{
+		ServiceAccountID: identity.ServiceAccountID,
+		IPAddress:        c.ClientIP(),
+	}))
+	c.Next()
+}
@@ -7,5 +7,6 @@ type Factories struct {
 	NameFactory *string    `json:"name_factory" validate:"required" gorm:"column:name_factory;"`
 	CreatedAt   *time.Time `json:"created_at" gorm:"column:created_at;"`
 	UpdatedAt   *time.Time `json:"updated_at" gorm:"column:updated_at;"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty" gorm:"column:deleted_at;"`
 	Location_ID string     `json:"location_id"  gorm:"column:location_id;"`
 }
@@ -7,4 +7,5 @@ type Locations struct {
 	NameLocal   *string    `json:"name_local" validate:"required" gorm:"column:name_local;"`
 	CreatedAt   *time.Time `json:"created_at" gorm:"column:created_at;"`
 	UpdatedAt   *time.Time `json:"updated_at" gorm:"column:updated_at;"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty" gorm:"column:deleted_at;"`
 }
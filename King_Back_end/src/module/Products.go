@@ -12,6 +12,14 @@ type Products struct {
 	Year        *time.Time `json:"year_product" validate:"required" gorm:"column:year_product;type:date;"`
 	CreatedAt   *time.Time `json:"created_at" gorm:"column:created_at;"`
 	UpdatedAt   *time.Time `json:"updated_at" gorm:"column:updated_at;"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty" gorm:"column:deleted_at;"`
 	Factory_ID  string     `json:"factory_id"  gorm:"column:factory_id;"`
 	NameFactory string     `json:"name_factory" gorm:"-"`
+	ImageURL    string     `json:"image_url,omitempty" gorm:"-"` // dựng từ key Image lúc trả response
+	VideoURL    string     `json:"video_url,omitempty" gorm:"-"`
+
+	ImageVariants    ImageVariants     `json:"-" gorm:"column:image_variants;"`
+	ImageVariantURLs map[string]string `json:"image_variants,omitempty" gorm:"-"`
+
+	Media []ProductMedia `json:"media,omitempty" gorm:"-"` // gallery đầy đủ, chỉ có khi xem chi tiết một sản phẩm
 }
@@ -11,4 +11,5 @@ type Users struct {
 	Role          *string    `json:"role_user" gorm:"column:role_user;"`
 	CreatedAt     *time.Time `json:"created_at" gorm:"column:created_at;"`
 	UpdatedAt     *time.Time `json:"updated_at" gorm:"column:updated_at;"`
+	DeletedAt     *time.Time `json:"deleted_at,omitempty" gorm:"column:deleted_at;"`
 }
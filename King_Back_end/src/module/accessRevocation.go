@@ -0,0 +1,16 @@
+package module
+
+import "time"
+
+// AccessTokenRevocation thu hồi một access token theo Jti, mọi access token của một phiên theo SessionID,
+// hoặc mọi access token của UserID có iat <= RevokedBefore (cùng làm tròn tới giây). Dòng hết tác dụng sau ExpiresAt vì token liên quan đã hết hạn.
+type AccessTokenRevocation struct {
+	RevocationID  string     `gorm:"column:revocation_id;"`
+	Jti           *string    `gorm:"column:jti;"`
+	UserID        string     `gorm:"column:user_id;"`
+	RevokedBefore *time.Time `gorm:"column:revoked_before;"`
+	SessionID     *string    `gorm:"column:session_id;"`
+	Reason        string     `gorm:"column:reason;"`
+	ExpiresAt     time.Time  `gorm:"column:expires_at;"`
+	CreatedAt     time.Time  `gorm:"column:created_at;"`
+}
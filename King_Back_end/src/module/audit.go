@@ -0,0 +1,79 @@
+package module
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Loại đối tượng và hành động được ghi vào audit_log
+const (
+	AuditEntityLocation     = "location"
+	AuditEntityFactory      = "factory"
+	AuditEntityProduct      = "product"
+	AuditEntityProductMedia = "product_media"
+	AuditEntityUser         = "user"
+
+	AuditActionCreate  = "create"
+	AuditActionUpdate  = "update"
+	AuditActionDelete  = "delete"
+	AuditActionRestore = "restore"
+	AuditActionPurge   = "purge"
+)
+
+var ErrInvalidAuditFilter = errors.New("invalid audit filter")
+
+// AuditChange là giá trị cũ và mới của một trường, create chỉ có New, delete chỉ có Old
+type AuditChange struct {
+	Old any `json:"old,omitempty"`
+	New any `json:"new,omitempty"`
+}
+
+// AuditChanges lưu vào cột jsonb changes, key là tên trường theo JSON của đối tượng
+type AuditChanges map[string]AuditChange
+
+func (a AuditChanges) Value() (driver.Value, error) {
+	raw, err := json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+	return string(raw), nil
+}
+
+func (a *AuditChanges) Scan(value any) error {
+	switch v := value.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, a)
+	case string:
+		return json.Unmarshal([]byte(v), a)
+	default:
+		return errors.New("unsupported audit changes type")
+	}
+}
+
+type AuditLog struct {
+	AuditID          string       `json:"audit_id" gorm:"column:audit_id;"`
+	ActorID          *string      `json:"actor_id" gorm:"column:actor_id;"`
+	ActorRole        string       `json:"actor_role" gorm:"column:actor_role;"`
+	ServiceAccountID *string      `json:"service_account_id" gorm:"column:service_account_id;"`
+	IPAddress        string       `json:"ip_address" gorm:"column:ip_address;"`
+	EntityType       string       `json:"entity_type" gorm:"column:entity_type;"`
+	EntityID         string       `json:"entity_id" gorm:"column:entity_id;"`
+	Action           string       `json:"action" gorm:"column:action;"`
+	Changes          AuditChanges `json:"changes" gorm:"column:changes;"`
+	CreatedAt        time.Time    `json:"created_at" gorm:"column:created_at;"`
+}
+
+// AuditFilter là điều kiện lọc của GET /audit, trường rỗng thì bỏ qua
+type AuditFilter struct {
+	EntityType string     `form:"entity_type"`
+	EntityID   string     `form:"entity_id"`
+	ActorID    string     `form:"actor_id"`
+	Action     string     `form:"action"`
+	From       *time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To         *time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+}
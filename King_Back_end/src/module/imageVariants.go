@@ -0,0 +1,35 @@
+package module
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+)
+
+// ImageVariants lưu vào cột jsonb image_variants: tên biến thể (thumb, medium, ...) -> key trong storage
+type ImageVariants map[string]string
+
+func (v ImageVariants) Value() (driver.Value, error) {
+	if v == nil {
+		return nil, nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(raw), nil
+}
+
+func (v *ImageVariants) Scan(value any) error {
+	switch data := value.(type) {
+	case nil:
+		*v = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(data, v)
+	case string:
+		return json.Unmarshal([]byte(data), v)
+	default:
+		return errors.New("unsupported image variants type")
+	}
+}
@@ -0,0 +1,66 @@
+package module
+
+import "errors"
+
+// Loại dữ liệu trong file nhập, cũng là tên sheet trong file XLSX
+const (
+	ImportEntityLocation = "locations"
+	ImportEntityFactory  = "factories"
+	ImportEntityProduct  = "products"
+)
+
+// Kết quả kiểm tra của một dòng
+const (
+	ImportActionCreate  = "create"  // hợp lệ, được tạo khi commit
+	ImportActionExists  = "exists"  // đã có trong DB nên bỏ qua, vẫn dùng làm cha cho các dòng khác được
+	ImportActionInvalid = "invalid" // có lỗi, cả file không được commit
+)
+
+// Giai đoạn gửi qua sự kiện import:progress
+const (
+	ImportPhaseValidate = "validate"
+	ImportPhaseCommit   = "commit"
+)
+
+var (
+	ErrImportFormat   = errors.New("unsupported import file, expected .csv or .xlsx")
+	ErrImportEntity   = errors.New("invalid import entity, expected locations, factories or products")
+	ErrImportColumns  = errors.New("invalid import columns")
+	ErrImportEmpty    = errors.New("import file has no data rows")
+	ErrImportTooLarge = errors.New("import file has too many rows")
+	ErrImportInvalid  = errors.New("import file has invalid rows")
+)
+
+// ImportRowResult là kết quả của một dòng, Row là số dòng trong file/sheet (dòng tiêu đề là 1)
+type ImportRowResult struct {
+	Entity string   `json:"entity"`
+	Row    int      `json:"row"`
+	Name   string   `json:"name"`
+	Action string   `json:"action"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// ImportCounts đếm số dòng theo từng kết quả của một loại dữ liệu
+type ImportCounts struct {
+	Create  int `json:"create"`
+	Exists  int `json:"exists"`
+	Invalid int `json:"invalid"`
+}
+
+// ImportReport là báo cáo từng dòng của một lần nhập, Committed chỉ true khi cả file đã được ghi vào DB
+type ImportReport struct {
+	ImportID  string                  `json:"import_id"`
+	DryRun    bool                    `json:"dry_run"`
+	Committed bool                    `json:"committed"`
+	Total     int                     `json:"total"`
+	Counts    map[string]ImportCounts `json:"counts"`
+	Rows      []ImportRowResult       `json:"rows"`
+}
+
+// ImportProgress là tiến độ của một giai đoạn, Done == Total khi giai đoạn kết thúc
+type ImportProgress struct {
+	ImportID string `json:"import_id"`
+	Phase    string `json:"phase"`
+	Done     int    `json:"done"`
+	Total    int    `json:"total"`
+}
@@ -1,9 +1,19 @@
 package module
 
-import "github.com/dgrijalva/jwt-go"
+import "github.com/golang-jwt/jwt/v5"
+
+const (
+	TokenUseAccess  = "access"
+	TokenUseRefresh = "refresh"
+	// TokenUseMfaPending: token ngắn hạn cấp sau khi đúng mật khẩu, chỉ dùng để hoàn tất bước 2FA
+	TokenUseMfaPending = "mfa_pending"
+)
 
 type Token struct {
-	UserID string  `json:"user_id"`
-	Role   *string `json:"role_user"`
-	jwt.StandardClaims
+	UserID   string  `json:"user_id"`
+	Role     *string `json:"role_user"`
+	TokenUse string  `json:"token_use"`
+	// SessionID là family của refresh token, access token cũng mang sid để thu hồi được theo từng phiên
+	SessionID string `json:"sid,omitempty"`
+	jwt.RegisteredClaims
 }
@@ -0,0 +1,14 @@
+package module
+
+import "time"
+
+// JwtKey là một cặp khóa ký JWT. Khóa ký token mới tới RetireAt và còn dùng để verify tới ExpiresAt.
+type JwtKey struct {
+	Kid        string    `gorm:"column:kid;"`
+	Algorithm  string    `gorm:"column:algorithm;"`
+	PrivateKey string    `gorm:"column:private_key;"` // PEM mã hóa bằng JWT_KEY_ENCRYPTION_KEY
+	PublicKey  string    `gorm:"column:public_key;"`
+	CreatedAt  time.Time `gorm:"column:created_at;"`
+	RetireAt   time.Time `gorm:"column:retire_at;"`
+	ExpiresAt  time.Time `gorm:"column:expires_at;"`
+}
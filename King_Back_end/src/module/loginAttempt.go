@@ -0,0 +1,19 @@
+package module
+
+import "time"
+
+const (
+	LoginAttemptAccount = "account"
+	LoginAttemptIP      = "ip"
+)
+
+// LoginAttempt đếm số lần đăng nhập sai liên tiếp của một tài khoản hoặc một IP
+type LoginAttempt struct {
+	AttemptKey   string     `json:"key" gorm:"column:attempt_key;"`
+	Kind         string     `json:"kind" gorm:"column:kind;"`
+	UserID       *string    `json:"user_id" gorm:"column:user_id;"`
+	Failures     int        `json:"failures" gorm:"column:failures;"`
+	LastFailedAt time.Time  `json:"last_failed_at" gorm:"column:last_failed_at;"`
+	LockedUntil  *time.Time `json:"locked_until" gorm:"column:locked_until;"`
+	UpdatedAt    time.Time  `json:"updated_at" gorm:"column:updated_at;"`
+}
@@ -0,0 +1,31 @@
+package module
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrMfaNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrMfaAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrMfaInvalidCode    = errors.New("invalid two-factor authentication code")
+	// ErrMfaRequired: role của user bắt buộc bật 2FA nên không được tắt
+	ErrMfaRequired = errors.New("two-factor authentication is required for this role")
+)
+
+// UserMfa lưu secret TOTP, LastUsedStep chặn việc dùng lại một mã trong cùng khoảng thời gian
+type UserMfa struct {
+	UserID       string     `gorm:"column:user_id;"`
+	Secret       string     `gorm:"column:secret;"`
+	Enabled      bool       `gorm:"column:enabled;"`
+	LastUsedStep *int64     `gorm:"column:last_used_step;"`
+	CreatedAt    time.Time  `gorm:"column:created_at;"`
+	EnabledAt    *time.Time `gorm:"column:enabled_at;"`
+}
+
+type MfaRecoveryCode struct {
+	CodeHash  string     `gorm:"column:code_hash;"`
+	UserID    string     `gorm:"column:user_id;"`
+	UsedAt    *time.Time `gorm:"column:used_at;"`
+	CreatedAt time.Time  `gorm:"column:created_at;"`
+}
@@ -0,0 +1,33 @@
+package module
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrOidcDisabled        = errors.New("oidc login is not configured")
+	ErrOidcStateInvalid    = errors.New("oidc login state is invalid or has expired")
+	ErrOidcEmailNotAllowed = errors.New("oidc account email is not allowed")
+	ErrOidcNotProvisioned  = errors.New("no account is linked to this oidc identity")
+	ErrOidcAccountDeleted  = errors.New("linked account has been deleted")
+)
+
+// OidcLoginState giữ code_verifier và nonce giữa lúc chuyển sang IdP và lúc IdP gọi lại callback
+type OidcLoginState struct {
+	StateHash    string    `gorm:"column:state_hash;"`
+	CodeVerifier string    `gorm:"column:code_verifier;"`
+	Nonce        string    `gorm:"column:nonce;"`
+	ExpiresAt    time.Time `gorm:"column:expires_at;"`
+	CreatedAt    time.Time `gorm:"column:created_at;"`
+}
+
+// UserIdentity liên kết user với tài khoản bên IdP, khóa là (issuer, sub) vì email có thể đổi
+type UserIdentity struct {
+	Issuer      string     `json:"issuer" gorm:"column:issuer;"`
+	Subject     string     `json:"subject" gorm:"column:subject;"`
+	UserID      string     `json:"user_id" gorm:"column:user_id;"`
+	Email       string     `json:"email" gorm:"column:email;"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"column:created_at;"`
+	LastLoginAt *time.Time `json:"last_login_at" gorm:"column:last_login_at;"`
+}
@@ -0,0 +1,18 @@
+package module
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrPasswordResetInvalid gộp các trường hợp token không tồn tại, đã dùng hoặc hết hạn để không lộ thông tin
+var ErrPasswordResetInvalid = errors.New("password reset token is invalid or has expired")
+
+// PasswordReset chỉ lưu SHA-256 của token gửi qua email
+type PasswordReset struct {
+	TokenHash string     `gorm:"column:token_hash;"`
+	UserID    string     `gorm:"column:user_id;"`
+	ExpiresAt time.Time  `gorm:"column:expires_at;"`
+	UsedAt    *time.Time `gorm:"column:used_at;"`
+	CreatedAt time.Time  `gorm:"column:created_at;"`
+}
@@ -0,0 +1,46 @@
+package module
+
+import (
+	"errors"
+	"time"
+)
+
+// Các quyền được kiểm tra bởi auth.RequirePermission, danh sách đầy đủ nằm trong bảng permissions
+const (
+	PermUserSelf        = "user:self" // quản lý hồ sơ, mật khẩu, 2FA và phiên đăng nhập của chính mình
+	PermUserCreate      = "user:create"
+	PermUserUpdate      = "user:update"
+	PermUserDelete      = "user:delete"
+	PermUserSessions    = "user:sessions"
+	PermUserUnlock      = "user:unlock"
+	PermProductWrite    = "product:write"
+	PermProductDelete   = "product:delete"
+	PermProductReview   = "product:review"  // xuất bản hoặc trả về bản nháp sản phẩm đang chờ duyệt
+	PermProductArchive  = "product:archive" // gỡ xuống, lưu trữ và mở lại sản phẩm đã xuất bản
+	PermFactoryWrite    = "factory:write"
+	PermFactoryDelete   = "factory:delete"
+	PermLocationWrite   = "location:write"
+	PermLocationDelete  = "location:delete"
+	PermPermissionAdmin = "permission:manage"
+	PermScopeAdmin      = "scope:manage"
+	PermServiceAccount  = "service_account:manage"
+	PermAuditRead       = "audit:read"
+	PermTrashPurge      = "trash:purge" // không role nào được gán, chỉ ROOT
+)
+
+var (
+	ErrPermissionNotFound = errors.New("permission not found")
+	ErrInvalidRole        = errors.New("invalid role")
+)
+
+type Permission struct {
+	PermissionKey string    `json:"permission_key" gorm:"column:permission_key;"`
+	Description   string    `json:"description" gorm:"column:description;"`
+	CreatedAt     time.Time `json:"created_at" gorm:"column:created_at;"`
+}
+
+type RolePermission struct {
+	Role          string    `json:"role_user" gorm:"column:role_user;"`
+	PermissionKey string    `json:"permission_key" gorm:"column:permission_key;"`
+	CreatedAt     time.Time `json:"created_at" gorm:"column:created_at;"`
+}
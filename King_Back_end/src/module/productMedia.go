@@ -0,0 +1,38 @@
+package module
+
+import (
+	"errors"
+	"time"
+)
+
+// Loại media trong gallery của sản phẩm
+const (
+	MediaTypeImage = "image"
+	MediaTypeVideo = "video"
+)
+
+var (
+	ErrInvalidMediaType     = errors.New("media_type must be image or video")
+	ErrPrimaryMediaType     = errors.New("only an image can be the primary media")
+	ErrPrimaryMediaRequired = errors.New("product must keep a primary image")
+	ErrInvalidMediaOrder    = errors.New("media order must list every media of the product exactly once")
+)
+
+// ProductMedia là một ảnh hoặc video trong gallery của sản phẩm.
+// Mỗi sản phẩm có đúng một ảnh chính, key của nó luôn trùng với products.image.
+type ProductMedia struct {
+	MediaID       string        `json:"media_id" gorm:"column:media_id;"`
+	Product_ID    string        `json:"product_id" gorm:"column:product_id;"`
+	Type          string        `json:"media_type" gorm:"column:media_type;"`
+	StorageKey    string        `json:"storage_key" gorm:"column:storage_key;"`
+	ImageVariants ImageVariants `json:"-" gorm:"column:image_variants;"`
+	Caption       *string       `json:"caption" gorm:"column:caption;"`
+	AltText       *string       `json:"alt_text" gorm:"column:alt_text;"`
+	SortOrder     int           `json:"sort_order" gorm:"column:sort_order;"`
+	IsPrimary     bool          `json:"is_primary" gorm:"column:is_primary;"`
+	CreatedAt     time.Time     `json:"created_at" gorm:"column:created_at;"`
+	UpdatedAt     time.Time     `json:"updated_at" gorm:"column:updated_at;"`
+
+	URL         string            `json:"url,omitempty" gorm:"-"` // dựng từ StorageKey lúc trả response
+	VariantURLs map[string]string `json:"image_variants,omitempty" gorm:"-"`
+}
@@ -0,0 +1,65 @@
+package module
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrInvalidRevision = errors.New("invalid revision number")
+
+// ProductSnapshot là các trường của sản phẩm được lưu lại ở mỗi phiên bản và được so sánh khi diff
+type ProductSnapshot struct {
+	Title      *string    `json:"title" gorm:"column:title;"`
+	Image      *string    `json:"image" gorm:"column:image;"`
+	Video      *string    `json:"video" gorm:"column:video;"`
+	Status     *string    `json:"status" gorm:"column:status;"`
+	Describe   string     `json:"describe_product" gorm:"column:describe_product;"`
+	Year       *time.Time `json:"year_product" gorm:"column:year_product;type:date;"`
+	Factory_ID string     `json:"factory_id" gorm:"column:factory_id;"`
+
+	ImageVariants ImageVariants `json:"-" gorm:"column:image_variants;"` // đi theo Image nên không so sánh riêng
+}
+
+type ProductRevision struct {
+	RevisionID       string `json:"revision_id" gorm:"column:revision_id;"`
+	Product_ID       string `json:"product_id" gorm:"column:product_id;"`
+	Revision         int    `json:"revision_number" gorm:"column:revision_number;"`
+	ProductSnapshot  `gorm:"embedded"`
+	ActorID          *string   `json:"actor_id" gorm:"column:actor_id;"`
+	ServiceAccountID *string   `json:"service_account_id" gorm:"column:service_account_id;"`
+	RevertedFrom     *int      `json:"reverted_from" gorm:"column:reverted_from;"`
+	CreatedAt        time.Time `json:"created_at" gorm:"column:created_at;"`
+	ImageURL         string    `json:"image_url,omitempty" gorm:"-"`
+	VideoURL         string    `json:"video_url,omitempty" gorm:"-"`
+
+	ImageVariantURLs map[string]string `json:"image_variants,omitempty" gorm:"-"`
+}
+
+// ProductRevisionDiff là các trường khác nhau giữa phiên bản From và To
+type ProductRevisionDiff struct {
+	Product_ID string       `json:"product_id"`
+	From       int          `json:"from"`
+	To         int          `json:"to"`
+	Changes    AuditChanges `json:"changes"`
+}
+
+// RevisionDiffQuery là tham số của GET /product/:product_id/revisions/diff?from=&to=
+type RevisionDiffQuery struct {
+	From int `form:"from" binding:"required"`
+	To   int `form:"to" binding:"required"`
+}
+
+// Snapshot lấy các trường cần lưu phiên bản từ sản phẩm
+func (p *Products) Snapshot() ProductSnapshot {
+	return ProductSnapshot{
+		Title:      p.Title,
+		Image:      p.Image,
+		Video:      p.Video,
+		Status:     p.Status,
+		Describe:   p.Describe,
+		Year:       p.Year,
+		Factory_ID: p.Factory_ID,
+
+		ImageVariants: p.ImageVariants,
+	}
+}
@@ -0,0 +1,21 @@
+package module
+
+// ProductSearchQuery: q theo cú pháp của websearch_to_tsquery ("cụm từ", OR, -loại trừ), không phân biệt dấu
+type ProductSearchQuery struct {
+	Q string `form:"q" binding:"required,max=200"`
+}
+
+// Ký tự điều khiển ts_headline dùng để đánh dấu từ khớp (chr(2), chr(3) trong SQL), không xuất hiện trong HTML đã escape
+const (
+	HighlightStart = "\x02"
+	HighlightStop  = "\x03"
+)
+
+// ProductSearchResult là sản phẩm khớp kèm điểm xếp hạng và đoạn trích.
+// TitleHighlight và Snippet là HTML đã escape, chỉ có thẻ <mark></mark> bọc từ khớp.
+type ProductSearchResult struct {
+	Products
+	Rank           float64 `json:"rank" gorm:"column:rank;"`
+	TitleHighlight string  `json:"title_highlight" gorm:"column:title_highlight;"`
+	Snippet        string  `json:"snippet" gorm:"column:snippet;"`
+}
@@ -0,0 +1,36 @@
+package module
+
+import (
+	"errors"
+	"time"
+)
+
+// Trạng thái của sản phẩm, chỉ đổi qua POST /product/:product_id/transition theo policy.ProductTransition
+const (
+	ProductStatusDraft     = "draft"
+	ProductStatusInReview  = "in_review"
+	ProductStatusPublished = "published"
+	ProductStatusArchived  = "archived"
+)
+
+var (
+	ErrInvalidProductStatus     = errors.New("invalid product status")
+	ErrStatusTransitionRequired = errors.New("status can only be changed through a transition")
+	ErrTransitionNotAllowed     = errors.New("status transition not allowed")
+	ErrTransitionForbidden      = errors.New("not allowed to perform this status transition")
+	ErrTransitionReasonRequired = errors.New("a reason is required for this status transition")
+	ErrStatusConflict           = errors.New("product status has changed")
+)
+
+// ProductStatusHistory là một lần chuyển trạng thái, actor lấy từ audit.ActorFrom như product_revisions
+type ProductStatusHistory struct {
+	HistoryID        string    `json:"history_id" gorm:"column:history_id;"`
+	Product_ID       string    `json:"product_id" gorm:"column:product_id;"`
+	FromStatus       string    `json:"from_status" gorm:"column:from_status;"`
+	ToStatus         string    `json:"to_status" gorm:"column:to_status;"`
+	Reason           *string   `json:"reason" gorm:"column:reason;"`
+	ActorID          *string   `json:"actor_id" gorm:"column:actor_id;"`
+	ActorRole        string    `json:"actor_role" gorm:"column:actor_role;"`
+	ServiceAccountID *string   `json:"service_account_id" gorm:"column:service_account_id;"`
+	CreatedAt        time.Time `json:"created_at" gorm:"column:created_at;"`
+}
@@ -1,13 +1,46 @@
 package module
 
 import (
+	"errors"
 	"time"
 )
 
+var (
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+	ErrRefreshTokenRevoked  = errors.New("refresh token has been revoked")
+	ErrRefreshTokenExpired  = errors.New("refresh token has expired")
+	// ErrRefreshTokenReused: token đã được xoay vòng nhưng vẫn bị gửi lại, cả family đã bị thu hồi
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+)
+
+// RefreshToken chỉ lưu SHA-256 của JWT; các token sinh ra từ cùng một lần đăng nhập chung FamilyID
 type RefreshToken struct {
-	Token     string    `gorm:"column:token;"`
-	UserID    string    `gorm:"column:user_id;"`
-	ExpiresAt time.Time `gorm:"column:expires_at;"`
-	Revoked   bool      `gorm:"column:revoked;"`
-	CreatedAt time.Time `gorm:"column:created_at;"`
+	TokenHash  string     `gorm:"column:token_hash;"`
+	FamilyID   string     `gorm:"column:family_id;"`
+	UserID     string     `gorm:"column:user_id;"`
+	ExpiresAt  time.Time  `gorm:"column:expires_at;"`
+	Revoked    bool       `gorm:"column:revoked;"`
+	ReplacedBy *string    `gorm:"column:replaced_by;"`
+	RevokedAt  *time.Time `gorm:"column:revoked_at;"`
+	CreatedAt  time.Time  `gorm:"column:created_at;"`
+	UserAgent  string     `gorm:"column:user_agent;"`
+	IPAddress  string     `gorm:"column:ip_address;"`
+	LastUsedAt *time.Time `gorm:"column:last_used_at;"`
+}
+
+// SessionClient là thiết bị gửi request đăng nhập hoặc làm mới token
+type SessionClient struct {
+	UserAgent string
+	IPAddress string
+}
+
+// Session là một family refresh token còn hiệu lực; SessionID chính là family_id
+type Session struct {
+	SessionID  string     `json:"session_id" gorm:"column:family_id;"`
+	UserAgent  string     `json:"user_agent" gorm:"column:user_agent;"`
+	IPAddress  string     `json:"ip_address" gorm:"column:ip_address;"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"column:created_at;"`
+	LastUsedAt *time.Time `json:"last_used_at" gorm:"column:last_used_at;"`
+	ExpiresAt  time.Time  `json:"expires_at" gorm:"column:expires_at;"`
+	Current    bool       `json:"current" gorm:"-"`
 }
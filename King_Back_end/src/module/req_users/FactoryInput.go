@@ -1,6 +1,6 @@
 package req_users
 
 type FactoriesInput struct {
-	NameFactory *string `json:"name_factory" validate:"required" gorm:"column:name_factory;"`
-	NameLocal   *string `json:"name_local" validate:"required" gorm:"column:name_local;"`
+	NameFactory *string `json:"name_factory" validate:"required,min=1" gorm:"column:name_factory;"`
+	NameLocal   *string `json:"name_local" validate:"required,min=1" gorm:"column:name_local;"`
 }
@@ -1,7 +1,13 @@
 package req_users
 
-type ForgorPwd struct {
-	Account         string `json:"account" gorm:"column:account;" validate:"required,thientan_email"`
+// RequestForgotPwd yêu cầu gửi link đặt lại mật khẩu tới email của tài khoản
+type RequestForgotPwd struct {
+	Account string `json:"account" validate:"required,thientan_email"`
+}
+
+// ConfirmForgotPwd đặt mật khẩu mới bằng token nhận được qua email
+type ConfirmForgotPwd struct {
+	Token           string `json:"token" validate:"required"`
 	NewPassword     string `json:"new_password" validate:"required,secure_password"`
 	ConfirmPassword string `json:"confirm_password" validate:"required,eqfield=NewPassword,secure_password"`
 }
@@ -0,0 +1,30 @@
+package req_users
+
+// RequestMfaCode mã 6 số từ ứng dụng authenticator, hoặc một mã khôi phục
+type RequestMfaCode struct {
+	Code         string `json:"code" validate:"required_without=RecoveryCode,omitempty,len=6,numeric"`
+	RecoveryCode string `json:"recovery_code" validate:"required_without=Code"`
+}
+
+// RequestMfaVerify xác nhận mã đầu tiên để bật 2FA
+type RequestMfaVerify struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+// RequestSignInMfa hoàn tất đăng nhập bằng mfa_token nhận ở bước mật khẩu
+type RequestSignInMfa struct {
+	MfaToken     string `json:"mfa_token" validate:"required"`
+	Code         string `json:"code" validate:"required_without=RecoveryCode,omitempty,len=6,numeric"`
+	RecoveryCode string `json:"recovery_code" validate:"required_without=Code"`
+}
+
+// RequestMfaEnroll đăng ký 2FA trong lúc đăng nhập với tài khoản bắt buộc 2FA
+type RequestMfaEnroll struct {
+	MfaToken string `json:"mfa_token" validate:"required"`
+}
+
+// RequestMfaEnrollVerify xác nhận mã đầu tiên để bật 2FA trong lúc đăng nhập
+type RequestMfaEnrollVerify struct {
+	MfaToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required,len=6,numeric"`
+}
@@ -1,6 +1,10 @@
 package req_users
 
-import "time"
+import (
+	"time"
+
+	"thelastking-blogger.com/src/module"
+)
 
 type ProductInput struct {
 	Title       *string    `json:"title" validate:"required,min=2,max=100" gorm:"column:title;"`
@@ -9,6 +13,34 @@ type ProductInput struct {
 	Status      *string    `json:"status" validate:"required" gorm:"column:status;"`
 	Year        *time.Time `json:"year_product" validate:"required" gorm:"column:year_product;type:date;"`
 	Describe    string     `json:"describe_product" validate:"required" gorm:"column:describe_product;"`
-	NameFactory *string    `json:"name_factory" validate:"required" gorm:"column:name_factory;"`
+	NameFactory *string    `json:"name_factory" validate:"required,min=1" gorm:"column:name_factory;"`
 	UpdatedAt   *time.Time `json:"updated_at" gorm:"column:updated_at;"`
+
+	ImageVariants module.ImageVariants `json:"-" gorm:"column:image_variants;"` // đi kèm Image, do pipeline xử lý ảnh tạo ra
+}
+
+// RequestCreateUpload mở một upload video theo từng phần, checksum là sha256 hex của cả file (không bắt buộc)
+type RequestCreateUpload struct {
+	FileName string `json:"file_name" validate:"required,max=255"`
+	Size     int64  `json:"size" validate:"required,gt=0"`
+	Checksum string `json:"checksum" validate:"omitempty,len=64,hexadecimal"`
+}
+
+// RequestUpdateProductMedia sửa chú thích và alt text của một media, trường bỏ trống thì giữ nguyên
+type RequestUpdateProductMedia struct {
+	Caption *string `json:"caption" validate:"omitempty,max=255"`
+	AltText *string `json:"alt_text" validate:"omitempty,max=255"`
+}
+
+// RequestReorderProductMedia liệt kê toàn bộ media_id của sản phẩm theo thứ tự hiển thị mới
+type RequestReorderProductMedia struct {
+	MediaIDs []string `json:"media_ids" validate:"required,min=1,dive,required"`
+}
+
+// RequestProductTransition chuyển trạng thái sản phẩm. from là trạng thái client đang thấy (không bắt buộc),
+// khác trạng thái hiện tại thì bị từ chối để không ghi đè thao tác của người khác.
+type RequestProductTransition struct {
+	To     string `json:"to" validate:"required"`
+	From   string `json:"from"`
+	Reason string `json:"reason" validate:"max=500"`
 }
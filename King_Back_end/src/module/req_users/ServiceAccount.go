@@ -0,0 +1,15 @@
+package req_users
+
+import "time"
+
+type RequestCreateServiceAccount struct {
+	Name        string `json:"name" validate:"required,max=100"`
+	Description string `json:"description" validate:"max=255"`
+}
+
+// RequestCreateApiKey: permissions phải là tập con quyền của người tạo, expires_at bỏ trống thì key không hết hạn
+type RequestCreateApiKey struct {
+	Name        string     `json:"name" validate:"required,max=100"`
+	Permissions []string   `json:"permissions" validate:"required,min=1,dive,required"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+}
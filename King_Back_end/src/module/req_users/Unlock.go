@@ -0,0 +1,7 @@
+package req_users
+
+// RequestUnlock mở khóa đăng nhập theo account hoặc theo IP
+type RequestUnlock struct {
+	Account string `json:"account" validate:"required_without=IP,omitempty,email"`
+	IP      string `json:"ip" validate:"required_without=Account,omitempty,ip"`
+}
@@ -1,13 +1,31 @@
 package module
 
+import "strings"
+
+// Roles theo thứ tự quyền tăng dần: USER < ADMIN < ROOT
 type Roles int
 
 const (
-	MEMBERS Roles = iota
+	USER Roles = iota
 	ADMIN
 	ROOT
 )
 
+var roleNames = []string{"USER", "ADMIN", "ROOT"}
+
 func (r Roles) String() string {
-	return []string{"MEMBERS", "ADMIN", "ROOT"}[r]
+	if r < USER || r > ROOT {
+		return ""
+	}
+	return roleNames[r]
+}
+
+// ParseRoles đọc role lưu trong users.role_user, không phân biệt hoa thường
+func ParseRoles(role string) (Roles, bool) {
+	for i, name := range roleNames {
+		if strings.EqualFold(role, name) {
+			return Roles(i), true
+		}
+	}
+	return USER, false
 }
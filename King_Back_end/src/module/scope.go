@@ -0,0 +1,49 @@
+package module
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+const (
+	ScopeLocation = "location"
+	ScopeFactory  = "factory"
+)
+
+var (
+	ErrOutOfScope       = errors.New("target is outside of your scope")
+	ErrInvalidScopeType = errors.New("invalid scope type")
+	// name_local, name_factory không unique nên tên khớp nhiều bản ghi thì không biết ghi vào đâu
+	ErrAmbiguousName = errors.New("name matches more than one record")
+)
+
+// UserScope là các khu vực và nhà máy user được quản lý.
+// Global = true với ROOT, khi đó hai danh sách không được dùng tới.
+type UserScope struct {
+	Global    bool     `json:"global"`
+	Locations []string `json:"locations"`
+	Factories []string `json:"factories"`
+}
+
+// CoversLocation cho biết khu vực nằm trong phạm vi, scope nil là phạm vi toàn cục
+func (s *UserScope) CoversLocation(locationID string) bool {
+	return s == nil || s.Global || slices.Contains(s.Locations, locationID)
+}
+
+// CoversFactory cho biết nhà máy được gán trực tiếp hoặc nằm trong khu vực được gán
+func (s *UserScope) CoversFactory(factoryID, locationID string) bool {
+	return s.CoversLocation(locationID) || slices.Contains(s.Factories, factoryID)
+}
+
+type UserLocationScope struct {
+	UserID     string    `json:"user_id" gorm:"column:user_id;"`
+	LocationID string    `json:"location_id" gorm:"column:location_id;"`
+	CreatedAt  time.Time `json:"created_at" gorm:"column:created_at;"`
+}
+
+type UserFactoryScope struct {
+	UserID    string    `json:"user_id" gorm:"column:user_id;"`
+	FactoryID string    `json:"factory_id" gorm:"column:factory_id;"`
+	CreatedAt time.Time `json:"created_at" gorm:"column:created_at;"`
+}
@@ -0,0 +1,52 @@
+package module
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrApiKeyInvalid             = errors.New("invalid api key")
+	ErrServiceAccountNotFound    = errors.New("service account not found")
+	ErrServiceAccountNameTaken   = errors.New("service account name already exists")
+	ErrApiKeyPermissionForbidden = errors.New("cannot grant a permission you do not have")
+	ErrApiKeyScopeForbidden      = errors.New("only users with global scope can create api keys")
+)
+
+type ServiceAccount struct {
+	ServiceAccountID string    `json:"service_account_id" gorm:"column:service_account_id;"`
+	Name             string    `json:"name" gorm:"column:name;"`
+	Description      string    `json:"description" gorm:"column:description;"`
+	CreatedBy        *string   `json:"created_by" gorm:"column:created_by;"`
+	CreatedAt        time.Time `json:"created_at" gorm:"column:created_at;"`
+	UpdatedAt        time.Time `json:"updated_at" gorm:"column:updated_at;"`
+	Keys             []ApiKey  `json:"keys" gorm:"-"`
+}
+
+// ApiKey không bao giờ chứa key gốc, key gốc chỉ trả về một lần lúc tạo
+type ApiKey struct {
+	KeyID            string     `json:"key_id" gorm:"column:key_id;"`
+	ServiceAccountID string     `json:"service_account_id" gorm:"column:service_account_id;"`
+	Name             string     `json:"name" gorm:"column:name;"`
+	Prefix           string     `json:"prefix" gorm:"column:prefix;"`
+	KeyHash          string     `json:"-" gorm:"column:key_hash;"`
+	ExpiresAt        *time.Time `json:"expires_at" gorm:"column:expires_at;"`
+	LastUsedAt       *time.Time `json:"last_used_at" gorm:"column:last_used_at;"`
+	LastUsedIP       string     `json:"last_used_ip" gorm:"column:last_used_ip;"`
+	RevokedAt        *time.Time `json:"revoked_at" gorm:"column:revoked_at;"`
+	CreatedBy        *string    `json:"created_by" gorm:"column:created_by;"`
+	CreatedAt        time.Time  `json:"created_at" gorm:"column:created_at;"`
+	Permissions      []string   `json:"permissions" gorm:"-"`
+}
+
+type ApiKeyPermission struct {
+	KeyID         string `json:"key_id" gorm:"column:key_id;"`
+	PermissionKey string `json:"permission_key" gorm:"column:permission_key;"`
+}
+
+// ApiKeyIdentity là danh tính JwtMiddleware đặt vào context khi request dùng Authorization: ApiKey
+type ApiKeyIdentity struct {
+	KeyID            string
+	ServiceAccountID string
+	Permissions      []string
+}
@@ -0,0 +1,10 @@
+package module
+
+import "errors"
+
+var (
+	// ErrParentDeleted: không khôi phục được con khi cha vẫn còn trong thùng rác
+	ErrParentDeleted = errors.New("parent is still in trash, restore it first")
+	// ErrAccountTaken: đã có tài khoản khác đang dùng account của tài khoản cần khôi phục
+	ErrAccountTaken = errors.New("account is already used by another user")
+)
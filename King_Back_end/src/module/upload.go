@@ -0,0 +1,39 @@
+package module
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrUploadNotFound         = errors.New("upload not found or has expired")
+	ErrUploadOffsetMismatch   = errors.New("upload offset does not match")
+	ErrUploadChecksumMismatch = errors.New("upload checksum does not match")
+	ErrUploadTooLarge         = errors.New("upload is too large")
+	ErrUploadIncomplete       = errors.New("upload is not complete")
+	ErrUploadCompleted        = errors.New("upload is already complete")
+)
+
+// Trạng thái của MediaUpload
+const (
+	UploadStatusPending   = "pending"   // đang nhận các phần
+	UploadStatusCompleted = "completed" // đã đủ size byte và nằm trong storage, chờ gắn vào sản phẩm
+)
+
+// MediaUpload là một file được upload theo từng phần, chỉ người tạo mới gửi tiếp và gắn được vào sản phẩm
+type MediaUpload struct {
+	UploadID         string    `json:"upload_id" gorm:"column:upload_id;"`
+	Kind             string    `json:"kind" gorm:"column:kind;"`
+	FileName         string    `json:"file_name" gorm:"column:file_name;"`
+	Size             int64     `json:"size" gorm:"column:size;"`
+	Offset           int64     `json:"offset" gorm:"column:upload_offset;"`
+	Checksum         *string   `json:"checksum,omitempty" gorm:"column:checksum;"` // sha256 hex của cả file, client gửi lúc tạo
+	Status           string    `json:"status" gorm:"column:status;"`
+	StorageKey       *string   `json:"-" gorm:"column:storage_key;"`
+	ContentType      *string   `json:"content_type,omitempty" gorm:"column:content_type;"`
+	UserID           *string   `json:"-" gorm:"column:user_id;"`
+	ServiceAccountID *string   `json:"-" gorm:"column:service_account_id;"`
+	ExpiresAt        time.Time `json:"expires_at" gorm:"column:expires_at;"`
+	CreatedAt        time.Time `json:"created_at" gorm:"column:created_at;"`
+	UpdatedAt        time.Time `json:"updated_at" gorm:"column:updated_at;"`
+}
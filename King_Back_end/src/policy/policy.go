@@ -0,0 +1,157 @@
+package policy
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"thelastking-blogger.com/src/config/logger"
+	"thelastking-blogger.com/src/module"
+)
+
+// reloadInterval là độ trễ tối đa để instance khác thấy thay đổi phân quyền
+const reloadInterval = 30 * time.Second
+
+// PermissionStore đọc bảng role_permissions
+type PermissionStore interface {
+	ListRolePermissions(ctx context.Context) ([]module.RolePermission, error)
+}
+
+// Evaluator là nơi duy nhất quyết định một role được làm gì, bảng quyền được cache trong bộ nhớ
+type Evaluator struct {
+	mu     sync.RWMutex
+	store  PermissionStore
+	byRole map[string]map[string]bool
+	log    logger.Logger
+}
+
+var (
+	instance *Evaluator
+	initOnce sync.Once
+	initErr  error
+)
+
+// Init nạp bảng quyền và chạy job nạp lại định kỳ
+func Init(store PermissionStore) error {
+	initOnce.Do(func() {
+		ev := &Evaluator{
+			store: store,
+			log:   logger.GetLogger(),
+		}
+		if initErr = ev.Reload(context.Background()); initErr != nil {
+			return
+		}
+		instance = ev
+		go ev.run()
+	})
+	return initErr
+}
+
+// Reload đọc lại bảng quyền, gọi ngay sau khi ROOT sửa phân quyền
+func Reload(ctx context.Context) error {
+	if instance == nil {
+		return nil
+	}
+	return instance.Reload(ctx)
+}
+
+func (ev *Evaluator) Reload(ctx context.Context) error {
+	rows, err := ev.store.ListRolePermissions(ctx)
+	if err != nil {
+		return err
+	}
+	byRole := make(map[string]map[string]bool)
+	for _, row := range rows {
+		if byRole[row.Role] == nil {
+			byRole[row.Role] = make(map[string]bool)
+		}
+		byRole[row.Role][row.PermissionKey] = true
+	}
+	ev.mu.Lock()
+	ev.byRole = byRole
+	ev.mu.Unlock()
+	return nil
+}
+
+func (ev *Evaluator) run() {
+	ticker := time.NewTicker(reloadInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := ev.Reload(context.Background()); err != nil {
+			ev.log.Errorf("Failed to reload role permissions: %v", err)
+		}
+	}
+}
+
+// Can cho biết role có quyền permission. ROOT luôn có mọi quyền để không thể tự khóa mình khỏi bảng phân quyền.
+func Can(role string, permission string) bool {
+	parsed, ok := module.ParseRoles(role)
+	if !ok {
+		return false
+	}
+	if parsed == module.ROOT {
+		return true
+	}
+	if instance == nil {
+		return false
+	}
+	instance.mu.RLock()
+	defer instance.mu.RUnlock()
+	return instance.byRole[parsed.String()][permission]
+}
+
+// CanManage áp dụng thứ bậc role cho thao tác trên tài khoản khác:
+// ROOT quản lý mọi tài khoản, các role khác chỉ quản lý được role thấp hơn mình
+func CanManage(actorRole, targetRole string) bool {
+	actor, ok := module.ParseRoles(actorRole)
+	if !ok {
+		return false
+	}
+	target, ok := module.ParseRoles(targetRole)
+	if !ok {
+		return actor == module.ROOT
+	}
+	return actor == module.ROOT || actor > target
+}
+
+// ManageableRoles trả về các role mà actor quản lý được theo CanManage.
+// ROOT quản lý mọi tài khoản kể cả role không hợp lệ nên nhận nil, nghĩa là không cần lọc theo role.
+func ManageableRoles(actorRole string) []string {
+	actor, ok := module.ParseRoles(actorRole)
+	if !ok {
+		return []string{}
+	}
+	if actor == module.ROOT {
+		return nil
+	}
+	roles := []string{}
+	for role := module.USER; role < actor; role++ {
+		roles = append(roles, role.String())
+	}
+	return roles
+}
+
+// CanAssignRole cho biết actor có được tạo tài khoản hoặc đổi role thành role này:
+// ROOT gán được mọi role, các role khác chỉ gán được role thấp hơn mình
+func CanAssignRole(actorRole, role string) bool {
+	actor, ok := module.ParseRoles(actorRole)
+	if !ok {
+		return false
+	}
+	assigned, ok := module.ParseRoles(role)
+	if !ok {
+		return false
+	}
+	return actor == module.ROOT || actor > assigned
+}
+
+// Roles trả về danh sách role hợp lệ theo thứ tự quyền tăng dần
+func Roles() []string {
+	return []string{module.USER.String(), module.ADMIN.String(), module.ROOT.String()}
+}
+
+// IsGlobal cho biết role không bị giới hạn theo khu vực/nhà máy
+func IsGlobal(role string) bool {
+	parsed, ok := module.ParseRoles(role)
+	return ok && parsed == module.ROOT
+}
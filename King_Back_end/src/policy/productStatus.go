@@ -0,0 +1,54 @@
+package policy
+
+import (
+	"fmt"
+
+	"thelastking-blogger.com/src/module"
+)
+
+// StatusTransition là một bước chuyển trạng thái sản phẩm được phép.
+// Permission là quyền người thực hiện phải có, RequireReason bắt buộc ghi lý do (trả lại, gỡ xuống...).
+type StatusTransition struct {
+	From          string `json:"from"`
+	To            string `json:"to"`
+	Permission    string `json:"permission"`
+	RequireReason bool   `json:"require_reason"`
+}
+
+// statusTransitions: draft -> in_review -> published -> archived, cùng các bước quay lại bản nháp
+var statusTransitions = []StatusTransition{
+	{From: module.ProductStatusDraft, To: module.ProductStatusInReview, Permission: module.PermProductWrite},
+	{From: module.ProductStatusInReview, To: module.ProductStatusPublished, Permission: module.PermProductReview},
+	{From: module.ProductStatusInReview, To: module.ProductStatusDraft, Permission: module.PermProductReview, RequireReason: true},
+	{From: module.ProductStatusPublished, To: module.ProductStatusArchived, Permission: module.PermProductArchive, RequireReason: true},
+	{From: module.ProductStatusPublished, To: module.ProductStatusDraft, Permission: module.PermProductArchive, RequireReason: true},
+	{From: module.ProductStatusArchived, To: module.ProductStatusDraft, Permission: module.PermProductArchive, RequireReason: true},
+}
+
+// IsProductStatus cho biết status thuộc tập trạng thái đã định nghĩa
+func IsProductStatus(status string) bool {
+	switch status {
+	case module.ProductStatusDraft, module.ProductStatusInReview, module.ProductStatusPublished, module.ProductStatusArchived:
+		return true
+	}
+	return false
+}
+
+// ProductTransition trả về bước chuyển from -> to, lỗi liệt kê các trạng thái đi được từ from
+func ProductTransition(from, to string) (*StatusTransition, error) {
+	if !IsProductStatus(to) {
+		return nil, fmt.Errorf("%w: %q", module.ErrInvalidProductStatus, to)
+	}
+	var next []string
+	for i := range statusTransitions {
+		if statusTransitions[i].From != from {
+			continue
+		}
+		if statusTransitions[i].To == to {
+			t := statusTransitions[i]
+			return &t, nil
+		}
+		next = append(next, statusTransitions[i].To)
+	}
+	return nil, fmt.Errorf("%w: %s -> %s, allowed from %s: %v", module.ErrTransitionNotAllowed, from, to, from, next)
+}
@@ -0,0 +1,107 @@
+package policy
+
+import (
+	"errors"
+	"testing"
+
+	"thelastking-blogger.com/src/module"
+)
+
+func TestProductTransition(t *testing.T) {
+	const (
+		draft     = module.ProductStatusDraft
+		inReview  = module.ProductStatusInReview
+		published = module.ProductStatusPublished
+		archived  = module.ProductStatusArchived
+	)
+	tests := []struct {
+		from, to       string
+		wantPermission string
+		wantReason     bool
+		wantErr        error
+	}{
+		{draft, inReview, module.PermProductWrite, false, nil},
+		{inReview, published, module.PermProductReview, false, nil},
+		{inReview, draft, module.PermProductReview, true, nil},
+		{published, archived, module.PermProductArchive, true, nil},
+		{published, draft, module.PermProductArchive, true, nil},
+		{archived, draft, module.PermProductArchive, true, nil},
+
+		// Không được bỏ qua bước duyệt hay đi ngược ngoài các bước đã định nghĩa
+		{draft, published, "", false, module.ErrTransitionNotAllowed},
+		{draft, archived, "", false, module.ErrTransitionNotAllowed},
+		{inReview, archived, "", false, module.ErrTransitionNotAllowed},
+		{published, inReview, "", false, module.ErrTransitionNotAllowed},
+		{archived, published, "", false, module.ErrTransitionNotAllowed},
+		{archived, inReview, "", false, module.ErrTransitionNotAllowed},
+
+		// Đứng yên tại chỗ không phải một bước chuyển
+		{draft, draft, "", false, module.ErrTransitionNotAllowed},
+		{inReview, inReview, "", false, module.ErrTransitionNotAllowed},
+		{published, published, "", false, module.ErrTransitionNotAllowed},
+		{archived, archived, "", false, module.ErrTransitionNotAllowed},
+
+		{draft, "deleted", "", false, module.ErrInvalidProductStatus},
+		{draft, "", "", false, module.ErrInvalidProductStatus},
+		{draft, "Published", "", false, module.ErrInvalidProductStatus},
+		{"legacy", draft, "", false, module.ErrTransitionNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from+"->"+tt.to, func(t *testing.T) {
+			got, err := ProductTransition(tt.from, tt.to)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				if got != nil {
+					t.Errorf("transition = %+v, want nil", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.From != tt.from || got.To != tt.to {
+				t.Errorf("transition = %s -> %s, want %s -> %s", got.From, got.To, tt.from, tt.to)
+			}
+			if got.Permission != tt.wantPermission || got.RequireReason != tt.wantReason {
+				t.Errorf("transition = %+v, want permission %s reason %v", got, tt.wantPermission, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestProductTransitionReturnsCopy(t *testing.T) {
+	got, err := ProductTransition(module.ProductStatusDraft, module.ProductStatusInReview)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got.Permission = "changed"
+	again, err := ProductTransition(module.ProductStatusDraft, module.ProductStatusInReview)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Permission != module.PermProductWrite {
+		t.Errorf("modifying a returned transition changed the table: %s", again.Permission)
+	}
+}
+
+func TestIsProductStatus(t *testing.T) {
+	tests := []struct {
+		status string
+		want   bool
+	}{
+		{module.ProductStatusDraft, true},
+		{module.ProductStatusInReview, true},
+		{module.ProductStatusPublished, true},
+		{module.ProductStatusArchived, true},
+		{"", false},
+		{"DRAFT", false},
+		{"active", false},
+	}
+	for _, tt := range tests {
+		if got := IsProductStatus(tt.status); got != tt.want {
+			t.Errorf("IsProductStatus(%q) = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
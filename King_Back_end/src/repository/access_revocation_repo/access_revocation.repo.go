@@ -0,0 +1,46 @@
+package access_revocation_repo
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"thelastking-blogger.com/src/module"
+)
+
+type sql struct {
+	db *gorm.DB
+}
+
+func NewSql(db *gorm.DB) *sql {
+	return &sql{db: db}
+}
+
+func (s *sql) CreateRevocation(ctx context.Context, data *module.AccessTokenRevocation) error {
+	if err := s.db.WithContext(ctx).Table("access_token_revocations").
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(data).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *sql) ListActiveRevocations(ctx context.Context, now time.Time) ([]module.AccessTokenRevocation, error) {
+	var data []module.AccessTokenRevocation
+	if err := s.db.WithContext(ctx).Table("access_token_revocations").
+		Where("expires_at > ?", now).
+		Find(&data).Error; err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (s *sql) DeleteExpiredRevocations(ctx context.Context, now time.Time) error {
+	if err := s.db.WithContext(ctx).Table("access_token_revocations").
+		Where("expires_at <= ?", now).
+		Delete(&module.AccessTokenRevocation{}).Error; err != nil {
+		return err
+	}
+	return nil
+}
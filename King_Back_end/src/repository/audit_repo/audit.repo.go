@@ -0,0 +1,43 @@
+package audit_repo
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"thelastking-blogger.com/src/controller/common"
+	"thelastking-blogger.com/src/module"
+)
+
+type sql struct {
+	db *gorm.DB
+}
+
+func NewSql(db *gorm.DB) *sql {
+	return &sql{db: db}
+}
+
+func (s *sql) ListAuditLogs(ctx context.Context, filter *module.AuditFilter, pagging *common.Paggings) ([]module.AuditLog, error) {
+	db := s.db.WithContext(ctx).Table("audit_log")
+	if filter.EntityType != "" {
+		db = db.Where("entity_type = ?", filter.EntityType)
+	}
+	if filter.EntityID != "" {
+		db = db.Where("entity_id = ?", filter.EntityID)
+	}
+	if filter.ActorID != "" {
+		db = db.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.Action != "" {
+		db = db.Where("action = ?", filter.Action)
+	}
+	if filter.From != nil {
+		db = db.Where("created_at >= ?", filter.From.UTC())
+	}
+	if filter.To != nil {
+		db = db.Where("created_at < ?", filter.To.UTC())
+	}
+	return common.FindPage(db, pagging, nil, common.Keyset{Time: "created_at", ID: "audit_id"}, func(l *module.AuditLog) (*time.Time, string) {
+		return &l.CreatedAt, l.AuditID
+	})
+}
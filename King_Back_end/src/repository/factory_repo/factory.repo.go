@@ -2,14 +2,16 @@ package factory_repo
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"thelastking-blogger.com/src/audit"
 	"thelastking-blogger.com/src/controller/common"
 	"thelastking-blogger.com/src/module"
 	"thelastking-blogger.com/src/module/req_users"
+	"thelastking-blogger.com/src/repository/product_repo"
 	"thelastking-blogger.com/src/utils"
 )
 
@@ -17,18 +19,29 @@ type sql struct {
 	db *gorm.DB
 }
 
+// ListFields là các trường lọc/sắp xếp được của danh sách nhà máy
+var ListFields = common.ListFields{
+	"location":     {Column: "location_id", Type: common.FieldString, Filter: true},
+	"created_at":   {Column: "created_at", Type: common.FieldTime, Filter: true, Sort: true},
+	"updated_at":   {Column: "updated_at", Type: common.FieldTime, Filter: true, Sort: true},
+	"name_factory": {Column: "name_factory", Type: common.FieldString, Sort: true},
+}
+
+// Thứ tự mặc định của danh sách là mới tạo lên đầu, thùng rác là mới xóa lên đầu
+var (
+	listKeyset  = common.Keyset{Time: "f.created_at", ID: "f.factory_id"}
+	trashKeyset = common.Keyset{Time: "f.deleted_at", ID: "f.factory_id"}
+)
+
+func createdKey(f *module.Factories) (*time.Time, string) { return f.CreatedAt, f.Factory_ID }
+func deletedKey(f *module.Factories) (*time.Time, string) { return f.DeletedAt, f.Factory_ID }
+
 func NewSql(db *gorm.DB) *sql {
 	return &sql{db: db}
 }
 
-func (s *sql) CreateFactory(ctx context.Context, data *req_users.FactoriesInput) error {
-	var dataLocation module.Locations
-	if err := s.db.Table("locations").Where("name_local = ?", data.NameLocal).First(&dataLocation).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return fmt.Errorf("locations with name '%s' not found", *data.NameFactory)
-		}
-		return err
-	}
+// CreateFactory: scope nil là phạm vi toàn cục, khu vực theo tên được tìm và kiểm tra phạm vi trong cùng transaction
+func (s *sql) CreateFactory(ctx context.Context, data *req_users.FactoriesInput, scope *module.UserScope) error {
 	newId, err := utils.GenerateUUID()
 	if err != nil {
 		return err
@@ -40,56 +53,176 @@ func (s *sql) CreateFactory(ctx context.Context, data *req_users.FactoriesInput)
 		NameFactory: data.NameFactory,
 		CreatedAt:   &times,
 		UpdatedAt:   &times,
-		Location_ID: dataLocation.Location_ID,
 	}
-	if err := s.db.Table("factories").Create(&newFactory).Error; err != nil {
-		return err
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		location, err := LocationByName(tx, data.NameLocal, scope)
+		if err != nil {
+			return err
+		}
+		newFactory.Location_ID = location.Location_ID
+		if err := tx.Table("factories").Create(&newFactory).Error; err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, module.AuditEntityFactory, newFactory.Factory_ID, module.AuditActionCreate, nil, &newFactory)
+	})
+}
+
+// LocationByName tìm đúng một khu vực chưa xóa theo tên, khóa dòng đó tới hết transaction rồi mới kiểm tra phạm vi.
+// Tên không unique nên khớp nhiều khu vực thì trả ErrAmbiguousName thay vì chọn bừa một bản ghi.
+func LocationByName(tx *gorm.DB, name *string, scope *module.UserScope) (*module.Locations, error) {
+	var rows []module.Locations
+	if err := tx.Table("locations").Clauses(clause.Locking{Strength: "SHARE"}).
+		Where("name_local = ? AND deleted_at IS NULL", name).Limit(2).Find(&rows).Error; err != nil {
+		return nil, err
 	}
-	return nil
+	switch len(rows) {
+	case 0:
+		return nil, fmt.Errorf("%w: location with name '%s'", gorm.ErrRecordNotFound, *name)
+	case 1:
+	default:
+		return nil, fmt.Errorf("%w: location '%s'", module.ErrAmbiguousName, *name)
+	}
+	if !scope.CoversLocation(rows[0].Location_ID) {
+		return nil, module.ErrOutOfScope
+	}
+	return &rows[0], nil
 }
 
 func (s *sql) GetFactory(ctx context.Context, id map[string]any) (*module.Factories, error) {
 	var data module.Factories
-	if err := s.db.Table("factories").Where(id).Find(&data).Error; err != nil {
+	if err := s.db.Table("factories").Where(id).Where("deleted_at IS NULL").Find(&data).Error; err != nil {
 		return nil, err
 	}
 	return &data, nil
 }
 
 func (s *sql) UpdateFactory(ctx context.Context, id map[string]any, upd *module.Factories) error {
-	if err := s.db.Table("factories").Where(id).Updates(upd).Error; err != nil {
-		return err
-	}
-	return nil
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var before, after module.Factories
+		if err := tx.Table("factories").Where(id).Where("deleted_at IS NULL").First(&before).Error; err != nil {
+			return err
+		}
+		if err := tx.Table("factories").Where(id).Updates(upd).Error; err != nil {
+			return err
+		}
+		if err := tx.Table("factories").Where(id).First(&after).Error; err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, module.AuditEntityFactory, before.Factory_ID, module.AuditActionUpdate, &before, &after)
+	})
 }
 
+// DeleteFactory chuyển nhà máy vào thùng rác cùng các sản phẩm của nó, chung một deleted_at
 func (s *sql) DeleteFactory(ctx context.Context, id map[string]any) error {
-	if err := s.db.Table("factories").Where(id).Delete(&module.Factories{}).Error; err != nil {
-		return err
-	}
-	return nil
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var before module.Factories
+		if err := tx.Table("factories").Where(id).Where("deleted_at IS NULL").First(&before).Error; err != nil {
+			return err
+		}
+		var products []module.Products
+		if err := tx.Table("products").Where("factory_id = ? AND deleted_at IS NULL", before.Factory_ID).Find(&products).Error; err != nil {
+			return err
+		}
+		now := time.Now().UTC().Truncate(time.Microsecond)
+		if err := tx.Table("factories").Where("factory_id = ?", before.Factory_ID).Update("deleted_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.Table("products").Where("factory_id = ? AND deleted_at IS NULL", before.Factory_ID).Update("deleted_at", now).Error; err != nil {
+			return err
+		}
+		if err := audit.Record(ctx, tx, module.AuditEntityFactory, before.Factory_ID, module.AuditActionDelete, &before, nil); err != nil {
+			return err
+		}
+		for i := range products {
+			if err := audit.Record(ctx, tx, module.AuditEntityProduct, products[i].Product_ID, module.AuditActionDelete, &products[i], nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
-func (s *sql) GetFactoryList(ctx context.Context, pagging *common.Paggings, morekeys ...string) ([]module.Factories, error) {
-	var data []module.Factories
-	if err := s.db.Table("factories").Count(&pagging.Total).Error; err != nil {
-		return nil, err
-	}
-	if err := s.db.Table("factories").
-		Order("factory_id desc").
-		Offset((pagging.Page - 1) * pagging.Limit).Limit(pagging.Limit).Find(&data).Error; err != nil {
-		return nil, err
+// RestoreFactory lấy nhà máy ra khỏi thùng rác cùng các sản phẩm bị xóa theo nó, khu vực cha phải đang hoạt động
+func (s *sql) RestoreFactory(ctx context.Context, id map[string]any) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var before module.Factories
+		if err := tx.Table("factories").Where(id).Where("deleted_at IS NOT NULL").First(&before).Error; err != nil {
+			return err
+		}
+		var active int64
+		if err := tx.Table("locations").Where("location_id = ? AND deleted_at IS NULL", before.Location_ID).Count(&active).Error; err != nil {
+			return err
+		}
+		if active == 0 {
+			return module.ErrParentDeleted
+		}
+		var products []module.Products
+		if err := tx.Table("products").Where("factory_id = ? AND deleted_at = ?", before.Factory_ID, *before.DeletedAt).Find(&products).Error; err != nil {
+			return err
+		}
+		if err := tx.Table("products").Where("factory_id = ? AND deleted_at = ?", before.Factory_ID, *before.DeletedAt).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Table("factories").Where("factory_id = ?", before.Factory_ID).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		after := before
+		after.DeletedAt = nil
+		if err := audit.Record(ctx, tx, module.AuditEntityFactory, before.Factory_ID, module.AuditActionRestore, &before, &after); err != nil {
+			return err
+		}
+		for i := range products {
+			restored := products[i]
+			restored.DeletedAt = nil
+			if err := audit.Record(ctx, tx, module.AuditEntityProduct, restored.Product_ID, module.AuditActionRestore, &products[i], &restored); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// PurgeFactory xóa vĩnh viễn nhà máy đang trong thùng rác, sản phẩm bị xóa theo qua ON DELETE CASCADE
+func (s *sql) PurgeFactory(ctx context.Context, id map[string]any) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var before module.Factories
+		if err := tx.Table("factories").Where(id).Where("deleted_at IS NOT NULL").First(&before).Error; err != nil {
+			return err
+		}
+		if err := product_repo.RecordPurgedProducts(ctx, tx, "factory_id = ?", before.Factory_ID); err != nil {
+			return err
+		}
+		if err := tx.Table("factories").Where("factory_id = ?", before.Factory_ID).Delete(&module.Factories{}).Error; err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, module.AuditEntityFactory, before.Factory_ID, module.AuditActionPurge, &before, nil)
+	})
+}
+
+func (s *sql) GetFactoryList(ctx context.Context, pagging *common.Paggings, query *common.ListQuery, scope *module.UserScope, morekeys ...string) ([]module.Factories, error) {
+	return s.list(ctx, "deleted_at IS NULL", listKeyset, createdKey, pagging, query, scope)
+}
+
+// ListTrashFactory liệt kê nhà máy trong thùng rác, mới xóa nhất lên đầu
+func (s *sql) ListTrashFactory(ctx context.Context, pagging *common.Paggings, scope *module.UserScope) ([]module.Factories, error) {
+	return s.list(ctx, "deleted_at IS NOT NULL", trashKeyset, deletedKey, pagging, nil, scope)
+}
+
+func (s *sql) list(ctx context.Context, deleted string, keys common.Keyset, key func(*module.Factories) (*time.Time, string),
+	pagging *common.Paggings, query *common.ListQuery, scope *module.UserScope) ([]module.Factories, error) {
+	db := s.db.WithContext(ctx).Table("factories AS f").Where(deleted)
+	if scope != nil {
+		db = db.Where("(factory_id IN ? OR location_id IN ?)", scope.Factories, scope.Locations)
 	}
-	return data, nil
+	return common.FindPage(db, pagging, query, keys, key)
 }
 
-func (s *sql) GetFactoryListByLocal(ctx context.Context, locationName map[string]any) ([]module.Factories, error) {
-	var listFactory []module.Factories
-	if err := s.db.Table("factories AS f").
+func (s *sql) GetFactoryListByLocal(ctx context.Context, locationName map[string]any, pagging *common.Paggings) ([]module.Factories, error) {
+	db := s.db.WithContext(ctx).
+		Table("factories AS f").
 		Select("f.*").
 		Joins("JOIN locations AS l ON l.location_id = f.location_id").
-		Where(locationName).Find(&listFactory).Error; err != nil {
-		return nil, err
-	}
-	return listFactory, nil
+		Where("f.deleted_at IS NULL AND l.deleted_at IS NULL").
+		Where(locationName)
+	return common.FindPage(db, pagging, nil, listKeyset, createdKey)
 }
@@ -0,0 +1,90 @@
+package import_repo
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+	"thelastking-blogger.com/src/module"
+	"thelastking-blogger.com/src/module/req_users"
+	"thelastking-blogger.com/src/repository/factory_repo"
+	"thelastking-blogger.com/src/repository/location_repo"
+	"thelastking-blogger.com/src/repository/product_repo"
+)
+
+type sql struct {
+	db *gorm.DB
+}
+
+func NewSql(db *gorm.DB) *sql {
+	return &sql{db: db}
+}
+
+// FindLocations trả về các khu vực chưa bị xóa có tên trong names
+func (s *sql) FindLocations(ctx context.Context, names []string) ([]module.Locations, error) {
+	var data []module.Locations
+	if len(names) == 0 {
+		return data, nil
+	}
+	if err := s.db.WithContext(ctx).Table("locations").
+		Where("name_local IN ? AND deleted_at IS NULL", names).Find(&data).Error; err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// FindFactories trả về các nhà máy chưa bị xóa có tên trong names
+func (s *sql) FindFactories(ctx context.Context, names []string) ([]module.Factories, error) {
+	var data []module.Factories
+	if len(names) == 0 {
+		return data, nil
+	}
+	if err := s.db.WithContext(ctx).Table("factories").
+		Where("name_factory IN ? AND deleted_at IS NULL", names).Find(&data).Error; err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// FindProducts trả về tiêu đề các sản phẩm chưa bị xóa của những nhà máy trong factoryIDs
+func (s *sql) FindProducts(ctx context.Context, factoryIDs []string) ([]module.Products, error) {
+	var data []module.Products
+	if len(factoryIDs) == 0 {
+		return data, nil
+	}
+	if err := s.db.WithContext(ctx).Table("products").Select("product_id", "title", "factory_id").
+		Where("factory_id IN ? AND deleted_at IS NULL", factoryIDs).Find(&data).Error; err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Import tạo toàn bộ khu vực, nhà máy, sản phẩm trong một transaction, lỗi ở bất kỳ dòng nào thì không dòng nào được ghi.
+// Dùng lại Create của từng repo (transaction lồng nhau thành savepoint) nên audit_log, product_revisions
+// và ảnh chính trong gallery giống hệt khi tạo qua API. created được gọi sau mỗi bản ghi.
+func (s *sql) Import(ctx context.Context, locations []module.Locations, factories []req_users.FactoriesInput, products []req_users.ProductInput, created func()) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		locationRepo := location_repo.NewSql(tx)
+		for i := range locations {
+			if err := locationRepo.CreateLocation(ctx, &locations[i]); err != nil {
+				return fmt.Errorf("location %q: %w", *locations[i].NameLocal, err)
+			}
+			created()
+		}
+		factoryRepo := factory_repo.NewSql(tx)
+		for i := range factories {
+			if err := factoryRepo.CreateFactory(ctx, &factories[i], nil); err != nil {
+				return fmt.Errorf("factory %q: %w", *factories[i].NameFactory, err)
+			}
+			created()
+		}
+		productRepo := product_repo.NewSql(tx)
+		for i := range products {
+			if err := productRepo.CreateProduct(ctx, &products[i], nil); err != nil {
+				return fmt.Errorf("product %q: %w", *products[i].Title, err)
+			}
+			created()
+		}
+		return nil
+	})
+}
@@ -0,0 +1,67 @@
+package jwt_key_repo
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"thelastking-blogger.com/src/module"
+)
+
+// rotationLockKey tránh việc nhiều instance cùng tạo khóa mới trong một lượt xoay vòng
+const rotationLockKey int64 = 72025002
+
+type sql struct {
+	db *gorm.DB
+}
+
+func NewSql(db *gorm.DB) *sql {
+	return &sql{db: db}
+}
+
+// ListJwtKeys trả về các khóa còn dùng để verify, mới nhất trước
+func (s *sql) ListJwtKeys(ctx context.Context, now time.Time) ([]module.JwtKey, error) {
+	var keys []module.JwtKey
+	if err := s.db.WithContext(ctx).Table("jwt_keys").
+		Where("expires_at > ?", now).
+		Order("created_at desc").
+		Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// CreateJwtKeyIfDue lưu khóa mới nếu không còn khóa nào cùng thuật toán ký được tới activeUntil.
+// Trả về false khi một instance khác đã tạo khóa trước.
+func (s *sql) CreateJwtKeyIfDue(ctx context.Context, key *module.JwtKey, activeUntil time.Time) (bool, error) {
+	created := false
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", rotationLockKey).Error; err != nil {
+			return err
+		}
+		var active int64
+		if err := tx.Table("jwt_keys").
+			Where("retire_at > ? AND algorithm = ?", activeUntil, key.Algorithm).
+			Count(&active).Error; err != nil {
+			return err
+		}
+		if active > 0 {
+			return nil
+		}
+		if err := tx.Table("jwt_keys").Create(key).Error; err != nil {
+			return err
+		}
+		created = true
+		return nil
+	})
+	return created, err
+}
+
+func (s *sql) DeleteExpiredJwtKeys(ctx context.Context, now time.Time) error {
+	if err := s.db.WithContext(ctx).Table("jwt_keys").
+		Where("expires_at <= ?", now).
+		Delete(&module.JwtKey{}).Error; err != nil {
+		return err
+	}
+	return nil
+}
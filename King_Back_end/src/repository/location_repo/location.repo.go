@@ -2,59 +2,230 @@ package location_repo
 
 import (
 	"context"
+	"time"
 
 	"gorm.io/gorm"
+	"thelastking-blogger.com/src/audit"
 	"thelastking-blogger.com/src/controller/common"
 	"thelastking-blogger.com/src/module"
+	"thelastking-blogger.com/src/repository/product_repo"
 )
 
 type sql struct {
 	db *gorm.DB
 }
 
+// ListFields là các trường lọc/sắp xếp được của danh sách khu vực
+var ListFields = common.ListFields{
+	"created_at": {Column: "created_at", Type: common.FieldTime, Filter: true, Sort: true},
+	"updated_at": {Column: "updated_at", Type: common.FieldTime, Filter: true, Sort: true},
+	"name_local": {Column: "name_local", Type: common.FieldString, Sort: true},
+}
+
+// Thứ tự mặc định của danh sách là mới tạo lên đầu, thùng rác là mới xóa lên đầu
+var (
+	listKeyset  = common.Keyset{Time: "created_at", ID: "location_id"}
+	trashKeyset = common.Keyset{Time: "deleted_at", ID: "location_id"}
+)
+
+func createdKey(l *module.Locations) (*time.Time, string) { return l.CreatedAt, l.Location_ID }
+func deletedKey(l *module.Locations) (*time.Time, string) { return l.DeletedAt, l.Location_ID }
+
 func NewSql(db *gorm.DB) *sql {
 	return &sql{db: db}
 }
 
 func (s *sql) CreateLocation(ctx context.Context, data *module.Locations) error {
-	if err := s.db.Table("locations").Create(&data).Error; err != nil {
-		return err
-	}
-	return nil
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table("locations").Create(&data).Error; err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, module.AuditEntityLocation, data.Location_ID, module.AuditActionCreate, nil, data)
+	})
 }
 
 func (s *sql) GetLocation(ctx context.Context, id map[string]any) (*module.Locations, error) {
 	var data module.Locations
-	if err := s.db.Table("locations").Where(id).First(&data).Error; err != nil {
+	if err := s.db.Table("locations").Where(id).Where("deleted_at IS NULL").First(&data).Error; err != nil {
 		return nil, err
 	}
 	return &data, nil
 }
 
 func (s *sql) UpdateLocation(ctx context.Context, id map[string]any, upd *module.Locations) error {
-	if err := s.db.Table("locations").Where(id).Updates(upd).Error; err != nil {
-		return err
-	}
-	return nil
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var before, after module.Locations
+		if err := tx.Table("locations").Where(id).Where("deleted_at IS NULL").First(&before).Error; err != nil {
+			return err
+		}
+		if err := tx.Table("locations").Where(id).Updates(upd).Error; err != nil {
+			return err
+		}
+		if err := tx.Table("locations").Where(id).First(&after).Error; err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, module.AuditEntityLocation, before.Location_ID, module.AuditActionUpdate, &before, &after)
+	})
 }
 
+// DeleteLocation chuyển khu vực vào thùng rác cùng mọi nhà máy và sản phẩm bên dưới, tất cả chung một deleted_at
 func (s *sql) DeleteLocation(ctx context.Context, id map[string]any) error {
-	var dataLocation module.Locations
-	if err := s.db.Table("locations").Where(id).Delete(&dataLocation).Error; err != nil {
-		return err
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var dataLocation module.Locations
+		if err := tx.Table("locations").Where(id).Where("deleted_at IS NULL").First(&dataLocation).Error; err != nil {
+			return err
+		}
+		factories, products, err := children(tx, dataLocation.Location_ID, "deleted_at IS NULL")
+		if err != nil {
+			return err
+		}
+		now := time.Now().UTC().Truncate(time.Microsecond)
+		if err := setDeletedAt(tx, dataLocation.Location_ID, factories, products, &now); err != nil {
+			return err
+		}
+		if err := audit.Record(ctx, tx, module.AuditEntityLocation, dataLocation.Location_ID, module.AuditActionDelete, &dataLocation, nil); err != nil {
+			return err
+		}
+		for i := range factories {
+			if err := audit.Record(ctx, tx, module.AuditEntityFactory, factories[i].Factory_ID, module.AuditActionDelete, &factories[i], nil); err != nil {
+				return err
+			}
+		}
+		for i := range products {
+			if err := audit.Record(ctx, tx, module.AuditEntityProduct, products[i].Product_ID, module.AuditActionDelete, &products[i], nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RestoreLocation lấy khu vực ra khỏi thùng rác cùng các nhà máy, sản phẩm bị xóa theo nó.
+// Con bị xóa riêng từ trước (deleted_at khác) vẫn nằm trong thùng rác.
+func (s *sql) RestoreLocation(ctx context.Context, id map[string]any) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var before module.Locations
+		if err := tx.Table("locations").Where(id).Where("deleted_at IS NOT NULL").First(&before).Error; err != nil {
+			return err
+		}
+		factories, products, err := children(tx, before.Location_ID, "deleted_at = ?", *before.DeletedAt)
+		if err != nil {
+			return err
+		}
+		if err := setDeletedAt(tx, before.Location_ID, factories, products, nil); err != nil {
+			return err
+		}
+		after := before
+		after.DeletedAt = nil
+		if err := audit.Record(ctx, tx, module.AuditEntityLocation, before.Location_ID, module.AuditActionRestore, &before, &after); err != nil {
+			return err
+		}
+		for i := range factories {
+			restored := factories[i]
+			restored.DeletedAt = nil
+			if err := audit.Record(ctx, tx, module.AuditEntityFactory, restored.Factory_ID, module.AuditActionRestore, &factories[i], &restored); err != nil {
+				return err
+			}
+		}
+		for i := range products {
+			restored := products[i]
+			restored.DeletedAt = nil
+			if err := audit.Record(ctx, tx, module.AuditEntityProduct, restored.Product_ID, module.AuditActionRestore, &products[i], &restored); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// PurgeLocation xóa vĩnh viễn khu vực đang trong thùng rác, ON DELETE CASCADE xóa luôn nhà máy và sản phẩm
+// (cũng đã nằm trong thùng rác vì không khôi phục được con khi cha còn bị xóa)
+func (s *sql) PurgeLocation(ctx context.Context, id map[string]any) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var before module.Locations
+		if err := tx.Table("locations").Where(id).Where("deleted_at IS NOT NULL").First(&before).Error; err != nil {
+			return err
+		}
+		// Xóa khu vực kéo theo nhà máy và sản phẩm qua ON DELETE CASCADE nên phải ghi audit cho chúng trước
+		var factories []module.Factories
+		if err := tx.Table("factories").Where("location_id = ?", before.Location_ID).Find(&factories).Error; err != nil {
+			return err
+		}
+		for i := range factories {
+			if err := audit.Record(ctx, tx, module.AuditEntityFactory, factories[i].Factory_ID, module.AuditActionPurge, &factories[i], nil); err != nil {
+				return err
+			}
+		}
+		if err := product_repo.RecordPurgedProducts(ctx, tx, "factory_id IN (SELECT factory_id FROM factories WHERE location_id = ?)", before.Location_ID); err != nil {
+			return err
+		}
+		if err := tx.Table("locations").Where("location_id = ?", before.Location_ID).Delete(&module.Locations{}).Error; err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, module.AuditEntityLocation, before.Location_ID, module.AuditActionPurge, &before, nil)
+	})
+}
+
+// ListLocation: với scope, khu vực chứa nhà máy được gán cũng được trả về để client hiển thị đường dẫn
+func (s *sql) ListLocation(ctx context.Context, pagging *common.Paggings, query *common.ListQuery, scope *module.UserScope, morekeys ...string) ([]module.Locations, error) {
+	return s.list(ctx, "deleted_at IS NULL", listKeyset, createdKey, pagging, query, scope)
+}
+
+// ListTrashLocation liệt kê khu vực trong thùng rác, mới xóa nhất lên đầu
+func (s *sql) ListTrashLocation(ctx context.Context, pagging *common.Paggings, scope *module.UserScope) ([]module.Locations, error) {
+	return s.list(ctx, "deleted_at IS NOT NULL", trashKeyset, deletedKey, pagging, nil, scope)
+}
+
+func (s *sql) list(ctx context.Context, deleted string, keys common.Keyset, key func(*module.Locations) (*time.Time, string),
+	pagging *common.Paggings, query *common.ListQuery, scope *module.UserScope) ([]module.Locations, error) {
+	db := s.db.WithContext(ctx).Table("locations").Where(deleted)
+	if scope != nil {
+		db = db.Where("(location_id IN ? OR location_id IN (SELECT location_id FROM factories WHERE factory_id IN ?))",
+			scope.Locations, scope.Factories)
 	}
-	return nil
+	return common.FindPage(db, pagging, query, keys, key)
 }
 
-func (s *sql) ListLocation(ctx context.Context, pagging *common.Paggings, morekeys ...string) ([]module.Locations, error) {
-	var data []module.Locations
-	if err := s.db.Table("locations").Count(&pagging.Total).Error; err != nil {
-		return nil, err
+// children trả về nhà máy của khu vực và sản phẩm của các nhà máy đó thỏa điều kiện deleted_at
+func children(tx *gorm.DB, locationID string, deleted string, args ...any) ([]module.Factories, []module.Products, error) {
+	var factories []module.Factories
+	if err := tx.Table("factories").Where("location_id = ?", locationID).Where(deleted, args...).Find(&factories).Error; err != nil {
+		return nil, nil, err
 	}
-	if err := s.db.Table("locations").
-		Order("location_id desc").
-		Offset((pagging.Page - 1) * pagging.Limit).Limit(pagging.Limit).Find(&data).Error; err != nil {
-		return nil, err
+	factoryIDs := make([]string, 0, len(factories))
+	for _, factory := range factories {
+		factoryIDs = append(factoryIDs, factory.Factory_ID)
 	}
-	return data, nil
+	var products []module.Products
+	if len(factoryIDs) > 0 {
+		if err := tx.Table("products").Where("factory_id IN ?", factoryIDs).Where(deleted, args...).Find(&products).Error; err != nil {
+			return nil, nil, err
+		}
+	}
+	return factories, products, nil
+}
+
+func setDeletedAt(tx *gorm.DB, locationID string, factories []module.Factories, products []module.Products, deletedAt *time.Time) error {
+	if err := tx.Table("locations").Where("location_id = ?", locationID).Update("deleted_at", deletedAt).Error; err != nil {
+		return err
+	}
+	if len(factories) > 0 {
+		ids := make([]string, 0, len(factories))
+		for _, factory := range factories {
+			ids = append(ids, factory.Factory_ID)
+		}
+		if err := tx.Table("factories").Where("factory_id IN ?", ids).Update("deleted_at", deletedAt).Error; err != nil {
+			return err
+		}
+	}
+	if len(products) > 0 {
+		ids := make([]string, 0, len(products))
+		for _, product := range products {
+			ids = append(ids, product.Product_ID)
+		}
+		if err := tx.Table("products").Where("product_id IN ?", ids).Update("deleted_at", deletedAt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -0,0 +1,85 @@
+package login_attempt_repo
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"thelastking-blogger.com/src/module"
+)
+
+type sql struct {
+	db *gorm.DB
+}
+
+func NewSql(db *gorm.DB) *sql {
+	return &sql{db: db}
+}
+
+func (s *sql) GetActiveLockouts(ctx context.Context, keys []string, now time.Time) ([]module.LoginAttempt, error) {
+	var data []module.LoginAttempt
+	if err := s.db.WithContext(ctx).Table("login_attempts").
+		Where("attempt_key IN ? AND locked_until > ?", keys, now).
+		Find(&data).Error; err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// RecordLoginFailure tăng bộ đếm trong một câu lệnh để các request đồng thời không ghi đè nhau.
+// Lần sai trước đã quá windowStart thì bộ đếm bắt đầu lại từ 1.
+func (s *sql) RecordLoginFailure(ctx context.Context, key, kind string, userID *string, now, windowStart time.Time) (*module.LoginAttempt, error) {
+	var data module.LoginAttempt
+	if err := s.db.WithContext(ctx).Raw(`INSERT INTO login_attempts (attempt_key, kind, user_id, failures, last_failed_at, updated_at)
+		VALUES (?, ?, ?, 1, ?, ?)
+		ON CONFLICT (attempt_key) DO UPDATE SET
+			failures = CASE WHEN login_attempts.last_failed_at < ? THEN 1 ELSE login_attempts.failures + 1 END,
+			user_id = COALESCE(EXCLUDED.user_id, login_attempts.user_id),
+			last_failed_at = EXCLUDED.last_failed_at,
+			updated_at = EXCLUDED.updated_at
+		RETURNING *`, key, kind, userID, now, now, windowStart).
+		Scan(&data).Error; err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+func (s *sql) LockLoginAttempt(ctx context.Context, key string, until time.Time) error {
+	if err := s.db.WithContext(ctx).Table("login_attempts").
+		Where("attempt_key = ?", key).
+		Updates(map[string]any{"locked_until": until, "updated_at": time.Now().UTC()}).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *sql) ResetLoginAttempts(ctx context.Context, key string) (bool, error) {
+	result := s.db.WithContext(ctx).Table("login_attempts").
+		Where("attempt_key = ?", key).
+		Delete(&module.LoginAttempt{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (s *sql) ListLockedLoginAttempts(ctx context.Context, now time.Time) ([]module.LoginAttempt, error) {
+	var data []module.LoginAttempt
+	if err := s.db.WithContext(ctx).Table("login_attempts").
+		Where("locked_until > ?", now).
+		Order("locked_until DESC").
+		Find(&data).Error; err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// DeleteStaleLoginAttempts xóa bộ đếm đã hết cửa sổ và không còn bị khóa
+func (s *sql) DeleteStaleLoginAttempts(ctx context.Context, windowStart, now time.Time) error {
+	if err := s.db.WithContext(ctx).Table("login_attempts").
+		Where("last_failed_at < ? AND (locked_until IS NULL OR locked_until < ?)", windowStart, now).
+		Delete(&module.LoginAttempt{}).Error; err != nil {
+		return err
+	}
+	return nil
+}
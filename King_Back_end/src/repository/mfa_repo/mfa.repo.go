@@ -0,0 +1,121 @@
+package mfa_repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"thelastking-blogger.com/src/module"
+)
+
+type sql struct {
+	db *gorm.DB
+}
+
+func NewSql(db *gorm.DB) *sql {
+	return &sql{db: db}
+}
+
+func (s *sql) GetUserMfa(ctx context.Context, userID string) (*module.UserMfa, error) {
+	var data module.UserMfa
+	if err := s.db.WithContext(ctx).Table("user_mfa").Where("user_id = ?", userID).First(&data).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, module.ErrMfaNotEnabled
+		}
+		return nil, err
+	}
+	return &data, nil
+}
+
+// SavePendingMfa lưu secret mới khi user bắt đầu đăng ký; không ghi đè nếu 2FA đã bật
+func (s *sql) SavePendingMfa(ctx context.Context, data *module.UserMfa) error {
+	result := s.db.WithContext(ctx).Table("user_mfa").
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}},
+			DoUpdates: clause.Assignments(map[string]any{"secret": data.Secret, "created_at": data.CreatedAt}),
+			Where:     clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "user_mfa.enabled = ?", Vars: []any{false}}}},
+		}).
+		Create(data)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return module.ErrMfaAlreadyEnabled
+	}
+	return nil
+}
+
+// EnableMfa bật 2FA và thay toàn bộ mã khôi phục trong một transaction
+func (s *sql) EnableMfa(ctx context.Context, userID string, step int64, codes []module.MfaRecoveryCode) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Table("user_mfa").
+			Where("user_id = ? AND enabled = ?", userID, false).
+			Updates(map[string]any{"enabled": true, "enabled_at": time.Now().UTC(), "last_used_step": step})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return module.ErrMfaAlreadyEnabled
+		}
+		return replaceRecoveryCodes(tx, userID, codes)
+	})
+}
+
+// UseTotpStep ghi nhận bước thời gian vừa dùng; trả về false nếu mã của bước này (hoặc bước sau) đã được dùng
+func (s *sql) UseTotpStep(ctx context.Context, userID string, step int64) (bool, error) {
+	result := s.db.WithContext(ctx).Table("user_mfa").
+		Where("user_id = ? AND (last_used_step IS NULL OR last_used_step < ?)", userID, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// UseRecoveryCode đánh dấu mã khôi phục đã dùng; trả về false nếu mã không tồn tại hoặc đã dùng
+func (s *sql) UseRecoveryCode(ctx context.Context, userID string, codeHash string) (bool, error) {
+	result := s.db.WithContext(ctx).Table("mfa_recovery_codes").
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now().UTC())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (s *sql) ReplaceRecoveryCodes(ctx context.Context, userID string, codes []module.MfaRecoveryCode) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return replaceRecoveryCodes(tx, userID, codes)
+	})
+}
+
+func (s *sql) CountRecoveryCodes(ctx context.Context, userID string) (int64, error) {
+	var count int64
+	if err := s.db.WithContext(ctx).Table("mfa_recovery_codes").
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (s *sql) DeleteUserMfa(ctx context.Context, userID string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table("mfa_recovery_codes").Where("user_id = ?", userID).Delete(&module.MfaRecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Table("user_mfa").Where("user_id = ?", userID).Delete(&module.UserMfa{}).Error
+	})
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID string, codes []module.MfaRecoveryCode) error {
+	if err := tx.Table("mfa_recovery_codes").Where("user_id = ?", userID).Delete(&module.MfaRecoveryCode{}).Error; err != nil {
+		return err
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+	return tx.Table("mfa_recovery_codes").Create(&codes).Error
+}
@@ -0,0 +1,125 @@
+package oidc_repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"thelastking-blogger.com/src/audit"
+	"thelastking-blogger.com/src/module"
+)
+
+type sql struct {
+	db *gorm.DB
+}
+
+func NewSql(db *gorm.DB) *sql {
+	return &sql{db: db}
+}
+
+func (s *sql) CreateLoginState(ctx context.Context, data *module.OidcLoginState) error {
+	if err := s.db.WithContext(ctx).Table("oidc_login_states").Create(data).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// ConsumeLoginState xóa và trả về state trong một câu lệnh, mỗi state chỉ dùng được một lần
+func (s *sql) ConsumeLoginState(ctx context.Context, stateHash string, now time.Time) (*module.OidcLoginState, error) {
+	var state module.OidcLoginState
+	if err := s.db.WithContext(ctx).
+		Raw("DELETE FROM oidc_login_states WHERE state_hash = ? RETURNING *", stateHash).
+		Scan(&state).Error; err != nil {
+		return nil, err
+	}
+	if state.StateHash == "" || state.ExpiresAt.Before(now) {
+		return nil, module.ErrOidcStateInvalid
+	}
+	return &state, nil
+}
+
+func (s *sql) DeleteExpiredLoginStates(ctx context.Context, now time.Time) error {
+	if err := s.db.WithContext(ctx).Table("oidc_login_states").
+		Where("expires_at < ?", now).
+		Delete(&module.OidcLoginState{}).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// FindUserByIdentity trả về user đã liên kết với (issuer, sub), gorm.ErrRecordNotFound nếu chưa liên kết.
+// Tài khoản trong thùng rác vẫn được trả về để service từ chối đăng nhập thay vì tạo tài khoản mới.
+func (s *sql) FindUserByIdentity(ctx context.Context, issuer, subject string) (*module.Users, error) {
+	var data module.Users
+	if err := s.db.WithContext(ctx).Table("users u").
+		Select("u.*").
+		Joins("JOIN user_identities i ON i.user_id = u.user_id").
+		Where("i.issuer = ? AND i.subject = ?", issuer, subject).
+		First(&data).Error; err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// FindUserByAccount ưu tiên tài khoản đang hoạt động khi account trùng với tài khoản đã bị xóa
+func (s *sql) FindUserByAccount(ctx context.Context, account string) (*module.Users, error) {
+	var data module.Users
+	if err := s.db.WithContext(ctx).Table("users").
+		Where("LOWER(account) = LOWER(?)", account).
+		Order("deleted_at DESC NULLS FIRST").
+		First(&data).Error; err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// CreateUserWithIdentity tạo tài khoản mới và liên kết định danh trong cùng một transaction
+func (s *sql) CreateUserWithIdentity(ctx context.Context, user *module.Users, identity *module.UserIdentity) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table("users").Create(user).Error; err != nil {
+			return err
+		}
+		if err := tx.Table("user_identities").Create(identity).Error; err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, module.AuditEntityUser, user.UserID, module.AuditActionCreate, nil, user)
+	})
+}
+
+func (s *sql) CreateIdentity(ctx context.Context, identity *module.UserIdentity) error {
+	if err := s.db.WithContext(ctx).Table("user_identities").Create(identity).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *sql) TouchIdentity(ctx context.Context, issuer, subject, email string, now time.Time) error {
+	if err := s.db.WithContext(ctx).Table("user_identities").
+		Where("issuer = ? AND subject = ?", issuer, subject).
+		Updates(map[string]any{"email": email, "last_login_at": now}).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *sql) UpdateUserRole(ctx context.Context, userID, role string, now time.Time) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var before, after module.Users
+		if err := tx.Table("users").Where("user_id = ? AND deleted_at IS NULL", userID).First(&before).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("user not found")
+			}
+			return err
+		}
+		if err := tx.Table("users").
+			Where("user_id = ?", userID).
+			Updates(map[string]any{"role_user": role, "updated_at": now}).Error; err != nil {
+			return err
+		}
+		if err := tx.Table("users").Where("user_id = ?", userID).First(&after).Error; err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, module.AuditEntityUser, userID, module.AuditActionUpdate, &before, &after)
+	})
+}
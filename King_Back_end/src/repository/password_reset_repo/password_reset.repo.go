@@ -0,0 +1,93 @@
+package password_reset_repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"thelastking-blogger.com/src/audit"
+	"thelastking-blogger.com/src/module"
+)
+
+type sql struct {
+	db *gorm.DB
+}
+
+func NewSql(db *gorm.DB) *sql {
+	return &sql{db: db}
+}
+
+// CreatePasswordReset lưu token mới và bỏ các token chưa dùng trước đó của user, chỉ link mới nhất còn hiệu lực
+func (s *sql) CreatePasswordReset(ctx context.Context, data *module.PasswordReset) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table("password_resets").
+			Where("user_id = ? AND used_at IS NULL", data.UserID).
+			Delete(&module.PasswordReset{}).Error; err != nil {
+			return err
+		}
+		return tx.Table("password_resets").Create(data).Error
+	})
+}
+
+// ConsumePasswordReset đánh dấu token đã dùng và đổi mật khẩu trong cùng một transaction,
+// khóa dòng token để hai request đồng thời không dùng được cùng một token
+func (s *sql) ConsumePasswordReset(ctx context.Context, tokenHash string, hashedPassword string) (string, error) {
+	var userID string
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var reset module.PasswordReset
+		if err := tx.Table("password_resets").
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", tokenHash).
+			First(&reset).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return module.ErrPasswordResetInvalid
+			}
+			return err
+		}
+		now := time.Now().UTC()
+		if reset.UsedAt != nil || reset.ExpiresAt.Before(now) {
+			return module.ErrPasswordResetInvalid
+		}
+
+		if err := tx.Table("password_resets").
+			Where("token_hash = ?", tokenHash).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+		var before, after module.Users
+		if err := tx.Table("users").Where("user_id = ? AND deleted_at IS NULL", reset.UserID).First(&before).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return module.ErrPasswordResetInvalid
+			}
+			return err
+		}
+		if err := tx.Table("users").
+			Where("user_id = ?", reset.UserID).
+			Updates(map[string]any{"password_user": hashedPassword, "updated_at": now}).Error; err != nil {
+			return err
+		}
+		if err := tx.Table("users").Where("user_id = ?", reset.UserID).First(&after).Error; err != nil {
+			return err
+		}
+		if err := audit.Record(ctx, tx, module.AuditEntityUser, reset.UserID, module.AuditActionUpdate, &before, &after); err != nil {
+			return err
+		}
+		userID = reset.UserID
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return userID, nil
+}
+
+func (s *sql) DeleteExpiredPasswordResets(ctx context.Context, now time.Time) error {
+	if err := s.db.WithContext(ctx).Table("password_resets").
+		Where("expires_at < ? OR used_at IS NOT NULL", now).
+		Delete(&module.PasswordReset{}).Error; err != nil {
+		return err
+	}
+	return nil
+}
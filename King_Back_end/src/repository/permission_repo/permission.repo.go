@@ -0,0 +1,62 @@
+package permission_repo
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"thelastking-blogger.com/src/module"
+)
+
+type sql struct {
+	db *gorm.DB
+}
+
+func NewSql(db *gorm.DB) *sql {
+	return &sql{db: db}
+}
+
+func (s *sql) ListPermissions(ctx context.Context) ([]module.Permission, error) {
+	var data []module.Permission
+	if err := s.db.WithContext(ctx).Table("permissions").Order("permission_key").Find(&data).Error; err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (s *sql) ListRolePermissions(ctx context.Context) ([]module.RolePermission, error) {
+	var data []module.RolePermission
+	if err := s.db.WithContext(ctx).Table("role_permissions").Order("role_user, permission_key").Find(&data).Error; err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (s *sql) GrantPermission(ctx context.Context, data *module.RolePermission) error {
+	var count int64
+	if err := s.db.WithContext(ctx).Table("permissions").Where("permission_key = ?", data.PermissionKey).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return module.ErrPermissionNotFound
+	}
+	if err := s.db.WithContext(ctx).Table("role_permissions").
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(data).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *sql) RevokePermission(ctx context.Context, role, permissionKey string) error {
+	result := s.db.WithContext(ctx).Table("role_permissions").
+		Where("role_user = ? AND permission_key = ?", role, permissionKey).
+		Delete(&module.RolePermission{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return module.ErrPermissionNotFound
+	}
+	return nil
+}
@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"thelastking-blogger.com/src/audit"
 	"thelastking-blogger.com/src/controller/common"
 	"thelastking-blogger.com/src/module"
 	"thelastking-blogger.com/src/module/req_users"
@@ -17,20 +19,34 @@ type sql struct {
 	db *gorm.DB
 }
 
+// ListFields là các trường lọc/sắp xếp được của danh sách sản phẩm
+var ListFields = common.ListFields{
+	"status":       {Column: "p.status", Type: common.FieldString, Filter: true, Sort: true},
+	"year_product": {Column: "p.year_product", Type: common.FieldDate, Filter: true, Sort: true},
+	"created_at":   {Column: "p.created_at", Type: common.FieldTime, Filter: true, Sort: true},
+	"updated_at":   {Column: "p.updated_at", Type: common.FieldTime, Filter: true, Sort: true},
+	"factory":      {Column: "p.factory_id", Type: common.FieldString, Filter: true},
+	"location":     {Column: "f.location_id", Type: common.FieldString, Filter: true},
+	"title":        {Column: "p.title", Type: common.FieldString, Sort: true},
+}
+
+// Thứ tự mặc định của danh sách là mới tạo lên đầu, thùng rác là mới xóa lên đầu
+var (
+	listKeyset  = common.Keyset{Time: "p.created_at", ID: "p.product_id"}
+	trashKeyset = common.Keyset{Time: "p.deleted_at", ID: "p.product_id"}
+)
+
+func createdKey(p *module.Products) (*time.Time, string) { return p.CreatedAt, p.Product_ID }
+func deletedKey(p *module.Products) (*time.Time, string) { return p.DeletedAt, p.Product_ID }
+
 func NewSql(db *gorm.DB) *sql {
 	return &sql{
 		db: db,
 	}
 }
 
-func (s *sql) CreateProduct(ctx context.Context, data *req_users.ProductInput) error {
-	var factory module.Factories
-	if err := s.db.Table("factories").Where("name_factory = ?", data.NameFactory).First(&factory).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return fmt.Errorf("factory with name '%s' not found", *data.NameFactory)
-		}
-		return err
-	}
+// CreateProduct: scope nil là phạm vi toàn cục, nhà máy theo tên được tìm và kiểm tra phạm vi trong cùng transaction
+func (s *sql) CreateProduct(ctx context.Context, data *req_users.ProductInput, scope *module.UserScope) error {
 	newId, err := utils.GenerateUUID()
 	if err != nil {
 		return err
@@ -46,77 +62,752 @@ func (s *sql) CreateProduct(ctx context.Context, data *req_users.ProductInput) e
 		Year:       data.Year,
 		CreatedAt:  &times,
 		UpdatedAt:  &times,
-		Factory_ID: factory.Factory_ID,
+
+		ImageVariants: data.ImageVariants,
 	}
 
-	if err := s.db.Table("products").Create(&product).Error; err != nil {
-		return err
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		factory, err := FactoryByName(tx, data.NameFactory, scope)
+		if err != nil {
+			return err
+		}
+		product.Factory_ID = factory.Factory_ID
+		if err := tx.Table("products").Create(&product).Error; err != nil {
+			return err
+		}
+		if err := recordRevision(ctx, tx, &product, nil); err != nil {
+			return err
+		}
+		if err := audit.Record(ctx, tx, module.AuditEntityProduct, product.Product_ID, module.AuditActionCreate, nil, &product); err != nil {
+			return err
+		}
+		return syncPrimaryMedia(ctx, tx, &product)
+	})
+}
+
+// FactoryByName tìm đúng một nhà máy chưa xóa theo tên, khóa dòng đó tới hết transaction rồi mới kiểm tra phạm vi.
+// Tên không unique nên khớp nhiều nhà máy thì trả ErrAmbiguousName thay vì chọn bừa một bản ghi.
+func FactoryByName(tx *gorm.DB, name *string, scope *module.UserScope) (*module.Factories, error) {
+	var rows []module.Factories
+	if err := tx.Table("factories").Clauses(clause.Locking{Strength: "SHARE"}).
+		Where("name_factory = ? AND deleted_at IS NULL", name).Limit(2).Find(&rows).Error; err != nil {
+		return nil, err
 	}
-	return nil
+	switch len(rows) {
+	case 0:
+		return nil, fmt.Errorf("%w: factory with name '%s'", gorm.ErrRecordNotFound, *name)
+	case 1:
+	default:
+		return nil, fmt.Errorf("%w: factory '%s'", module.ErrAmbiguousName, *name)
+	}
+	if !scope.CoversFactory(rows[0].Factory_ID, rows[0].Location_ID) {
+		return nil, module.ErrOutOfScope
+	}
+	return &rows[0], nil
 }
 
 func (s *sql) GetProduct(ctx context.Context, idProduct map[string]any) (*module.Products, error) {
 	var data module.Products
-	if err := s.db.Table("products").Where(idProduct).First(&data).Error; err != nil {
+	if err := s.db.Table("products").Where(idProduct).Where("deleted_at IS NULL").First(&data).Error; err != nil {
 		return nil, err
 	}
 	return &data, nil
 }
 
-func (s *sql) UpdateProduct(ctx context.Context, idProduct map[string]any, upd *req_users.ProductInput) error {
-	if err := s.db.Table("products").Where(idProduct).Updates(upd).Error; err != nil {
+// UpdateProduct: name_factory không phải cột của products, có giá trị thì đổi factory_id sang nhà máy đó
+// (nhà máy mới cũng phải nằm trong scope, nil là phạm vi toàn cục)
+func (s *sql) UpdateProduct(ctx context.Context, idProduct map[string]any, upd *req_users.ProductInput, scope *module.UserScope) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var before, after module.Products
+		// Khóa dòng sản phẩm để các lần sửa đồng thời nhận số phiên bản liên tiếp
+		if err := tx.Table("products").Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where(idProduct).Where("deleted_at IS NULL").First(&before).Error; err != nil {
+			return err
+		}
+		// Trạng thái chỉ đổi qua TransitionProductStatus, gửi lại đúng trạng thái hiện tại thì bỏ qua
+		if upd.Status != nil && *upd.Status != "" {
+			if before.Status == nil || *upd.Status != *before.Status {
+				return module.ErrStatusTransitionRequired
+			}
+		}
+		if upd.NameFactory != nil && *upd.NameFactory != "" {
+			factory, err := FactoryByName(tx, upd.NameFactory, scope)
+			if err != nil {
+				return err
+			}
+			if err := tx.Table("products").Where(idProduct).Update("factory_id", factory.Factory_ID).Error; err != nil {
+				return err
+			}
+		}
+		// status chỉ được ghi bởi TransitionProductStatus, form sửa để trống status cũng không được ghi đè
+		if err := tx.Table("products").Where(idProduct).Omit("name_factory", "status").Updates(upd).Error; err != nil {
+			return err
+		}
+		if err := tx.Table("products").Where(idProduct).First(&after).Error; err != nil {
+			return err
+		}
+		// Chỉ đổi updated_at thì không tạo phiên bản mới
+		changes, err := audit.Diff(before.Snapshot(), after.Snapshot())
+		if err != nil {
+			return err
+		}
+		if len(changes) > 0 {
+			if err := recordRevision(ctx, tx, &after, nil); err != nil {
+				return err
+			}
+		}
+		if err := audit.Record(ctx, tx, module.AuditEntityProduct, before.Product_ID, module.AuditActionUpdate, &before, &after); err != nil {
+			return err
+		}
+		if sameImage(before.Image, after.Image) {
+			return nil
+		}
+		return syncPrimaryMedia(ctx, tx, &after)
+	})
+}
+
+// DeleteProduct chuyển sản phẩm vào thùng rác
+func (s *sql) DeleteProduct(ctx context.Context, idProduct map[string]any) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var before module.Products
+		if err := tx.Table("products").Where(idProduct).Where("deleted_at IS NULL").First(&before).Error; err != nil {
+			return err
+		}
+		if err := tx.Table("products").Where("product_id = ?", before.Product_ID).Update("deleted_at", time.Now().UTC().Truncate(time.Microsecond)).Error; err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, module.AuditEntityProduct, before.Product_ID, module.AuditActionDelete, &before, nil)
+	})
+}
+
+// RestoreProduct lấy sản phẩm ra khỏi thùng rác, nhà máy chứa nó phải đang hoạt động
+func (s *sql) RestoreProduct(ctx context.Context, idProduct map[string]any) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var before module.Products
+		if err := tx.Table("products").Where(idProduct).Where("deleted_at IS NOT NULL").First(&before).Error; err != nil {
+			return err
+		}
+		var active int64
+		if err := tx.Table("factories").Where("factory_id = ? AND deleted_at IS NULL", before.Factory_ID).Count(&active).Error; err != nil {
+			return err
+		}
+		if active == 0 {
+			return module.ErrParentDeleted
+		}
+		if err := tx.Table("products").Where("product_id = ?", before.Product_ID).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		after := before
+		after.DeletedAt = nil
+		return audit.Record(ctx, tx, module.AuditEntityProduct, before.Product_ID, module.AuditActionRestore, &before, &after)
+	})
+}
+
+func (s *sql) PurgeProduct(ctx context.Context, idProduct map[string]any) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var before module.Products
+		if err := tx.Table("products").Where(idProduct).Where("deleted_at IS NOT NULL").First(&before).Error; err != nil {
+			return err
+		}
+		if err := recordPurgedMedia(ctx, tx, []string{before.Product_ID}); err != nil {
+			return err
+		}
+		if err := tx.Table("products").Where("product_id = ?", before.Product_ID).Delete(&module.Products{}).Error; err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, module.AuditEntityProduct, before.Product_ID, module.AuditActionPurge, &before, nil)
+	})
+}
+
+// RecordPurgedProducts ghi audit purge cho sản phẩm thỏa điều kiện và media của chúng.
+// Gọi trong tx trước khi xóa vĩnh viễn khu vực/nhà máy cha vì ON DELETE CASCADE xóa các dòng này mà không qua repo.
+func RecordPurgedProducts(ctx context.Context, tx *gorm.DB, query string, args ...any) error {
+	var products []module.Products
+	if err := tx.Table("products").Where(query, args...).Find(&products).Error; err != nil {
 		return err
 	}
-	return nil
+	if len(products) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(products))
+	for i := range products {
+		ids = append(ids, products[i].Product_ID)
+		if err := audit.Record(ctx, tx, module.AuditEntityProduct, products[i].Product_ID, module.AuditActionPurge, &products[i], nil); err != nil {
+			return err
+		}
+	}
+	return recordPurgedMedia(ctx, tx, ids)
 }
 
-func (s *sql) DeleteProduct(ctx context.Context, idProduct map[string]any) error {
-	if err := s.db.Table("products").Where(idProduct).Delete(&module.Products{}).Error; err != nil {
+// recordPurgedMedia ghi audit purge cho media của các sản phẩm sắp bị xóa vĩnh viễn
+func recordPurgedMedia(ctx context.Context, tx *gorm.DB, productIDs []string) error {
+	var media []module.ProductMedia
+	if err := tx.Table("product_media").Where("product_id IN ?", productIDs).Find(&media).Error; err != nil {
 		return err
 	}
+	for i := range media {
+		if err := audit.Record(ctx, tx, module.AuditEntityProductMedia, media[i].MediaID, module.AuditActionPurge, &media[i], nil); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func (s *sql) GetProductsList(ctx context.Context, pagging *common.Paggings, morekeys ...string) ([]module.Products, error) {
-	var data []module.Products
-	db := s.db.Table("products AS p").
+// GetProductsList: publishedOnly dành cho khách và người chỉ có quyền đọc
+func (s *sql) GetProductsList(ctx context.Context, pagging *common.Paggings, query *common.ListQuery, scope *module.UserScope, publishedOnly bool) ([]module.Products, error) {
+	deleted := "p.deleted_at IS NULL"
+	if publishedOnly {
+		deleted = "p.deleted_at IS NULL AND p.status = 'published'"
+	}
+	return s.list(ctx, deleted, listKeyset, createdKey, pagging, query, scope)
+}
+
+// ListTrashProduct liệt kê sản phẩm trong thùng rác, mới xóa nhất lên đầu
+func (s *sql) ListTrashProduct(ctx context.Context, pagging *common.Paggings, scope *module.UserScope) ([]module.Products, error) {
+	return s.list(ctx, "p.deleted_at IS NOT NULL", trashKeyset, deletedKey, pagging, nil, scope)
+}
+
+func (s *sql) list(ctx context.Context, deleted string, keys common.Keyset, key func(*module.Products) (*time.Time, string),
+	pagging *common.Paggings, query *common.ListQuery, scope *module.UserScope) ([]module.Products, error) {
+	db := s.db.WithContext(ctx).Table("products AS p").
 		Select("p.*, f.name_factory").
-		Joins("JOIN factories AS f ON p.factory_id = f.factory_id")
+		Joins("JOIN factories AS f ON p.factory_id = f.factory_id").
+		Where(deleted)
+	if scope != nil {
+		db = db.Where("(p.factory_id IN ? OR f.location_id IN ?)", scope.Factories, scope.Locations)
+	}
+	return common.FindPage(db, pagging, query, keys, key)
+}
+
+// SearchProducts tìm theo search_vector (tiêu đề và mô tả, đã bỏ dấu), điểm cao nhất lên đầu.
+// Từ khớp được bọc bởi module.HighlightStart/HighlightStop, service escape HTML rồi mới đổi sang <mark>.
+func (s *sql) SearchProducts(ctx context.Context, q string, pagging *common.Paggings, scope *module.UserScope, publishedOnly bool) ([]module.ProductSearchResult, error) {
+	var data []module.ProductSearchResult
+	db := s.db.WithContext(ctx).Table("products AS p").
+		Joins("CROSS JOIN websearch_to_tsquery('vn_unaccent', ?) AS query", q).
+		Joins("JOIN factories AS f ON p.factory_id = f.factory_id").
+		Where("p.deleted_at IS NULL AND p.search_vector @@ query")
+	if scope != nil {
+		db = db.Where("(p.factory_id IN ? OR f.location_id IN ?)", scope.Factories, scope.Locations)
+	}
+	if publishedOnly {
+		db = db.Where("p.status = ?", module.ProductStatusPublished)
+	}
 
 	if err := db.Count(&pagging.Total).Error; err != nil {
 		return nil, err
 	}
-	if err := db.Order("p.product_id desc").Offset((pagging.Page - 1) * pagging.Limit).Limit(pagging.Limit).Find(&data).Error; err != nil {
+	if err := db.Select(`p.*, f.name_factory,
+			ts_rank(p.search_vector, query) AS rank,
+			ts_headline('vn_unaccent', p.title, query, 'StartSel=' || chr(2) || ', StopSel=' || chr(3) || ', HighlightAll=true') AS title_highlight,
+			ts_headline('vn_unaccent', p.describe_product, query, 'StartSel=' || chr(2) || ', StopSel=' || chr(3) || ', MaxFragments=2, MaxWords=25, MinWords=8') AS snippet`).
+		Order("rank DESC, p.product_id DESC").
+		Offset((pagging.Page - 1) * pagging.Limit).Limit(pagging.Limit).
+		Find(&data).Error; err != nil {
 		return nil, err
 	}
 	return data, nil
 }
 
-func (s *sql) GetProductsByFactories(ctx context.Context, factoryName map[string]any) ([]module.Products, error) {
-	var listProduct []module.Products
-
+func (s *sql) GetProductsByFactories(ctx context.Context, factoryName map[string]any, pagging *common.Paggings) ([]module.Products, error) {
 	db := s.db.WithContext(ctx).
-		Table("products AS P").
+		Table("products AS p").
 		Select("p.*, f.name_factory").
 		Joins("JOIN factories AS f ON p.factory_id = f.factory_id").
-		Where(factoryName).Find(&listProduct)
-
-	if err := db.Error; err != nil {
-		return nil, err
-	}
-	return listProduct, nil
+		Where("p.deleted_at IS NULL AND f.deleted_at IS NULL").
+		Where(factoryName)
+	return common.FindPage(db, pagging, nil, listKeyset, createdKey)
 }
 
-func (s *sql) GetProductsByLocation(ctx context.Context, locationName map[string]any) ([]module.Products, error) {
-	var listProduct []module.Products
-	db := s.db.Table("products AS p").
+func (s *sql) GetProductsByLocation(ctx context.Context, locationName map[string]any, pagging *common.Paggings) ([]module.Products, error) {
+	db := s.db.WithContext(ctx).
+		Table("products AS p").
 		Select("p.*, l.name_local").
 		Joins("JOIN factories AS f ON p.factory_id = f.factory_id").
 		Joins("JOIN locations AS l ON l.location_id = f.location_id").
-		Where(locationName).Find(&listProduct)
+		Where("p.deleted_at IS NULL AND l.deleted_at IS NULL").
+		Where(locationName)
+	return common.FindPage(db, pagging, nil, listKeyset, createdKey)
+}
+
+// RevertProduct đưa sản phẩm về nội dung của một phiên bản cũ và ghi lại thành phiên bản mới.
+// Nhà máy của phiên bản cũ phải thuộc scope (nil là phạm vi toàn cục)
+func (s *sql) RevertProduct(ctx context.Context, idProduct map[string]any, revision int, scope *module.UserScope) (*module.Products, error) {
+	var after module.Products
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var before module.Products
+		if err := tx.Table("products").Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where(idProduct).Where("deleted_at IS NULL").First(&before).Error; err != nil {
+			return err
+		}
+		var rev module.ProductRevision
+		if err := tx.Table("product_revisions").
+			Where("product_id = ? AND revision_number = ?", before.Product_ID, revision).First(&rev).Error; err != nil {
+			return err
+		}
+		// Nhà máy của phiên bản cũ có thể đã bị xóa hoặc nằm ngoài phạm vi của người khôi phục
+		var factories []module.Factories
+		if err := tx.Table("factories").Where("factory_id = ? AND deleted_at IS NULL", rev.Factory_ID).Find(&factories).Error; err != nil {
+			return err
+		}
+		if len(factories) == 0 {
+			return module.ErrParentDeleted
+		}
+		if !scope.CoversFactory(factories[0].Factory_ID, factories[0].Location_ID) {
+			return module.ErrOutOfScope
+		}
+		if err := tx.Table("products").Where("product_id = ?", before.Product_ID).Updates(map[string]any{
+			"title":            rev.Title,
+			"image":            rev.Image,
+			"video":            rev.Video,
+			"describe_product": rev.Describe,
+			"year_product":     rev.Year,
+			"factory_id":       rev.Factory_ID,
+			"image_variants":   rev.ImageVariants,
+			"updated_at":       time.Now().UTC(),
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Table("products").Where("product_id = ?", before.Product_ID).First(&after).Error; err != nil {
+			return err
+		}
+		if err := recordRevision(ctx, tx, &after, &revision); err != nil {
+			return err
+		}
+		if err := audit.Record(ctx, tx, module.AuditEntityProduct, before.Product_ID, module.AuditActionUpdate, &before, &after); err != nil {
+			return err
+		}
+		if sameImage(before.Image, after.Image) {
+			return nil
+		}
+		return syncPrimaryMedia(ctx, tx, &after)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &after, nil
+}
+
+// ListProductRevisions liệt kê phiên bản của sản phẩm, mới nhất lên đầu; sản phẩm trong thùng rác vẫn xem được lịch sử
+func (s *sql) ListProductRevisions(ctx context.Context, idProduct map[string]any, pagging *common.Paggings) ([]module.ProductRevision, error) {
+	var product module.Products
+	if err := s.db.WithContext(ctx).Table("products").Where(idProduct).First(&product).Error; err != nil {
+		return nil, err
+	}
+	var data []module.ProductRevision
+	db := s.db.WithContext(ctx).Table("product_revisions").Where("product_id = ?", product.Product_ID)
+	if err := db.Count(&pagging.Total).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Order("revision_number desc").Offset((pagging.Page - 1) * pagging.Limit).Limit(pagging.Limit).Find(&data).Error; err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (s *sql) GetProductRevision(ctx context.Context, productID string, revision int) (*module.ProductRevision, error) {
+	var data module.ProductRevision
+	if err := s.db.WithContext(ctx).Table("product_revisions").
+		Where("product_id = ? AND revision_number = ?", productID, revision).First(&data).Error; err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// recordRevision lưu ảnh chụp sản phẩm thành phiên bản kế tiếp, dòng sản phẩm phải đang bị khóa trong tx
+// (vừa tạo, SELECT ... FOR UPDATE hoặc vừa UPDATE) để hai giao dịch không lấy cùng số phiên bản.
+func recordRevision(ctx context.Context, tx *gorm.DB, product *module.Products, revertedFrom *int) error {
+	var last int
+	if err := tx.Table("product_revisions").Select("COALESCE(MAX(revision_number), 0)").
+		Where("product_id = ?", product.Product_ID).Scan(&last).Error; err != nil {
+		return err
+	}
+	revisionID, err := utils.GenerateUUID()
+	if err != nil {
+		return err
+	}
+	actor := audit.ActorFrom(ctx)
+	rev := &module.ProductRevision{
+		RevisionID:      revisionID,
+		Product_ID:      product.Product_ID,
+		Revision:        last + 1,
+		ProductSnapshot: product.Snapshot(),
+		RevertedFrom:    revertedFrom,
+		CreatedAt:       time.Now().UTC(),
+	}
+	if actor.UserID != "" {
+		rev.ActorID = &actor.UserID
+	}
+	if actor.ServiceAccountID != "" {
+		rev.ServiceAccountID = &actor.ServiceAccountID
+	}
+	return tx.Table("product_revisions").Create(rev).Error
+}
+
+// TransitionProductStatus chuyển trạng thái trong lúc khóa dòng sản phẩm, check nhận trạng thái hiện tại
+// và trả lỗi khi bước chuyển không hợp lệ. Lần chuyển được ghi vào product_status_history, phiên bản và audit.
+func (s *sql) TransitionProductStatus(ctx context.Context, idProduct map[string]any, to string, reason *string, check func(from string) error) (*module.ProductStatusHistory, error) {
+	var history *module.ProductStatusHistory
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		before, err := lockProduct(tx, idProduct)
+		if err != nil {
+			return err
+		}
+		from := ""
+		if before.Status != nil {
+			from = *before.Status
+		}
+		if err := check(from); err != nil {
+			return err
+		}
+		now := time.Now().UTC()
+		if err := tx.Table("products").Where("product_id = ?", before.Product_ID).Updates(map[string]any{
+			"status":     to,
+			"updated_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		after := *before
+		after.Status = &to
+		after.UpdatedAt = &now
+
+		historyID, err := utils.GenerateUUID()
+		if err != nil {
+			return err
+		}
+		actor := audit.ActorFrom(ctx)
+		history = &module.ProductStatusHistory{
+			HistoryID:  historyID,
+			Product_ID: before.Product_ID,
+			FromStatus: from,
+			ToStatus:   to,
+			Reason:     reason,
+			ActorRole:  actor.Role,
+			CreatedAt:  now,
+		}
+		if actor.UserID != "" {
+			history.ActorID = &actor.UserID
+		}
+		if actor.ServiceAccountID != "" {
+			history.ServiceAccountID = &actor.ServiceAccountID
+		}
+		if err := tx.Table("product_status_history").Create(history).Error; err != nil {
+			return err
+		}
+		if err := recordRevision(ctx, tx, &after, nil); err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, module.AuditEntityProduct, before.Product_ID, module.AuditActionUpdate, before, &after)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// ListProductStatusHistory liệt kê các lần chuyển trạng thái, mới nhất lên đầu
+func (s *sql) ListProductStatusHistory(ctx context.Context, idProduct map[string]any, pagging *common.Paggings) ([]module.ProductStatusHistory, error) {
+	var product module.Products
+	if err := s.db.WithContext(ctx).Table("products").Where(idProduct).First(&product).Error; err != nil {
+		return nil, err
+	}
+	db := s.db.WithContext(ctx).Table("product_status_history").Where("product_id = ?", product.Product_ID)
+	return common.FindPage(db, pagging, nil, common.Keyset{Time: "created_at", ID: "history_id"}, func(h *module.ProductStatusHistory) (*time.Time, string) {
+		return &h.CreatedAt, h.HistoryID
+	})
+}
+
+// ListProductMedia trả về gallery theo thứ tự hiển thị
+func (s *sql) ListProductMedia(ctx context.Context, productID string) ([]module.ProductMedia, error) {
+	var data []module.ProductMedia
+	if err := s.db.WithContext(ctx).Table("product_media").
+		Where("product_id = ?", productID).
+		Order("sort_order, created_at").
+		Find(&data).Error; err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// AddProductMedia thêm media vào cuối gallery, IsPrimary thì media mới thay ảnh chính của sản phẩm
+func (s *sql) AddProductMedia(ctx context.Context, idProduct map[string]any, media *module.ProductMedia) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		product, err := lockProduct(tx, idProduct)
+		if err != nil {
+			return err
+		}
+		if media.IsPrimary && media.Type != module.MediaTypeImage {
+			return module.ErrPrimaryMediaType
+		}
+		mediaID, err := utils.GenerateUUID()
+		if err != nil {
+			return err
+		}
+		var last int
+		if err := tx.Table("product_media").Select("COALESCE(MAX(sort_order), -1)").
+			Where("product_id = ?", product.Product_ID).Scan(&last).Error; err != nil {
+			return err
+		}
+		now := time.Now().UTC()
+		primary := media.IsPrimary
+		media.MediaID = mediaID
+		media.Product_ID = product.Product_ID
+		media.SortOrder = last + 1
+		media.IsPrimary = false
+		media.CreatedAt = now
+		media.UpdatedAt = now
+		if err := tx.Table("product_media").Create(media).Error; err != nil {
+			return err
+		}
+		if err := audit.Record(ctx, tx, module.AuditEntityProductMedia, media.MediaID, module.AuditActionCreate, nil, media); err != nil {
+			return err
+		}
+		if !primary {
+			return nil
+		}
+		return setPrimaryMedia(ctx, tx, product, media)
+	})
+}
+
+func (s *sql) UpdateProductMedia(ctx context.Context, idProduct map[string]any, mediaID string, upd *req_users.RequestUpdateProductMedia) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		product, err := lockProduct(tx, idProduct)
+		if err != nil {
+			return err
+		}
+		var before module.ProductMedia
+		if err := tx.Table("product_media").Where("product_id = ? AND media_id = ?", product.Product_ID, mediaID).First(&before).Error; err != nil {
+			return err
+		}
+		after := before
+		if upd.Caption != nil {
+			after.Caption = upd.Caption
+		}
+		if upd.AltText != nil {
+			after.AltText = upd.AltText
+		}
+		after.UpdatedAt = time.Now().UTC()
+		if err := tx.Table("product_media").Where("media_id = ?", mediaID).Updates(map[string]any{
+			"caption":    after.Caption,
+			"alt_text":   after.AltText,
+			"updated_at": after.UpdatedAt,
+		}).Error; err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, module.AuditEntityProductMedia, mediaID, module.AuditActionUpdate, &before, &after)
+	})
+}
+
+// ReorderProductMedia đặt sort_order theo vị trí trong mediaIDs, mediaIDs phải chứa đủ và đúng các media của sản phẩm
+func (s *sql) ReorderProductMedia(ctx context.Context, idProduct map[string]any, mediaIDs []string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		product, err := lockProduct(tx, idProduct)
+		if err != nil {
+			return err
+		}
+		var current []module.ProductMedia
+		if err := tx.Table("product_media").Where("product_id = ?", product.Product_ID).Find(&current).Error; err != nil {
+			return err
+		}
+		if len(current) != len(mediaIDs) {
+			return module.ErrInvalidMediaOrder
+		}
+		byID := make(map[string]*module.ProductMedia, len(current))
+		for i := range current {
+			byID[current[i].MediaID] = &current[i]
+		}
+		now := time.Now().UTC()
+		for order, mediaID := range mediaIDs {
+			before, ok := byID[mediaID]
+			if !ok {
+				return module.ErrInvalidMediaOrder
+			}
+			// Mỗi media_id chỉ được xuất hiện một lần
+			delete(byID, mediaID)
+			if before.SortOrder == order {
+				continue
+			}
+			after := *before
+			after.SortOrder = order
+			after.UpdatedAt = now
+			if err := tx.Table("product_media").Where("media_id = ?", mediaID).Updates(map[string]any{
+				"sort_order": order,
+				"updated_at": now,
+			}).Error; err != nil {
+				return err
+			}
+			if err := audit.Record(ctx, tx, module.AuditEntityProductMedia, mediaID, module.AuditActionUpdate, before, &after); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *sql) SetPrimaryProductMedia(ctx context.Context, idProduct map[string]any, mediaID string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		product, err := lockProduct(tx, idProduct)
+		if err != nil {
+			return err
+		}
+		var media module.ProductMedia
+		if err := tx.Table("product_media").Where("product_id = ? AND media_id = ?", product.Product_ID, mediaID).First(&media).Error; err != nil {
+			return err
+		}
+		if media.Type != module.MediaTypeImage {
+			return module.ErrPrimaryMediaType
+		}
+		return setPrimaryMedia(ctx, tx, product, &media)
+	})
+}
+
+// DeleteProductMedia gỡ media khỏi gallery, gỡ ảnh chính thì ảnh kế tiếp theo thứ tự trở thành ảnh chính.
+// File trong storage được giữ lại vì các phiên bản cũ của sản phẩm có thể vẫn dùng.
+func (s *sql) DeleteProductMedia(ctx context.Context, idProduct map[string]any, mediaID string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		product, err := lockProduct(tx, idProduct)
+		if err != nil {
+			return err
+		}
+		var media module.ProductMedia
+		if err := tx.Table("product_media").Where("product_id = ? AND media_id = ?", product.Product_ID, mediaID).First(&media).Error; err != nil {
+			return err
+		}
+		if media.IsPrimary {
+			var next module.ProductMedia
+			if err := tx.Table("product_media").
+				Where("product_id = ? AND media_type = ? AND media_id <> ?", product.Product_ID, module.MediaTypeImage, mediaID).
+				Order("sort_order, created_at").First(&next).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return module.ErrPrimaryMediaRequired
+				}
+				return err
+			}
+			if err := setPrimaryMedia(ctx, tx, product, &next); err != nil {
+				return err
+			}
+		}
+		if err := tx.Table("product_media").Where("media_id = ?", mediaID).Delete(&module.ProductMedia{}).Error; err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, module.AuditEntityProductMedia, mediaID, module.AuditActionDelete, &media, nil)
+	})
+}
 
-	if err := db.Error; err != nil {
+func lockProduct(tx *gorm.DB, idProduct map[string]any) (*module.Products, error) {
+	var product module.Products
+	if err := tx.Table("products").Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where(idProduct).Where("deleted_at IS NULL").First(&product).Error; err != nil {
 		return nil, err
 	}
-	return listProduct, nil
+	return &product, nil
+}
+
+// setPrimaryMedia đánh dấu media là ảnh chính rồi chép key sang products.image, việc đổi ảnh của sản phẩm
+// được ghi phiên bản và audit như một lần sửa. product phải đang bị khóa và được cập nhật theo giá trị mới.
+func setPrimaryMedia(ctx context.Context, tx *gorm.DB, product *module.Products, media *module.ProductMedia) error {
+	now := time.Now().UTC()
+	// Bỏ ảnh chính cũ trước vì mỗi sản phẩm chỉ được có một dòng is_primary
+	if err := tx.Table("product_media").
+		Where("product_id = ? AND is_primary AND media_id <> ?", product.Product_ID, media.MediaID).
+		Updates(map[string]any{"is_primary": false, "updated_at": now}).Error; err != nil {
+		return err
+	}
+	if !media.IsPrimary {
+		if err := tx.Table("product_media").Where("media_id = ?", media.MediaID).
+			Updates(map[string]any{"is_primary": true, "updated_at": now}).Error; err != nil {
+			return err
+		}
+		media.IsPrimary = true
+	}
+	if product.Image != nil && *product.Image == media.StorageKey {
+		return nil
+	}
+
+	before := *product
+	if err := tx.Table("products").Where("product_id = ?", product.Product_ID).Updates(map[string]any{
+		"image":          media.StorageKey,
+		"image_variants": media.ImageVariants,
+		"updated_at":     now,
+	}).Error; err != nil {
+		return err
+	}
+	if err := tx.Table("products").Where("product_id = ?", product.Product_ID).First(product).Error; err != nil {
+		return err
+	}
+	if err := recordRevision(ctx, tx, product, nil); err != nil {
+		return err
+	}
+	return audit.Record(ctx, tx, module.AuditEntityProduct, product.Product_ID, module.AuditActionUpdate, &before, product)
+}
+
+// syncPrimaryMedia đưa products.image vừa được tạo, sửa hoặc khôi phục vào gallery:
+// ảnh đã có trong gallery thì thành ảnh chính, chưa có thì thay file của ảnh chính hiện tại (hoặc thêm mới nếu chưa có)
+func syncPrimaryMedia(ctx context.Context, tx *gorm.DB, product *module.Products) error {
+	if product.Image == nil || *product.Image == "" {
+		return nil
+	}
+	now := time.Now().UTC()
+	var existing module.ProductMedia
+	err := tx.Table("product_media").
+		Where("product_id = ? AND media_type = ? AND storage_key = ?", product.Product_ID, module.MediaTypeImage, *product.Image).
+		Order("is_primary DESC, sort_order").First(&existing).Error
+	if err == nil {
+		return setPrimaryMedia(ctx, tx, product, &existing)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	var primary module.ProductMedia
+	err = tx.Table("product_media").Where("product_id = ? AND is_primary", product.Product_ID).First(&primary).Error
+	if err == nil {
+		after := primary
+		after.StorageKey = *product.Image
+		after.ImageVariants = product.ImageVariants
+		after.UpdatedAt = now
+		if err := tx.Table("product_media").Where("media_id = ?", primary.MediaID).Updates(map[string]any{
+			"storage_key":    after.StorageKey,
+			"image_variants": after.ImageVariants,
+			"updated_at":     now,
+		}).Error; err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, module.AuditEntityProductMedia, primary.MediaID, module.AuditActionUpdate, &primary, &after)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	mediaID, err := utils.GenerateUUID()
+	if err != nil {
+		return err
+	}
+	var last int
+	if err := tx.Table("product_media").Select("COALESCE(MAX(sort_order), -1)").
+		Where("product_id = ?", product.Product_ID).Scan(&last).Error; err != nil {
+		return err
+	}
+	media := &module.ProductMedia{
+		MediaID:       mediaID,
+		Product_ID:    product.Product_ID,
+		Type:          module.MediaTypeImage,
+		StorageKey:    *product.Image,
+		ImageVariants: product.ImageVariants,
+		SortOrder:     last + 1,
+		IsPrimary:     true,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := tx.Table("product_media").Create(media).Error; err != nil {
+		return err
+	}
+	return audit.Record(ctx, tx, module.AuditEntityProductMedia, media.MediaID, module.AuditActionCreate, nil, media)
+}
+
+func sameImage(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
 }
@@ -2,9 +2,11 @@ package refresh_token_repo
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"thelastking-blogger.com/src/config/logger"
 	"thelastking-blogger.com/src/module"
 )
@@ -19,42 +21,138 @@ func NewSql(db *gorm.DB) *sql {
 	}
 }
 
-func (s *sql) DeleteRefreshTokenByTokenID(ctx context.Context, tokenID string) error {
-	if err := s.db.Table("refresh_tokens").
-		Where("token = ? AND revoked = ? AND expires_at > ?", tokenID, false, time.Now()).
-		Delete(&module.RefreshToken{}).Error; err != nil {
+func (s *sql) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	if err := s.db.WithContext(ctx).Table("refresh_tokens").
+		Where("family_id = ? AND revoked = ?", familyID, false).
+		Updates(map[string]any{"revoked": true, "revoked_at": time.Now().UTC()}).Error; err != nil {
 		return err
 	}
 	return nil
 }
 
+// RevokeRefreshTokenByUserID thu hồi mọi family của user
 func (s *sql) RevokeRefreshTokenByUserID(ctx context.Context, userID string) error {
-	if err := s.db.Table("refresh_tokens").
+	if err := s.db.WithContext(ctx).Table("refresh_tokens").
 		Where("user_id = ? AND revoked = ?", userID, false).
-		Update("revoked", true).Error; err != nil {
+		Updates(map[string]any{"revoked": true, "revoked_at": time.Now().UTC()}).Error; err != nil {
 		return err
 	}
 	return nil
 }
 
-func (s *sql) GetRefreshTokenByTokenID(ctx context.Context, tokenID string) (*module.RefreshToken, error) {
+func (s *sql) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*module.RefreshToken, error) {
 	var token module.RefreshToken
-	if err := s.db.Table("refresh_tokens").Where("token = ?", tokenID).First(&token).Error; err != nil {
-		logger.GetLogger().Errorf("Không tìm thấy refresh token: TokenID=%s, Lỗi=%v", tokenID, err)
+	if err := s.db.WithContext(ctx).Table("refresh_tokens").Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		logger.GetLogger().Errorf("Không tìm thấy refresh token: Hash=%s, Lỗi=%v", tokenHash, err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, module.ErrRefreshTokenNotFound
+		}
 		return nil, err
 	}
 	return &token, nil
 }
 
 func (s *sql) CreateRefreshToken(ctx context.Context, data *module.RefreshToken) error {
-	if err := s.db.Table("refresh_tokens").Create(&data).Error; err != nil {
+	if err := s.db.WithContext(ctx).Table("refresh_tokens").Create(&data).Error; err != nil {
 		logger.GetLogger().Errorf("Không thể tạo refresh token: %v", err)
 		return err
 	}
 	return nil
 }
+
+// RotateRefreshToken thu hồi token cũ và lưu token mới cùng family trong một transaction.
+// Nếu token cũ đã được xoay vòng trước đó thì thu hồi toàn bộ family và trả về ErrRefreshTokenReused.
+func (s *sql) RotateRefreshToken(ctx context.Context, oldHash string, newToken *module.RefreshToken) error {
+	reused := false
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var current module.RefreshToken
+		if err := tx.Table("refresh_tokens").
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", oldHash).
+			First(&current).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return module.ErrRefreshTokenNotFound
+			}
+			return err
+		}
+
+		now := time.Now().UTC()
+		if current.Revoked {
+			if current.ReplacedBy == nil {
+				return module.ErrRefreshTokenRevoked
+			}
+			reused = true
+			return tx.Table("refresh_tokens").
+				Where("family_id = ? AND revoked = ?", current.FamilyID, false).
+				Updates(map[string]any{"revoked": true, "revoked_at": now}).Error
+		}
+		if current.ExpiresAt.Before(now) {
+			return module.ErrRefreshTokenExpired
+		}
+
+		newToken.FamilyID = current.FamilyID
+		newToken.UserID = current.UserID
+		if err := tx.Table("refresh_tokens").Create(newToken).Error; err != nil {
+			return err
+		}
+		return tx.Table("refresh_tokens").
+			Where("token_hash = ?", oldHash).
+			Updates(map[string]any{"revoked": true, "revoked_at": now, "replaced_by": newToken.TokenHash}).Error
+	})
+	if err != nil {
+		return err
+	}
+	if reused {
+		return module.ErrRefreshTokenReused
+	}
+	return nil
+}
+
+// ListSessions trả về các family còn token dùng được của user; mỗi family chỉ có đúng một token như vậy
+func (s *sql) ListSessions(ctx context.Context, userID string, now time.Time) ([]module.Session, error) {
+	var data []module.Session
+	if err := s.db.WithContext(ctx).Raw(`SELECT t.family_id, t.user_agent, t.ip_address, t.last_used_at, t.expires_at,
+			(SELECT MIN(f.created_at) FROM refresh_tokens f WHERE f.family_id = t.family_id) AS created_at
+		FROM refresh_tokens t
+		WHERE t.user_id = ? AND t.revoked = ? AND t.expires_at > ?
+		ORDER BY t.last_used_at DESC NULLS LAST`, userID, false, now).
+		Scan(&data).Error; err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// RevokeSession thu hồi một family của user, trả về false nếu không có token nào còn hiệu lực
+func (s *sql) RevokeSession(ctx context.Context, userID, familyID string) (bool, error) {
+	result := s.db.WithContext(ctx).Table("refresh_tokens").
+		Where("user_id = ? AND family_id = ? AND revoked = ?", userID, familyID, false).
+		Updates(map[string]any{"revoked": true, "revoked_at": time.Now().UTC()})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// RevokeOtherSessions thu hồi mọi family của user trừ keepFamilyID, trả về các family vừa bị thu hồi
+func (s *sql) RevokeOtherSessions(ctx context.Context, userID, keepFamilyID string) ([]string, error) {
+	var families []string
+	if err := s.db.WithContext(ctx).Raw(`UPDATE refresh_tokens SET revoked = ?, revoked_at = ?
+		WHERE user_id = ? AND family_id <> ? AND revoked = ?
+		RETURNING family_id`, true, time.Now().UTC(), userID, keepFamilyID, false).
+		Scan(&families).Error; err != nil {
+		return nil, err
+	}
+	return families, nil
+}
+
+// CleanupOldRevokedTokens xóa những family không còn token nào dùng được.
+// Token đã xoay vòng của family còn sống được giữ lại để phát hiện việc dùng lại.
 func (s *sql) CleanupOldRevokedTokens(ctx context.Context) error {
-	if err := s.db.Table("refresh_tokens").Where("revoked = ? AND expires_at < ?", true, time.Now()).Delete(&module.RefreshToken{}).Error; err != nil {
+	if err := s.db.WithContext(ctx).Exec(`DELETE FROM refresh_tokens WHERE family_id IN (
+		SELECT family_id FROM refresh_tokens
+		GROUP BY family_id
+		HAVING bool_and(revoked OR expires_at < ?)
+	)`, time.Now().UTC()).Error; err != nil {
 		return err
 	}
 	return nil
@@ -0,0 +1,103 @@
+package refresh_token_repo
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"thelastking-blogger.com/src/module"
+	"thelastking-blogger.com/src/repository/repotest"
+)
+
+var tokenColumns = []string{"token_hash", "family_id", "user_id", "expires_at", "revoked", "replaced_by", "revoked_at", "created_at"}
+
+// tokenRow là dòng refresh_tokens của token "old-hash" thuộc family-1 của user-1
+func tokenRow(expiresAt time.Time, revoked bool, replacedBy any) repotest.Result {
+	return repotest.Result{
+		Columns: tokenColumns,
+		Rows:    [][]driver.Value{{"old-hash", "family-1", "user-1", expiresAt, revoked, replacedBy, nil, time.Now()}},
+	}
+}
+
+func TestRotateRefreshToken(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+	tests := []struct {
+		name          string
+		current       repotest.Result
+		expect        []string // câu lệnh sau SELECT ... FOR UPDATE
+		wantErr       error
+		wantCommitted bool
+	}{
+		{
+			name:          "active token is replaced within its family",
+			current:       tokenRow(future, false, nil),
+			expect:        []string{`INSERT INTO "refresh_tokens"`, `"replaced_by"=`},
+			wantCommitted: true,
+		},
+		{
+			name:          "rotated token sent again revokes the whole family",
+			current:       tokenRow(future, true, "new-hash"),
+			expect:        []string{`WHERE family_id = $3 AND revoked = $4`},
+			wantErr:       module.ErrRefreshTokenReused,
+			wantCommitted: true,
+		},
+		{
+			name:    "signed out token",
+			current: tokenRow(future, true, nil),
+			wantErr: module.ErrRefreshTokenRevoked,
+		},
+		{
+			name:    "expired token",
+			current: tokenRow(time.Now().Add(-time.Minute), false, nil),
+			wantErr: module.ErrRefreshTokenExpired,
+		},
+		{
+			name:    "unknown token",
+			current: repotest.Result{Columns: tokenColumns},
+			wantErr: module.ErrRefreshTokenNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := repotest.Open(t)
+			fake.Expect("FOR UPDATE", tt.current)
+			for _, statement := range tt.expect {
+				fake.Expect(statement, repotest.Result{RowsAffected: 1})
+			}
+
+			newToken := &module.RefreshToken{TokenHash: "new-hash", ExpiresAt: future}
+			err := NewSql(db).RotateRefreshToken(context.Background(), "old-hash", newToken)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RotateRefreshToken() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantCommitted != (fake.Commits == 1) || fake.Commits+fake.Rollbacks != 1 {
+				t.Errorf("commits = %d, rollbacks = %d, want committed %v", fake.Commits, fake.Rollbacks, tt.wantCommitted)
+			}
+			if tt.wantErr == nil && (newToken.FamilyID != "family-1" || newToken.UserID != "user-1") {
+				t.Errorf("new token family = %q user = %q, want family-1 user-1", newToken.FamilyID, newToken.UserID)
+			}
+		})
+	}
+}
+
+func TestRotateRefreshTokenReuseRevokesFamily(t *testing.T) {
+	db, fake := repotest.Open(t)
+	fake.Expect("FOR UPDATE", tokenRow(time.Now().Add(time.Hour), true, "new-hash"))
+	fake.Expect(`UPDATE "refresh_tokens"`, repotest.Result{RowsAffected: 2})
+
+	err := NewSql(db).RotateRefreshToken(context.Background(), "old-hash", &module.RefreshToken{TokenHash: "other-hash"})
+	if !errors.Is(err, module.ErrRefreshTokenReused) {
+		t.Fatalf("RotateRefreshToken() error = %v, want ErrRefreshTokenReused", err)
+	}
+	update := fake.Statements[len(fake.Statements)-1]
+	// SET revoked = true, revoked_at, rồi WHERE family_id = family-1 AND revoked = false
+	if len(update.Args) != 4 || update.Args[0] != true || update.Args[2] != "family-1" || update.Args[3] != false {
+		t.Errorf("family revoke args = %v", update.Args)
+	}
+	// Chỉ có SELECT ... FOR UPDATE và lệnh thu hồi family, không có token mới nào được lưu
+	if len(fake.Statements) != 2 {
+		t.Errorf("executed %d statements, want 2", len(fake.Statements))
+	}
+}
@@ -0,0 +1,166 @@
+// Package repotest chạy repository trên một driver giả dùng dialect PostgreSQL của GORM,
+// để test kiểm tra câu lệnh được gửi đi và xử lý kết quả mà không cần database thật.
+package repotest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Result là kết quả trả cho một câu lệnh: các dòng nếu là truy vấn, số dòng bị ảnh hưởng nếu là lệnh ghi
+type Result struct {
+	Columns      []string
+	Rows         [][]driver.Value
+	RowsAffected int64
+	Err          error
+}
+
+// Statement là một câu lệnh repository đã gửi xuống database
+type Statement struct {
+	SQL  string
+	Args []driver.Value
+}
+
+// DB trả kết quả cho từng câu lệnh theo đúng thứ tự đã Expect và ghi lại mọi câu lệnh, commit, rollback
+type DB struct {
+	t          testing.TB
+	mu         sync.Mutex
+	expected   []expectation
+	Statements []Statement
+	Commits    int
+	Rollbacks  int
+}
+
+type expectation struct {
+	contains string
+	result   Result
+}
+
+// Open tạo gorm.DB trên driver giả, test báo lỗi nếu còn câu lệnh đã Expect nhưng chưa chạy
+func Open(t testing.TB) (*gorm.DB, *DB) {
+	t.Helper()
+	fake := &DB{t: t}
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sql.OpenDB(connector{fake})}), &gorm.Config{
+		DisableAutomaticPing: true,
+		Logger:               logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		for _, e := range fake.expected {
+			t.Errorf("expected statement containing %q was not executed", e.contains)
+		}
+	})
+	return db, fake
+}
+
+// Expect xếp kết quả cho câu lệnh kế tiếp, câu lệnh đó phải chứa contains
+func (d *DB) Expect(contains string, result Result) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.expected = append(d.expected, expectation{contains: contains, result: result})
+}
+
+func (d *DB) next(query string, named []driver.NamedValue) (Result, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	args := make([]driver.Value, len(named))
+	for i, arg := range named {
+		args[i] = arg.Value
+	}
+	d.Statements = append(d.Statements, Statement{SQL: query, Args: args})
+	if len(d.expected) == 0 {
+		d.t.Errorf("unexpected statement %s", query)
+		return Result{}, fmt.Errorf("repotest: unexpected statement")
+	}
+	e := d.expected[0]
+	d.expected = d.expected[1:]
+	if !strings.Contains(query, e.contains) {
+		d.t.Errorf("statement %s does not contain %q", query, e.contains)
+		return Result{}, fmt.Errorf("repotest: unexpected statement")
+	}
+	return e.result, e.result.Err
+}
+
+type connector struct{ db *DB }
+
+func (c connector) Connect(context.Context) (driver.Conn, error) { return &conn{db: c.db}, nil }
+func (c connector) Driver() driver.Driver                        { return fakeDriver{c.db} }
+
+type fakeDriver struct{ db *DB }
+
+func (d fakeDriver) Open(string) (driver.Conn, error) { return &conn{db: d.db}, nil }
+
+type conn struct{ db *DB }
+
+func (c *conn) Prepare(string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("repotest: prepared statements are not supported")
+}
+func (c *conn) Close() error              { return nil }
+func (c *conn) Begin() (driver.Tx, error) { return tx{db: c.db}, nil }
+
+func (c *conn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	return tx{db: c.db}, nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	result, err := c.db.next(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{columns: result.Columns, values: result.Rows}, nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	result, err := c.db.next(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(result.RowsAffected), nil
+}
+
+type tx struct{ db *DB }
+
+func (t tx) Commit() error {
+	t.db.mu.Lock()
+	defer t.db.mu.Unlock()
+	t.db.Commits++
+	return nil
+}
+
+func (t tx) Rollback() error {
+	t.db.mu.Lock()
+	defer t.db.mu.Unlock()
+	t.db.Rollbacks++
+	return nil
+}
+
+type rows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *rows) Columns() []string { return r.columns }
+func (r *rows) Close() error      { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
@@ -0,0 +1,122 @@
+package scope_repo
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"thelastking-blogger.com/src/module"
+)
+
+// factoryCovered đúng khi user được gán nhà máy f hoặc khu vực chứa nhà máy f
+const factoryCovered = `(EXISTS (SELECT 1 FROM user_factory_scopes AS s WHERE s.user_id = ? AND s.factory_id = f.factory_id)
+	OR EXISTS (SELECT 1 FROM user_location_scopes AS s WHERE s.user_id = ? AND s.location_id = f.location_id))`
+
+type sql struct {
+	db *gorm.DB
+}
+
+func NewSql(db *gorm.DB) *sql {
+	return &sql{db: db}
+}
+
+func (s *sql) ListLocationScopes(ctx context.Context, userID string) ([]module.UserLocationScope, error) {
+	var data []module.UserLocationScope
+	if err := s.db.WithContext(ctx).Table("user_location_scopes").
+		Where("user_id = ?", userID).Order("location_id").Find(&data).Error; err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (s *sql) ListFactoryScopes(ctx context.Context, userID string) ([]module.UserFactoryScope, error) {
+	var data []module.UserFactoryScope
+	if err := s.db.WithContext(ctx).Table("user_factory_scopes").
+		Where("user_id = ?", userID).Order("factory_id").Find(&data).Error; err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (s *sql) AddLocationScope(ctx context.Context, data *module.UserLocationScope) error {
+	var count int64
+	if err := s.db.WithContext(ctx).Table("locations").Where("location_id = ? AND deleted_at IS NULL", data.LocationID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return s.db.WithContext(ctx).Table("user_location_scopes").
+		Clauses(clause.OnConflict{DoNothing: true}).Create(data).Error
+}
+
+func (s *sql) AddFactoryScope(ctx context.Context, data *module.UserFactoryScope) error {
+	var count int64
+	if err := s.db.WithContext(ctx).Table("factories").Where("factory_id = ? AND deleted_at IS NULL", data.FactoryID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return s.db.WithContext(ctx).Table("user_factory_scopes").
+		Clauses(clause.OnConflict{DoNothing: true}).Create(data).Error
+}
+
+func (s *sql) RemoveLocationScope(ctx context.Context, userID, locationID string) error {
+	result := s.db.WithContext(ctx).Table("user_location_scopes").
+		Where("user_id = ? AND location_id = ?", userID, locationID).Delete(&module.UserLocationScope{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (s *sql) RemoveFactoryScope(ctx context.Context, userID, factoryID string) error {
+	result := s.db.WithContext(ctx).Table("user_factory_scopes").
+		Where("user_id = ? AND factory_id = ?", userID, factoryID).Delete(&module.UserFactoryScope{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// CoversLocation kiểm tra user được gán trực tiếp khu vực khớp điều kiện (cột của locations AS l)
+func (s *sql) CoversLocation(ctx context.Context, userID string, location map[string]any) (bool, error) {
+	var count int64
+	if err := s.db.WithContext(ctx).Table("locations AS l").Where(location).
+		Where("EXISTS (SELECT 1 FROM user_location_scopes AS s WHERE s.user_id = ? AND s.location_id = l.location_id)", userID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// CoversFactory kiểm tra nhà máy khớp điều kiện (cột của factories AS f) nằm trong phạm vi của user
+func (s *sql) CoversFactory(ctx context.Context, userID string, factory map[string]any) (bool, error) {
+	var count int64
+	if err := s.db.WithContext(ctx).Table("factories AS f").Where(factory).
+		Where(factoryCovered, userID, userID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// CoversProduct kiểm tra nhà máy chứa sản phẩm nằm trong phạm vi của user
+func (s *sql) CoversProduct(ctx context.Context, userID string, productID string) (bool, error) {
+	var count int64
+	if err := s.db.WithContext(ctx).Table("products AS p").
+		Joins("JOIN factories AS f ON p.factory_id = f.factory_id").
+		Where("p.product_id = ?", productID).
+		Where(factoryCovered, userID, userID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
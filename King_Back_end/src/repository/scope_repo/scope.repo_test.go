@@ -0,0 +1,103 @@
+package scope_repo
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"thelastking-blogger.com/src/repository/repotest"
+)
+
+func count(n int64) repotest.Result {
+	return repotest.Result{Columns: []string{"count"}, Rows: [][]driver.Value{{n}}}
+}
+
+func TestCovers(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name     string
+		call     func(s *sql) (bool, error)
+		count    int64
+		want     bool
+		wantSQL  []string
+		wantArgs []driver.Value
+	}{
+		{
+			name: "factory assigned directly or through its location",
+			call: func(s *sql) (bool, error) {
+				return s.CoversFactory(ctx, "user-1", map[string]any{"f.factory_id": "factory-1"})
+			},
+			count: 1,
+			want:  true,
+			wantSQL: []string{
+				"FROM factories AS f",
+				"s.user_id = $2 AND s.factory_id = f.factory_id",
+				"OR EXISTS (SELECT 1 FROM user_location_scopes AS s WHERE s.user_id = $3 AND s.location_id = f.location_id)",
+			},
+			wantArgs: []driver.Value{"factory-1", "user-1", "user-1"},
+		},
+		{
+			name: "factory outside the scope",
+			call: func(s *sql) (bool, error) {
+				return s.CoversFactory(ctx, "user-1", map[string]any{"f.factory_id": "factory-2"})
+			},
+			count:    0,
+			want:     false,
+			wantSQL:  []string{"user_factory_scopes", "user_location_scopes"},
+			wantArgs: []driver.Value{"factory-2", "user-1", "user-1"},
+		},
+		{
+			name: "product checked through its factory",
+			call: func(s *sql) (bool, error) {
+				return s.CoversProduct(ctx, "user-1", "product-1")
+			},
+			count: 1,
+			want:  true,
+			wantSQL: []string{
+				"FROM products AS p JOIN factories AS f ON p.factory_id = f.factory_id",
+				"p.product_id = $1",
+				"user_factory_scopes", "user_location_scopes",
+			},
+			wantArgs: []driver.Value{"product-1", "user-1", "user-1"},
+		},
+		{
+			name: "location only counts when assigned directly",
+			call: func(s *sql) (bool, error) {
+				return s.CoversLocation(ctx, "user-1", map[string]any{"l.location_id": "location-1"})
+			},
+			count:    1,
+			want:     true,
+			wantSQL:  []string{"FROM locations AS l", "user_location_scopes AS s WHERE s.user_id = $2 AND s.location_id = l.location_id"},
+			wantArgs: []driver.Value{"location-1", "user-1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := repotest.Open(t)
+			fake.Expect("SELECT count(*)", count(tt.count))
+			got, err := tt.call(NewSql(db))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("covered = %v, want %v", got, tt.want)
+			}
+			statement := fake.Statements[0]
+			for _, part := range tt.wantSQL {
+				if !strings.Contains(statement.SQL, part) {
+					t.Errorf("SQL %s does not contain %q", statement.SQL, part)
+				}
+			}
+			if len(statement.Args) != len(tt.wantArgs) {
+				t.Fatalf("args = %v, want %v", statement.Args, tt.wantArgs)
+			}
+			for i := range tt.wantArgs {
+				if statement.Args[i] != tt.wantArgs[i] {
+					t.Errorf("args = %v, want %v", statement.Args, tt.wantArgs)
+					break
+				}
+			}
+		})
+	}
+}
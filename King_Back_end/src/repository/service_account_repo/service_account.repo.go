@@ -0,0 +1,144 @@
+package service_account_repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"thelastking-blogger.com/src/module"
+)
+
+type sql struct {
+	db *gorm.DB
+}
+
+func NewSql(db *gorm.DB) *sql {
+	return &sql{db: db}
+}
+
+func (s *sql) CreateServiceAccount(ctx context.Context, data *module.ServiceAccount) error {
+	var count int64
+	if err := s.db.WithContext(ctx).Table("service_accounts").Where("name = ?", data.Name).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return module.ErrServiceAccountNameTaken
+	}
+	return s.db.WithContext(ctx).Table("service_accounts").Create(data).Error
+}
+
+func (s *sql) ListServiceAccounts(ctx context.Context) ([]module.ServiceAccount, error) {
+	var data []module.ServiceAccount
+	if err := s.db.WithContext(ctx).Table("service_accounts").Order("name").Find(&data).Error; err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (s *sql) GetServiceAccount(ctx context.Context, id string) (*module.ServiceAccount, error) {
+	var data module.ServiceAccount
+	if err := s.db.WithContext(ctx).Table("service_accounts").Where("service_account_id = ?", id).First(&data).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, module.ErrServiceAccountNotFound
+		}
+		return nil, err
+	}
+	return &data, nil
+}
+
+// DeleteServiceAccount xóa tài khoản, các API key bị xóa theo nhờ ON DELETE CASCADE
+func (s *sql) DeleteServiceAccount(ctx context.Context, id string) error {
+	result := s.db.WithContext(ctx).Table("service_accounts").
+		Where("service_account_id = ?", id).Delete(&module.ServiceAccount{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return module.ErrServiceAccountNotFound
+	}
+	return nil
+}
+
+func (s *sql) ListApiKeys(ctx context.Context, serviceAccountIDs []string) ([]module.ApiKey, error) {
+	var data []module.ApiKey
+	if err := s.db.WithContext(ctx).Table("api_keys").
+		Where("service_account_id IN ?", serviceAccountIDs).
+		Order("created_at DESC").Find(&data).Error; err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (s *sql) ListApiKeyPermissions(ctx context.Context, keyIDs []string) ([]module.ApiKeyPermission, error) {
+	var data []module.ApiKeyPermission
+	if err := s.db.WithContext(ctx).Table("api_key_permissions").
+		Where("key_id IN ?", keyIDs).
+		Order("permission_key").Find(&data).Error; err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// CreateApiKey lưu key cùng danh sách quyền trong một transaction, quyền không có trong bảng permissions bị từ chối
+func (s *sql) CreateApiKey(ctx context.Context, data *module.ApiKey, permissions []string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Table("permissions").Where("permission_key IN ?", permissions).Count(&count).Error; err != nil {
+			return err
+		}
+		if int(count) != len(permissions) {
+			return module.ErrPermissionNotFound
+		}
+		if err := tx.Table("api_keys").Create(data).Error; err != nil {
+			return err
+		}
+		rows := make([]module.ApiKeyPermission, 0, len(permissions))
+		for _, permission := range permissions {
+			rows = append(rows, module.ApiKeyPermission{KeyID: data.KeyID, PermissionKey: permission})
+		}
+		return tx.Table("api_key_permissions").Create(&rows).Error
+	})
+}
+
+func (s *sql) RevokeApiKey(ctx context.Context, serviceAccountID, keyID string, now time.Time) error {
+	result := s.db.WithContext(ctx).Table("api_keys").
+		Where("key_id = ? AND service_account_id = ? AND revoked_at IS NULL", keyID, serviceAccountID).
+		Update("revoked_at", now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetApiKeyCreatorRole trả về role hiện tại của người tạo key, rỗng nếu tài khoản đó đã bị xóa
+func (s *sql) GetApiKeyCreatorRole(ctx context.Context, keyID string) (string, error) {
+	var roles []string
+	if err := s.db.WithContext(ctx).Table("api_keys AS k").
+		Joins("JOIN users AS u ON u.user_id = k.created_by AND u.deleted_at IS NULL").
+		Where("k.key_id = ?", keyID).Limit(1).Pluck("u.role_user", &roles).Error; err != nil {
+		return "", err
+	}
+	if len(roles) == 0 {
+		return "", nil
+	}
+	return roles[0], nil
+}
+
+func (s *sql) GetApiKeyByHash(ctx context.Context, keyHash string) (*module.ApiKey, error) {
+	var data module.ApiKey
+	if err := s.db.WithContext(ctx).Table("api_keys").Where("key_hash = ?", keyHash).First(&data).Error; err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// TouchApiKey ghi lần dùng gần nhất, bỏ qua nếu đã ghi sau mốc since để không update mỗi request
+func (s *sql) TouchApiKey(ctx context.Context, keyID, ip string, now, since time.Time) error {
+	return s.db.WithContext(ctx).Table("api_keys").
+		Where("key_id = ? AND (last_used_at IS NULL OR last_used_at < ?)", keyID, since).
+		Updates(map[string]any{"last_used_at": now, "last_used_ip": ip}).Error
+}
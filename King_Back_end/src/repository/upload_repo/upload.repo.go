@@ -0,0 +1,129 @@
+package upload_repo
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"thelastking-blogger.com/src/module"
+)
+
+type sql struct {
+	db *gorm.DB
+}
+
+func NewSql(db *gorm.DB) *sql {
+	return &sql{db: db}
+}
+
+func (s *sql) CreateUpload(ctx context.Context, data *module.MediaUpload) error {
+	if err := s.db.WithContext(ctx).Table("media_uploads").Create(data).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetUpload chỉ trả về upload chưa hết hạn, cond gồm upload_id và chủ sở hữu
+func (s *sql) GetUpload(ctx context.Context, cond map[string]any, now time.Time) (*module.MediaUpload, error) {
+	var data module.MediaUpload
+	if err := s.db.WithContext(ctx).Table("media_uploads").
+		Where(cond).Where("expires_at > ?", now).
+		First(&data).Error; err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// AppendChunk khóa dòng upload trong lúc write ghi phần mới vào file tạm, các phần của cùng một upload
+// vì vậy được ghi lần lượt kể cả khi client gửi trùng hoặc chạy nhiều instance. write trả về offset mới.
+func (s *sql) AppendChunk(ctx context.Context, cond map[string]any, now, expiresAt time.Time, write func(upload *module.MediaUpload) (int64, error)) (*module.MediaUpload, error) {
+	var data module.MediaUpload
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table("media_uploads").Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where(cond).Where("expires_at > ?", now).
+			First(&data).Error; err != nil {
+			return err
+		}
+		offset, err := write(&data)
+		if err != nil {
+			return err
+		}
+		data.Offset = offset
+		data.ExpiresAt = expiresAt
+		data.UpdatedAt = now
+		return tx.Table("media_uploads").Where("upload_id = ?", data.UploadID).Updates(map[string]any{
+			"upload_offset": offset,
+			"expires_at":    expiresAt,
+			"updated_at":    now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// CompleteUpload ghi nhận file đã nằm trong storage, chỉ áp dụng cho upload còn đang nhận
+func (s *sql) CompleteUpload(ctx context.Context, uploadID, storageKey, contentType string, now, expiresAt time.Time) error {
+	result := s.db.WithContext(ctx).Table("media_uploads").
+		Where("upload_id = ? AND status = ?", uploadID, module.UploadStatusPending).
+		Updates(map[string]any{
+			"status":       module.UploadStatusCompleted,
+			"storage_key":  storageKey,
+			"content_type": contentType,
+			"expires_at":   expiresAt,
+			"updated_at":   now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteUpload xóa và trả về upload để service dọn file tạm, gorm.ErrRecordNotFound nếu không có
+func (s *sql) DeleteUpload(ctx context.Context, cond map[string]any) (*module.MediaUpload, error) {
+	var data []module.MediaUpload
+	if err := s.db.WithContext(ctx).Table("media_uploads").
+		Clauses(clause.Returning{}).
+		Where(cond).
+		Delete(&data).Error; err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &data[0], nil
+}
+
+func (s *sql) ListExpiredUploads(ctx context.Context, now time.Time, limit int) ([]module.MediaUpload, error) {
+	var data []module.MediaUpload
+	if err := s.db.WithContext(ctx).Table("media_uploads").
+		Where("expires_at <= ?", now).
+		Order("expires_at").
+		Limit(limit).
+		Find(&data).Error; err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// MediaKeyInUse kiểm tra key còn được sản phẩm, gallery hoặc phiên bản cũ nào dùng không,
+// kể cả dưới dạng biến thể trong image_variants.
+// Key được đặt theo nội dung nên một file có thể được nhiều sản phẩm dùng chung.
+func (s *sql) MediaKeyInUse(ctx context.Context, key string) (bool, error) {
+	var inUse bool
+	if err := s.db.WithContext(ctx).Raw(`SELECT EXISTS (SELECT 1 FROM products WHERE video = @key OR image = @key)
+		OR EXISTS (SELECT 1 FROM product_revisions WHERE video = @key OR image = @key)
+		OR EXISTS (SELECT 1 FROM product_media WHERE storage_key = @key)
+		OR EXISTS (SELECT 1 FROM products AS p, jsonb_each_text(p.image_variants) AS v WHERE v.value = @key)
+		OR EXISTS (SELECT 1 FROM product_revisions AS r, jsonb_each_text(r.image_variants) AS v WHERE v.value = @key)
+		OR EXISTS (SELECT 1 FROM product_media AS m, jsonb_each_text(m.image_variants) AS v WHERE v.value = @key)`,
+		map[string]any{"key": key}).Scan(&inUse).Error; err != nil {
+		return false, err
+	}
+	return inUse, nil
+}
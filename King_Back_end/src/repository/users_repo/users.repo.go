@@ -3,9 +3,10 @@ package users_repo
 import (
 	"context"
 	"errors"
-	"fmt"
+	"time"
 
 	"gorm.io/gorm"
+	"thelastking-blogger.com/src/audit"
 	"thelastking-blogger.com/src/controller/common"
 	"thelastking-blogger.com/src/module"
 	"thelastking-blogger.com/src/module/req_users"
@@ -16,43 +17,137 @@ type sql struct {
 	db *gorm.DB
 }
 
+// ListFields là các trường lọc/sắp xếp được của danh sách tài khoản
+var ListFields = common.ListFields{
+	"role":       {Column: "role_user", Type: common.FieldString, Filter: true, Sort: true},
+	"tag":        {Column: "tag", Type: common.FieldString, Filter: true, Sort: true},
+	"created_at": {Column: "created_at", Type: common.FieldTime, Filter: true, Sort: true},
+	"updated_at": {Column: "updated_at", Type: common.FieldTime, Filter: true, Sort: true},
+	"full_name":  {Column: "full_name", Type: common.FieldString, Sort: true},
+	"account":    {Column: "account", Type: common.FieldString, Sort: true},
+}
+
+// Thứ tự mặc định của danh sách là mới tạo lên đầu, thùng rác là mới xóa lên đầu
+var (
+	listKeyset  = common.Keyset{Time: "created_at", ID: "user_id"}
+	trashKeyset = common.Keyset{Time: "deleted_at", ID: "user_id"}
+)
+
+func createdKey(u *module.Users) (*time.Time, string) { return u.CreatedAt, u.UserID }
+func deletedKey(u *module.Users) (*time.Time, string) { return u.DeletedAt, u.UserID }
+
 func NewSql(db *gorm.DB) *sql {
 	return &sql{db: db}
 }
 
 func (s *sql) CreateUsers(ctx context.Context, data *module.Users) error {
-	if err := s.db.Table("users").FirstOrCreate(&data, &module.Users{Account: data.Account}).Error; err != nil {
-		return err
-	}
-	return nil
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Table("users").Where("deleted_at IS NULL").FirstOrCreate(&data, &module.Users{Account: data.Account})
+		if result.Error != nil {
+			return result.Error
+		}
+		// Account đã tồn tại thì FirstOrCreate không tạo dòng mới, không có gì để ghi
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		return audit.Record(ctx, tx, module.AuditEntityUser, data.UserID, module.AuditActionCreate, nil, data)
+	})
 }
 
 func (s *sql) ProfileUsers(ctx context.Context, idData map[string]any) (*module.Users, error) {
 	var data module.Users
-	if err := s.db.Table("users").Where(idData).First(&data).Error; err != nil {
+	if err := s.db.Table("users").Where(idData).Where("deleted_at IS NULL").First(&data).Error; err != nil {
 		return nil, err
 	}
 	return &data, nil
 }
 
-func (s *sql) UpdatedUsers(ctx context.Context, updateData *req_users.UpdateUsers, idData map[string]any) error {
-	if err := s.db.Table("users").Where(idData).Updates(updateData).Error; err != nil {
-		return err
+// GetDeletedUser đọc tài khoản đang trong thùng rác để kiểm tra quyền trước khi khôi phục hoặc xóa vĩnh viễn
+func (s *sql) GetDeletedUser(ctx context.Context, idData map[string]any) (*module.Users, error) {
+	var data module.Users
+	if err := s.db.Table("users").Where(idData).Where("deleted_at IS NOT NULL").First(&data).Error; err != nil {
+		return nil, err
 	}
-	return nil
+	return &data, nil
+}
+
+func (s *sql) UpdatedUsers(ctx context.Context, updateData *req_users.UpdateUsers, idData map[string]any) error {
+	return s.updateWithAudit(ctx, idData, func(tx *gorm.DB) error {
+		return tx.Table("users").Where(idData).Updates(updateData).Error
+	})
 }
 
 func (s *sql) DeleteUsers(ctx context.Context, idData map[string]any) error {
-	if err := s.db.Table("users").Where(idData).Delete(&module.Users{}).Error; err != nil {
-		return err
-	}
-	return nil
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var before module.Users
+		if err := tx.Table("users").Where(idData).Where("deleted_at IS NULL").First(&before).Error; err != nil {
+			return err
+		}
+		if err := tx.Table("users").Where("user_id = ?", before.UserID).Update("deleted_at", time.Now().UTC().Truncate(time.Microsecond)).Error; err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, module.AuditEntityUser, before.UserID, module.AuditActionDelete, &before, nil)
+	})
+}
+
+// RestoreUsers khôi phục tài khoản, từ chối nếu account đã được tài khoản khác sử dụng trong lúc bị xóa
+func (s *sql) RestoreUsers(ctx context.Context, idData map[string]any) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var before module.Users
+		if err := tx.Table("users").Where(idData).Where("deleted_at IS NOT NULL").First(&before).Error; err != nil {
+			return err
+		}
+		var taken int64
+		if err := tx.Table("users").Where("account = ? AND deleted_at IS NULL", before.Account).Count(&taken).Error; err != nil {
+			return err
+		}
+		if taken > 0 {
+			return module.ErrAccountTaken
+		}
+		if err := tx.Table("users").Where("user_id = ?", before.UserID).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		after := before
+		after.DeletedAt = nil
+		return audit.Record(ctx, tx, module.AuditEntityUser, before.UserID, module.AuditActionRestore, &before, &after)
+	})
+}
+
+// PurgeUsers xóa vĩnh viễn tài khoản trong thùng rác, dữ liệu phụ (phiên, 2FA, phạm vi) bị xóa theo ON DELETE CASCADE
+func (s *sql) PurgeUsers(ctx context.Context, idData map[string]any) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var before module.Users
+		if err := tx.Table("users").Where(idData).Where("deleted_at IS NOT NULL").First(&before).Error; err != nil {
+			return err
+		}
+		if err := tx.Table("users").Where("user_id = ?", before.UserID).Delete(&module.Users{}).Error; err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, module.AuditEntityUser, before.UserID, module.AuditActionPurge, &before, nil)
+	})
+}
+
+// updateWithAudit chạy update trong transaction và ghi audit_log với trạng thái user trước và sau khi đổi
+func (s *sql) updateWithAudit(ctx context.Context, idData map[string]any, update func(tx *gorm.DB) error) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var before, after module.Users
+		if err := tx.Table("users").Where(idData).Where("deleted_at IS NULL").First(&before).Error; err != nil {
+			return err
+		}
+		if err := update(tx); err != nil {
+			return err
+		}
+		if err := tx.Table("users").Where(idData).First(&after).Error; err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, module.AuditEntityUser, before.UserID, module.AuditActionUpdate, &before, &after)
+	})
 }
 
 func (s *sql) SignIn(ctx context.Context, data *req_users.RequestSignIn) (*module.Users, error) {
 	var dataUser module.Users
 	if err := s.db.Table("users").
-		Where("account = ?", data.Account).
+		Where("account = ? AND deleted_at IS NULL", data.Account).
 		First(&dataUser).Error; err != nil {
 		return nil, err
 	}
@@ -61,62 +156,70 @@ func (s *sql) SignIn(ctx context.Context, data *req_users.RequestSignIn) (*modul
 
 func (s *sql) ChanrgePwd(ctx context.Context, idData map[string]any, chanrge *req_users.RequestUpdatePassword) error {
 	var dataUser module.Users
-	if err := s.db.Table("users").Where("user_id = ?", idData["user_id"]).First(&dataUser).Error; err != nil {
+	if err := s.db.Table("users").Where("user_id = ? AND deleted_at IS NULL", idData["user_id"]).First(&dataUser).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return errors.New("old password is incorrect")
 		}
 		return err
 	}
 
-	if !security.ComparePasswords(dataUser.Password_user, []byte(chanrge.OldPassword)) {
+	match, _, err := security.ComparePasswords(dataUser.Password_user, []byte(chanrge.OldPassword))
+	if err != nil {
+		return err
+	}
+	if !match {
 		return errors.New("old password is incorrect")
 	}
 
-	hashPwd := security.HashAndSalt([]byte(chanrge.NewPassword))
-	if err := s.db.Table("users").
-		Where("user_id = ?", idData["user_id"]).
-		Update("password_user", hashPwd).Error; err != nil {
+	hashPwd, err := security.HashPassword([]byte(chanrge.NewPassword))
+	if err != nil {
 		return err
 	}
-	return nil
+	return s.updateWithAudit(ctx, idData, func(tx *gorm.DB) error {
+		return tx.Table("users").
+			Where("user_id = ?", idData["user_id"]).
+			Update("password_user", hashPwd).Error
+	})
 }
 
-func (s *sql) ForgotPwd(ctx context.Context, upPwd *req_users.ForgorPwd) error {
-	var user module.Users
-	if err := s.db.Table("users").
-		Where("account = ?", upPwd.Account).
-		First(&user).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return fmt.Errorf("account does not exist")
-		}
-		return err
-	}
-	// Nếu tìm thấy, cập nhật mật khẩu
-	if err := s.db.Table("users").
-		Where("account = ?", upPwd.Account).
-		Update("password_user", upPwd.NewPassword).Error; err != nil {
-		return err
-	}
+// UpdatePasswordHash thay hash cũ bằng hash mới của cùng mật khẩu,
+// chỉ ghi khi hash trong database vẫn là oldHash để không đè lên lần đổi mật khẩu xảy ra đồng thời
+func (s *sql) UpdatePasswordHash(ctx context.Context, idData map[string]any, oldHash, newHash string) error {
+	return s.updateWithAudit(ctx, idData, func(tx *gorm.DB) error {
+		return tx.Table("users").
+			Where(idData).
+			Where("password_user = ?", oldHash).
+			Update("password_user", newHash).Error
+	})
+}
 
-	return nil
+func (s *sql) ListUser(ctx context.Context, pagging *common.Paggings, query *common.ListQuery) ([]module.Users, error) {
+	return s.list(ctx, "deleted_at IS NULL", listKeyset, createdKey, pagging, query)
 }
 
-func (s *sql) ListUser(ctx context.Context, pagging *common.Paggings) ([]module.Users, error) {
-	var data []module.Users
-	if err := s.db.Table("users").Count(&pagging.Total).Error; err != nil {
-		return nil, err
-	}
-	if err := s.db.Table("users").
-		Order("user_id desc").
-		Offset((pagging.Page - 1) * pagging.Limit).Limit(pagging.Limit).Find(&data).Error; err != nil {
-		return nil, err
+// ListTrashUser liệt kê tài khoản trong thùng rác, mới xóa nhất lên đầu.
+// roles nil là không lọc theo role, ngược lại chỉ lấy tài khoản có role nằm trong roles.
+func (s *sql) ListTrashUser(ctx context.Context, roles []string, pagging *common.Paggings) ([]module.Users, error) {
+	db := s.db.WithContext(ctx).Table("users").Where("deleted_at IS NOT NULL")
+	if roles != nil {
+		db = db.Where("UPPER(role_user) IN ?", roles)
 	}
-	return data, nil
+	return s.page(db, trashKeyset, deletedKey, pagging, nil)
+}
+
+func (s *sql) list(ctx context.Context, deleted string, keys common.Keyset, key func(*module.Users) (*time.Time, string),
+	pagging *common.Paggings, query *common.ListQuery) ([]module.Users, error) {
+	return s.page(s.db.WithContext(ctx).Table("users").Where(deleted), keys, key, pagging, query)
+}
+
+// page không đọc password_user, danh sách tài khoản không bao giờ cần tới mật khẩu đã băm
+func (s *sql) page(db *gorm.DB, keys common.Keyset, key func(*module.Users) (*time.Time, string),
+	pagging *common.Paggings, query *common.ListQuery) ([]module.Users, error) {
+	return common.FindPage(db.Omit("password_user"), pagging, query, keys, key)
 }
 
 func (s *sql) UpdatedUsersByID(ctx context.Context, updateData *req_users.UpdateUsersByID, idData map[string]any) error {
-	if err := s.db.Table("users").Where(idData).Updates(updateData).Error; err != nil {
-		return err
-	}
-	return nil
+	return s.updateWithAudit(ctx, idData, func(tx *gorm.DB) error {
+		return tx.Table("users").Where(idData).Updates(updateData).Error
+	})
 }
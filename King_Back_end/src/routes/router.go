@@ -6,14 +6,23 @@ import (
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 	"thelastking-blogger.com/src/config/db_config"
+	storageconfig "thelastking-blogger.com/src/config/storage_config"
 	"thelastking-blogger.com/src/controller/handler/application_handler/factory_handler"
 	"thelastking-blogger.com/src/controller/handler/application_handler/locations_handler"
 	"thelastking-blogger.com/src/controller/handler/application_handler/product_handler"
+	"thelastking-blogger.com/src/controller/handler/application_handler/scope_handler"
+	"thelastking-blogger.com/src/controller/handler/audit_handler"
+	"thelastking-blogger.com/src/controller/handler/import_handler"
+	"thelastking-blogger.com/src/controller/handler/jwks_handler"
+	"thelastking-blogger.com/src/controller/handler/permission_handler"
+	"thelastking-blogger.com/src/controller/handler/service_account_handler"
 	"thelastking-blogger.com/src/controller/handler/socket_handler"
 	"thelastking-blogger.com/src/controller/handler/users_handler"
 	"thelastking-blogger.com/src/middleware/CORS_Middleware"
+	auditmiddleware "thelastking-blogger.com/src/middleware/audit_Middleware"
 	auth "thelastking-blogger.com/src/middleware/auth_Middleware"
 	jwtmiddleware "thelastking-blogger.com/src/middleware/jwtMiddleware"
+	"thelastking-blogger.com/src/module"
 )
 
 func ThienTanRouters(incomingRoutes *gin.Engine, socketServer *socket_handler.SocketServer) {
@@ -21,6 +30,8 @@ func ThienTanRouters(incomingRoutes *gin.Engine, socketServer *socket_handler.So
 
 	// Áp dụng CORS middleware toàn cục
 	incomingRoutes.Use(CORS_Middleware.CORSMiddleWare())
+	// Gắn IP người gọi vào context cho audit_log
+	incomingRoutes.Use(auditmiddleware.AuditActor())
 
 	// Tạo ServeMux cho WebSocket
 	mux := http.NewServeMux()
@@ -31,65 +42,153 @@ func ThienTanRouters(incomingRoutes *gin.Engine, socketServer *socket_handler.So
 		mux.ServeHTTP(c.Writer, c.Request)
 	})
 
+	// Public key để service khác verify JWT
+	incomingRoutes.GET("/.well-known/jwks.json", jwks_handler.HandlerJWKS())
+
 	router := incomingRoutes.Group("/thientancay")
 	setupLocationRoutes(router.Group("/location"), db, socketServer)
 	setupFactoriesRoutes(router.Group("/factory"), db, socketServer)
 	setupProductRoutes(router.Group("/product"), db, socketServer)
 	setupUserRoutes(router.Group("/users"), db, socketServer)
+	setupPermissionRoutes(router.Group("/permissions"), db)
+	setupServiceAccountRoutes(router.Group("/service-accounts"), db)
+	setupAuditRoutes(router.Group("/audit"), db)
+	setupImportRoutes(router.Group("/import"), db, socketServer)
 
-	incomingRoutes.Static("/uploads", "./uploads")
+	// Với S3 client tải file trực tiếp từ bucket qua URL trong response
+	if cfg := storageconfig.Get(); cfg.Driver == "local" {
+		incomingRoutes.Static("/uploads", cfg.LocalDir)
+	}
 }
 
 func setupUserRoutes(user *gin.RouterGroup, db *gorm.DB, socketServer *socket_handler.SocketServer) {
 	user.POST("/id", users_handler.HandlerCreateUser(db, socketServer))
-	user.POST("/sign-in", users_handler.HandlerSignIn(db))
+	user.POST("/sign-in", users_handler.HandlerSignIn(db, socketServer))
+	user.POST("/sign-in/mfa", users_handler.HandlerSignInMfa(db, socketServer))
+	user.POST("/sign-in/mfa/enroll", users_handler.HandlerSignInMfaEnroll(db))
+	user.POST("/sign-in/mfa/verify", users_handler.HandlerSignInMfaVerify(db, socketServer))
+	user.GET("/oidc/login", users_handler.HandlerOidcLogin(db))
+	user.GET("/oidc/callback", users_handler.HandlerOidcCallback(db, socketServer))
 	user.POST("/sign-out", users_handler.HandlerSignOut(db))
-	user.PATCH("/forgot", users_handler.HandlerForgotPwd(db))
+	user.POST("/forgot", users_handler.HandlerForgotPwd(db))
+	user.POST("/forgot/confirm", users_handler.HandlerConfirmForgotPwd(db))
 	user.POST("/refresh-token", users_handler.HandlerRefreshToken(db))
-	user.GET("/list", users_handler.HandlerListUsers(db))
 	user.Use(jwtmiddleware.JwtMiddleware(db))
 	registerUserHandlers(user, db, socketServer)
 }
 
 func registerUserHandlers(rg *gin.RouterGroup, db *gorm.DB, socketServer *socket_handler.SocketServer) {
-	rg.POST("/createUser", auth.RequireRole("ADMIN", "ROOT"), users_handler.HandlerCreateUserByRole(db, socketServer))
-	rg.GET("/profile", auth.RequireRole("ADMIN", "USER", "ROOT"), users_handler.HandlerProfIle(db))
-	rg.PATCH("/upd", auth.RequireRole("ADMIN", "USER", "ROOT"), users_handler.HandlerUpdUser(db, socketServer))
-	rg.PATCH("/updUser/:id", auth.RequireRole("ADMIN", "ROOT"), users_handler.HandlerUpdateUser(db, socketServer))
-	rg.PATCH("/updPwd", auth.RequireRole("USER", "ADMIN", "ROOT"), users_handler.HandlerChanrgePwd(db))
-	rg.DELETE("/del/:id", auth.RequireRole("ADMIN", "ROOT"), users_handler.HandlerDeletedUser(db, socketServer))
+	rg.POST("/createUser", auth.RequirePermission(module.PermUserCreate), users_handler.HandlerCreateUserByRole(db, socketServer))
+	rg.GET("/list", auth.RequirePermission(module.PermUserUpdate), users_handler.HandlerListUsers(db))
+	rg.GET("/profile", auth.RequirePermission(module.PermUserSelf), users_handler.HandlerProfIle(db))
+	rg.PATCH("/upd", auth.RequirePermission(module.PermUserSelf), users_handler.HandlerUpdUser(db, socketServer))
+	rg.PATCH("/updUser/:id", auth.RequirePermission(module.PermUserUpdate), users_handler.HandlerUpdateUser(db, socketServer))
+	rg.PATCH("/updPwd", auth.RequirePermission(module.PermUserSelf), users_handler.HandlerChanrgePwd(db))
+	rg.DELETE("/del/:id", auth.RequirePermission(module.PermUserDelete), users_handler.HandlerDeletedUser(db, socketServer))
+	rg.GET("/trash", auth.RequirePermission(module.PermUserDelete), users_handler.HandlerListTrashUsers(db))
+	rg.POST("/trash/:id/restore", auth.RequirePermission(module.PermUserDelete), users_handler.HandlerRestoreUser(db, socketServer))
+	rg.DELETE("/trash/:id", auth.RequirePermission(module.PermTrashPurge), users_handler.HandlerPurgeUser(db, socketServer))
+	rg.GET("/sessions", auth.RequirePermission(module.PermUserSelf), users_handler.HandlerListSessions(db))
+	rg.DELETE("/sessions/:id", auth.RequirePermission(module.PermUserSelf), users_handler.HandlerRevokeSession(db))
+	rg.POST("/sessions/revoke-others", auth.RequirePermission(module.PermUserSelf), users_handler.HandlerRevokeOtherSessions(db))
+	rg.GET("/sessions/user/:user_id", auth.RequirePermission(module.PermUserSessions), users_handler.HandlerListUserSessions(db))
+	rg.DELETE("/sessions/user/:user_id/:id", auth.RequirePermission(module.PermUserSessions), users_handler.HandlerRevokeUserSession(db, socketServer))
+	rg.GET("/locked", auth.RequirePermission(module.PermUserUnlock), users_handler.HandlerListLocked(db))
+	rg.POST("/locked/unlock", auth.RequirePermission(module.PermUserUnlock), users_handler.HandlerUnlock(db, socketServer))
+	rg.GET("/mfa", auth.RequirePermission(module.PermUserSelf), users_handler.HandlerMfaStatus(db))
+	rg.POST("/mfa/enroll", auth.RequirePermission(module.PermUserSelf), users_handler.HandlerMfaEnroll(db))
+	rg.POST("/mfa/verify", auth.RequirePermission(module.PermUserSelf), users_handler.HandlerMfaVerify(db))
+	rg.POST("/mfa/disable", auth.RequirePermission(module.PermUserSelf), users_handler.HandlerMfaDisable(db))
+	rg.POST("/mfa/recovery-codes", auth.RequirePermission(module.PermUserSelf), users_handler.HandlerMfaRecoveryCodes(db))
+	rg.GET("/scopes", auth.RequirePermission(module.PermUserSelf), scope_handler.HandlerMyScope(db))
+	rg.GET("/scopes/:user_id", auth.RequirePermission(module.PermScopeAdmin), scope_handler.HandlerUserScope(db))
+	rg.PUT("/scopes/:user_id/:type/:id", auth.RequirePermission(module.PermScopeAdmin), scope_handler.HandlerAssignScope(db, socketServer))
+	rg.DELETE("/scopes/:user_id/:type/:id", auth.RequirePermission(module.PermScopeAdmin), scope_handler.HandlerRemoveScope(db, socketServer))
+}
+
+// PERMISSIONS
+func setupPermissionRoutes(permission *gin.RouterGroup, db *gorm.DB) {
+	permission.Use(jwtmiddleware.JwtMiddleware(db), auth.RequirePermission(module.PermPermissionAdmin))
+	permission.GET("", permission_handler.HandlerListPermissions(db))
+	permission.PUT("/roles/:role/:permission", permission_handler.HandlerGrantPermission(db))
+	permission.DELETE("/roles/:role/:permission", permission_handler.HandlerRevokePermission(db))
+}
 
+// SERVICE ACCOUNTS
+func setupServiceAccountRoutes(account *gin.RouterGroup, db *gorm.DB) {
+	account.Use(jwtmiddleware.JwtMiddleware(db), auth.RequirePermission(module.PermServiceAccount))
+	account.GET("", service_account_handler.HandlerListServiceAccounts(db))
+	account.POST("", service_account_handler.HandlerCreateServiceAccount(db))
+	account.DELETE("/:id", service_account_handler.HandlerDeleteServiceAccount(db))
+	account.POST("/:id/keys", service_account_handler.HandlerCreateApiKey(db))
+	account.DELETE("/:id/keys/:key_id", service_account_handler.HandlerRevokeApiKey(db))
 }
 
 // PRODUCT
 func setupProductRoutes(product *gin.RouterGroup, db *gorm.DB, socketServer *socket_handler.SocketServer) {
-	product.GET("/list", product_handler.HandlerListProduct(db))
-	product.GET("/list/by-local", product_handler.HandlerListProductByLocation(db))
-	product.GET("/list/by-factory", product_handler.HandlerListProductByFactory(db))
+	product.GET("/list", jwtmiddleware.OptionalJwtMiddleware(db), product_handler.HandlerListProduct(db))
+	product.GET("/search", jwtmiddleware.OptionalJwtMiddleware(db), product_handler.HandlerSearchProduct(db))
+	product.GET("/list/by-local", jwtmiddleware.OptionalJwtMiddleware(db), product_handler.HandlerListProductByLocation(db))
+	product.GET("/list/by-factory", jwtmiddleware.OptionalJwtMiddleware(db), product_handler.HandlerListProductByFactory(db))
 	product.Use(jwtmiddleware.JwtMiddleware(db))
 	product.GET("/:product_id", product_handler.HandlerGetProduct(db))
-	product.POST("/", product_handler.HandlerCreateProduct(db, socketServer))
-	product.PATCH("/upd/:product_id", product_handler.HandlerUpdProduct(db, socketServer))
-	product.DELETE("/del/:product_id", product_handler.HandlerDeletedProduct(db, socketServer))
+	product.POST("/", auth.RequirePermission(module.PermProductWrite), product_handler.HandlerCreateProduct(db, socketServer))
+	product.PATCH("/upd/:product_id", auth.RequirePermission(module.PermProductWrite), product_handler.HandlerUpdProduct(db, socketServer))
+	product.DELETE("/del/:product_id", auth.RequirePermission(module.PermProductDelete), product_handler.HandlerDeletedProduct(db, socketServer))
+	product.GET("/:product_id/revisions", auth.RequirePermission(module.PermProductWrite), product_handler.HandlerListProductRevisions(db))
+	product.GET("/:product_id/revisions/diff", auth.RequirePermission(module.PermProductWrite), product_handler.HandlerDiffProductRevisions(db))
+	product.POST("/:product_id/revisions/:revision/revert", auth.RequirePermission(module.PermProductWrite), product_handler.HandlerRevertProduct(db, socketServer))
+	product.POST("/:product_id/transition", auth.RequirePermission(module.PermProductWrite), product_handler.HandlerTransitionProduct(db, socketServer))
+	product.GET("/:product_id/status-history", auth.RequirePermission(module.PermProductWrite), product_handler.HandlerListProductStatusHistory(db))
+	product.POST("/:product_id/media", auth.RequirePermission(module.PermProductWrite), product_handler.HandlerAddProductMedia(db, socketServer))
+	product.PUT("/:product_id/media/order", auth.RequirePermission(module.PermProductWrite), product_handler.HandlerReorderProductMedia(db, socketServer))
+	product.PATCH("/:product_id/media/:media_id", auth.RequirePermission(module.PermProductWrite), product_handler.HandlerUpdateProductMedia(db, socketServer))
+	product.POST("/:product_id/media/:media_id/primary", auth.RequirePermission(module.PermProductWrite), product_handler.HandlerSetPrimaryProductMedia(db, socketServer))
+	product.DELETE("/:product_id/media/:media_id", auth.RequirePermission(module.PermProductWrite), product_handler.HandlerDeleteProductMedia(db, socketServer))
+	product.POST("/uploads", auth.RequirePermission(module.PermProductWrite), product_handler.HandlerCreateUpload(db))
+	product.GET("/uploads/:upload_id", auth.RequirePermission(module.PermProductWrite), product_handler.HandlerGetUpload(db))
+	product.PATCH("/uploads/:upload_id", auth.RequirePermission(module.PermProductWrite), product_handler.HandlerUploadChunk(db))
+	product.DELETE("/uploads/:upload_id", auth.RequirePermission(module.PermProductWrite), product_handler.HandlerCancelUpload(db))
+	product.GET("/trash", auth.RequirePermission(module.PermProductDelete), product_handler.HandlerListTrashProduct(db))
+	product.POST("/trash/:product_id/restore", auth.RequirePermission(module.PermProductDelete), product_handler.HandlerRestoreProduct(db, socketServer))
+	product.DELETE("/trash/:product_id", auth.RequirePermission(module.PermTrashPurge), product_handler.HandlerPurgeProduct(db, socketServer))
 }
 
 // LOCATIONS
 func setupLocationRoutes(local *gin.RouterGroup, db *gorm.DB, socketServer *socket_handler.SocketServer) {
-	local.GET("/list", locations_handler.HandlerListLocation(db))
+	local.GET("/list", jwtmiddleware.OptionalJwtMiddleware(db), locations_handler.HandlerListLocation(db))
 	local.Use(jwtmiddleware.JwtMiddleware(db))
 	local.GET("/:location_id", locations_handler.HandlerGetLocation(db))
-	local.POST("/", locations_handler.HandlerCreateLocation(db, socketServer))
-	local.PATCH("/upd/:location_id", locations_handler.HandlerUpdLocation(db, socketServer))
-	local.DELETE("/del/:location_id", locations_handler.HandlerDeletedLocation(db, socketServer))
+	local.POST("/", auth.RequirePermission(module.PermLocationWrite), locations_handler.HandlerCreateLocation(db, socketServer))
+	local.PATCH("/upd/:location_id", auth.RequirePermission(module.PermLocationWrite), locations_handler.HandlerUpdLocation(db, socketServer))
+	local.DELETE("/del/:location_id", auth.RequirePermission(module.PermLocationDelete), locations_handler.HandlerDeletedLocation(db, socketServer))
+	local.GET("/trash", auth.RequirePermission(module.PermLocationDelete), locations_handler.HandlerListTrashLocation(db))
+	local.POST("/trash/:location_id/restore", auth.RequirePermission(module.PermLocationDelete), locations_handler.HandlerRestoreLocation(db, socketServer))
+	local.DELETE("/trash/:location_id", auth.RequirePermission(module.PermTrashPurge), locations_handler.HandlerPurgeLocation(db, socketServer))
 }
 
 // FACTORIES
 func setupFactoriesRoutes(factory *gin.RouterGroup, db *gorm.DB, socketServer *socket_handler.SocketServer) {
-	factory.GET("/list", factory_handler.HandlerListFactory(db))
+	factory.GET("/list", jwtmiddleware.OptionalJwtMiddleware(db), factory_handler.HandlerListFactory(db))
 	factory.GET("/list/by-local", factory_handler.HandlerListFactoryByLocation(db))
 	factory.Use(jwtmiddleware.JwtMiddleware(db))
 	factory.GET("/:factory_id", factory_handler.HandlerGetFactories(db))
-	factory.POST("/", factory_handler.HandlerCreateFactories(db, socketServer))
-	factory.PATCH("/upd/:factory_id", factory_handler.HandlerUpdFactories(db, socketServer))
-	factory.DELETE("/del/:factory_id", factory_handler.HandlerDeletedFactory(db, socketServer))
+	factory.POST("/", auth.RequirePermission(module.PermFactoryWrite), factory_handler.HandlerCreateFactories(db, socketServer))
+	factory.PATCH("/upd/:factory_id", auth.RequirePermission(module.PermFactoryWrite), factory_handler.HandlerUpdFactories(db, socketServer))
+	factory.DELETE("/del/:factory_id", auth.RequirePermission(module.PermFactoryDelete), factory_handler.HandlerDeletedFactory(db, socketServer))
+	factory.GET("/trash", auth.RequirePermission(module.PermFactoryDelete), factory_handler.HandlerListTrashFactory(db))
+	factory.POST("/trash/:factory_id/restore", auth.RequirePermission(module.PermFactoryDelete), factory_handler.HandlerRestoreFactory(db, socketServer))
+	factory.DELETE("/trash/:factory_id", auth.RequirePermission(module.PermTrashPurge), factory_handler.HandlerPurgeFactory(db, socketServer))
+}
+
+// AUDIT LOG
+func setupAuditRoutes(audit *gin.RouterGroup, db *gorm.DB) {
+	audit.Use(jwtmiddleware.JwtMiddleware(db), auth.RequirePermission(module.PermAuditRead))
+	audit.GET("", audit_handler.HandlerListAudit(db))
+}
+
+// IMPORT: một file có thể tạo cả khu vực, nhà máy và sản phẩm nên cần đủ quyền ghi của cả ba
+func setupImportRoutes(imports *gin.RouterGroup, db *gorm.DB, socketServer *socket_handler.SocketServer) {
+	imports.Use(jwtmiddleware.JwtMiddleware(db), auth.RequirePermission(module.PermLocationWrite, module.PermFactoryWrite, module.PermProductWrite))
+	imports.POST("", import_handler.HandlerImport(db, socketServer))
 }
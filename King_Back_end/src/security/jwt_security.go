@@ -5,116 +5,162 @@ import (
 	"errors"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 	"gorm.io/gorm"
 
-	jwtconfig "thelastking-blogger.com/src/config/jwt_config"
 	"thelastking-blogger.com/src/config/logger"
 	"thelastking-blogger.com/src/module"
 	"thelastking-blogger.com/src/repository/refresh_token_repo"
+	"thelastking-blogger.com/src/security/jwtkeys"
 	"thelastking-blogger.com/src/service/refresh_token_service"
+	"thelastking-blogger.com/src/utils"
 )
 
 // ValidateAccessToken validates an access token (statelessly)
 func ValidateAccessToken(tokenString string) (*module.Token, error) {
 	log := logger.GetLogger()
 
-	token, err := jwt.ParseWithClaims(
-		tokenString,
-		&module.Token{},
-		func(token *jwt.Token) (any, error) {
-			return []byte(jwtconfig.KeyJwt), nil
-		},
-	)
+	token, err := jwtkeys.Parse(tokenString, &module.Token{})
 	if err != nil {
 		log.Errorf("Access token parse failed: %v", err)
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, errors.New("access token has expired")
+		}
 		return nil, errors.New("invalid access token")
 	}
 
 	claims, ok := token.Claims.(*module.Token)
-	if !ok || !token.Valid {
+	if !ok || !token.Valid || claims.TokenUse != module.TokenUseAccess {
 		log.Errorf("Invalid access token claims or token not valid")
 		return nil, errors.New("invalid access token claims")
 	}
 
-	if claims.ExpiresAt < time.Now().Unix() {
-		log.Errorf("Access token has expired")
-		return nil, errors.New("access token has expired")
-	}
-
 	log.Infof("Access token valid: UserID=%s", claims.UserID)
 	return claims, nil
 }
 
-// UpdateToken refreshes an access token using a valid refresh token
-func UpdateToken(ctx context.Context, db *gorm.DB, refreshTokenString string) (string, error) {
-	claims, err := ValidateToken(ctx, db, refreshTokenString)
+// ValidateMfaPendingToken kiểm tra token cấp sau bước mật khẩu, chỉ dùng cho bước xác thực hai bước
+func ValidateMfaPendingToken(tokenString string) (*module.Token, error) {
+	log := logger.GetLogger()
+
+	token, err := jwtkeys.Parse(tokenString, &module.Token{})
 	if err != nil {
-		return "", err
+		log.Errorf("MFA pending token parse failed: %v", err)
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, errors.New("mfa token has expired")
+		}
+		return nil, errors.New("invalid mfa token")
 	}
 
-	newClaims := module.Token{
-		UserID: claims.UserID,
-		Role:   claims.Role,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Local().Add(time.Minute * time.Duration(15)).Unix(),
-			IssuedAt:  time.Now().Unix(),
-			NotBefore: time.Now().Unix(),
-		},
+	claims, ok := token.Claims.(*module.Token)
+	if !ok || !token.Valid || claims.TokenUse != module.TokenUseMfaPending {
+		log.Errorf("Invalid mfa pending token claims")
+		return nil, errors.New("invalid mfa token claims")
+	}
+	return claims, nil
+}
+
+// UpdateToken xoay vòng refresh token: thu hồi token cũ, trả về access token và refresh token mới cùng family.
+// Token đã xoay vòng mà bị gửi lại sẽ khiến cả family bị thu hồi (module.ErrRefreshTokenReused).
+func UpdateToken(ctx context.Context, db *gorm.DB, refreshTokenString string, client module.SessionClient) (string, string, error) {
+	claims, err := parseRefreshToken(refreshTokenString)
+	if err != nil {
+		return "", "", err
 	}
 
-	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, newClaims).SignedString([]byte(jwtconfig.KeyJwt))
+	buss := refresh_token_service.NewRefreshTokenController(refresh_token_repo.NewSql(db))
+	sessionID := claims.SessionID
+	if sessionID == "" {
+		// Refresh token cấp trước khi có sid: family lấy trong database
+		dbToken, err := buss.NewGetRefreshTokenByHash(ctx, HashToken(refreshTokenString))
+		if err != nil {
+			return "", "", err
+		}
+		sessionID = dbToken.FamilyID
+	}
+	accessToken, refreshToken, err := utils.GenerateTokens(&module.Users{UserID: claims.UserID, Role: claims.Role}, sessionID)
+	if err != nil {
+		logger.GetLogger().Errorf("Error generating new tokens during refresh: %v", err)
+		return "", "", errors.New("failed to generate new access token")
+	}
+	refreshClaims, err := utils.ParseToken(refreshToken)
 	if err != nil {
-		logger.GetLogger().Errorf("Error generating new access token during refresh: %v", err)
-		return "", errors.New("failed to generate new access token")
+		return "", "", err
 	}
 
-	logger.GetLogger().Infof("Generated new access token for user %s via refresh token", claims.UserID)
-	return accessToken, nil
+	now := time.Now().UTC()
+	newToken := &module.RefreshToken{
+		TokenHash:  HashToken(refreshToken),
+		ExpiresAt:  refreshClaims.ExpiresAt.Time.UTC(),
+		Revoked:    false,
+		CreatedAt:  now,
+		UserAgent:  client.UserAgent,
+		IPAddress:  client.IPAddress,
+		LastUsedAt: &now,
+	}
+	if err := buss.NewRotateRefreshToken(ctx, HashToken(refreshTokenString), newToken); err != nil {
+		return "", "", err
+	}
+
+	logger.GetLogger().Infof("Rotated refresh token for user %s", claims.UserID)
+	return accessToken, refreshToken, nil
 }
 
 // ValidateToken validates a refresh token by checking the database
 func ValidateToken(ctx context.Context, db *gorm.DB, refreshTokenString string) (*module.Token, error) {
 	log := logger.GetLogger()
 
-	token, err := jwt.ParseWithClaims(
-		refreshTokenString,
-		&module.Token{},
-		func(token *jwt.Token) (any, error) {
-			return []byte(jwtconfig.KeyJwt), nil
-		},
-	)
+	claims, err := parseRefreshToken(refreshTokenString)
 	if err != nil {
-		log.Errorf("Phân tích refresh token thất bại: Token=%s, Lỗi=%v", refreshTokenString, err)
-		return nil, errors.New("refresh token không hợp lệ")
+		return nil, err
 	}
 
-	claims, ok := token.Claims.(*module.Token)
-	if !ok || !token.Valid {
-		log.Errorf("Claims refresh token không hợp lệ: Claims=%+v, Hợp lệ=%v", claims, token.Valid)
-		return nil, errors.New("claims refresh token không hợp lệ")
-	}
-
-	log.Infof("Đang xác thực refresh token: UserID=%s, ExpiresAt=%d", claims.UserID, claims.ExpiresAt)
+	log.Infof("Đang xác thực refresh token: UserID=%s, ExpiresAt=%v", claims.UserID, claims.ExpiresAt)
 
 	buss := refresh_token_service.NewRefreshTokenController(refresh_token_repo.NewSql(db))
-	dbToken, err := buss.NewGetRefreshTokenByTokenID(ctx, refreshTokenString) // Truy vấn cột token
+	dbToken, err := buss.NewGetRefreshTokenByHash(ctx, HashToken(refreshTokenString))
 	if err != nil {
-		log.Errorf("Không tìm thấy refresh token trong cơ sở dữ liệu: Token=%s, Lỗi=%v", refreshTokenString, err)
+		log.Errorf("Không tìm thấy refresh token trong cơ sở dữ liệu: UserID=%s, Lỗi=%v", claims.UserID, err)
 		return nil, errors.New("refresh token không tìm thấy hoặc đã bị xóa")
 	}
 
 	if dbToken.Revoked {
-		log.Errorf("Refresh token đã bị thu hồi: Token=%s", refreshTokenString)
+		// Token đã được xoay vòng mà vẫn bị dùng lại: có thể cookie đã bị đánh cắp
+		if dbToken.ReplacedBy != nil {
+			log.Errorf("Phát hiện dùng lại refresh token: UserID=%s, Family=%s", dbToken.UserID, dbToken.FamilyID)
+			if err := buss.NewRevokeRefreshTokenFamily(ctx, dbToken.FamilyID); err != nil {
+				return nil, err
+			}
+			return nil, module.ErrRefreshTokenReused
+		}
+		log.Errorf("Refresh token đã bị thu hồi: UserID=%s, Family=%s", dbToken.UserID, dbToken.FamilyID)
 		return nil, errors.New("refresh token đã bị thu hồi")
 	}
 	if dbToken.ExpiresAt.Before(time.Now()) {
-		log.Errorf("Refresh token đã hết hạn: Token=%s, ExpiresAt=%v", refreshTokenString, dbToken.ExpiresAt)
+		log.Errorf("Refresh token đã hết hạn: UserID=%s, ExpiresAt=%v", dbToken.UserID, dbToken.ExpiresAt)
 		return nil, errors.New("refresh token đã hết hạn")
 	}
 
-	log.Infof("Refresh token đã được xác thực: UserID=%s, Token=%s", claims.UserID, refreshTokenString)
+	log.Infof("Refresh token đã được xác thực: UserID=%s, Family=%s", claims.UserID, dbToken.FamilyID)
+	return claims, nil
+}
+
+// parseRefreshToken kiểm tra chữ ký và claims của refresh token, chưa đụng tới database
+func parseRefreshToken(refreshTokenString string) (*module.Token, error) {
+	log := logger.GetLogger()
+
+	token, err := jwtkeys.Parse(refreshTokenString, &module.Token{})
+	if err != nil {
+		log.Errorf("Phân tích refresh token thất bại: Lỗi=%v", err)
+		return nil, errors.New("refresh token không hợp lệ")
+	}
+
+	claims, ok := token.Claims.(*module.Token)
+	if !ok || !token.Valid || claims.TokenUse != module.TokenUseRefresh {
+		log.Errorf("Claims refresh token không hợp lệ: Claims=%+v, Hợp lệ=%v", claims, token.Valid)
+		return nil, errors.New("claims refresh token không hợp lệ")
+	}
 	return claims, nil
 }
 
@@ -125,6 +171,5 @@ func ValidateCookieToken(ctx context.Context, c *gin.Context, db *gorm.DB) (*mod
 		logger.GetLogger().Errorf("Missing refresh_token cookie: %v", err)
 		return nil, errors.New("refresh token cookie missing")
 	}
-	logger.GetLogger().Infof("Cookie refresh_token: %s", cookieToken)
 	return ValidateToken(ctx, db, cookieToken)
 }
@@ -0,0 +1,350 @@
+package jwtkeys
+
+import (
+	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	jwtconfig "thelastking-blogger.com/src/config/jwt_config"
+	"thelastking-blogger.com/src/config/logger"
+	"thelastking-blogger.com/src/module"
+)
+
+// KeyStore lưu trữ khóa ký để mọi instance dùng chung và giữ được qua các lần khởi động lại
+type KeyStore interface {
+	ListJwtKeys(ctx context.Context, now time.Time) ([]module.JwtKey, error)
+	CreateJwtKeyIfDue(ctx context.Context, key *module.JwtKey, activeUntil time.Time) (bool, error)
+	DeleteExpiredJwtKeys(ctx context.Context, now time.Time) error
+}
+
+type signingKey struct {
+	kid       string
+	method    jwt.SigningMethod
+	private   crypto.Signer
+	public    crypto.PublicKey
+	retireAt  time.Time
+	expiresAt time.Time
+}
+
+type KeySet struct {
+	mu    sync.RWMutex
+	store KeyStore
+	cfg   *jwtconfig.Config
+	aead  cipher.AEAD  // mã hóa private key trước khi lưu vào store
+	keys  []signingKey // mới nhất trước
+	log   logger.Logger
+}
+
+var (
+	instance *KeySet
+	initOnce sync.Once
+	initErr  error
+)
+
+// Init nạp khóa từ store, tạo khóa đầu tiên nếu cần và chạy job xoay vòng khóa
+func Init(store KeyStore) error {
+	initOnce.Do(func() {
+		cfg := jwtconfig.Get()
+		aead, err := newAEAD(cfg.KeyEncryptionKey)
+		if err != nil {
+			initErr = err
+			return
+		}
+		ks := &KeySet{
+			store: store,
+			cfg:   cfg,
+			aead:  aead,
+			log:   logger.GetLogger(),
+		}
+		if initErr = ks.Rotate(context.Background()); initErr != nil {
+			return
+		}
+		instance = ks
+		go ks.runRotation()
+	})
+	return initErr
+}
+
+// Sign ký claims bằng khóa đang hoạt động, header kid cho biết khóa nào được dùng
+func Sign(claims jwt.Claims) (string, error) {
+	if instance == nil {
+		return "", errors.New("jwt key set is not initialized")
+	}
+	key, err := instance.activeKey()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.kid
+	return token.SignedString(key.private)
+}
+
+// Parse kiểm tra chữ ký bằng khóa tương ứng với kid và issuer của token
+func Parse(tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	if instance == nil {
+		return nil, errors.New("jwt key set is not initialized")
+	}
+	return jwt.ParseWithClaims(tokenString, claims, instance.keyFunc,
+		jwt.WithValidMethods([]string{"RS256", "EdDSA"}),
+		jwt.WithIssuer(instance.cfg.Issuer),
+		jwt.WithExpirationRequired(),
+	)
+}
+
+func (ks *KeySet) keyFunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, errors.New("token has no kid header")
+	}
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	for _, key := range ks.keys {
+		if key.kid == kid {
+			if key.method.Alg() != token.Method.Alg() {
+				return nil, fmt.Errorf("key %s does not use %s", kid, token.Method.Alg())
+			}
+			return key.public, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key %s", kid)
+}
+
+func (ks *KeySet) activeKey() (signingKey, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	now := time.Now()
+	for _, key := range ks.keys {
+		if key.retireAt.After(now) && key.method.Alg() == ks.cfg.Algorithm {
+			return key, nil
+		}
+	}
+	return signingKey{}, errors.New("no active jwt signing key")
+}
+
+// Rotate nạp lại danh sách khóa, tạo khóa mới khi khóa đang ký sẽ nghỉ trước lượt kiểm tra kế tiếp
+// và xóa khóa hết hạn. Khóa mới chỉ được sinh khi thật sự cần vì sinh khóa RSA khá tốn CPU.
+func (ks *KeySet) Rotate(ctx context.Context) error {
+	now := time.Now().UTC()
+	if err := ks.reload(ctx, now); err != nil {
+		return err
+	}
+	// Khóa mới có trước khi khóa cũ nghỉ nên không có lúc nào thiếu khóa để ký
+	activeUntil := now.Add(checkInterval(ks.cfg))
+	if ks.activeAt(activeUntil) {
+		if err := ks.store.DeleteExpiredJwtKeys(ctx, now); err != nil {
+			ks.log.Errorf("Failed to delete expired JWT keys: %v", err)
+		}
+		return nil
+	}
+
+	key, err := generateKey(ks.cfg, ks.aead, now)
+	if err != nil {
+		return err
+	}
+	created, err := ks.store.CreateJwtKeyIfDue(ctx, key, activeUntil)
+	if err != nil {
+		return fmt.Errorf("create jwt key: %w", err)
+	}
+	if created {
+		ks.log.Infof("Created new JWT signing key kid=%s alg=%s", key.Kid, key.Algorithm)
+	}
+	if err := ks.store.DeleteExpiredJwtKeys(ctx, now); err != nil {
+		ks.log.Errorf("Failed to delete expired JWT keys: %v", err)
+	}
+	return ks.reload(ctx, now)
+}
+
+// activeAt cho biết có khóa với thuật toán đang cấu hình còn ký được tại thời điểm t
+func (ks *KeySet) activeAt(t time.Time) bool {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	for _, key := range ks.keys {
+		if key.retireAt.After(t) && key.method.Alg() == ks.cfg.Algorithm {
+			return true
+		}
+	}
+	return false
+}
+
+func (ks *KeySet) reload(ctx context.Context, now time.Time) error {
+	rows, err := ks.store.ListJwtKeys(ctx, now)
+	if err != nil {
+		return fmt.Errorf("load jwt keys: %w", err)
+	}
+	keys := make([]signingKey, 0, len(rows))
+	for _, row := range rows {
+		key, err := decodeKey(ks.aead, row)
+		if err != nil {
+			ks.log.Errorf("Skipping JWT key %s: %v", row.Kid, err)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	ks.mu.Lock()
+	ks.keys = keys
+	ks.mu.Unlock()
+	return nil
+}
+
+// checkInterval là khoảng giữa hai lượt kiểm tra xoay vòng khóa
+func checkInterval(cfg *jwtconfig.Config) time.Duration {
+	interval := cfg.RotationInterval / 10
+	if interval > 10*time.Minute {
+		interval = 10 * time.Minute
+	}
+	if interval < time.Minute {
+		interval = time.Minute
+	}
+	return interval
+}
+
+// runRotation kiểm tra định kỳ, đồng thời nạp khóa do instance khác tạo ra
+func (ks *KeySet) runRotation() {
+	ticker := time.NewTicker(checkInterval(ks.cfg))
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := ks.Rotate(context.Background()); err != nil {
+			ks.log.Errorf("JWT key rotation failed: %v", err)
+		}
+	}
+}
+
+func generateKey(cfg *jwtconfig.Config, aead cipher.AEAD, now time.Time) (*module.JwtKey, error) {
+	var private crypto.Signer
+	var err error
+	switch cfg.Algorithm {
+	case "RS256":
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	default:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(private.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	kid := uuid.NewString()
+	sealed, err := sealPrivateKey(aead, kid, privateDER)
+	if err != nil {
+		return nil, err
+	}
+	retireAt := now.Add(cfg.RotationInterval)
+	return &module.JwtKey{
+		Kid:        kid,
+		Algorithm:  cfg.Algorithm,
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: encryptedKeyType, Bytes: sealed})),
+		PublicKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})),
+		CreatedAt:  now,
+		RetireAt:   retireAt,
+		// Token ký ngay trước khi khóa nghỉ vẫn phải verify được tới khi refresh token hết hạn
+		ExpiresAt: retireAt.Add(cfg.RefreshTTL),
+	}, nil
+}
+
+func decodeKey(aead cipher.AEAD, row module.JwtKey) (signingKey, error) {
+	block, _ := pem.Decode([]byte(row.PrivateKey))
+	if block == nil {
+		return signingKey{}, errors.New("invalid private key PEM")
+	}
+	der := block.Bytes
+	switch block.Type {
+	case encryptedKeyType:
+		var err error
+		if der, err = openPrivateKey(aead, row.Kid, block.Bytes); err != nil {
+			return signingKey{}, err
+		}
+	case "PRIVATE KEY":
+		// Khóa tạo trước khi có mã hóa vẫn được dùng tới khi hết hạn, khóa mới luôn được mã hóa
+	default:
+		return signingKey{}, fmt.Errorf("unsupported private key PEM type %q", block.Type)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return signingKey{}, err
+	}
+	private, ok := parsed.(crypto.Signer)
+	if !ok {
+		return signingKey{}, errors.New("private key cannot sign")
+	}
+
+	var method jwt.SigningMethod
+	switch row.Algorithm {
+	case "RS256":
+		if _, ok := private.(*rsa.PrivateKey); !ok {
+			return signingKey{}, errors.New("RS256 key is not RSA")
+		}
+		method = jwt.SigningMethodRS256
+	case "EdDSA":
+		if _, ok := private.(ed25519.PrivateKey); !ok {
+			return signingKey{}, errors.New("EdDSA key is not Ed25519")
+		}
+		method = jwt.SigningMethodEdDSA
+	default:
+		return signingKey{}, fmt.Errorf("unsupported algorithm %s", row.Algorithm)
+	}
+
+	return signingKey{
+		kid:       row.Kid,
+		method:    method,
+		private:   private,
+		public:    private.Public(),
+		retireAt:  row.RetireAt,
+		expiresAt: row.ExpiresAt,
+	}, nil
+}
+
+// encryptedKeyType là kiểu PEM của private key đã mã hóa: nonce || AES-256-GCM(PKCS#8 DER), kid là dữ liệu bổ sung
+// nên không thể chép private key của dòng này sang dòng khác
+const encryptedKeyType = "JWT ENCRYPTED PRIVATE KEY"
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) == 0 {
+		return nil, errors.New("JWT_KEY_ENCRYPTION_KEY is not set, generate one with `openssl rand -base64 32`")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func sealPrivateKey(aead cipher.AEAD, kid string, der []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, der, []byte(kid)), nil
+}
+
+func openPrivateKey(aead cipher.AEAD, kid string, sealed []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("encrypted private key is too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	der, err := aead.Open(nil, nonce, ciphertext, []byte(kid))
+	if err != nil {
+		return nil, errors.New("cannot decrypt private key, JWT_KEY_ENCRYPTION_KEY may have changed")
+	}
+	return der, nil
+}
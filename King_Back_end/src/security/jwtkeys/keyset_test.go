@@ -0,0 +1,298 @@
+package jwtkeys
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	jwtconfig "thelastking-blogger.com/src/config/jwt_config"
+	"thelastking-blogger.com/src/config/logger"
+	"thelastking-blogger.com/src/module"
+)
+
+// memoryStore giữ khóa trong bộ nhớ với cùng điều kiện như jwt_key_repo
+type memoryStore struct {
+	keys    []module.JwtKey
+	created int
+}
+
+func (s *memoryStore) ListJwtKeys(ctx context.Context, now time.Time) ([]module.JwtKey, error) {
+	var rows []module.JwtKey
+	for _, key := range s.keys {
+		if key.ExpiresAt.After(now) {
+			rows = append(rows, key)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].CreatedAt.After(rows[j].CreatedAt) })
+	return rows, nil
+}
+
+func (s *memoryStore) CreateJwtKeyIfDue(ctx context.Context, key *module.JwtKey, activeUntil time.Time) (bool, error) {
+	for _, existing := range s.keys {
+		if existing.RetireAt.After(activeUntil) && existing.Algorithm == key.Algorithm {
+			return false, nil
+		}
+	}
+	s.keys = append(s.keys, *key)
+	s.created++
+	return true, nil
+}
+
+func (s *memoryStore) DeleteExpiredJwtKeys(ctx context.Context, now time.Time) error {
+	kept := s.keys[:0]
+	for _, key := range s.keys {
+		if key.ExpiresAt.After(now) {
+			kept = append(kept, key)
+		}
+	}
+	s.keys = kept
+	return nil
+}
+
+func testConfig(algorithm string) *jwtconfig.Config {
+	return &jwtconfig.Config{
+		Algorithm:        algorithm,
+		RotationInterval: 24 * time.Hour,
+		AccessTTL:        15 * time.Minute,
+		RefreshTTL:       7 * 24 * time.Hour,
+		Issuer:           "test-issuer",
+		KeyEncryptionKey: make([]byte, 32),
+	}
+}
+
+func newTestKeySet(t *testing.T, algorithm string, store KeyStore) *KeySet {
+	t.Helper()
+	cfg := testConfig(algorithm)
+	aead, err := newAEAD(cfg.KeyEncryptionKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &KeySet{store: store, cfg: cfg, aead: aead, log: logger.GetLogger()}
+}
+
+func TestRotate(t *testing.T) {
+	now := time.Now().UTC()
+	tests := []struct {
+		name        string
+		existing    func(ks *KeySet) []module.JwtKey
+		wantCreated int
+	}{
+		{
+			name:        "empty store creates the first key",
+			existing:    func(ks *KeySet) []module.JwtKey { return nil },
+			wantCreated: 1,
+		},
+		{
+			name: "active key far from retiring is kept",
+			existing: func(ks *KeySet) []module.JwtKey {
+				return []module.JwtKey{mustGenerate(t, ks, now)}
+			},
+			wantCreated: 0,
+		},
+		{
+			name: "active key retiring before the next check gets a successor",
+			existing: func(ks *KeySet) []module.JwtKey {
+				key := mustGenerate(t, ks, now)
+				key.RetireAt = now.Add(30 * time.Second)
+				return []module.JwtKey{key}
+			},
+			wantCreated: 1,
+		},
+		{
+			name: "key of another algorithm does not count",
+			existing: func(ks *KeySet) []module.JwtKey {
+				other := newTestKeySet(t, "RS256", nil)
+				return []module.JwtKey{mustGenerate(t, other, now)}
+			},
+			wantCreated: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &memoryStore{}
+			ks := newTestKeySet(t, "EdDSA", store)
+			store.keys = tt.existing(ks)
+			if err := ks.Rotate(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if store.created != tt.wantCreated {
+				t.Errorf("created %d keys, want %d", store.created, tt.wantCreated)
+			}
+			if _, err := ks.activeKey(); err != nil {
+				t.Errorf("no active key after Rotate: %v", err)
+			}
+			// Lượt kiểm tra tiếp theo không được sinh thêm khóa
+			if err := ks.Rotate(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if store.created != tt.wantCreated {
+				t.Errorf("second Rotate created a key, total %d", store.created)
+			}
+		})
+	}
+}
+
+func TestGeneratedKeyIsEncrypted(t *testing.T) {
+	ks := newTestKeySet(t, "EdDSA", nil)
+	row := mustGenerate(t, ks, time.Now().UTC())
+	block, _ := pem.Decode([]byte(row.PrivateKey))
+	if block == nil || block.Type != encryptedKeyType {
+		t.Fatalf("private key PEM type = %v, want %s", block, encryptedKeyType)
+	}
+	if strings.Contains(row.PrivateKey, "BEGIN PRIVATE KEY") {
+		t.Error("private key is stored as plaintext PEM")
+	}
+}
+
+func TestDecodeKey(t *testing.T) {
+	ks := newTestKeySet(t, "EdDSA", nil)
+	now := time.Now().UTC()
+	encrypted := mustGenerate(t, ks, now)
+
+	swapped := encrypted
+	swapped.Kid = "another-kid"
+
+	otherKey := newTestKeySet(t, "EdDSA", nil)
+	otherKey.cfg.KeyEncryptionKey[0] = 1
+	var err error
+	if otherKey.aead, err = newAEAD(otherKey.cfg.KeyEncryptionKey); err != nil {
+		t.Fatal(err)
+	}
+
+	legacy := encrypted
+	legacy.PrivateKey = legacyPEM(t)
+
+	wrongAlg := encrypted
+	wrongAlg.Algorithm = "RS256"
+
+	unknownType := encrypted
+	unknownType.PrivateKey = string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: []byte{1}}))
+
+	tests := []struct {
+		name    string
+		ks      *KeySet
+		row     module.JwtKey
+		wantErr bool
+	}{
+		{"encrypted key", ks, encrypted, false},
+		{"legacy plaintext key", ks, legacy, false},
+		{"encrypted key moved to another kid", ks, swapped, true},
+		{"different encryption key", otherKey, encrypted, true},
+		{"algorithm does not match key", ks, wrongAlg, true},
+		{"unsupported PEM type", ks, unknownType, true},
+		{"not PEM", ks, module.JwtKey{Kid: "x", Algorithm: "EdDSA", PrivateKey: "garbage"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := decodeKey(tt.ks.aead, tt.row)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && key.kid != tt.row.Kid {
+				t.Errorf("kid = %s, want %s", key.kid, tt.row.Kid)
+			}
+		})
+	}
+}
+
+func TestNewAEADRequiresKey(t *testing.T) {
+	if _, err := newAEAD(nil); err == nil {
+		t.Error("newAEAD(nil) returned no error")
+	}
+	if _, err := newAEAD(make([]byte, 7)); err == nil {
+		t.Error("newAEAD with 7 byte key returned no error")
+	}
+}
+
+func TestSignParseAndJWKS(t *testing.T) {
+	for _, algorithm := range []string{"EdDSA", "RS256"} {
+		t.Run(algorithm, func(t *testing.T) {
+			store := &memoryStore{}
+			ks := newTestKeySet(t, algorithm, store)
+			if err := ks.Rotate(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			previous := instance
+			instance = ks
+			defer func() { instance = previous }()
+
+			now := time.Now()
+			signed, err := Sign(jwt.RegisteredClaims{
+				Issuer:    "test-issuer",
+				Subject:   "user-1",
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims := &jwt.RegisteredClaims{}
+			if _, err := Parse(signed, claims); err != nil {
+				t.Fatalf("Parse() error: %v", err)
+			}
+			if claims.Subject != "user-1" {
+				t.Errorf("subject = %s, want user-1", claims.Subject)
+			}
+
+			wrongIssuer, err := Sign(jwt.RegisteredClaims{
+				Issuer:    "someone-else",
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Parse(wrongIssuer, &jwt.RegisteredClaims{}); err == nil {
+				t.Error("Parse() accepted a token from another issuer")
+			}
+
+			set := JWKS()
+			if len(set.Keys) != 1 {
+				t.Fatalf("JWKS has %d keys, want 1", len(set.Keys))
+			}
+			jwk := set.Keys[0]
+			if jwk.Kid != store.keys[0].Kid || jwk.Alg != algorithm || jwk.Use != "sig" {
+				t.Errorf("unexpected JWK %+v", jwk)
+			}
+			switch algorithm {
+			case "EdDSA":
+				if jwk.Kty != "OKP" || jwk.Crv != "Ed25519" || jwk.X == "" {
+					t.Errorf("unexpected Ed25519 JWK %+v", jwk)
+				}
+			case "RS256":
+				if jwk.Kty != "RSA" || jwk.N == "" || jwk.E != "AQAB" {
+					t.Errorf("unexpected RSA JWK %+v", jwk)
+				}
+			}
+		})
+	}
+}
+
+func mustGenerate(t *testing.T, ks *KeySet, now time.Time) module.JwtKey {
+	t.Helper()
+	key, err := generateKey(ks.cfg, ks.aead, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *key
+}
+
+// legacyPEM là khóa Ed25519 lưu dạng PKCS#8 chưa mã hóa như trước khi có JWT_KEY_ENCRYPTION_KEY
+func legacyPEM(t *testing.T) string {
+	t.Helper()
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
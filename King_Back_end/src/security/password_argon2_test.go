@@ -0,0 +1,209 @@
+package security
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Tham số nhỏ để test chạy nhanh, không phải chính sách thật
+var testArgon2 = argon2idHasher{memory: 1024, time: 1, threads: 1}
+
+func TestArgon2Verify(t *testing.T) {
+	encoded, err := testArgon2.Hash([]byte("Mat-khau-dung-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(encoded, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Fatalf("unexpected hash format %q", encoded)
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		want     bool
+	}{
+		{"correct password", "Mat-khau-dung-1", true},
+		{"wrong password", "Mat-khau-sai-1", false},
+		{"empty password", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := testArgon2.Verify(encoded, []byte(tt.password))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Verify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArgon2HashUsesRandomSalt(t *testing.T) {
+	first, err := testArgon2.Hash([]byte("same"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := testArgon2.Hash([]byte("same"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Error("two hashes of the same password are identical")
+	}
+}
+
+func TestArgon2VerifyInvalidHash(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{"empty", ""},
+		{"bcrypt hash", "$2a$10$abcdefghijklmnopqrstuuKcNn0tFoN1bH0Kk3ZbO6nQeTzB7pDxu"},
+		{"wrong version", "$argon2id$v=16$m=1024,t=1,p=1$c2FsdHNhbHRzYWx0$a2V5"},
+		{"bad params", "$argon2id$v=19$m=x,t=1,p=1$c2FsdHNhbHRzYWx0$a2V5"},
+		{"bad salt", "$argon2id$v=19$m=1024,t=1,p=1$!!!$a2V5"},
+		{"empty key", "$argon2id$v=19$m=1024,t=1,p=1$c2FsdHNhbHRzYWx0$"},
+		{"missing part", "$argon2id$v=19$m=1024,t=1,p=1$c2FsdHNhbHRzYWx0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := testArgon2.Verify(tt.encoded, []byte("x")); err == nil {
+				t.Error("Verify() returned no error")
+			}
+		})
+	}
+}
+
+func TestArgon2NeedsRehash(t *testing.T) {
+	encoded, err := testArgon2.Hash([]byte("pwd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		policy argon2idHasher
+		hash   string
+		want   bool
+	}{
+		{"same policy", testArgon2, encoded, false},
+		{"weaker policy", argon2idHasher{memory: 512, time: 1, threads: 1}, encoded, false},
+		{"more memory", argon2idHasher{memory: 2048, time: 1, threads: 1}, encoded, true},
+		{"more iterations", argon2idHasher{memory: 1024, time: 2, threads: 1}, encoded, true},
+		{"different threads", argon2idHasher{memory: 1024, time: 1, threads: 2}, encoded, true},
+		{"short key", testArgon2, "$argon2id$v=19$m=1024,t=1,p=1$c2FsdHNhbHRzYWx0$a2V5", true},
+		{"unreadable hash", testArgon2, "garbage", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.NeedsRehash(tt.hash); got != tt.want {
+				t.Errorf("NeedsRehash() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBcryptVerifyAndRehash(t *testing.T) {
+	hasher := bcryptHasher{cost: bcrypt.MinCost}
+	encoded, err := hasher.Hash([]byte("pwd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := hasher.Verify(encoded, []byte("pwd")); err != nil || !ok {
+		t.Errorf("Verify(correct) = (%v, %v), want (true, nil)", ok, err)
+	}
+	if ok, err := hasher.Verify(encoded, []byte("other")); err != nil || ok {
+		t.Errorf("Verify(wrong) = (%v, %v), want (false, nil)", ok, err)
+	}
+	if hasher.NeedsRehash(encoded) {
+		t.Error("NeedsRehash() with same cost = true")
+	}
+	if !(bcryptHasher{cost: bcrypt.MinCost + 1}).NeedsRehash(encoded) {
+		t.Error("NeedsRehash() with higher cost = false")
+	}
+}
+
+func TestHasherMatches(t *testing.T) {
+	tests := []struct {
+		encoded    string
+		wantArgon2 bool
+		wantBcrypt bool
+	}{
+		{"$argon2id$v=19$m=1024,t=1,p=1$c2FsdA$a2V5", true, false},
+		{"$2a$10$abc", false, true},
+		{"$2b$10$abc", false, true},
+		{"$2y$10$abc", false, true},
+		{"$argon2i$v=19$m=1024,t=1,p=1$c2FsdA$a2V5", false, false},
+		{"plaintext", false, false},
+	}
+	for _, tt := range tests {
+		if got := testArgon2.Matches(tt.encoded); got != tt.wantArgon2 {
+			t.Errorf("argon2 Matches(%q) = %v, want %v", tt.encoded, got, tt.wantArgon2)
+		}
+		if got := (bcryptHasher{}).Matches(tt.encoded); got != tt.wantBcrypt {
+			t.Errorf("bcrypt Matches(%q) = %v, want %v", tt.encoded, got, tt.wantBcrypt)
+		}
+	}
+}
+
+func TestComparePasswords(t *testing.T) {
+	// Chính sách hiện tại lấy từ env do TestMain đặt: argon2id m=1024,t=1,p=1
+	current, err := HashPassword([]byte("pwd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	weaker, err := argon2idHasher{memory: 512, time: 1, threads: 1}.Hash([]byte("pwd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := bcryptHasher{cost: bcrypt.MinCost}.Hash([]byte("pwd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		hash        string
+		password    string
+		wantMatch   bool
+		wantRehash  bool
+		wantUnknown bool
+	}{
+		{"current policy", current, "pwd", true, false, false},
+		{"current policy wrong password", current, "other", false, false, false},
+		{"weaker argon2 params", weaker, "pwd", true, true, false},
+		{"weaker argon2 wrong password", weaker, "other", false, false, false},
+		{"bcrypt hash", legacy, "pwd", true, true, false},
+		{"bcrypt wrong password", legacy, "other", false, false, false},
+		{"unknown format", "plaintext", "plaintext", false, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, rehash, err := ComparePasswords(tt.hash, []byte(tt.password))
+			if tt.wantUnknown {
+				if !errors.Is(err, ErrUnknownPasswordHash) {
+					t.Fatalf("err = %v, want ErrUnknownPasswordHash", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if match != tt.wantMatch || rehash != tt.wantRehash {
+				t.Errorf("ComparePasswords() = (%v, %v), want (%v, %v)", match, rehash, tt.wantMatch, tt.wantRehash)
+			}
+		})
+	}
+}
+
+func TestMain(m *testing.M) {
+	os.Setenv("PASSWORD_HASHER", "argon2id")
+	os.Setenv("ARGON2_MEMORY", "1024")
+	os.Setenv("ARGON2_TIME", "1")
+	os.Setenv("ARGON2_THREADS", "1")
+	os.Exit(m.Run())
+}
@@ -1,35 +1,65 @@
 package security
 
 import (
-	"log"
+	"errors"
 
-	"golang.org/x/crypto/bcrypt"
+	passwordconfig "thelastking-blogger.com/src/config/password_config"
 )
 
-func HashAndSalt(pwd []byte) string {
+// ErrUnknownPasswordHash: hash trong database không thuộc thuật toán nào được hỗ trợ
+var ErrUnknownPasswordHash = errors.New("unknown password hash format")
 
-	// Use GenerateFromPassword to hash & salt pwd
-	// MinCost is just an integer constant provided by the bcrypt
-	// package along with DefaultCost & MaxCost.
-	// The cost can be any value you want provided it isn't lower
-	// than the MinCost (4)
-	hash, err := bcrypt.GenerateFromPassword(pwd, bcrypt.MinCost)
-	if err != nil {
-		log.Println(err)
+// PasswordHasher băm và kiểm tra mật khẩu. Hash được mã hóa kèm thuật toán và tham số
+// (bcrypt: $2a$<cost>$..., argon2id: chuẩn PHC $argon2id$v=19$m=..,t=..,p=..$salt$hash)
+// nên đổi chính sách không làm hỏng các hash đã lưu.
+type PasswordHasher interface {
+	Hash(password []byte) (string, error)
+	Verify(encoded string, password []byte) (bool, error)
+	// NeedsRehash cho biết hash được tạo với tham số khác chính sách hiện tại
+	NeedsRehash(encoded string) bool
+	// Matches cho biết encoded có phải do hasher này tạo ra
+	Matches(encoded string) bool
+}
+
+// currentHasher trả về hasher theo chính sách hiện tại
+func currentHasher() PasswordHasher {
+	cfg := passwordconfig.Get()
+	if cfg.Algorithm == "bcrypt" {
+		return bcryptHasher{cost: cfg.BcryptCost}
 	}
-	// GenerateFromPassword returns a byte slice so we need to
-	// convert the bytes to a string and return it
-	return string(hash)
+	return argon2idHasher{memory: cfg.Argon2Memory, time: cfg.Argon2Time, threads: cfg.Argon2Threads}
 }
-func ComparePasswords(hashedPwd string, plainPwd []byte) bool {
-	// Since we'll be getting the hashed password from the DB it
-	// will be a string so we'll need to convert it to a byte slice
-	byteHash := []byte(hashedPwd)
-	err := bcrypt.CompareHashAndPassword(byteHash, plainPwd)
-	if err != nil {
-		log.Println(err)
-		return false
+
+func hasherFor(encoded string) (PasswordHasher, error) {
+	cfg := passwordconfig.Get()
+	candidates := []PasswordHasher{
+		argon2idHasher{memory: cfg.Argon2Memory, time: cfg.Argon2Time, threads: cfg.Argon2Threads},
+		bcryptHasher{cost: cfg.BcryptCost},
+	}
+	for _, hasher := range candidates {
+		if hasher.Matches(encoded) {
+			return hasher, nil
+		}
 	}
+	return nil, ErrUnknownPasswordHash
+}
+
+// HashPassword băm mật khẩu theo chính sách hiện tại
+func HashPassword(pwd []byte) (string, error) {
+	return currentHasher().Hash(pwd)
+}
 
-	return true
+// ComparePasswords kiểm tra mật khẩu với hash đã lưu.
+// needsRehash = true khi mật khẩu đúng nhưng hash dùng thuật toán hoặc tham số yếu hơn chính sách hiện tại.
+func ComparePasswords(hashedPwd string, plainPwd []byte) (match bool, needsRehash bool, err error) {
+	hasher, err := hasherFor(hashedPwd)
+	if err != nil {
+		return false, false, err
+	}
+	match, err = hasher.Verify(hashedPwd, plainPwd)
+	if err != nil || !match {
+		return false, false, err
+	}
+	current := currentHasher()
+	return true, !current.Matches(hashedPwd) || current.NeedsRehash(hashedPwd), nil
 }
@@ -0,0 +1,12 @@
+package security
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// HashToken trả về SHA-256 dạng hex của token, dùng làm khóa lưu trong database thay cho token gốc
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
@@ -10,21 +11,69 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 	"thelastking-blogger.com/src/config/db_config"
+	serverconfig "thelastking-blogger.com/src/config/server_config"
 	"thelastking-blogger.com/src/controller/handler/socket_handler" // Thêm import cho socket_handler
+	"thelastking-blogger.com/src/database"
+	"thelastking-blogger.com/src/mailer"
+	"thelastking-blogger.com/src/policy"
+	"thelastking-blogger.com/src/repository/access_revocation_repo"
+	"thelastking-blogger.com/src/repository/jwt_key_repo"
+	"thelastking-blogger.com/src/repository/login_attempt_repo"
+	"thelastking-blogger.com/src/repository/oidc_repo"
+	"thelastking-blogger.com/src/repository/password_reset_repo"
+	"thelastking-blogger.com/src/repository/permission_repo"
 	"thelastking-blogger.com/src/repository/refresh_token_repo"
+	"thelastking-blogger.com/src/repository/upload_repo"
 	"thelastking-blogger.com/src/routes"
+	"thelastking-blogger.com/src/security/jwtkeys"
+	"thelastking-blogger.com/src/service/access_revocation_service"
+	"thelastking-blogger.com/src/service/login_attempt_service"
+	"thelastking-blogger.com/src/service/oidc_service"
+	"thelastking-blogger.com/src/service/password_reset_service"
 	"thelastking-blogger.com/src/service/refresh_token_service"
+	"thelastking-blogger.com/src/service/upload_service"
+	"thelastking-blogger.com/src/storage"
 )
 
 func Server() {
 	// Khởi tạo cơ sở dữ liệu
 	dbConn := db_config.GetInstance().Run()
 
+	// Áp dụng migration và dừng ngay nếu schema không khớp với repository
+	if err := migrateDatabase(dbConn); err != nil {
+		log.Fatalf("Database chưa sẵn sàng: %v", err)
+	}
+
+	// Nạp khóa ký JWT và bật lịch xoay vòng khóa
+	if err := jwtkeys.Init(jwt_key_repo.NewSql(dbConn)); err != nil {
+		log.Fatalf("Không thể khởi tạo khóa JWT: %v", err)
+	}
+
+	// Nạp bảng phân quyền role -> permission
+	if err := policy.Init(permission_repo.NewSql(dbConn)); err != nil {
+		log.Fatalf("Không thể nạp bảng phân quyền: %v", err)
+	}
+
+	// Kết nối nơi lưu file media (đĩa local hoặc S3/MinIO)
+	if err := storage.Init(); err != nil {
+		log.Fatalf("Không thể khởi tạo storage: %v", err)
+	}
+
 	// Khởi tạo job dọn dẹp refresh token
 	refreshRepo := refresh_token_repo.NewSql(dbConn)
 	refreshCtrl := refresh_token_service.NewRefreshTokenController(refreshRepo)
 	refresh_token_service.RunCleanupTokensJob(refreshCtrl)
+	login_attempt_service.RunCleanupLoginAttemptsJob(login_attempt_service.NewLoginAttemptController(login_attempt_repo.NewSql(dbConn)))
+	password_reset_service.RunCleanupPasswordResetsJob(password_reset_service.NewPasswordResetController(password_reset_repo.NewSql(dbConn), mailer.Get()))
+	oidc_service.RunCleanupOidcStatesJob(oidc_service.NewOidcController(oidc_repo.NewSql(dbConn)))
+	upload_service.RunCleanupUploadsJob(upload_service.NewUploadController(upload_repo.NewSql(dbConn)))
+
+	// Nạp danh sách access token bị thu hồi trước khi nhận request
+	if err := access_revocation_service.Init(access_revocation_repo.NewSql(dbConn)); err != nil {
+		log.Fatalf("Không thể nạp danh sách access token bị thu hồi: %v", err)
+	}
 
 	// Khởi tạo WebSocket server
 	socketServer := socket_handler.NewSocketServer(dbConn)
@@ -32,6 +81,10 @@ func Server() {
 
 	// Khởi tạo router Gin
 	r := gin.New()
+	// Chỉ đọc IP client từ header của các proxy được cấu hình, mặc định không tin proxy nào
+	if err := r.SetTrustedProxies(serverconfig.Get().TrustedProxies); err != nil {
+		log.Fatalf("TRUSTED_PROXIES không hợp lệ: %v", err)
+	}
 	r.Use(gin.Logger())
 	r.Use(gin.Recovery())
 
@@ -71,3 +124,26 @@ func Server() {
 	}
 	log.Println("Server đã tắt an toàn")
 }
+
+// migrateDatabase chạy các migration còn thiếu (trừ khi AUTO_MIGRATE=false) rồi kiểm tra schema
+func migrateDatabase(db *gorm.DB) error {
+	ctx := context.Background()
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	if os.Getenv("AUTO_MIGRATE") != "false" {
+		if err := migrator.Up(ctx); err != nil {
+			return err
+		}
+	} else {
+		pending, err := migrator.Pending(ctx)
+		if err != nil {
+			return err
+		}
+		if pending > 0 {
+			return fmt.Errorf("%d pending migration(s), run `migrate up` first", pending)
+		}
+	}
+	return database.VerifySchema(ctx, db)
+}
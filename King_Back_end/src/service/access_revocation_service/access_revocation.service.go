@@ -0,0 +1,269 @@
+package access_revocation_service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	jwtconfig "thelastking-blogger.com/src/config/jwt_config"
+	"thelastking-blogger.com/src/config/logger"
+	"thelastking-blogger.com/src/module"
+	"thelastking-blogger.com/src/utils"
+)
+
+const (
+	ReasonSignOut         = "sign_out"
+	ReasonUserDeleted     = "user_deleted"
+	ReasonRoleChanged     = "role_changed"
+	ReasonPasswordChanged = "password_changed"
+	ReasonMfaCompleted    = "mfa_completed"
+	ReasonSessionRevoked  = "session_revoked"
+)
+
+// reloadInterval là độ trễ tối đa để instance khác thấy một lần thu hồi
+const reloadInterval = 30 * time.Second
+
+type AccessRevocationResponse interface {
+	CreateRevocation(ctx context.Context, data *module.AccessTokenRevocation) error
+	ListActiveRevocations(ctx context.Context, now time.Time) ([]module.AccessTokenRevocation, error)
+	DeleteExpiredRevocations(ctx context.Context, now time.Time) error
+}
+
+type revocationController struct {
+	r   AccessRevocationResponse
+	log logger.Logger
+
+	mu        sync.RWMutex
+	loaded    bool                      // false tới khi nạp được database lần đầu, trong lúc đó mọi token bị từ chối
+	byJTI     map[string]time.Time      // jti -> thời điểm token hết hạn
+	bySession map[string]time.Time      // sid -> thời điểm access token cuối cùng của phiên hết hạn
+	byUser    map[string]userRevocation // user_id -> mốc thu hồi mới nhất của user
+}
+
+// userRevocation: token của user có iat không sau before bị thu hồi, tới expiresAt thì mọi token đó đã hết hạn
+type userRevocation struct {
+	before    time.Time
+	expiresAt time.Time
+}
+
+var (
+	instance *revocationController
+	once     sync.Once
+	initErr  error
+)
+
+// Init nạp danh sách thu hồi trước khi server nhận request.
+// Lỗi phải dừng khởi động, cache chưa nạp được sẽ từ chối mọi token tới lần nạp lại thành công.
+func Init(r AccessRevocationResponse) error {
+	GetRevocationController(r)
+	return initErr
+}
+
+// GetRevocationController trả về controller dùng chung để mọi request đọc cùng một cache
+func GetRevocationController(r AccessRevocationResponse) *revocationController {
+	once.Do(func() {
+		instance = &revocationController{
+			r:         r,
+			log:       logger.GetLogger(),
+			byJTI:     make(map[string]time.Time),
+			bySession: make(map[string]time.Time),
+			byUser:    make(map[string]userRevocation),
+		}
+		if initErr = instance.reload(context.Background()); initErr != nil {
+			instance.log.Errorf("Failed to load access token revocations: %v", initErr)
+		}
+		go instance.run()
+	})
+	return instance
+}
+
+// NewRevokeToken thu hồi đúng một access token
+func (res *revocationController) NewRevokeToken(ctx context.Context, claims *module.Token, reason string) error {
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return nil
+	}
+	id, err := utils.GenerateUUID()
+	if err != nil {
+		return err
+	}
+	jti := claims.ID
+	data := &module.AccessTokenRevocation{
+		RevocationID: id,
+		Jti:          &jti,
+		UserID:       claims.UserID,
+		Reason:       reason,
+		ExpiresAt:    claims.ExpiresAt.Time.UTC(),
+		CreatedAt:    time.Now().UTC(),
+	}
+	if err := res.r.CreateRevocation(ctx, data); err != nil {
+		res.log.Errorf("Revoke access token %s faild: %v", jti, err)
+		return err
+	}
+	res.mu.Lock()
+	keepLater(res.byJTI, jti, data.ExpiresAt)
+	res.mu.Unlock()
+	res.log.Infof("Access token %s of user %s revoked: %s", jti, claims.UserID, reason)
+	return nil
+}
+
+// NewRevokeSessionTokens thu hồi mọi access token đã cấp cho phiên sessionID, dùng khi phiên bị đăng xuất từ xa
+func (res *revocationController) NewRevokeSessionTokens(ctx context.Context, userID, sessionID string, reason string) error {
+	id, err := utils.GenerateUUID()
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	data := &module.AccessTokenRevocation{
+		RevocationID: id,
+		UserID:       userID,
+		SessionID:    &sessionID,
+		Reason:       reason,
+		ExpiresAt:    now.Add(jwtconfig.Get().AccessTTL),
+		CreatedAt:    now,
+	}
+	if err := res.r.CreateRevocation(ctx, data); err != nil {
+		res.log.Errorf("Revoke access tokens of session %s faild: %v", sessionID, err)
+		return err
+	}
+	res.mu.Lock()
+	keepLater(res.bySession, sessionID, data.ExpiresAt)
+	res.mu.Unlock()
+	res.log.Infof("Access tokens of session %s of user %s revoked: %s", sessionID, userID, reason)
+	return nil
+}
+
+// NewRevokeUserTokens thu hồi mọi access token đã cấp cho user tới thời điểm hiện tại
+func (res *revocationController) NewRevokeUserTokens(ctx context.Context, userID string, reason string) error {
+	id, err := utils.GenerateUUID()
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	// iat chỉ có độ chính xác tới giây nên mốc thu hồi cũng được làm tròn xuống giây
+	before := now.Truncate(time.Second)
+	data := &module.AccessTokenRevocation{
+		RevocationID:  id,
+		UserID:        userID,
+		RevokedBefore: &before,
+		Reason:        reason,
+		ExpiresAt:     now.Add(jwtconfig.Get().AccessTTL),
+		CreatedAt:     now,
+	}
+	if err := res.r.CreateRevocation(ctx, data); err != nil {
+		res.log.Errorf("Revoke access tokens of user %s faild: %v", userID, err)
+		return err
+	}
+	res.mu.Lock()
+	res.revokeUserBefore(userID, before, data.ExpiresAt)
+	res.mu.Unlock()
+	res.log.Infof("All access tokens of user %s revoked: %s", userID, reason)
+	return nil
+}
+
+// IsRevoked kiểm tra token trong cache, không truy vấn database.
+// Token bị thu hồi khi revoked_before >= iat, cả hai cùng tính theo giây nên token cấp trong cùng giây
+// với lần thu hồi cũng bị từ chối.
+func (res *revocationController) IsRevoked(claims *module.Token) bool {
+	res.mu.RLock()
+	defer res.mu.RUnlock()
+	if !res.loaded {
+		return true
+	}
+	if claims.ID != "" {
+		if _, ok := res.byJTI[claims.ID]; ok {
+			return true
+		}
+	}
+	if claims.SessionID != "" {
+		if _, ok := res.bySession[claims.SessionID]; ok {
+			return true
+		}
+	}
+	if revoked, ok := res.byUser[claims.UserID]; ok {
+		if claims.IssuedAt == nil || !revoked.before.Before(claims.IssuedAt.Time) {
+			return true
+		}
+	}
+	return false
+}
+
+// reload gộp các dòng còn hiệu lực trong database vào cache thay vì thay cả cache:
+// thu hồi được ghi vào cache sau khi câu SELECT chạy không có trong rows nhưng vẫn phải giữ lại.
+func (res *revocationController) reload(ctx context.Context) error {
+	now := time.Now().UTC()
+	rows, err := res.r.ListActiveRevocations(ctx, now)
+	if err != nil {
+		return err
+	}
+	res.mu.Lock()
+	defer res.mu.Unlock()
+	for _, row := range rows {
+		if row.Jti != nil {
+			keepLater(res.byJTI, *row.Jti, row.ExpiresAt)
+		}
+		if row.SessionID != nil {
+			keepLater(res.bySession, *row.SessionID, row.ExpiresAt)
+		}
+		if row.RevokedBefore != nil {
+			// Dòng ghi trước khi mốc được làm tròn vẫn có phần lẻ của giây
+			res.revokeUserBefore(row.UserID, row.RevokedBefore.Truncate(time.Second), row.ExpiresAt)
+		}
+	}
+	// Bỏ các mục mà mọi token liên quan đã hết hạn
+	for jti, expiresAt := range res.byJTI {
+		if !expiresAt.After(now) {
+			delete(res.byJTI, jti)
+		}
+	}
+	for sessionID, expiresAt := range res.bySession {
+		if !expiresAt.After(now) {
+			delete(res.bySession, sessionID)
+		}
+	}
+	for userID, revoked := range res.byUser {
+		if !revoked.expiresAt.After(now) {
+			delete(res.byUser, userID)
+		}
+	}
+	res.loaded = true
+	return nil
+}
+
+// revokeUserBefore giữ mốc thu hồi và thời điểm hết tác dụng muộn nhất của user, gọi khi đang giữ mu
+func (res *revocationController) revokeUserBefore(userID string, before, expiresAt time.Time) {
+	current := res.byUser[userID]
+	if before.After(current.before) {
+		current.before = before
+	}
+	if expiresAt.After(current.expiresAt) {
+		current.expiresAt = expiresAt
+	}
+	res.byUser[userID] = current
+}
+
+// keepLater ghi thời điểm hết hạn của key, giữ thời điểm muộn hơn nếu key đã có trong cache
+func keepLater(entries map[string]time.Time, key string, expiresAt time.Time) {
+	if current, ok := entries[key]; !ok || expiresAt.After(current) {
+		entries[key] = expiresAt
+	}
+}
+
+// run nạp lại cache định kỳ để thấy thu hồi từ instance khác và dọn các dòng đã hết tác dụng
+func (res *revocationController) run() {
+	reload := time.NewTicker(reloadInterval)
+	cleanup := time.NewTicker(time.Hour)
+	defer reload.Stop()
+	defer cleanup.Stop()
+	for {
+		select {
+		case <-reload.C:
+			if err := res.reload(context.Background()); err != nil {
+				res.log.Errorf("Failed to reload access token revocations: %v", err)
+			}
+		case <-cleanup.C:
+			if err := res.r.DeleteExpiredRevocations(context.Background(), time.Now().UTC()); err != nil {
+				res.log.Errorf("Failed to cleanup access token revocations: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package access_revocation_service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"thelastking-blogger.com/src/config/logger"
+	"thelastking-blogger.com/src/module"
+)
+
+// memoryStore giữ các dòng thu hồi trong bộ nhớ, beforeList chạy sau khi snapshot đã được đọc
+type memoryStore struct {
+	rows       []module.AccessTokenRevocation
+	beforeList func()
+}
+
+func (s *memoryStore) CreateRevocation(ctx context.Context, data *module.AccessTokenRevocation) error {
+	s.rows = append(s.rows, *data)
+	return nil
+}
+
+func (s *memoryStore) ListActiveRevocations(ctx context.Context, now time.Time) ([]module.AccessTokenRevocation, error) {
+	var rows []module.AccessTokenRevocation
+	for _, row := range s.rows {
+		if row.ExpiresAt.After(now) {
+			rows = append(rows, row)
+		}
+	}
+	if s.beforeList != nil {
+		s.beforeList()
+	}
+	return rows, nil
+}
+
+func (s *memoryStore) DeleteExpiredRevocations(ctx context.Context, now time.Time) error {
+	return nil
+}
+
+func newTestController(store *memoryStore) *revocationController {
+	return &revocationController{
+		r:         store,
+		log:       logger.GetLogger(),
+		byJTI:     make(map[string]time.Time),
+		bySession: make(map[string]time.Time),
+		byUser:    make(map[string]userRevocation),
+	}
+}
+
+func token(userID, jti string, iat *time.Time) *module.Token {
+	claims := &module.Token{UserID: userID}
+	claims.ID = jti
+	if iat != nil {
+		claims.IssuedAt = jwt.NewNumericDate(*iat)
+	}
+	return claims
+}
+
+func withSession(claims *module.Token, sessionID string) *module.Token {
+	claims.SessionID = sessionID
+	return claims
+}
+
+func TestIsRevoked(t *testing.T) {
+	revokedAt := time.Date(2024, 6, 1, 8, 30, 15, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		value := revokedAt.Add(d)
+		return &value
+	}
+	expires := revokedAt.Add(time.Hour)
+
+	res := newTestController(&memoryStore{})
+	res.loaded = true
+	res.byJTI["jti-revoked"] = expires
+	res.bySession["sid-revoked"] = expires
+	res.revokeUserBefore("user-1", revokedAt, expires)
+
+	tests := []struct {
+		name   string
+		claims *module.Token
+		want   bool
+	}{
+		{"revoked jti", token("user-2", "jti-revoked", at(0)), true},
+		{"other jti", token("user-2", "jti-2", at(0)), false},
+		{"revoked session", withSession(token("user-2", "jti-2", at(0)), "sid-revoked"), true},
+		{"other session", withSession(token("user-2", "jti-2", at(0)), "sid-2"), false},
+		{"issued before the cutoff", token("user-1", "jti-3", at(-time.Minute)), true},
+		{"issued in the cutoff second", token("user-1", "jti-3", at(0)), true},
+		{"issued later in the cutoff second", token("user-1", "jti-3", at(900*time.Millisecond)), true},
+		{"issued the next second", token("user-1", "jti-3", at(time.Second)), false},
+		{"missing iat", token("user-1", "jti-3", nil), true},
+		{"other user", token("user-2", "jti-3", at(-time.Minute)), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := res.IsRevoked(tt.claims); got != tt.want {
+				t.Errorf("IsRevoked() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsRevokedBeforeFirstLoad(t *testing.T) {
+	res := newTestController(&memoryStore{})
+	now := time.Now()
+	if !res.IsRevoked(token("user-1", "jti-1", &now)) {
+		t.Error("token accepted before revocations were loaded")
+	}
+	if err := res.reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if res.IsRevoked(token("user-1", "jti-1", &now)) {
+		t.Error("token rejected after an empty load")
+	}
+}
+
+func TestRevokeUserTokensUsesWholeSeconds(t *testing.T) {
+	res := newTestController(&memoryStore{})
+	res.loaded = true
+	issued := time.Now()
+	if err := res.NewRevokeUserTokens(context.Background(), "user-1", ReasonPasswordChanged); err != nil {
+		t.Fatal(err)
+	}
+	// Token cấp ngay sau lần thu hồi trong cùng giây cũng bị từ chối
+	if !res.IsRevoked(token("user-1", "", &issued)) {
+		t.Error("token issued in the same second as the revocation is accepted")
+	}
+	next := res.byUser["user-1"].before.Add(time.Second)
+	if res.IsRevoked(token("user-1", "", &next)) {
+		t.Error("token issued the second after the revocation is rejected")
+	}
+}
+
+func TestReloadKeepsConcurrentRevocations(t *testing.T) {
+	store := &memoryStore{}
+	res := newTestController(store)
+	ctx := context.Background()
+	expires := time.Now().Add(time.Hour)
+	if err := res.reload(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Thu hồi xảy ra sau khi reload đã đọc snapshot nhưng trước khi cache được cập nhật
+	store.beforeList = func() {
+		store.beforeList = nil
+		claims := token("user-1", "jti-late", nil)
+		claims.ExpiresAt = jwt.NewNumericDate(expires)
+		if err := res.NewRevokeToken(ctx, claims, ReasonSignOut); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := res.reload(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !res.IsRevoked(token("user-1", "jti-late", nil)) {
+		t.Error("reload dropped a revocation written while it was running")
+	}
+}
+
+func TestReloadMergesSnapshot(t *testing.T) {
+	now := time.Now().UTC()
+	jti := "jti-1"
+	sessionID := "sid-1"
+	earlier := now.Add(-time.Minute).Truncate(time.Second)
+	later := now.Add(time.Minute)
+	store := &memoryStore{rows: []module.AccessTokenRevocation{
+		{Jti: &jti, UserID: "user-1", ExpiresAt: now.Add(time.Hour)},
+		{SessionID: &sessionID, UserID: "user-1", ExpiresAt: now.Add(time.Hour)},
+		// Dòng cũ có mốc có phần lẻ của giây
+		{UserID: "user-2", RevokedBefore: &later, ExpiresAt: now.Add(time.Hour)},
+	}}
+	res := newTestController(store)
+	res.byJTI["jti-expired"] = now.Add(-time.Second)
+	res.byJTI[jti] = now.Add(2 * time.Hour)
+	res.revokeUserBefore("user-2", earlier, now.Add(30*time.Minute))
+	res.revokeUserBefore("user-3", earlier, now.Add(-time.Second))
+
+	if err := res.reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := res.byJTI["jti-expired"]; ok {
+		t.Error("expired jti kept after reload")
+	}
+	if got := res.byJTI[jti]; !got.Equal(now.Add(2 * time.Hour)) {
+		t.Errorf("jti expiry = %v, want the later local expiry", got)
+	}
+	if _, ok := res.bySession[sessionID]; !ok {
+		t.Error("session from the snapshot missing")
+	}
+	user2 := res.byUser["user-2"]
+	if !user2.before.Equal(later.Truncate(time.Second)) || !user2.expiresAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("user-2 revocation = %+v, want the later cutoff and expiry", user2)
+	}
+	if _, ok := res.byUser["user-3"]; ok {
+		t.Error("expired user revocation kept after reload")
+	}
+}
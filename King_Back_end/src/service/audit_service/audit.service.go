@@ -0,0 +1,48 @@
+package audit_service
+
+import (
+	"context"
+
+	"thelastking-blogger.com/src/config/logger"
+	"thelastking-blogger.com/src/controller/common"
+	"thelastking-blogger.com/src/module"
+)
+
+type AuditResponse interface {
+	ListAuditLogs(ctx context.Context, filter *module.AuditFilter, pagging *common.Paggings) ([]module.AuditLog, error)
+}
+
+type auditController struct {
+	r   AuditResponse
+	log logger.Logger
+}
+
+func NewAuditController(r AuditResponse) *auditController {
+	return &auditController{
+		r:   r,
+		log: logger.GetLogger(),
+	}
+}
+
+// NewListAuditLogs lọc nhật ký theo đối tượng, người thực hiện, hành động và khoảng thời gian [from, to)
+func (res *auditController) NewListAuditLogs(ctx context.Context, filter *module.AuditFilter, pagging *common.Paggings) ([]module.AuditLog, error) {
+	switch filter.EntityType {
+	case "", module.AuditEntityLocation, module.AuditEntityFactory, module.AuditEntityProduct, module.AuditEntityProductMedia, module.AuditEntityUser:
+	default:
+		return nil, module.ErrInvalidAuditFilter
+	}
+	switch filter.Action {
+	case "", module.AuditActionCreate, module.AuditActionUpdate, module.AuditActionDelete, module.AuditActionRestore, module.AuditActionPurge:
+	default:
+		return nil, module.ErrInvalidAuditFilter
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return nil, module.ErrInvalidAuditFilter
+	}
+	data, err := res.r.ListAuditLogs(ctx, filter, pagging)
+	if err != nil {
+		res.log.Errorf("Failed to get audit log: %v", err)
+		return nil, err
+	}
+	return data, nil
+}
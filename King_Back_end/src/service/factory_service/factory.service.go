@@ -10,12 +10,15 @@ import (
 )
 
 type FactoryResponse interface {
-	CreateFactory(ctx context.Context, data *req_users.FactoriesInput) error
+	CreateFactory(ctx context.Context, data *req_users.FactoriesInput, scope *module.UserScope) error
 	GetFactory(ctx context.Context, id map[string]any) (*module.Factories, error)
 	UpdateFactory(ctx context.Context, id map[string]any, upd *module.Factories) error
 	DeleteFactory(ctx context.Context, id map[string]any) error
-	GetFactoryList(ctx context.Context, pagging *common.Paggings, morekeys ...string) ([]module.Factories, error)
-	GetFactoryListByLocal(ctx context.Context, locationName map[string]any) ([]module.Factories, error)
+	GetFactoryList(ctx context.Context, pagging *common.Paggings, query *common.ListQuery, scope *module.UserScope, morekeys ...string) ([]module.Factories, error)
+	GetFactoryListByLocal(ctx context.Context, locationName map[string]any, pagging *common.Paggings) ([]module.Factories, error)
+	ListTrashFactory(ctx context.Context, pagging *common.Paggings, scope *module.UserScope) ([]module.Factories, error)
+	RestoreFactory(ctx context.Context, id map[string]any) error
+	PurgeFactory(ctx context.Context, id map[string]any) error
 }
 
 type factoryController struct {
@@ -30,8 +33,9 @@ func NewFactoryController(f FactoryResponse) *factoryController {
 	}
 }
 
-func (res *factoryController) NewCreateFactory(ctx context.Context, data *req_users.FactoriesInput) error {
-	if err := res.f.CreateFactory(ctx, data); err != nil {
+// NewCreateFactory: scope khác nil thì khu vực theo name_local phải thuộc phạm vi đó
+func (res *factoryController) NewCreateFactory(ctx context.Context, data *req_users.FactoriesInput, scope *module.UserScope) error {
+	if err := res.f.CreateFactory(ctx, data, scope); err != nil {
 		res.log.Errorf("Failed to create facotory: %v", err)
 		return err
 	}
@@ -67,8 +71,9 @@ func (res *factoryController) NewDeleteFactory(ctx context.Context, id string) e
 	return nil
 }
 
-func (res *factoryController) NewGetFactoryList(ctx context.Context, pagging *common.Paggings) ([]module.Factories, error) {
-	listData, err := res.f.GetFactoryList(ctx, pagging)
+// NewGetFactoryList: scope khác nil thì chỉ lấy nhà máy thuộc phạm vi đó
+func (res *factoryController) NewGetFactoryList(ctx context.Context, pagging *common.Paggings, query *common.ListQuery, scope *module.UserScope) ([]module.Factories, error) {
+	listData, err := res.f.GetFactoryList(ctx, pagging, query, scope)
 	if err != nil {
 		res.log.Errorf("Failed to get facotory list: %v", err)
 		return nil, err
@@ -77,8 +82,8 @@ func (res *factoryController) NewGetFactoryList(ctx context.Context, pagging *co
 	return listData, nil
 }
 
-func (res *factoryController) NewGetFactoryListByLocal(ctx context.Context, locationName string) ([]module.Factories, error) {
-	dataFactoryList, err := res.f.GetFactoryListByLocal(ctx, map[string]any{"l.name_local": locationName})
+func (res *factoryController) NewGetFactoryListByLocal(ctx context.Context, locationName string, pagging *common.Paggings) ([]module.Factories, error) {
+	dataFactoryList, err := res.f.GetFactoryListByLocal(ctx, map[string]any{"l.name_local": locationName}, pagging)
 	if err != nil {
 		res.log.Errorf("Failed to get factory list by location: %v", err)
 		return nil, err
@@ -89,3 +94,33 @@ func (res *factoryController) NewGetFactoryListByLocal(ctx context.Context, loca
 	res.log.Infof("Retrieved factory by location list: %d factories found", len(dataFactoryList))
 	return dataFactoryList, nil
 }
+
+// NewListTrashFactory liệt kê nhà máy trong thùng rác
+func (res *factoryController) NewListTrashFactory(ctx context.Context, pagging *common.Paggings, scope *module.UserScope) ([]module.Factories, error) {
+	listData, err := res.f.ListTrashFactory(ctx, pagging, scope)
+	if err != nil {
+		res.log.Errorf("Failed to get factory trash: %v", err)
+		return nil, err
+	}
+	return listData, nil
+}
+
+// NewRestoreFactory khôi phục nhà máy cùng sản phẩm bị xóa theo nó, trả về module.ErrParentDeleted nếu khu vực còn bị xóa
+func (res *factoryController) NewRestoreFactory(ctx context.Context, id string) error {
+	if err := res.f.RestoreFactory(ctx, map[string]any{"factory_id": id}); err != nil {
+		res.log.Errorf("Failed to restore factory %s: %v", id, err)
+		return err
+	}
+	res.log.Infof("Factory %s restored", id)
+	return nil
+}
+
+// NewPurgeFactory xóa vĩnh viễn, chỉ áp dụng cho nhà máy đã nằm trong thùng rác
+func (res *factoryController) NewPurgeFactory(ctx context.Context, id string) error {
+	if err := res.f.PurgeFactory(ctx, map[string]any{"factory_id": id}); err != nil {
+		res.log.Errorf("Failed to purge factory %s: %v", id, err)
+		return err
+	}
+	res.log.Infof("Factory %s purged", id)
+	return nil
+}
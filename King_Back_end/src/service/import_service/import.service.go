@@ -0,0 +1,376 @@
+package import_service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-playground/validator/v10"
+	importconfig "thelastking-blogger.com/src/config/import_config"
+	"thelastking-blogger.com/src/config/logger"
+	"thelastking-blogger.com/src/importer"
+	"thelastking-blogger.com/src/module"
+	"thelastking-blogger.com/src/module/req_users"
+	"thelastking-blogger.com/src/storage"
+	"thelastking-blogger.com/src/utils"
+)
+
+type ImportResponse interface {
+	FindLocations(ctx context.Context, names []string) ([]module.Locations, error)
+	FindFactories(ctx context.Context, names []string) ([]module.Factories, error)
+	FindProducts(ctx context.Context, factoryIDs []string) ([]module.Products, error)
+	Import(ctx context.Context, locations []module.Locations, factories []req_users.FactoriesInput, products []req_users.ProductInput, created func()) error
+}
+
+type importController struct {
+	r     ImportResponse
+	media storage.Storage
+	log   logger.Logger
+}
+
+// NewImportController: media dùng để kiểm tra key ảnh/video trong file đã có trong storage
+func NewImportController(r ImportResponse, media storage.Storage) *importController {
+	return &importController{
+		r:     r,
+		media: media,
+		log:   logger.GetLogger(),
+	}
+}
+
+// Độ dài tối đa của tên theo cột VARCHAR trong DB, validate tag của Locations/FactoriesInput chỉ có required
+const (
+	maxNameLocal   = 100
+	maxNameFactory = 50
+)
+
+// validate dùng tên cột (tag json) trong thông báo lỗi để khớp với dòng tiêu đề của file
+var validate = func() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		return strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+	})
+	return v
+}()
+
+// plan là kết quả kiểm tra file: báo cáo từng dòng và các bản ghi sẽ được tạo khi commit
+type plan struct {
+	report    *module.ImportReport
+	locations []module.Locations
+	factories []req_users.FactoriesInput
+	products  []req_users.ProductInput
+	invalid   bool
+}
+
+// add ghi kết quả của một dòng, dòng có lỗi luôn là invalid
+func (p *plan) add(result module.ImportRowResult, action string) string {
+	if len(result.Errors) > 0 {
+		action = module.ImportActionInvalid
+		p.invalid = true
+	}
+	result.Action = action
+	counts := p.report.Counts[result.Entity]
+	switch action {
+	case module.ImportActionCreate:
+		counts.Create++
+	case module.ImportActionExists:
+		counts.Exists++
+	default:
+		counts.Invalid++
+	}
+	p.report.Counts[result.Entity] = counts
+	p.report.Rows = append(p.report.Rows, result)
+	return action
+}
+
+// NewImport kiểm tra mọi dòng của file, tên cha (name_local, name_factory) được tìm trong chính file rồi tới DB.
+// Dòng đã có trong DB được bỏ qua nên nhập lại cùng một file không tạo bản ghi trùng.
+// Có dòng lỗi thì trả về báo cáo kèm ErrImportInvalid và không ghi gì; dry run chỉ trả về báo cáo;
+// còn lại tạo mọi dòng create trong một transaction. progress nhận tiến độ của từng giai đoạn.
+func (res *importController) NewImport(ctx context.Context, importID string, file *importer.File, dryRun bool, progress func(module.ImportProgress)) (*module.ImportReport, error) {
+	total := file.Len()
+	if total == 0 {
+		return nil, module.ErrImportEmpty
+	}
+	if limit := importconfig.Get().MaxRows; total > limit {
+		return nil, fmt.Errorf("%w: %d rows, at most %d", module.ErrImportTooLarge, total, limit)
+	}
+
+	done := 0
+	p, err := res.check(ctx, file, func() {
+		done++
+		progress(module.ImportProgress{ImportID: importID, Phase: module.ImportPhaseValidate, Done: done, Total: total})
+	})
+	if err != nil {
+		res.log.Errorf("Import %s: check failed: %v", importID, err)
+		return nil, err
+	}
+	p.report.ImportID = importID
+	p.report.DryRun = dryRun
+	if p.invalid {
+		res.log.Infof("Import %s rejected: %+v", importID, p.report.Counts)
+		return p.report, module.ErrImportInvalid
+	}
+	if dryRun {
+		res.log.Infof("Import %s dry run: %+v", importID, p.report.Counts)
+		return p.report, nil
+	}
+
+	creates := len(p.locations) + len(p.factories) + len(p.products)
+	done = 0
+	progress(module.ImportProgress{ImportID: importID, Phase: module.ImportPhaseCommit, Done: done, Total: creates})
+	if err := res.r.Import(ctx, p.locations, p.factories, p.products, func() {
+		done++
+		progress(module.ImportProgress{ImportID: importID, Phase: module.ImportPhaseCommit, Done: done, Total: creates})
+	}); err != nil {
+		res.log.Errorf("Import %s failed, rolled back: %v", importID, err)
+		return nil, err
+	}
+	p.report.Committed = true
+	res.log.Infof("Import %s committed: %+v", importID, p.report.Counts)
+	return p.report, nil
+}
+
+// check kiểm tra lần lượt khu vực, nhà máy, sản phẩm; step được gọi sau mỗi dòng
+func (res *importController) check(ctx context.Context, file *importer.File, step func()) (*plan, error) {
+	localNames, factoryNames := make(map[string]bool), make(map[string]bool)
+	for _, row := range file.Locations {
+		addName(localNames, row.Input.NameLocal)
+	}
+	for _, row := range file.Factories {
+		addName(localNames, row.Input.NameLocal)
+		addName(factoryNames, row.Input.NameFactory)
+	}
+	for _, row := range file.Products {
+		addName(factoryNames, row.Input.NameFactory)
+	}
+
+	dbLocations, err := res.r.FindLocations(ctx, slices.Collect(maps.Keys(localNames)))
+	if err != nil {
+		return nil, err
+	}
+	// Tên không phải duy nhất trong DB: dùng tên trùng làm cha thì CreateFactory/CreateProduct trả ErrAmbiguousName
+	// nên dòng đó bị báo lỗi ngay ở bước kiểm tra
+	locationIDs := make(map[string]string, len(dbLocations))
+	ambiguousLocations := make(map[string]bool)
+	for _, l := range dbLocations {
+		if _, ok := locationIDs[*l.NameLocal]; ok {
+			ambiguousLocations[*l.NameLocal] = true
+			continue
+		}
+		locationIDs[*l.NameLocal] = l.Location_ID
+	}
+	dbFactories, err := res.r.FindFactories(ctx, slices.Collect(maps.Keys(factoryNames)))
+	if err != nil {
+		return nil, err
+	}
+	factories := make(map[string][]module.Factories, len(dbFactories))
+	var factoryIDs []string
+	for _, f := range dbFactories {
+		factories[*f.NameFactory] = append(factories[*f.NameFactory], f)
+		factoryIDs = append(factoryIDs, f.Factory_ID)
+	}
+	dbProducts, err := res.r.FindProducts(ctx, factoryIDs)
+	if err != nil {
+		return nil, err
+	}
+	products := make(map[[2]string]bool, len(dbProducts))
+	for _, product := range dbProducts {
+		if product.Title != nil {
+			products[[2]string{product.Factory_ID, *product.Title}] = true
+		}
+	}
+
+	p := &plan{report: &module.ImportReport{
+		Total: file.Len(),
+		Counts: map[string]module.ImportCounts{
+			module.ImportEntityLocation: {},
+			module.ImportEntityFactory:  {},
+			module.ImportEntityProduct:  {},
+		},
+		Rows: make([]module.ImportRowResult, 0, file.Len()),
+	}}
+	now := time.Now().UTC()
+
+	// Khu vực dùng được làm cha: đã có trong DB hoặc là dòng hợp lệ của file
+	knownLocations := make(map[string]bool)
+	for name := range locationIDs {
+		knownLocations[name] = true
+	}
+	seen := make(map[string]int)
+	for _, row := range file.Locations {
+		name := deref(row.Input.NameLocal)
+		result := module.ImportRowResult{Entity: module.ImportEntityLocation, Row: row.Line, Name: name}
+		result.Errors = append(slices.Clone(row.Errors), fieldErrors(row.Input, row.Errors)...)
+		if utf8.RuneCountInString(name) > maxNameLocal {
+			result.Errors = append(result.Errors, fmt.Sprintf("name_local: max=%d", maxNameLocal))
+		}
+		if first, ok := seen[name]; ok && name != "" {
+			result.Errors = append(result.Errors, fmt.Sprintf("name_local: duplicate of row %d", first))
+		} else {
+			seen[name] = row.Line
+		}
+		action := module.ImportActionCreate
+		if _, ok := locationIDs[name]; ok {
+			action = module.ImportActionExists
+		}
+		if p.add(result, action) == module.ImportActionCreate {
+			id, err := utils.GenerateUUID()
+			if err != nil {
+				return nil, err
+			}
+			p.locations = append(p.locations, module.Locations{
+				Location_ID: id,
+				NameLocal:   row.Input.NameLocal,
+				CreatedAt:   &now,
+				UpdatedAt:   &now,
+			})
+			knownLocations[name] = true
+		}
+		step()
+	}
+
+	knownFactories := make(map[string]bool)
+	for name := range factories {
+		knownFactories[name] = true
+	}
+	seen = make(map[string]int)
+	for _, row := range file.Factories {
+		name, local := deref(row.Input.NameFactory), deref(row.Input.NameLocal)
+		result := module.ImportRowResult{Entity: module.ImportEntityFactory, Row: row.Line, Name: name}
+		result.Errors = append(slices.Clone(row.Errors), fieldErrors(row.Input, row.Errors)...)
+		if utf8.RuneCountInString(name) > maxNameFactory {
+			result.Errors = append(result.Errors, fmt.Sprintf("name_factory: max=%d", maxNameFactory))
+		}
+		if first, ok := seen[name]; ok && name != "" {
+			result.Errors = append(result.Errors, fmt.Sprintf("name_factory: duplicate of row %d", first))
+		} else {
+			seen[name] = row.Line
+		}
+		switch {
+		case ambiguousLocations[local]:
+			result.Errors = append(result.Errors, fmt.Sprintf("name_local: %s: more than one location is named %q in the database", module.ErrAmbiguousName, local))
+		case local != "" && !knownLocations[local]:
+			result.Errors = append(result.Errors, fmt.Sprintf("name_local: location %q is neither in the database nor a valid row of the file", local))
+		}
+		action := module.ImportActionCreate
+		if existing := factories[name]; len(existing) > 0 {
+			id, ok := locationIDs[local]
+			inLocation := slices.ContainsFunc(existing, func(f module.Factories) bool { return f.Location_ID == id })
+			if local != "" && !ambiguousLocations[local] && (!ok || !inLocation) {
+				result.Errors = append(result.Errors, fmt.Sprintf("name_factory: factory %q already exists in another location", name))
+			}
+			action = module.ImportActionExists
+		}
+		if p.add(result, action) == module.ImportActionCreate {
+			p.factories = append(p.factories, row.Input)
+			knownFactories[name] = true
+		}
+		step()
+	}
+
+	media := make(map[string]error)
+	seenProducts := make(map[[2]string]int)
+	for _, row := range file.Products {
+		input := row.Input
+		title, factory := deref(input.Title), deref(input.NameFactory)
+		result := module.ImportRowResult{Entity: module.ImportEntityProduct, Row: row.Line, Name: title}
+		result.Errors = slices.Clone(row.Errors)
+		// Giống NewCreateProduct: sản phẩm mới luôn là bản nháp, trạng thái đổi qua transition
+		if input.Status != nil && *input.Status != module.ProductStatusDraft {
+			result.Errors = append(result.Errors, "status: "+module.ErrStatusTransitionRequired.Error())
+		}
+		status := module.ProductStatusDraft
+		input.Status = &status
+		result.Errors = append(result.Errors, fieldErrors(input, row.Errors)...)
+		if input.Image != nil {
+			if err := res.stored(ctx, *input.Image, media); err != nil {
+				result.Errors = append(result.Errors, "image: "+err.Error())
+			}
+		}
+		if input.Video != nil {
+			if err := res.stored(ctx, *input.Video, media); err != nil {
+				result.Errors = append(result.Errors, "video: "+err.Error())
+			}
+		}
+		switch {
+		case len(factories[factory]) > 1:
+			result.Errors = append(result.Errors, fmt.Sprintf("name_factory: %s: more than one factory is named %q in the database", module.ErrAmbiguousName, factory))
+		case factory != "" && !knownFactories[factory]:
+			result.Errors = append(result.Errors, fmt.Sprintf("name_factory: factory %q is neither in the database nor a valid row of the file", factory))
+		}
+		key := [2]string{factory, title}
+		if first, ok := seenProducts[key]; ok && title != "" {
+			result.Errors = append(result.Errors, fmt.Sprintf("title: duplicate of row %d in the same factory", first))
+		} else {
+			seenProducts[key] = row.Line
+		}
+		action := module.ImportActionCreate
+		if existing := factories[factory]; len(existing) == 1 && products[[2]string{existing[0].Factory_ID, title}] {
+			action = module.ImportActionExists
+		}
+		if p.add(result, action) == module.ImportActionCreate {
+			p.products = append(p.products, input)
+		}
+		step()
+	}
+	return p, nil
+}
+
+// stored: URL tuyệt đối được giữ nguyên như dữ liệu cũ (storage.URLFor), key phải đã có trong storage
+func (res *importController) stored(ctx context.Context, key string, cache map[string]error) error {
+	if strings.HasPrefix(key, "http://") || strings.HasPrefix(key, "https://") {
+		return nil
+	}
+	if err, ok := cache[key]; ok {
+		return err
+	}
+	exists, err := res.media.Exists(ctx, key)
+	if err == nil && !exists {
+		err = storage.ErrObjectNotFound
+	}
+	cache[key] = err
+	return err
+}
+
+// fieldErrors đổi lỗi của validator thành "cột: tag=param", bỏ qua cột đã có lỗi đọc ô trong reported
+func fieldErrors(input any, reported []string) []string {
+	err := validate.Struct(input)
+	if err == nil {
+		return nil
+	}
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return []string{err.Error()}
+	}
+	var out []string
+	for _, fe := range verrs {
+		if slices.ContainsFunc(reported, func(e string) bool { return strings.HasPrefix(e, fe.Field()+":") }) {
+			continue
+		}
+		msg := fe.Field() + ": " + fe.Tag()
+		if fe.Param() != "" {
+			msg += "=" + fe.Param()
+		}
+		out = append(out, msg)
+	}
+	return out
+}
+
+func addName(names map[string]bool, name *string) {
+	if name != nil {
+		names[*name] = true
+	}
+}
+
+func deref(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
@@ -13,7 +13,10 @@ type LocationRespone interface {
 	GetLocation(ctx context.Context, id map[string]any) (*module.Locations, error)
 	UpdateLocation(ctx context.Context, id map[string]any, upd *module.Locations) error
 	DeleteLocation(ctx context.Context, id map[string]any) error
-	ListLocation(ctx context.Context, pagging *common.Paggings, morekeys ...string) ([]module.Locations, error)
+	ListLocation(ctx context.Context, pagging *common.Paggings, query *common.ListQuery, scope *module.UserScope, morekeys ...string) ([]module.Locations, error)
+	ListTrashLocation(ctx context.Context, pagging *common.Paggings, scope *module.UserScope) ([]module.Locations, error)
+	RestoreLocation(ctx context.Context, id map[string]any) error
+	PurgeLocation(ctx context.Context, id map[string]any) error
 }
 
 type locationController struct {
@@ -65,8 +68,9 @@ func (res *locationController) NewUpdateLocation(ctx context.Context, id string,
 	return nil
 }
 
-func (res *locationController) NewListLocation(ctx context.Context, pagging *common.Paggings) ([]module.Locations, error) {
-	listData, err := res.l.ListLocation(ctx, pagging)
+// NewListLocation: scope khác nil thì chỉ lấy khu vực thuộc phạm vi đó
+func (res *locationController) NewListLocation(ctx context.Context, pagging *common.Paggings, query *common.ListQuery, scope *module.UserScope) ([]module.Locations, error) {
+	listData, err := res.l.ListLocation(ctx, pagging, query, scope)
 	if err != nil {
 		res.log.Errorf("Failed to get location list: %v", err)
 		return nil, err
@@ -74,3 +78,33 @@ func (res *locationController) NewListLocation(ctx context.Context, pagging *com
 	res.log.Infof("Retrieved location list: %d location found", len(listData))
 	return listData, nil
 }
+
+// NewListTrashLocation liệt kê khu vực trong thùng rác
+func (res *locationController) NewListTrashLocation(ctx context.Context, pagging *common.Paggings, scope *module.UserScope) ([]module.Locations, error) {
+	listData, err := res.l.ListTrashLocation(ctx, pagging, scope)
+	if err != nil {
+		res.log.Errorf("Failed to get location trash: %v", err)
+		return nil, err
+	}
+	return listData, nil
+}
+
+// NewRestoreLocation khôi phục khu vực cùng nhà máy và sản phẩm bị xóa theo nó
+func (res *locationController) NewRestoreLocation(ctx context.Context, id string) error {
+	if err := res.l.RestoreLocation(ctx, map[string]any{"location_id": id}); err != nil {
+		res.log.Errorf("Failed to restore location %s: %v", id, err)
+		return err
+	}
+	res.log.Infof("Location %s restored", id)
+	return nil
+}
+
+// NewPurgeLocation xóa vĩnh viễn, chỉ áp dụng cho khu vực đã nằm trong thùng rác
+func (res *locationController) NewPurgeLocation(ctx context.Context, id string) error {
+	if err := res.l.PurgeLocation(ctx, map[string]any{"location_id": id}); err != nil {
+		res.log.Errorf("Failed to purge location %s: %v", id, err)
+		return err
+	}
+	res.log.Infof("Location %s purged", id)
+	return nil
+}
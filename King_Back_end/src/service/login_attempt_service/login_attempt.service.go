@@ -0,0 +1,142 @@
+package login_attempt_service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"thelastking-blogger.com/src/config/logger"
+	loginconfig "thelastking-blogger.com/src/config/login_config"
+	"thelastking-blogger.com/src/module"
+)
+
+type LoginAttemptResponse interface {
+	GetActiveLockouts(ctx context.Context, keys []string, now time.Time) ([]module.LoginAttempt, error)
+	RecordLoginFailure(ctx context.Context, key, kind string, userID *string, now, windowStart time.Time) (*module.LoginAttempt, error)
+	LockLoginAttempt(ctx context.Context, key string, until time.Time) error
+	ResetLoginAttempts(ctx context.Context, key string) (bool, error)
+	ListLockedLoginAttempts(ctx context.Context, now time.Time) ([]module.LoginAttempt, error)
+	DeleteStaleLoginAttempts(ctx context.Context, windowStart, now time.Time) error
+}
+
+type loginAttemptController struct {
+	r   LoginAttemptResponse
+	cfg *loginconfig.Config
+	log logger.Logger
+}
+
+func NewLoginAttemptController(r LoginAttemptResponse) *loginAttemptController {
+	return &loginAttemptController{
+		r:   r,
+		cfg: loginconfig.Get(),
+		log: logger.GetLogger(),
+	}
+}
+
+// AccountKey chuẩn hóa email để "A@x.com" và "a@x.com" dùng chung bộ đếm
+func AccountKey(account string) string {
+	return module.LoginAttemptAccount + ":" + strings.ToLower(strings.TrimSpace(account))
+}
+
+func IPKey(ip string) string {
+	return module.LoginAttemptIP + ":" + ip
+}
+
+// NewLockedUntil trả về thời điểm hết khóa xa nhất của account và IP, nil nếu không bị khóa
+func (res *loginAttemptController) NewLockedUntil(ctx context.Context, account, ip string) (*time.Time, error) {
+	rows, err := res.r.GetActiveLockouts(ctx, []string{AccountKey(account), IPKey(ip)}, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	var until *time.Time
+	for _, row := range rows {
+		if until == nil || row.LockedUntil.After(*until) {
+			until = row.LockedUntil
+		}
+	}
+	return until, nil
+}
+
+// NewRecordFailure tăng bộ đếm của account và IP, trả về các bộ đếm vừa bị khóa
+func (res *loginAttemptController) NewRecordFailure(ctx context.Context, account, ip string, userID *string) ([]module.LoginAttempt, error) {
+	now := time.Now().UTC()
+	windowStart := now.Add(-res.cfg.Window)
+	targets := []struct {
+		key       string
+		kind      string
+		userID    *string
+		threshold int
+	}{
+		{AccountKey(account), module.LoginAttemptAccount, userID, res.cfg.AccountMaxAttempts},
+		{IPKey(ip), module.LoginAttemptIP, nil, res.cfg.IPMaxAttempts},
+	}
+
+	var locked []module.LoginAttempt
+	for _, target := range targets {
+		attempt, err := res.r.RecordLoginFailure(ctx, target.key, target.kind, target.userID, now, windowStart)
+		if err != nil {
+			res.log.Errorf("Record login failure for %s faild: %v", target.key, err)
+			return nil, err
+		}
+		lockFor := res.cfg.LockoutFor(attempt.Failures, target.threshold)
+		if lockFor == 0 {
+			continue
+		}
+		until := now.Add(lockFor)
+		if err := res.r.LockLoginAttempt(ctx, target.key, until); err != nil {
+			return nil, err
+		}
+		attempt.LockedUntil = &until
+		locked = append(locked, *attempt)
+		res.log.Warnf("Sign-in locked for %s until %s after %d failures", target.key, until.Format(time.RFC3339), attempt.Failures)
+	}
+	return locked, nil
+}
+
+// NewRecordSuccess xóa bộ đếm của account; bộ đếm IP giữ nguyên để một tài khoản hợp lệ không xóa được dấu vết dò mật khẩu tài khoản khác
+func (res *loginAttemptController) NewRecordSuccess(ctx context.Context, account string) error {
+	if _, err := res.r.ResetLoginAttempts(ctx, AccountKey(account)); err != nil {
+		res.log.Errorf("Reset login attempts for %s faild: %v", account, err)
+		return err
+	}
+	return nil
+}
+
+func (res *loginAttemptController) NewListLocked(ctx context.Context) ([]module.LoginAttempt, error) {
+	data, err := res.r.ListLockedLoginAttempts(ctx, time.Now().UTC())
+	if err != nil {
+		res.log.Errorf("List locked sign-ins faild: %v", err)
+		return nil, err
+	}
+	return data, nil
+}
+
+// NewUnlock mở khóa một account hoặc IP, trả về false nếu key không có bộ đếm nào
+func (res *loginAttemptController) NewUnlock(ctx context.Context, key string) (bool, error) {
+	found, err := res.r.ResetLoginAttempts(ctx, key)
+	if err != nil {
+		res.log.Errorf("Unlock %s faild: %v", key, err)
+		return false, err
+	}
+	if found {
+		res.log.Infof("Sign-in unlocked for %s", key)
+	}
+	return found, nil
+}
+
+func (res *loginAttemptController) NewDeleteStale(ctx context.Context) error {
+	now := time.Now().UTC()
+	return res.r.DeleteStaleLoginAttempts(ctx, now.Add(-res.cfg.Window), now)
+}
+
+func RunCleanupLoginAttemptsJob(controller *loginAttemptController) {
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := controller.NewDeleteStale(context.Background()); err != nil {
+				controller.log.Errorf("Failed to cleanup login attempts: %v", err)
+			}
+		}
+	}()
+}
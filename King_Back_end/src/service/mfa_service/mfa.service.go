@@ -0,0 +1,236 @@
+package mfa_service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"thelastking-blogger.com/src/config/logger"
+	mfaconfig "thelastking-blogger.com/src/config/mfa_config"
+	"thelastking-blogger.com/src/module"
+	"thelastking-blogger.com/src/security"
+	"thelastking-blogger.com/src/security/totp"
+)
+
+// recoveryCodeCount mã khôi phục được cấp mỗi lần bật 2FA hoặc tạo lại
+const recoveryCodeCount = 10
+
+type MfaResponse interface {
+	GetUserMfa(ctx context.Context, userID string) (*module.UserMfa, error)
+	SavePendingMfa(ctx context.Context, data *module.UserMfa) error
+	EnableMfa(ctx context.Context, userID string, step int64, codes []module.MfaRecoveryCode) error
+	UseTotpStep(ctx context.Context, userID string, step int64) (bool, error)
+	UseRecoveryCode(ctx context.Context, userID string, codeHash string) (bool, error)
+	ReplaceRecoveryCodes(ctx context.Context, userID string, codes []module.MfaRecoveryCode) error
+	CountRecoveryCodes(ctx context.Context, userID string) (int64, error)
+	DeleteUserMfa(ctx context.Context, userID string) error
+}
+
+type mfaController struct {
+	r   MfaResponse
+	cfg *mfaconfig.Config
+	log logger.Logger
+}
+
+func NewMfaController(r MfaResponse) *mfaController {
+	return &mfaController{
+		r:   r,
+		cfg: mfaconfig.Get(),
+		log: logger.GetLogger(),
+	}
+}
+
+// NewIsEnabled cho biết user đã bật 2FA hay chưa
+func (res *mfaController) NewIsEnabled(ctx context.Context, userID string) (bool, error) {
+	data, err := res.r.GetUserMfa(ctx, userID)
+	if err != nil {
+		if errors.Is(err, module.ErrMfaNotEnabled) {
+			return false, nil
+		}
+		return false, err
+	}
+	return data.Enabled, nil
+}
+
+// NewStatus trả về trạng thái 2FA để hiển thị cho user
+func (res *mfaController) NewStatus(ctx context.Context, user *module.Users) (map[string]any, error) {
+	enabled, err := res.NewIsEnabled(ctx, user.UserID)
+	if err != nil {
+		return nil, err
+	}
+	status := map[string]any{
+		"enabled":  enabled,
+		"required": res.cfg.IsRequired(user.Role),
+	}
+	if enabled {
+		remaining, err := res.r.CountRecoveryCodes(ctx, user.UserID)
+		if err != nil {
+			return nil, err
+		}
+		status["recovery_codes_remaining"] = remaining
+	}
+	return status, nil
+}
+
+// NewStartEnrollment tạo secret mới và trả về secret cùng otpauth URI để quét mã QR
+func (res *mfaController) NewStartEnrollment(ctx context.Context, user *module.Users) (string, string, error) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", "", err
+	}
+	data := &module.UserMfa{
+		UserID:    user.UserID,
+		Secret:    secret,
+		Enabled:   false,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := res.r.SavePendingMfa(ctx, data); err != nil {
+		res.log.Errorf("Start MFA enrollment for user %s faild: %v", user.UserID, err)
+		return "", "", err
+	}
+	res.log.Infof("MFA enrollment started for user %s", user.UserID)
+	return secret, totp.ProvisioningURI(res.cfg.Issuer, user.Account, secret), nil
+}
+
+// NewConfirmEnrollment bật 2FA khi user nhập đúng mã đầu tiên, trả về mã khôi phục dạng gốc (chỉ hiển thị một lần)
+func (res *mfaController) NewConfirmEnrollment(ctx context.Context, userID string, code string) ([]string, error) {
+	data, err := res.r.GetUserMfa(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if data.Enabled {
+		return nil, module.ErrMfaAlreadyEnabled
+	}
+	step, ok := totp.Validate(data.Secret, code, time.Now())
+	if !ok {
+		return nil, module.ErrMfaInvalidCode
+	}
+	codes, rows, err := generateRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := res.r.EnableMfa(ctx, userID, step, rows); err != nil {
+		res.log.Errorf("Enable MFA for user %s faild: %v", userID, err)
+		return nil, err
+	}
+	res.log.Infof("MFA enabled for user %s", userID)
+	return codes, nil
+}
+
+// NewVerifyCode kiểm tra mã TOTP, mỗi mã chỉ dùng được một lần
+func (res *mfaController) NewVerifyCode(ctx context.Context, userID string, code string) error {
+	data, err := res.r.GetUserMfa(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !data.Enabled {
+		return module.ErrMfaNotEnabled
+	}
+	step, ok := totp.Validate(data.Secret, code, time.Now())
+	if !ok {
+		res.log.Warnf("Invalid MFA code for user %s", userID)
+		return module.ErrMfaInvalidCode
+	}
+	fresh, err := res.r.UseTotpStep(ctx, userID, step)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		res.log.Warnf("Replayed MFA code for user %s", userID)
+		return module.ErrMfaInvalidCode
+	}
+	return nil
+}
+
+// NewVerifyRecoveryCode dùng một mã khôi phục thay cho mã TOTP
+func (res *mfaController) NewVerifyRecoveryCode(ctx context.Context, userID string, code string) error {
+	enabled, err := res.NewIsEnabled(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return module.ErrMfaNotEnabled
+	}
+	ok, err := res.r.UseRecoveryCode(ctx, userID, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		res.log.Warnf("Invalid MFA recovery code for user %s", userID)
+		return module.ErrMfaInvalidCode
+	}
+	res.log.Infof("MFA recovery code used for user %s", userID)
+	return nil
+}
+
+// NewVerify chấp nhận mã TOTP hoặc mã khôi phục
+func (res *mfaController) NewVerify(ctx context.Context, userID string, code string, recoveryCode string) error {
+	if recoveryCode != "" {
+		return res.NewVerifyRecoveryCode(ctx, userID, recoveryCode)
+	}
+	return res.NewVerifyCode(ctx, userID, code)
+}
+
+// NewRegenerateRecoveryCodes hủy các mã cũ và cấp bộ mã mới
+func (res *mfaController) NewRegenerateRecoveryCodes(ctx context.Context, userID string) ([]string, error) {
+	codes, rows, err := generateRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := res.r.ReplaceRecoveryCodes(ctx, userID, rows); err != nil {
+		res.log.Errorf("Regenerate MFA recovery codes for user %s faild: %v", userID, err)
+		return nil, err
+	}
+	res.log.Infof("MFA recovery codes regenerated for user %s", userID)
+	return codes, nil
+}
+
+// NewDisable tắt 2FA; role bắt buộc 2FA thì không được tắt
+func (res *mfaController) NewDisable(ctx context.Context, user *module.Users) error {
+	if res.cfg.IsRequired(user.Role) {
+		return module.ErrMfaRequired
+	}
+	if err := res.r.DeleteUserMfa(ctx, user.UserID); err != nil {
+		res.log.Errorf("Disable MFA for user %s faild: %v", user.UserID, err)
+		return err
+	}
+	res.log.Infof("MFA disabled for user %s", user.UserID)
+	return nil
+}
+
+// NewIsRequired cho biết role có bắt buộc 2FA
+func (res *mfaController) NewIsRequired(role *string) bool {
+	return res.cfg.IsRequired(role)
+}
+
+// generateRecoveryCodes tạo mã dạng xxxx-xxxx-xxxx-xxxx (80 bit) và bản hash để lưu
+func generateRecoveryCodes(userID string) ([]string, []module.MfaRecoveryCode, error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	now := time.Now().UTC()
+	codes := make([]string, 0, recoveryCodeCount)
+	rows := make([]module.MfaRecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw := make([]byte, 10)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+		plain := strings.ToLower(encoding.EncodeToString(raw))
+		code := plain[0:4] + "-" + plain[4:8] + "-" + plain[8:12] + "-" + plain[12:16]
+		codes = append(codes, code)
+		rows = append(rows, module.MfaRecoveryCode{
+			CodeHash:  hashRecoveryCode(code),
+			UserID:    userID,
+			CreatedAt: now,
+		})
+	}
+	return codes, rows, nil
+}
+
+// hashRecoveryCode bỏ dấu gạch và khoảng trắng để user nhập kiểu nào cũng khớp
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return security.HashToken(normalized)
+}
@@ -0,0 +1,306 @@
+package oidc_service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+	"thelastking-blogger.com/src/config/logger"
+	oidcconfig "thelastking-blogger.com/src/config/oidc_config"
+	"thelastking-blogger.com/src/module"
+	"thelastking-blogger.com/src/security"
+	"thelastking-blogger.com/src/security/oidc"
+	"thelastking-blogger.com/src/utils"
+)
+
+// unusablePassword không phải hash hợp lệ nên tài khoản tạo qua SSO không đăng nhập được bằng mật khẩu
+const unusablePassword = "!oidc"
+
+type OidcResponse interface {
+	CreateLoginState(ctx context.Context, data *module.OidcLoginState) error
+	ConsumeLoginState(ctx context.Context, stateHash string, now time.Time) (*module.OidcLoginState, error)
+	DeleteExpiredLoginStates(ctx context.Context, now time.Time) error
+	FindUserByIdentity(ctx context.Context, issuer, subject string) (*module.Users, error)
+	FindUserByAccount(ctx context.Context, account string) (*module.Users, error)
+	CreateUserWithIdentity(ctx context.Context, user *module.Users, identity *module.UserIdentity) error
+	CreateIdentity(ctx context.Context, identity *module.UserIdentity) error
+	TouchIdentity(ctx context.Context, issuer, subject, email string, now time.Time) error
+	UpdateUserRole(ctx context.Context, userID, role string, now time.Time) error
+}
+
+// LoginResult là kết quả đăng nhập SSO, handler dùng để thu hồi phiên khi role đổi và phát sự kiện
+type LoginResult struct {
+	User        *module.Users
+	RoleChanged bool
+	Provisioned bool
+}
+
+type oidcController struct {
+	r   OidcResponse
+	cfg *oidcconfig.Config
+	log logger.Logger
+}
+
+var (
+	providerOnce sync.Once
+	provider     *oidc.Provider
+)
+
+// getProvider dùng chung một Provider để cache discovery và JWKS giữa các request
+func getProvider(cfg *oidcconfig.Config) *oidc.Provider {
+	providerOnce.Do(func() {
+		provider = oidc.NewProvider(oidc.Config{
+			Issuer:       cfg.Issuer,
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       cfg.Scopes,
+		})
+	})
+	return provider
+}
+
+func NewOidcController(r OidcResponse) *oidcController {
+	return &oidcController{
+		r:   r,
+		cfg: oidcconfig.Get(),
+		log: logger.GetLogger(),
+	}
+}
+
+// NewStartLogin tạo state, nonce và code_verifier, trả về URL đăng nhập của IdP cùng state gốc để đặt vào cookie
+func (res *oidcController) NewStartLogin(ctx context.Context) (string, string, error) {
+	if !res.cfg.Enabled() {
+		return "", "", module.ErrOidcDisabled
+	}
+	state, err := oidc.RandomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := oidc.RandomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := oidc.RandomString(48)
+	if err != nil {
+		return "", "", err
+	}
+	authURL, err := getProvider(res.cfg).AuthCodeURL(ctx, state, nonce, oidc.CodeChallenge(verifier))
+	if err != nil {
+		res.log.Errorf("Build oidc authorization url faild: %v", err)
+		return "", "", err
+	}
+	now := time.Now().UTC()
+	if err := res.r.CreateLoginState(ctx, &module.OidcLoginState{
+		StateHash:    security.HashToken(state),
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		ExpiresAt:    now.Add(res.cfg.StateTTL),
+		CreatedAt:    now,
+	}); err != nil {
+		return "", "", err
+	}
+	return authURL, state, nil
+}
+
+// NewCompleteLogin đổi code lấy id_token, kiểm tra email rồi tìm user theo (issuer, sub),
+// sau đó theo email, cuối cùng tạo tài khoản mới nếu bật OIDC_AUTO_PROVISION
+func (res *oidcController) NewCompleteLogin(ctx context.Context, code, state string) (*LoginResult, error) {
+	if !res.cfg.Enabled() {
+		return nil, module.ErrOidcDisabled
+	}
+	now := time.Now().UTC()
+	loginState, err := res.r.ConsumeLoginState(ctx, security.HashToken(state), now)
+	if err != nil {
+		return nil, err
+	}
+	p := getProvider(res.cfg)
+	rawIDToken, err := p.Exchange(ctx, code, loginState.CodeVerifier)
+	if err != nil {
+		res.log.Warnf("Oidc code exchange faild: %v", err)
+		return nil, err
+	}
+	claims, err := p.VerifyIDToken(ctx, rawIDToken, loginState.Nonce)
+	if err != nil {
+		res.log.Warnf("Oidc id token rejected: %v", err)
+		return nil, err
+	}
+
+	subject, _ := claims["sub"].(string)
+	email := strings.ToLower(strings.TrimSpace(stringClaim(claims, res.cfg.EmailClaim)))
+	if !res.emailAllowed(email, claims) {
+		res.log.Warnf("Oidc login rejected for subject %s: email %q not allowed", subject, email)
+		return nil, module.ErrOidcEmailNotAllowed
+	}
+	// Nhóm bên IdP là nguồn gốc của role, không có nhóm nào được map thì về role mặc định
+	role, mapped := res.cfg.MapRole(groupsClaim(claims, res.cfg.GroupsClaim))
+	if !mapped {
+		role = res.cfg.DefaultRole
+	}
+
+	result := &LoginResult{}
+	user, err := res.r.FindUserByIdentity(ctx, res.cfg.Issuer, subject)
+	switch {
+	case err == nil:
+		if user.DeletedAt != nil {
+			break
+		}
+		if err := res.r.TouchIdentity(ctx, res.cfg.Issuer, subject, email, now); err != nil {
+			return nil, err
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		user, err = res.r.FindUserByAccount(ctx, email)
+		switch {
+		case err == nil:
+			if user.DeletedAt != nil {
+				break
+			}
+			// Tài khoản có sẵn cùng email: liên kết lần đầu, các lần sau tìm theo (issuer, sub)
+			if err := res.r.CreateIdentity(ctx, res.identity(user.UserID, subject, email, now)); err != nil {
+				return nil, err
+			}
+			res.log.Infof("Linked oidc subject %s to user %s", subject, user.UserID)
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if !res.cfg.AutoProvision {
+				return nil, module.ErrOidcNotProvisioned
+			}
+			user, err = res.provision(ctx, subject, email, claims, role, now)
+			if err != nil {
+				return nil, err
+			}
+			result.Provisioned = true
+		default:
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	// Tài khoản đã bị xóa không được tự tạo lại hay đăng nhập qua SSO, chỉ khôi phục từ thùng rác
+	if user.DeletedAt != nil {
+		res.log.Warnf("Oidc login rejected for subject %s: user %s is deleted", subject, user.UserID)
+		return nil, module.ErrOidcAccountDeleted
+	}
+
+	// Role được đồng bộ theo nhóm ở mỗi lần đăng nhập, tài khoản ROOT không bao giờ bị đổi role qua SSO
+	if !result.Provisioned && user.Role != nil && *user.Role != module.ROOT.String() && *user.Role != role {
+		if err := res.r.UpdateUserRole(ctx, user.UserID, role, now); err != nil {
+			return nil, err
+		}
+		res.log.Infof("Oidc groups changed role of user %s from %s to %s", user.UserID, *user.Role, role)
+		user.Role = &role
+		user.UpdatedAt = &now
+		result.RoleChanged = true
+	}
+	result.User = user
+	return result, nil
+}
+
+func (res *oidcController) provision(ctx context.Context, subject, email string, claims jwt.MapClaims, role string, now time.Time) (*module.Users, error) {
+	userID, err := utils.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+	fullName := strings.TrimSpace(stringClaim(claims, res.cfg.NameClaim))
+	if fullName == "" {
+		fullName, _, _ = strings.Cut(email, "@")
+	}
+	user := &module.Users{
+		UserID:        userID,
+		FullName:      fullName,
+		Account:       email,
+		Password_user: unusablePassword,
+		Tag:           res.cfg.DefaultTag,
+		Role:          &role,
+		CreatedAt:     &now,
+		UpdatedAt:     &now,
+	}
+	if err := res.r.CreateUserWithIdentity(ctx, user, res.identity(userID, subject, email, now)); err != nil {
+		return nil, err
+	}
+	res.log.Infof("Provisioned user %s from oidc subject %s with role %s", userID, subject, role)
+	return user, nil
+}
+
+func (res *oidcController) identity(userID, subject, email string, now time.Time) *module.UserIdentity {
+	return &module.UserIdentity{
+		Issuer:      res.cfg.Issuer,
+		Subject:     subject,
+		UserID:      userID,
+		Email:       email,
+		CreatedAt:   now,
+		LastLoginAt: &now,
+	}
+}
+
+// emailAllowed: email phải thuộc OIDC_ALLOWED_DOMAIN và đã được IdP xác minh (nếu bật OIDC_REQUIRE_EMAIL_VERIFIED)
+func (res *oidcController) emailAllowed(email string, claims jwt.MapClaims) bool {
+	_, domain, found := strings.Cut(email, "@")
+	if !found || domain == "" {
+		return false
+	}
+	if res.cfg.AllowedDomain != "" && domain != res.cfg.AllowedDomain {
+		return false
+	}
+	if res.cfg.RequireEmailVerified {
+		switch verified := claims["email_verified"].(type) {
+		case bool:
+			return verified
+		case string:
+			return verified == "true"
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func (res *oidcController) NewDeleteExpiredLoginStates(ctx context.Context) error {
+	if err := res.r.DeleteExpiredLoginStates(ctx, time.Now().UTC()); err != nil {
+		return err
+	}
+	return nil
+}
+
+func RunCleanupOidcStatesJob(controller *oidcController) {
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := controller.NewDeleteExpiredLoginStates(context.Background()); err != nil {
+				controller.log.Errorf("Failed to cleanup oidc login states: %v", err)
+			}
+		}
+	}()
+}
+
+func stringClaim(claims jwt.MapClaims, name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
+
+// groupsClaim nhận cả mảng lẫn chuỗi cách nhau bởi dấu phẩy vì mỗi IdP trả về một kiểu
+func groupsClaim(claims jwt.MapClaims, name string) []string {
+	switch value := claims[name].(type) {
+	case []any:
+		groups := make([]string, 0, len(value))
+		for _, item := range value {
+			if group, ok := item.(string); ok {
+				groups = append(groups, group)
+			}
+		}
+		return groups
+	case string:
+		groups := strings.Split(value, ",")
+		for i := range groups {
+			groups[i] = strings.TrimSpace(groups[i])
+		}
+		return groups
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,119 @@
+package password_reset_service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"thelastking-blogger.com/src/config/logger"
+	mailconfig "thelastking-blogger.com/src/config/mail_config"
+	"thelastking-blogger.com/src/mailer"
+	"thelastking-blogger.com/src/module"
+	"thelastking-blogger.com/src/security"
+)
+
+type PasswordResetResponse interface {
+	CreatePasswordReset(ctx context.Context, data *module.PasswordReset) error
+	ConsumePasswordReset(ctx context.Context, tokenHash string, hashedPassword string) (string, error)
+	DeleteExpiredPasswordResets(ctx context.Context, now time.Time) error
+}
+
+type passwordResetController struct {
+	r      PasswordResetResponse
+	mailer mailer.Mailer
+	cfg    *mailconfig.Config
+	log    logger.Logger
+}
+
+func NewPasswordResetController(r PasswordResetResponse, m mailer.Mailer) *passwordResetController {
+	return &passwordResetController{
+		r:      r,
+		mailer: m,
+		cfg:    mailconfig.Get(),
+		log:    logger.GetLogger(),
+	}
+}
+
+// NewRequestPasswordReset tạo token ngẫu nhiên, chỉ lưu hash và gửi link chứa token gốc tới email của user
+func (res *passwordResetController) NewRequestPasswordReset(ctx context.Context, user *module.Users) error {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	now := time.Now().UTC()
+	data := &module.PasswordReset{
+		TokenHash: security.HashToken(token),
+		UserID:    user.UserID,
+		ExpiresAt: now.Add(res.cfg.ResetTTL),
+		CreatedAt: now,
+	}
+	if err := res.r.CreatePasswordReset(ctx, data); err != nil {
+		res.log.Errorf("Create password reset for user %s faild: %v", user.UserID, err)
+		return err
+	}
+
+	link, err := url.Parse(res.cfg.ResetURL)
+	if err != nil {
+		return fmt.Errorf("invalid PASSWORD_RESET_URL: %w", err)
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+
+	msg := mailer.Message{
+		To:      user.Account,
+		Subject: "Đặt lại mật khẩu",
+		Body: fmt.Sprintf("Xin chào %s,\n\nMở link sau để đặt mật khẩu mới (hết hạn sau %s):\n%s\n\nNếu bạn không yêu cầu đặt lại mật khẩu, hãy bỏ qua email này.",
+			user.FullName, res.cfg.ResetTTL, link.String()),
+	}
+	if err := res.mailer.Send(ctx, msg); err != nil {
+		res.log.Errorf("Send password reset mail to user %s faild: %v", user.UserID, err)
+		return err
+	}
+	res.log.Infof("Password reset requested for user %s", user.UserID)
+	return nil
+}
+
+// NewConfirmPasswordReset đổi mật khẩu bằng token và trả về user_id để thu hồi phiên đăng nhập
+func (res *passwordResetController) NewConfirmPasswordReset(ctx context.Context, token string, newPassword string) (string, error) {
+	hashedPassword, err := security.HashPassword([]byte(newPassword))
+	if err != nil {
+		return "", err
+	}
+	userID, err := res.r.ConsumePasswordReset(ctx, security.HashToken(token), hashedPassword)
+	if err != nil {
+		if errors.Is(err, module.ErrPasswordResetInvalid) {
+			res.log.Warnf("Invalid password reset token used")
+		} else {
+			res.log.Errorf("Confirm password reset faild: %v", err)
+		}
+		return "", err
+	}
+	res.log.Infof("Password reset for user %s", userID)
+	return userID, nil
+}
+
+func (res *passwordResetController) NewDeleteExpiredPasswordResets(ctx context.Context) error {
+	if err := res.r.DeleteExpiredPasswordResets(ctx, time.Now().UTC()); err != nil {
+		return err
+	}
+	return nil
+}
+
+func RunCleanupPasswordResetsJob(controller *passwordResetController) {
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := controller.NewDeleteExpiredPasswordResets(context.Background()); err != nil {
+				controller.log.Errorf("Failed to cleanup password resets: %v", err)
+			}
+		}
+	}()
+}
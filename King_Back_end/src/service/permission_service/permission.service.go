@@ -0,0 +1,100 @@
+package permission_service
+
+import (
+	"context"
+	"time"
+
+	"thelastking-blogger.com/src/config/logger"
+	"thelastking-blogger.com/src/module"
+	"thelastking-blogger.com/src/policy"
+)
+
+type PermissionResponse interface {
+	ListPermissions(ctx context.Context) ([]module.Permission, error)
+	ListRolePermissions(ctx context.Context) ([]module.RolePermission, error)
+	GrantPermission(ctx context.Context, data *module.RolePermission) error
+	RevokePermission(ctx context.Context, role, permissionKey string) error
+}
+
+type permissionController struct {
+	r   PermissionResponse
+	log logger.Logger
+}
+
+func NewPermissionController(r PermissionResponse) *permissionController {
+	return &permissionController{
+		r:   r,
+		log: logger.GetLogger(),
+	}
+}
+
+// NewListMatrix trả về danh sách quyền và quyền của từng role (ROOT mặc định có tất cả)
+func (res *permissionController) NewListMatrix(ctx context.Context) (map[string]any, error) {
+	permissions, err := res.r.ListPermissions(ctx)
+	if err != nil {
+		res.log.Errorf("List permissions faild: %v", err)
+		return nil, err
+	}
+	rows, err := res.r.ListRolePermissions(ctx)
+	if err != nil {
+		res.log.Errorf("List role permissions faild: %v", err)
+		return nil, err
+	}
+
+	roles := make(map[string][]string)
+	for _, role := range policy.Roles() {
+		roles[role] = []string{}
+	}
+	for _, permission := range permissions {
+		roles[module.ROOT.String()] = append(roles[module.ROOT.String()], permission.PermissionKey)
+	}
+	for _, row := range rows {
+		if row.Role != module.ROOT.String() {
+			roles[row.Role] = append(roles[row.Role], row.PermissionKey)
+		}
+	}
+	return map[string]any{
+		"permissions": permissions,
+		"roles":       roles,
+	}, nil
+}
+
+func (res *permissionController) NewGrantPermission(ctx context.Context, role, permissionKey string) error {
+	parsed, err := parseEditableRole(role)
+	if err != nil {
+		return err
+	}
+	data := &module.RolePermission{
+		Role:          parsed,
+		PermissionKey: permissionKey,
+		CreatedAt:     time.Now().UTC(),
+	}
+	if err := res.r.GrantPermission(ctx, data); err != nil {
+		res.log.Errorf("Grant %s to %s faild: %v", permissionKey, parsed, err)
+		return err
+	}
+	res.log.Infof("Granted %s to %s", permissionKey, parsed)
+	return policy.Reload(ctx)
+}
+
+func (res *permissionController) NewRevokePermission(ctx context.Context, role, permissionKey string) error {
+	parsed, err := parseEditableRole(role)
+	if err != nil {
+		return err
+	}
+	if err := res.r.RevokePermission(ctx, parsed, permissionKey); err != nil {
+		res.log.Errorf("Revoke %s from %s faild: %v", permissionKey, parsed, err)
+		return err
+	}
+	res.log.Infof("Revoked %s from %s", permissionKey, parsed)
+	return policy.Reload(ctx)
+}
+
+// parseEditableRole: ROOT luôn có mọi quyền nên không sửa được
+func parseEditableRole(role string) (string, error) {
+	parsed, ok := module.ParseRoles(role)
+	if !ok || parsed == module.ROOT {
+		return "", module.ErrInvalidRole
+	}
+	return parsed.String(), nil
+}
@@ -2,21 +2,41 @@ package product_service
 
 import (
 	"context"
+	"fmt"
+	"html"
+	"strings"
 
+	"thelastking-blogger.com/src/audit"
 	"thelastking-blogger.com/src/config/logger"
 	"thelastking-blogger.com/src/controller/common"
 	"thelastking-blogger.com/src/module"
 	"thelastking-blogger.com/src/module/req_users"
+	"thelastking-blogger.com/src/policy"
 )
 
 type ProductResponse interface {
-	CreateProduct(ctx context.Context, data *req_users.ProductInput) error //sai
+	CreateProduct(ctx context.Context, data *req_users.ProductInput, scope *module.UserScope) error //sai
 	GetProduct(ctx context.Context, idProduct map[string]any) (*module.Products, error)
-	UpdateProduct(ctx context.Context, idProduct map[string]any, upd *req_users.ProductInput) error
+	UpdateProduct(ctx context.Context, idProduct map[string]any, upd *req_users.ProductInput, scope *module.UserScope) error
 	DeleteProduct(ctx context.Context, idProduct map[string]any) error
-	GetProductsList(ctx context.Context, pagging *common.Paggings, morekeys ...string) ([]module.Products, error)
-	GetProductsByFactories(ctx context.Context, factoryName map[string]any) ([]module.Products, error)
-	GetProductsByLocation(ctx context.Context, locationName map[string]any) ([]module.Products, error)
+	GetProductsList(ctx context.Context, pagging *common.Paggings, query *common.ListQuery, scope *module.UserScope, publishedOnly bool) ([]module.Products, error)
+	SearchProducts(ctx context.Context, q string, pagging *common.Paggings, scope *module.UserScope, publishedOnly bool) ([]module.ProductSearchResult, error)
+	GetProductsByFactories(ctx context.Context, factoryName map[string]any, pagging *common.Paggings) ([]module.Products, error)
+	GetProductsByLocation(ctx context.Context, locationName map[string]any, pagging *common.Paggings) ([]module.Products, error)
+	ListTrashProduct(ctx context.Context, pagging *common.Paggings, scope *module.UserScope) ([]module.Products, error)
+	RestoreProduct(ctx context.Context, idProduct map[string]any) error
+	PurgeProduct(ctx context.Context, idProduct map[string]any) error
+	ListProductRevisions(ctx context.Context, idProduct map[string]any, pagging *common.Paggings) ([]module.ProductRevision, error)
+	GetProductRevision(ctx context.Context, productID string, revision int) (*module.ProductRevision, error)
+	RevertProduct(ctx context.Context, idProduct map[string]any, revision int, scope *module.UserScope) (*module.Products, error)
+	ListProductMedia(ctx context.Context, productID string) ([]module.ProductMedia, error)
+	AddProductMedia(ctx context.Context, idProduct map[string]any, media *module.ProductMedia) error
+	UpdateProductMedia(ctx context.Context, idProduct map[string]any, mediaID string, upd *req_users.RequestUpdateProductMedia) error
+	ReorderProductMedia(ctx context.Context, idProduct map[string]any, mediaIDs []string) error
+	SetPrimaryProductMedia(ctx context.Context, idProduct map[string]any, mediaID string) error
+	DeleteProductMedia(ctx context.Context, idProduct map[string]any, mediaID string) error
+	TransitionProductStatus(ctx context.Context, idProduct map[string]any, to string, reason *string, check func(from string) error) (*module.ProductStatusHistory, error)
+	ListProductStatusHistory(ctx context.Context, idProduct map[string]any, pagging *common.Paggings) ([]module.ProductStatusHistory, error)
 }
 
 type productController struct {
@@ -31,26 +51,43 @@ func NewProductController(p ProductResponse) *productController {
 	}
 }
 
-func (res *productController) NewCreateProduct(ctx context.Context, data *req_users.ProductInput) error {
-	if err := res.p.CreateProduct(ctx, data); err != nil {
+// NewCreateProduct: sản phẩm mới luôn bắt đầu ở bản nháp, các trạng thái khác phải đi qua NewTransitionProduct.
+// scope khác nil thì nhà máy theo name_factory phải thuộc phạm vi đó
+func (res *productController) NewCreateProduct(ctx context.Context, data *req_users.ProductInput, scope *module.UserScope) error {
+	status := module.ProductStatusDraft
+	if data.Status != nil && *data.Status != "" && *data.Status != status {
+		return module.ErrStatusTransitionRequired
+	}
+	data.Status = &status
+	if err := res.p.CreateProduct(ctx, data, scope); err != nil {
 		res.log.Errorf("Failed to create product: %v", err)
 		return err
 	}
 	res.log.Infof("Product created successfully: %+v", data)
 	return nil
 }
-func (res *productController) NewGetProduct(ctx context.Context, idProduct string) (*module.Products, error) {
-	data, err := res.p.GetProduct(ctx, map[string]any{"product_id": idProduct})
+
+// NewGetProduct: publishedOnly thì sản phẩm chưa xuất bản được coi như không tồn tại
+func (res *productController) NewGetProduct(ctx context.Context, idProduct string, publishedOnly bool) (*module.Products, error) {
+	where := map[string]any{"product_id": idProduct}
+	if publishedOnly {
+		where["status"] = module.ProductStatusPublished
+	}
+	data, err := res.p.GetProduct(ctx, where)
 	if err != nil {
 		res.log.Errorf("Failed to get product with ID %s: %v", idProduct, err)
 		return nil, err
 	}
+	if data.Media, err = res.p.ListProductMedia(ctx, data.Product_ID); err != nil {
+		res.log.Errorf("Failed to get media of product %s: %v", idProduct, err)
+		return nil, err
+	}
 	res.log.Infof("Retrieved product: %+v", data)
 	return data, nil
 }
 
-func (res *productController) NewUpdateProduct(ctx context.Context, idProduct string, upd *req_users.ProductInput) error {
-	if err := res.p.UpdateProduct(ctx, map[string]any{"product_id": idProduct}, upd); err != nil {
+func (res *productController) NewUpdateProduct(ctx context.Context, idProduct string, upd *req_users.ProductInput, scope *module.UserScope) error {
+	if err := res.p.UpdateProduct(ctx, map[string]any{"product_id": idProduct}, upd, scope); err != nil {
 		res.log.Errorf("Failed to update product with ID %s: %v", idProduct, err)
 		return err
 	}
@@ -67,8 +104,9 @@ func (res *productController) NewDeleteProduct(ctx context.Context, idProduct st
 	return nil
 }
 
-func (res *productController) NewGetProductsList(ctx context.Context, pagging *common.Paggings) ([]module.Products, error) {
-	listData, err := res.p.GetProductsList(ctx, pagging)
+// NewGetProductsList: scope khác nil thì chỉ lấy sản phẩm thuộc phạm vi đó, publishedOnly thì chỉ lấy sản phẩm đã xuất bản
+func (res *productController) NewGetProductsList(ctx context.Context, pagging *common.Paggings, query *common.ListQuery, scope *module.UserScope, publishedOnly bool) ([]module.Products, error) {
+	listData, err := res.p.GetProductsList(ctx, pagging, query, scope, publishedOnly)
 	if err != nil {
 		res.log.Errorf("Failed to get product list: %v", err)
 		return nil, err
@@ -76,8 +114,27 @@ func (res *productController) NewGetProductsList(ctx context.Context, pagging *c
 	res.log.Infof("Retrieved product list: %d products found", len(listData))
 	return listData, nil
 }
-func (res *productController) NewGetProductsByFactories(ctx context.Context, factoryName string) ([]module.Products, error) {
-	dataProductList, err := res.p.GetProductsByFactories(ctx, map[string]any{"f.name_factory": factoryName})
+
+// NewSearchProducts tìm sản phẩm theo tiêu đề và mô tả, không phân biệt dấu
+func (res *productController) NewSearchProducts(ctx context.Context, q string, pagging *common.Paggings, scope *module.UserScope, publishedOnly bool) ([]module.ProductSearchResult, error) {
+	listData, err := res.p.SearchProducts(ctx, strings.TrimSpace(q), pagging, scope, publishedOnly)
+	if err != nil {
+		res.log.Errorf("Failed to search products %q: %v", q, err)
+		return nil, err
+	}
+	for i := range listData {
+		listData[i].TitleHighlight = highlight(listData[i].TitleHighlight)
+		listData[i].Snippet = highlight(listData[i].Snippet)
+	}
+	return listData, nil
+}
+
+func (res *productController) NewGetProductsByFactories(ctx context.Context, factoryName string, pagging *common.Paggings, publishedOnly bool) ([]module.Products, error) {
+	where := map[string]any{"f.name_factory": factoryName}
+	if publishedOnly {
+		where["p.status"] = module.ProductStatusPublished
+	}
+	dataProductList, err := res.p.GetProductsByFactories(ctx, where, pagging)
 	if err != nil {
 		res.log.Errorf("Failed to get product list by factory: %v", err)
 		return nil, err
@@ -89,8 +146,12 @@ func (res *productController) NewGetProductsByFactories(ctx context.Context, fac
 	return dataProductList, nil
 }
 
-func (res *productController) NewGetProductsByLocation(ctx context.Context, locationName string) ([]module.Products, error) {
-	dataProductList, err := res.p.GetProductsByLocation(ctx, map[string]any{"l.name_local": locationName})
+func (res *productController) NewGetProductsByLocation(ctx context.Context, locationName string, pagging *common.Paggings, publishedOnly bool) ([]module.Products, error) {
+	where := map[string]any{"l.name_local": locationName}
+	if publishedOnly {
+		where["p.status"] = module.ProductStatusPublished
+	}
+	dataProductList, err := res.p.GetProductsByLocation(ctx, where, pagging)
 	if err != nil {
 		res.log.Errorf("Failed to get product list by location: %v", err)
 		return nil, err
@@ -101,3 +162,181 @@ func (res *productController) NewGetProductsByLocation(ctx context.Context, loca
 	res.log.Infof("Retrieved product by location list: %d products found", len(dataProductList))
 	return dataProductList, nil
 }
+
+// NewListTrashProduct liệt kê sản phẩm trong thùng rác
+func (res *productController) NewListTrashProduct(ctx context.Context, pagging *common.Paggings, scope *module.UserScope) ([]module.Products, error) {
+	listData, err := res.p.ListTrashProduct(ctx, pagging, scope)
+	if err != nil {
+		res.log.Errorf("Failed to get product trash: %v", err)
+		return nil, err
+	}
+	return listData, nil
+}
+
+// NewRestoreProduct trả về module.ErrParentDeleted nếu nhà máy chứa sản phẩm còn bị xóa
+func (res *productController) NewRestoreProduct(ctx context.Context, id string) error {
+	if err := res.p.RestoreProduct(ctx, map[string]any{"product_id": id}); err != nil {
+		res.log.Errorf("Failed to restore product %s: %v", id, err)
+		return err
+	}
+	res.log.Infof("Product %s restored", id)
+	return nil
+}
+
+// NewPurgeProduct xóa vĩnh viễn, chỉ áp dụng cho sản phẩm đã nằm trong thùng rác
+func (res *productController) NewPurgeProduct(ctx context.Context, id string) error {
+	if err := res.p.PurgeProduct(ctx, map[string]any{"product_id": id}); err != nil {
+		res.log.Errorf("Failed to purge product %s: %v", id, err)
+		return err
+	}
+	res.log.Infof("Product %s purged", id)
+	return nil
+}
+
+// NewListProductRevisions liệt kê lịch sử phiên bản của sản phẩm
+func (res *productController) NewListProductRevisions(ctx context.Context, idProduct string, pagging *common.Paggings) ([]module.ProductRevision, error) {
+	listData, err := res.p.ListProductRevisions(ctx, map[string]any{"product_id": idProduct}, pagging)
+	if err != nil {
+		res.log.Errorf("Failed to list revisions of product %s: %v", idProduct, err)
+		return nil, err
+	}
+	return listData, nil
+}
+
+// NewDiffProductRevisions so sánh từng trường giữa hai phiên bản bất kỳ của sản phẩm
+func (res *productController) NewDiffProductRevisions(ctx context.Context, idProduct string, from, to int) (*module.ProductRevisionDiff, error) {
+	if from <= 0 || to <= 0 {
+		return nil, module.ErrInvalidRevision
+	}
+	fromRev, err := res.p.GetProductRevision(ctx, idProduct, from)
+	if err != nil {
+		res.log.Errorf("Failed to get revision %d of product %s: %v", from, idProduct, err)
+		return nil, err
+	}
+	toRev, err := res.p.GetProductRevision(ctx, idProduct, to)
+	if err != nil {
+		res.log.Errorf("Failed to get revision %d of product %s: %v", to, idProduct, err)
+		return nil, err
+	}
+	changes, err := audit.Diff(fromRev.ProductSnapshot, toRev.ProductSnapshot)
+	if err != nil {
+		return nil, err
+	}
+	return &module.ProductRevisionDiff{Product_ID: idProduct, From: from, To: to, Changes: changes}, nil
+}
+
+// NewRevertProduct khôi phục nội dung của phiên bản cũ, trả về module.ErrParentDeleted nếu nhà máy của phiên bản đó đã bị xóa
+// và module.ErrOutOfScope nếu nhà máy đó nằm ngoài scope
+func (res *productController) NewRevertProduct(ctx context.Context, idProduct string, revision int, scope *module.UserScope) (*module.Products, error) {
+	if revision <= 0 {
+		return nil, module.ErrInvalidRevision
+	}
+	data, err := res.p.RevertProduct(ctx, map[string]any{"product_id": idProduct}, revision, scope)
+	if err != nil {
+		res.log.Errorf("Failed to revert product %s to revision %d: %v", idProduct, revision, err)
+		return nil, err
+	}
+	res.log.Infof("Product %s reverted to revision %d", idProduct, revision)
+	return data, nil
+}
+
+// NewTransitionProduct chuyển trạng thái theo policy.ProductTransition. allowed cho biết người gọi có quyền
+// được yêu cầu hay không; việc kiểm tra chạy trên trạng thái đã khóa trong transaction để hai lần chuyển đồng thời
+// không cùng đi từ một trạng thái.
+func (res *productController) NewTransitionProduct(ctx context.Context, idProduct string, req *req_users.RequestProductTransition, allowed func(permission string) bool) (*module.ProductStatusHistory, error) {
+	reason := strings.TrimSpace(req.Reason)
+	check := func(from string) error {
+		if req.From != "" && req.From != from {
+			return fmt.Errorf("%w: current status is %s", module.ErrStatusConflict, from)
+		}
+		transition, err := policy.ProductTransition(from, req.To)
+		if err != nil {
+			return err
+		}
+		if !allowed(transition.Permission) {
+			return fmt.Errorf("%w: requires %s", module.ErrTransitionForbidden, transition.Permission)
+		}
+		if transition.RequireReason && reason == "" {
+			return module.ErrTransitionReasonRequired
+		}
+		return nil
+	}
+	var reasonPtr *string
+	if reason != "" {
+		reasonPtr = &reason
+	}
+	history, err := res.p.TransitionProductStatus(ctx, map[string]any{"product_id": idProduct}, req.To, reasonPtr, check)
+	if err != nil {
+		res.log.Warnf("Failed to change status of product %s to %s: %v", idProduct, req.To, err)
+		return nil, err
+	}
+	res.log.Infof("Product %s status changed %s -> %s", idProduct, history.FromStatus, history.ToStatus)
+	return history, nil
+}
+
+func (res *productController) NewListProductStatusHistory(ctx context.Context, idProduct string, pagging *common.Paggings) ([]module.ProductStatusHistory, error) {
+	data, err := res.p.ListProductStatusHistory(ctx, map[string]any{"product_id": idProduct}, pagging)
+	if err != nil {
+		res.log.Errorf("Failed to list status history of product %s: %v", idProduct, err)
+		return nil, err
+	}
+	return data, nil
+}
+
+// NewAddProductMedia thêm ảnh hoặc video vào cuối gallery và trả về gallery mới
+func (res *productController) NewAddProductMedia(ctx context.Context, idProduct string, media *module.ProductMedia) ([]module.ProductMedia, error) {
+	if media.Type != module.MediaTypeImage && media.Type != module.MediaTypeVideo {
+		return nil, module.ErrInvalidMediaType
+	}
+	if err := res.p.AddProductMedia(ctx, map[string]any{"product_id": idProduct}, media); err != nil {
+		res.log.Errorf("Failed to add media to product %s: %v", idProduct, err)
+		return nil, err
+	}
+	res.log.Infof("Media %s added to product %s", media.MediaID, idProduct)
+	return res.p.ListProductMedia(ctx, idProduct)
+}
+
+func (res *productController) NewUpdateProductMedia(ctx context.Context, idProduct, mediaID string, upd *req_users.RequestUpdateProductMedia) ([]module.ProductMedia, error) {
+	if err := res.p.UpdateProductMedia(ctx, map[string]any{"product_id": idProduct}, mediaID, upd); err != nil {
+		res.log.Errorf("Failed to update media %s of product %s: %v", mediaID, idProduct, err)
+		return nil, err
+	}
+	return res.p.ListProductMedia(ctx, idProduct)
+}
+
+// NewReorderProductMedia trả về module.ErrInvalidMediaOrder nếu mediaIDs thiếu, thừa hoặc lặp media
+func (res *productController) NewReorderProductMedia(ctx context.Context, idProduct string, mediaIDs []string) ([]module.ProductMedia, error) {
+	if err := res.p.ReorderProductMedia(ctx, map[string]any{"product_id": idProduct}, mediaIDs); err != nil {
+		res.log.Errorf("Failed to reorder media of product %s: %v", idProduct, err)
+		return nil, err
+	}
+	return res.p.ListProductMedia(ctx, idProduct)
+}
+
+// NewSetPrimaryProductMedia đổi ảnh chính, products.image được cập nhật theo
+func (res *productController) NewSetPrimaryProductMedia(ctx context.Context, idProduct, mediaID string) ([]module.ProductMedia, error) {
+	if err := res.p.SetPrimaryProductMedia(ctx, map[string]any{"product_id": idProduct}, mediaID); err != nil {
+		res.log.Errorf("Failed to set primary media %s of product %s: %v", mediaID, idProduct, err)
+		return nil, err
+	}
+	res.log.Infof("Media %s is now the primary image of product %s", mediaID, idProduct)
+	return res.p.ListProductMedia(ctx, idProduct)
+}
+
+// NewDeleteProductMedia trả về module.ErrPrimaryMediaRequired khi gỡ ảnh cuối cùng của sản phẩm
+func (res *productController) NewDeleteProductMedia(ctx context.Context, idProduct, mediaID string) ([]module.ProductMedia, error) {
+	if err := res.p.DeleteProductMedia(ctx, map[string]any{"product_id": idProduct}, mediaID); err != nil {
+		res.log.Errorf("Failed to delete media %s of product %s: %v", mediaID, idProduct, err)
+		return nil, err
+	}
+	res.log.Infof("Media %s removed from product %s", mediaID, idProduct)
+	return res.p.ListProductMedia(ctx, idProduct)
+}
+
+// highlightTags đổi dấu đánh dấu của ts_headline thành thẻ <mark> sau khi đã escape nội dung sản phẩm
+var highlightTags = strings.NewReplacer(module.HighlightStart, "<mark>", module.HighlightStop, "</mark>")
+
+// highlight escape HTML của tiêu đề/mô tả (nội dung do người dùng nhập) để đoạn trích không chứa thẻ lạ
+func highlight(s string) string {
+	return highlightTags.Replace(html.EscapeString(s))
+}
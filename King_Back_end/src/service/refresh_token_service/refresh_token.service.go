@@ -2,6 +2,7 @@ package refresh_token_service
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"thelastking-blogger.com/src/config/logger"
@@ -10,9 +11,13 @@ import (
 
 type RefreshTokenResponse interface {
 	CreateRefreshToken(ctx context.Context, data *module.RefreshToken) error
-	GetRefreshTokenByTokenID(ctx context.Context, tokenID string) (*module.RefreshToken, error)
-	DeleteRefreshTokenByTokenID(ctx context.Context, tokenID string) error
+	GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*module.RefreshToken, error)
+	RotateRefreshToken(ctx context.Context, oldHash string, newToken *module.RefreshToken) error
+	RevokeRefreshTokenFamily(ctx context.Context, familyID string) error
 	RevokeRefreshTokenByUserID(ctx context.Context, userID string) error
+	ListSessions(ctx context.Context, userID string, now time.Time) ([]module.Session, error)
+	RevokeSession(ctx context.Context, userID, familyID string) (bool, error)
+	RevokeOtherSessions(ctx context.Context, userID, keepFamilyID string) ([]string, error)
 	CleanupOldRevokedTokens(ctx context.Context) error
 }
 
@@ -28,31 +33,44 @@ func NewRefreshTokenController(r RefreshTokenResponse) *refreshTokenController {
 	}
 }
 
-func (res *refreshTokenController) NewGetRefreshTokenByTokenID(ctx context.Context, tokenID string) (*module.RefreshToken, error) {
-	data, err := res.r.GetRefreshTokenByTokenID(ctx, tokenID)
+func (res *refreshTokenController) NewGetRefreshTokenByHash(ctx context.Context, tokenHash string) (*module.RefreshToken, error) {
+	data, err := res.r.GetRefreshTokenByHash(ctx, tokenHash)
 	if err != nil {
 		res.log.Errorf("Refresh token non-existent: %v", err)
 		return nil, err
 	}
-	res.log.Infof("Refresh token existent: %+v", data)
+	res.log.Infof("Refresh token existent: family=%s user=%s", data.FamilyID, data.UserID)
 	return data, nil
 }
 
-func (res *refreshTokenController) NewDeleteRefreshTokenByTokenID(ctx context.Context, tokenID string) error {
-	if err := res.r.DeleteRefreshTokenByTokenID(ctx, tokenID); err != nil {
-		res.log.Errorf("Refresh token non-existent: %v", err)
+func (res *refreshTokenController) NewCreateRefreshToken(ctx context.Context, data *module.RefreshToken) error {
+	if err := res.r.CreateRefreshToken(ctx, data); err != nil {
+		res.log.Errorf("Create refresh token faild: %v", err)
 		return err
 	}
-	res.log.Infof("Refresh token has been deleted")
+	res.log.Infof("Create refresh token success")
 	return nil
 }
 
-func (res *refreshTokenController) NewCreateRefreshToken(ctx context.Context, data *module.RefreshToken) error {
-	if err := res.r.CreateRefreshToken(ctx, data); err != nil {
-		res.log.Errorf("Create refresh token faild: %v", err)
+func (res *refreshTokenController) NewRotateRefreshToken(ctx context.Context, oldHash string, newToken *module.RefreshToken) error {
+	if err := res.r.RotateRefreshToken(ctx, oldHash, newToken); err != nil {
+		if errors.Is(err, module.ErrRefreshTokenReused) {
+			res.log.Warnf("Refresh token reuse detected, family revoked")
+		} else {
+			res.log.Errorf("Rotate refresh token faild: %v", err)
+		}
 		return err
 	}
-	res.log.Infof("Create refresh token success")
+	res.log.Infof("Refresh token rotated: family=%s user=%s", newToken.FamilyID, newToken.UserID)
+	return nil
+}
+
+func (res *refreshTokenController) NewRevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	if err := res.r.RevokeRefreshTokenFamily(ctx, familyID); err != nil {
+		res.log.Errorf("Revoke refresh token family %s faild: %v", familyID, err)
+		return err
+	}
+	res.log.Infof("Refresh token family %s has been revoked", familyID)
 	return nil
 }
 
@@ -63,6 +81,42 @@ func (res *refreshTokenController) NewRevokeRefreshTokenByUserID(ctx context.Con
 	return nil
 }
 
+// NewListSessions đánh dấu phiên hiện tại bằng family của refresh token trong cookie
+func (res *refreshTokenController) NewListSessions(ctx context.Context, userID, currentFamilyID string) ([]module.Session, error) {
+	data, err := res.r.ListSessions(ctx, userID, time.Now().UTC())
+	if err != nil {
+		res.log.Errorf("List sessions of user %s faild: %v", userID, err)
+		return nil, err
+	}
+	for i := range data {
+		data[i].Current = data[i].SessionID == currentFamilyID
+	}
+	return data, nil
+}
+
+func (res *refreshTokenController) NewRevokeSession(ctx context.Context, userID, familyID string) (bool, error) {
+	found, err := res.r.RevokeSession(ctx, userID, familyID)
+	if err != nil {
+		res.log.Errorf("Revoke session %s of user %s faild: %v", familyID, userID, err)
+		return false, err
+	}
+	if found {
+		res.log.Infof("Session %s of user %s has been revoked", familyID, userID)
+	}
+	return found, nil
+}
+
+// NewRevokeOtherSessions trả về các family vừa bị thu hồi để thu hồi luôn access token của chúng
+func (res *refreshTokenController) NewRevokeOtherSessions(ctx context.Context, userID, keepFamilyID string) ([]string, error) {
+	families, err := res.r.RevokeOtherSessions(ctx, userID, keepFamilyID)
+	if err != nil {
+		res.log.Errorf("Revoke other sessions of user %s faild: %v", userID, err)
+		return nil, err
+	}
+	res.log.Infof("All sessions of user %s except %s have been revoked", userID, keepFamilyID)
+	return families, nil
+}
+
 func (res *refreshTokenController) NewCleanupOldRevokedTokens(ctx context.Context) error {
 	if err := res.r.CleanupOldRevokedTokens(ctx); err != nil {
 		return err
@@ -0,0 +1,150 @@
+package scope_service
+
+import (
+	"context"
+	"time"
+
+	"thelastking-blogger.com/src/config/logger"
+	"thelastking-blogger.com/src/module"
+	"thelastking-blogger.com/src/policy"
+)
+
+type ScopeResponse interface {
+	ListLocationScopes(ctx context.Context, userID string) ([]module.UserLocationScope, error)
+	ListFactoryScopes(ctx context.Context, userID string) ([]module.UserFactoryScope, error)
+	AddLocationScope(ctx context.Context, data *module.UserLocationScope) error
+	AddFactoryScope(ctx context.Context, data *module.UserFactoryScope) error
+	RemoveLocationScope(ctx context.Context, userID, locationID string) error
+	RemoveFactoryScope(ctx context.Context, userID, factoryID string) error
+	CoversLocation(ctx context.Context, userID string, location map[string]any) (bool, error)
+	CoversFactory(ctx context.Context, userID string, factory map[string]any) (bool, error)
+	CoversProduct(ctx context.Context, userID string, productID string) (bool, error)
+}
+
+type scopeController struct {
+	r   ScopeResponse
+	log logger.Logger
+}
+
+func NewScopeController(r ScopeResponse) *scopeController {
+	return &scopeController{
+		r:   r,
+		log: logger.GetLogger(),
+	}
+}
+
+// NewGetScope trả về phạm vi của user, ROOT luôn có phạm vi toàn cục
+func (res *scopeController) NewGetScope(ctx context.Context, userID, role string) (*module.UserScope, error) {
+	if policy.IsGlobal(role) {
+		return &module.UserScope{Global: true, Locations: []string{}, Factories: []string{}}, nil
+	}
+	locations, err := res.r.ListLocationScopes(ctx, userID)
+	if err != nil {
+		res.log.Errorf("List location scopes of user %s faild: %v", userID, err)
+		return nil, err
+	}
+	factories, err := res.r.ListFactoryScopes(ctx, userID)
+	if err != nil {
+		res.log.Errorf("List factory scopes of user %s faild: %v", userID, err)
+		return nil, err
+	}
+	scope := &module.UserScope{
+		Locations: make([]string, 0, len(locations)),
+		Factories: make([]string, 0, len(factories)),
+	}
+	for _, row := range locations {
+		scope.Locations = append(scope.Locations, row.LocationID)
+	}
+	for _, row := range factories {
+		scope.Factories = append(scope.Factories, row.FactoryID)
+	}
+	return scope, nil
+}
+
+// NewCoversLocation trả về module.ErrOutOfScope nếu khu vực không thuộc phạm vi của user
+func (res *scopeController) NewCoversLocation(ctx context.Context, userID, role string, location map[string]any) error {
+	if policy.IsGlobal(role) {
+		return nil
+	}
+	ok, err := res.r.CoversLocation(ctx, userID, location)
+	if err != nil {
+		res.log.Errorf("Check location scope of user %s faild: %v", userID, err)
+		return err
+	}
+	if !ok {
+		res.log.Warnf("User %s is out of scope for location %v", userID, location)
+		return module.ErrOutOfScope
+	}
+	return nil
+}
+
+// NewCoversFactory trả về module.ErrOutOfScope nếu nhà máy không thuộc phạm vi của user
+func (res *scopeController) NewCoversFactory(ctx context.Context, userID, role string, factory map[string]any) error {
+	if policy.IsGlobal(role) {
+		return nil
+	}
+	ok, err := res.r.CoversFactory(ctx, userID, factory)
+	if err != nil {
+		res.log.Errorf("Check factory scope of user %s faild: %v", userID, err)
+		return err
+	}
+	if !ok {
+		res.log.Warnf("User %s is out of scope for factory %v", userID, factory)
+		return module.ErrOutOfScope
+	}
+	return nil
+}
+
+// NewCoversProduct trả về module.ErrOutOfScope nếu sản phẩm thuộc nhà máy ngoài phạm vi của user
+func (res *scopeController) NewCoversProduct(ctx context.Context, userID, role string, productID string) error {
+	if policy.IsGlobal(role) {
+		return nil
+	}
+	ok, err := res.r.CoversProduct(ctx, userID, productID)
+	if err != nil {
+		res.log.Errorf("Check product scope of user %s faild: %v", userID, err)
+		return err
+	}
+	if !ok {
+		res.log.Warnf("User %s is out of scope for product %s", userID, productID)
+		return module.ErrOutOfScope
+	}
+	return nil
+}
+
+func (res *scopeController) NewAssignScope(ctx context.Context, userID, scopeType, targetID string) error {
+	now := time.Now().UTC()
+	var err error
+	switch scopeType {
+	case module.ScopeLocation:
+		err = res.r.AddLocationScope(ctx, &module.UserLocationScope{UserID: userID, LocationID: targetID, CreatedAt: now})
+	case module.ScopeFactory:
+		err = res.r.AddFactoryScope(ctx, &module.UserFactoryScope{UserID: userID, FactoryID: targetID, CreatedAt: now})
+	default:
+		return module.ErrInvalidScopeType
+	}
+	if err != nil {
+		res.log.Errorf("Assign %s %s to user %s faild: %v", scopeType, targetID, userID, err)
+		return err
+	}
+	res.log.Infof("Assigned %s %s to user %s", scopeType, targetID, userID)
+	return nil
+}
+
+func (res *scopeController) NewRemoveScope(ctx context.Context, userID, scopeType, targetID string) error {
+	var err error
+	switch scopeType {
+	case module.ScopeLocation:
+		err = res.r.RemoveLocationScope(ctx, userID, targetID)
+	case module.ScopeFactory:
+		err = res.r.RemoveFactoryScope(ctx, userID, targetID)
+	default:
+		return module.ErrInvalidScopeType
+	}
+	if err != nil {
+		res.log.Errorf("Remove %s %s from user %s faild: %v", scopeType, targetID, userID, err)
+		return err
+	}
+	res.log.Infof("Removed %s %s from user %s", scopeType, targetID, userID)
+	return nil
+}
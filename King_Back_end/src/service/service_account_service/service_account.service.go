@@ -0,0 +1,262 @@
+package service_account_service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"thelastking-blogger.com/src/config/logger"
+	"thelastking-blogger.com/src/module"
+	"thelastking-blogger.com/src/policy"
+	"thelastking-blogger.com/src/security"
+	"thelastking-blogger.com/src/utils"
+)
+
+// apiKeyPrefix giúp nhận ra key bị lộ trong log hoặc trong mã nguồn
+const apiKeyPrefix = "tk_"
+
+// touchInterval: last_used_at chỉ được ghi tối đa một lần mỗi phút cho mỗi key
+const touchInterval = time.Minute
+
+type ServiceAccountResponse interface {
+	CreateServiceAccount(ctx context.Context, data *module.ServiceAccount) error
+	ListServiceAccounts(ctx context.Context) ([]module.ServiceAccount, error)
+	GetServiceAccount(ctx context.Context, id string) (*module.ServiceAccount, error)
+	DeleteServiceAccount(ctx context.Context, id string) error
+	ListApiKeys(ctx context.Context, serviceAccountIDs []string) ([]module.ApiKey, error)
+	ListApiKeyPermissions(ctx context.Context, keyIDs []string) ([]module.ApiKeyPermission, error)
+	CreateApiKey(ctx context.Context, data *module.ApiKey, permissions []string) error
+	RevokeApiKey(ctx context.Context, serviceAccountID, keyID string, now time.Time) error
+	GetApiKeyByHash(ctx context.Context, keyHash string) (*module.ApiKey, error)
+	GetApiKeyCreatorRole(ctx context.Context, keyID string) (string, error)
+	TouchApiKey(ctx context.Context, keyID, ip string, now, since time.Time) error
+}
+
+type serviceAccountController struct {
+	r   ServiceAccountResponse
+	log logger.Logger
+}
+
+func NewServiceAccountController(r ServiceAccountResponse) *serviceAccountController {
+	return &serviceAccountController{
+		r:   r,
+		log: logger.GetLogger(),
+	}
+}
+
+func (res *serviceAccountController) NewCreateServiceAccount(ctx context.Context, name, description, createdBy string) (*module.ServiceAccount, error) {
+	id, err := utils.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	data := &module.ServiceAccount{
+		ServiceAccountID: id,
+		Name:             name,
+		Description:      description,
+		CreatedBy:        &createdBy,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+		Keys:             []module.ApiKey{},
+	}
+	if err := res.r.CreateServiceAccount(ctx, data); err != nil {
+		res.log.Errorf("Create service account %s faild: %v", name, err)
+		return nil, err
+	}
+	res.log.Infof("Service account %s (%s) created by %s", name, id, createdBy)
+	return data, nil
+}
+
+// NewListServiceAccounts trả về các service account kèm API key và quyền của từng key
+func (res *serviceAccountController) NewListServiceAccounts(ctx context.Context) ([]module.ServiceAccount, error) {
+	accounts, err := res.r.ListServiceAccounts(ctx)
+	if err != nil {
+		res.log.Errorf("List service accounts faild: %v", err)
+		return nil, err
+	}
+	if len(accounts) == 0 {
+		return accounts, nil
+	}
+	accountIDs := make([]string, 0, len(accounts))
+	for _, account := range accounts {
+		accountIDs = append(accountIDs, account.ServiceAccountID)
+	}
+	keys, err := res.r.ListApiKeys(ctx, accountIDs)
+	if err != nil {
+		res.log.Errorf("List api keys faild: %v", err)
+		return nil, err
+	}
+	if err := res.attachPermissions(ctx, keys); err != nil {
+		return nil, err
+	}
+
+	byAccount := make(map[string][]module.ApiKey)
+	for _, key := range keys {
+		byAccount[key.ServiceAccountID] = append(byAccount[key.ServiceAccountID], key)
+	}
+	for i := range accounts {
+		accounts[i].Keys = byAccount[accounts[i].ServiceAccountID]
+		if accounts[i].Keys == nil {
+			accounts[i].Keys = []module.ApiKey{}
+		}
+	}
+	return accounts, nil
+}
+
+func (res *serviceAccountController) NewDeleteServiceAccount(ctx context.Context, id string) error {
+	if err := res.r.DeleteServiceAccount(ctx, id); err != nil {
+		res.log.Errorf("Delete service account %s faild: %v", id, err)
+		return err
+	}
+	res.log.Infof("Service account %s deleted", id)
+	return nil
+}
+
+// NewCreateApiKey tạo key mới và trả về key gốc, đây là lần duy nhất key gốc được trả về.
+// Người tạo chỉ được cấp những quyền mà role của mình đang có. Key không bị giới hạn theo khu vực/nhà máy
+// nên chỉ người có phạm vi toàn cục mới được tạo.
+func (res *serviceAccountController) NewCreateApiKey(ctx context.Context, serviceAccountID, name string, permissions []string, expiresAt *time.Time, actorID, actorRole string) (string, *module.ApiKey, error) {
+	if !policy.IsGlobal(actorRole) {
+		res.log.Warnf("User %s without global scope tried to create an api key", actorID)
+		return "", nil, module.ErrApiKeyScopeForbidden
+	}
+	if _, err := res.r.GetServiceAccount(ctx, serviceAccountID); err != nil {
+		return "", nil, err
+	}
+	permissions = uniquePermissions(permissions)
+	for _, permission := range permissions {
+		if !policy.Can(actorRole, permission) {
+			res.log.Warnf("User %s tried to grant %s to an api key", actorID, permission)
+			return "", nil, module.ErrApiKeyPermissionForbidden
+		}
+	}
+
+	prefixRaw := make([]byte, 6)
+	if _, err := rand.Read(prefixRaw); err != nil {
+		return "", nil, err
+	}
+	secretRaw := make([]byte, 32)
+	if _, err := rand.Read(secretRaw); err != nil {
+		return "", nil, err
+	}
+	prefix := apiKeyPrefix + hex.EncodeToString(prefixRaw)
+	plain := prefix + "_" + base64.RawURLEncoding.EncodeToString(secretRaw)
+
+	id, err := utils.GenerateUUID()
+	if err != nil {
+		return "", nil, err
+	}
+	if expiresAt != nil {
+		utc := expiresAt.UTC()
+		expiresAt = &utc
+	}
+	data := &module.ApiKey{
+		KeyID:            id,
+		ServiceAccountID: serviceAccountID,
+		Name:             name,
+		Prefix:           prefix,
+		KeyHash:          security.HashToken(plain),
+		ExpiresAt:        expiresAt,
+		CreatedBy:        &actorID,
+		CreatedAt:        time.Now().UTC(),
+		Permissions:      permissions,
+	}
+	if err := res.r.CreateApiKey(ctx, data, permissions); err != nil {
+		res.log.Errorf("Create api key for service account %s faild: %v", serviceAccountID, err)
+		return "", nil, err
+	}
+	res.log.Infof("Api key %s (%s) created for service account %s by %s", id, prefix, serviceAccountID, actorID)
+	return plain, data, nil
+}
+
+func (res *serviceAccountController) NewRevokeApiKey(ctx context.Context, serviceAccountID, keyID string) error {
+	if err := res.r.RevokeApiKey(ctx, serviceAccountID, keyID, time.Now().UTC()); err != nil {
+		res.log.Errorf("Revoke api key %s faild: %v", keyID, err)
+		return err
+	}
+	res.log.Infof("Api key %s of service account %s revoked", keyID, serviceAccountID)
+	return nil
+}
+
+// NewAuthenticate kiểm tra key gốc trong header Authorization: ApiKey và ghi lại lần dùng gần nhất.
+// Người tạo bị hạ quyền hoặc bị xóa thì key mất hiệu lực, vì key được dùng với phạm vi toàn cục.
+func (res *serviceAccountController) NewAuthenticate(ctx context.Context, plain, ip string) (*module.ApiKeyIdentity, error) {
+	key, err := res.r.GetApiKeyByHash(ctx, security.HashToken(plain))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, module.ErrApiKeyInvalid
+		}
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && !key.ExpiresAt.After(now)) {
+		res.log.Warnf("Rejected revoked or expired api key %s", key.Prefix)
+		return nil, module.ErrApiKeyInvalid
+	}
+	creatorRole, err := res.r.GetApiKeyCreatorRole(ctx, key.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if !policy.IsGlobal(creatorRole) {
+		res.log.Warnf("Rejected api key %s whose creator no longer has global scope", key.Prefix)
+		return nil, module.ErrApiKeyInvalid
+	}
+	rows, err := res.r.ListApiKeyPermissions(ctx, []string{key.KeyID})
+	if err != nil {
+		return nil, err
+	}
+	identity := &module.ApiKeyIdentity{
+		KeyID:            key.KeyID,
+		ServiceAccountID: key.ServiceAccountID,
+		Permissions:      make([]string, 0, len(rows)),
+	}
+	for _, row := range rows {
+		identity.Permissions = append(identity.Permissions, row.PermissionKey)
+	}
+	if err := res.r.TouchApiKey(ctx, key.KeyID, ip, now, now.Add(-touchInterval)); err != nil {
+		res.log.Errorf("Update last use of api key %s faild: %v", key.Prefix, err)
+	}
+	return identity, nil
+}
+
+func (res *serviceAccountController) attachPermissions(ctx context.Context, keys []module.ApiKey) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	keyIDs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		keyIDs = append(keyIDs, key.KeyID)
+	}
+	rows, err := res.r.ListApiKeyPermissions(ctx, keyIDs)
+	if err != nil {
+		res.log.Errorf("List api key permissions faild: %v", err)
+		return err
+	}
+	byKey := make(map[string][]string)
+	for _, row := range rows {
+		byKey[row.KeyID] = append(byKey[row.KeyID], row.PermissionKey)
+	}
+	for i := range keys {
+		keys[i].Permissions = byKey[keys[i].KeyID]
+		if keys[i].Permissions == nil {
+			keys[i].Permissions = []string{}
+		}
+	}
+	return nil
+}
+
+func uniquePermissions(permissions []string) []string {
+	seen := make(map[string]bool, len(permissions))
+	out := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		if !seen[permission] {
+			seen[permission] = true
+			out = append(out, permission)
+		}
+	}
+	return out
+}
@@ -0,0 +1,346 @@
+package upload_service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gorm.io/gorm"
+	"thelastking-blogger.com/src/audit"
+	"thelastking-blogger.com/src/config/logger"
+	uploadconfig "thelastking-blogger.com/src/config/upload_config"
+	"thelastking-blogger.com/src/module"
+	"thelastking-blogger.com/src/module/req_users"
+	"thelastking-blogger.com/src/storage"
+	"thelastking-blogger.com/src/utils"
+)
+
+// cleanupBatch là số upload hết hạn được dọn trong một lượt truy vấn
+const cleanupBatch = 100
+
+type UploadResponse interface {
+	CreateUpload(ctx context.Context, data *module.MediaUpload) error
+	GetUpload(ctx context.Context, cond map[string]any, now time.Time) (*module.MediaUpload, error)
+	AppendChunk(ctx context.Context, cond map[string]any, now, expiresAt time.Time, write func(upload *module.MediaUpload) (int64, error)) (*module.MediaUpload, error)
+	CompleteUpload(ctx context.Context, uploadID, storageKey, contentType string, now, expiresAt time.Time) error
+	DeleteUpload(ctx context.Context, cond map[string]any) (*module.MediaUpload, error)
+	ListExpiredUploads(ctx context.Context, now time.Time, limit int) ([]module.MediaUpload, error)
+	MediaKeyInUse(ctx context.Context, key string) (bool, error)
+}
+
+type uploadController struct {
+	r   UploadResponse
+	cfg *uploadconfig.Config
+	log logger.Logger
+}
+
+func NewUploadController(r UploadResponse) *uploadController {
+	return &uploadController{
+		r:   r,
+		cfg: uploadconfig.Get(),
+		log: logger.GetLogger(),
+	}
+}
+
+// ChunkSize là kích thước tối đa của một phần, trả cho client lúc tạo upload
+func (res *uploadController) ChunkSize() int64 {
+	return res.cfg.ChunkSize
+}
+
+// NewCreateUpload mở upload cho video, người gọi (user hoặc service account) là chủ sở hữu
+func (res *uploadController) NewCreateUpload(ctx context.Context, req *req_users.RequestCreateUpload) (*module.MediaUpload, error) {
+	if req.Size > res.cfg.MaxSize {
+		return nil, module.ErrUploadTooLarge
+	}
+	if err := os.MkdirAll(res.cfg.TmpDir, 0o755); err != nil {
+		return nil, err
+	}
+	uploadID, err := utils.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	actor := audit.ActorFrom(ctx)
+	upload := &module.MediaUpload{
+		UploadID:         uploadID,
+		Kind:             storage.KindVideo,
+		FileName:         filepath.Base(req.FileName),
+		Size:             req.Size,
+		Status:           module.UploadStatusPending,
+		UserID:           nullable(actor.UserID),
+		ServiceAccountID: nullable(actor.ServiceAccountID),
+		ExpiresAt:        now.Add(res.cfg.TTL),
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+	if req.Checksum != "" {
+		upload.Checksum = &req.Checksum
+	}
+	if err := res.r.CreateUpload(ctx, upload); err != nil {
+		res.log.Errorf("Failed to create upload: %v", err)
+		return nil, err
+	}
+	res.log.Infof("Upload %s created for %s (%d bytes)", uploadID, upload.FileName, upload.Size)
+	return upload, nil
+}
+
+func (res *uploadController) NewGetUpload(ctx context.Context, uploadID string) (*module.MediaUpload, error) {
+	upload, err := res.r.GetUpload(ctx, ownerCond(ctx, uploadID), time.Now().UTC())
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, module.ErrUploadNotFound
+		}
+		return nil, err
+	}
+	return upload, nil
+}
+
+// NewAppendChunk ghi phần bắt đầu tại offset, offset phải bằng số byte server đã nhận.
+// chunkSum là sha256 của phần này (nil nếu client không gửi), sai thì phần bị bỏ và offset giữ nguyên.
+// Khi nhận đủ size byte file được chuyển vào storage; nếu bước này lỗi thì gửi lại một phần rỗng tại offset cuối để thử lại.
+func (res *uploadController) NewAppendChunk(ctx context.Context, uploadID string, offset int64, chunkSum []byte, body io.Reader) (*module.MediaUpload, error) {
+	chunk, err := io.ReadAll(io.LimitReader(body, res.cfg.ChunkSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(chunk)) > res.cfg.ChunkSize {
+		return nil, module.ErrUploadTooLarge
+	}
+	if chunkSum != nil {
+		sum := sha256.Sum256(chunk)
+		if !bytes.Equal(sum[:], chunkSum) {
+			return nil, module.ErrUploadChecksumMismatch
+		}
+	}
+
+	now := time.Now().UTC()
+	upload, err := res.r.AppendChunk(ctx, ownerCond(ctx, uploadID), now, now.Add(res.cfg.TTL), func(upload *module.MediaUpload) (int64, error) {
+		if upload.Status != module.UploadStatusPending {
+			return 0, module.ErrUploadCompleted
+		}
+		if offset != upload.Offset {
+			return 0, module.ErrUploadOffsetMismatch
+		}
+		if offset+int64(len(chunk)) > upload.Size {
+			return 0, module.ErrUploadTooLarge
+		}
+		if err := writeChunk(res.partPath(upload.UploadID), offset, chunk); err != nil {
+			return 0, err
+		}
+		return offset + int64(len(chunk)), nil
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, module.ErrUploadNotFound
+		}
+		return nil, err
+	}
+	if upload.Offset < upload.Size {
+		return upload, nil
+	}
+	return res.complete(ctx, upload)
+}
+
+// complete kiểm tra checksum của cả file và loại file thật rồi chuyển vào storage.
+// File sai checksum hoặc không phải video thì upload bị hủy vì không biết phần nào hỏng.
+func (res *uploadController) complete(ctx context.Context, upload *module.MediaUpload) (*module.MediaUpload, error) {
+	part := res.partPath(upload.UploadID)
+	f, err := os.Open(part)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if upload.Checksum != nil {
+		hash := sha256.New()
+		if _, err := io.Copy(hash, f); err != nil {
+			return nil, err
+		}
+		if hex.EncodeToString(hash.Sum(nil)) != *upload.Checksum {
+			res.discard(ctx, upload.UploadID)
+			return nil, module.ErrUploadChecksumMismatch
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+	obj, err := storage.Save(ctx, storage.Get(), f, upload.Kind)
+	if err != nil {
+		if errors.Is(err, storage.ErrUnsupportedMedia) {
+			res.discard(ctx, upload.UploadID)
+		}
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	expiresAt := now.Add(res.cfg.TTL)
+	if err := res.r.CompleteUpload(ctx, upload.UploadID, obj.Key, obj.ContentType, now, expiresAt); err != nil {
+		// Upload bị hủy, hết hạn hoặc đã được request khác hoàn tất trong lúc chuyển file vào storage
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, module.ErrUploadNotFound
+		}
+		res.log.Errorf("Failed to complete upload %s: %v", upload.UploadID, err)
+		return nil, err
+	}
+	if err := os.Remove(part); err != nil {
+		res.log.Warnf("Failed to remove upload part %s: %v", part, err)
+	}
+	upload.Status = module.UploadStatusCompleted
+	upload.StorageKey = &obj.Key
+	upload.ContentType = &obj.ContentType
+	upload.ExpiresAt = expiresAt
+	upload.UpdatedAt = now
+	res.log.Infof("Upload %s completed as %s", upload.UploadID, obj.Key)
+	return upload, nil
+}
+
+// NewCancelUpload hủy upload của người gọi và xóa phần đã nhận
+func (res *uploadController) NewCancelUpload(ctx context.Context, uploadID string) error {
+	upload, err := res.r.DeleteUpload(ctx, ownerCond(ctx, uploadID))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return module.ErrUploadNotFound
+		}
+		return err
+	}
+	res.removeUpload(ctx, upload)
+	res.log.Infof("Upload %s cancelled", uploadID)
+	return nil
+}
+
+// NewResolveUpload trả về key trong storage của upload đã hoàn tất để gắn vào sản phẩm
+func (res *uploadController) NewResolveUpload(ctx context.Context, uploadID string) (string, error) {
+	upload, err := res.NewGetUpload(ctx, uploadID)
+	if err != nil {
+		return "", err
+	}
+	if upload.Status != module.UploadStatusCompleted || upload.StorageKey == nil {
+		return "", module.ErrUploadIncomplete
+	}
+	return *upload.StorageKey, nil
+}
+
+// NewConsumeUpload xóa upload sau khi đã gắn vào sản phẩm, file trong storage được giữ lại
+func (res *uploadController) NewConsumeUpload(ctx context.Context, uploadID string) error {
+	cond := ownerCond(ctx, uploadID)
+	cond["status"] = module.UploadStatusCompleted
+	if _, err := res.r.DeleteUpload(ctx, cond); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		res.log.Errorf("Failed to consume upload %s: %v", uploadID, err)
+		return err
+	}
+	return nil
+}
+
+// NewDeleteExpiredUploads dọn upload bỏ dở và upload đã hoàn tất nhưng không được gắn vào sản phẩm nào
+func (res *uploadController) NewDeleteExpiredUploads(ctx context.Context) error {
+	for {
+		uploads, err := res.r.ListExpiredUploads(ctx, time.Now().UTC(), cleanupBatch)
+		if err != nil {
+			return err
+		}
+		for i := range uploads {
+			upload, err := res.r.DeleteUpload(ctx, map[string]any{"upload_id": uploads[i].UploadID})
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					continue
+				}
+				return err
+			}
+			res.removeUpload(ctx, upload)
+		}
+		if len(uploads) < cleanupBatch {
+			return nil
+		}
+	}
+}
+
+func RunCleanupUploadsJob(controller *uploadController) {
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := controller.NewDeleteExpiredUploads(context.Background()); err != nil {
+				controller.log.Errorf("Failed to cleanup media uploads: %v", err)
+			}
+		}
+	}()
+}
+
+// removeUpload xóa file tạm, và file trong storage nếu không sản phẩm nào dùng
+func (res *uploadController) removeUpload(ctx context.Context, upload *module.MediaUpload) {
+	if err := os.Remove(res.partPath(upload.UploadID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		res.log.Warnf("Failed to remove upload part %s: %v", upload.UploadID, err)
+	}
+	if upload.StorageKey == nil {
+		return
+	}
+	inUse, err := res.r.MediaKeyInUse(ctx, *upload.StorageKey)
+	if err != nil {
+		res.log.Errorf("Failed to check media key %s: %v", *upload.StorageKey, err)
+		return
+	}
+	if inUse {
+		return
+	}
+	if err := storage.Get().Delete(ctx, *upload.StorageKey); err != nil {
+		res.log.Errorf("Failed to delete media %s: %v", *upload.StorageKey, err)
+	}
+}
+
+// discard hủy upload bị lỗi ở bước hoàn tất
+func (res *uploadController) discard(ctx context.Context, uploadID string) {
+	upload, err := res.r.DeleteUpload(ctx, map[string]any{"upload_id": uploadID})
+	if err != nil {
+		res.log.Errorf("Failed to discard upload %s: %v", uploadID, err)
+		return
+	}
+	res.removeUpload(ctx, upload)
+}
+
+func (res *uploadController) partPath(uploadID string) string {
+	return filepath.Join(res.cfg.TmpDir, uploadID+".part")
+}
+
+// writeChunk ghi đè từ offset và cắt bỏ phần thừa của lần ghi trước bị lỗi giữa chừng
+func writeChunk(path string, offset int64, chunk []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteAt(chunk, offset); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Truncate(offset + int64(len(chunk))); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ownerCond giới hạn truy vấn vào upload của chính người gọi
+func ownerCond(ctx context.Context, uploadID string) map[string]any {
+	actor := audit.ActorFrom(ctx)
+	return map[string]any{
+		"upload_id":          uploadID,
+		"user_id":            nullable(actor.UserID),
+		"service_account_id": nullable(actor.ServiceAccountID),
+	}
+}
+
+func nullable(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
@@ -17,9 +17,13 @@ type UsersResponse interface {
 	DeleteUsers(ctx context.Context, idData map[string]any) error
 	ChanrgePwd(ctx context.Context, idData map[string]any, chanrge *req_users.RequestUpdatePassword) error
 	SignIn(ctx context.Context, data *req_users.RequestSignIn) (*module.Users, error)
-	ForgotPwd(ctx context.Context, upPwd *req_users.ForgorPwd) error
-	ListUser(ctx context.Context, pagging *common.Paggings) ([]module.Users, error)
+	UpdatePasswordHash(ctx context.Context, idData map[string]any, oldHash, newHash string) error
+	ListUser(ctx context.Context, pagging *common.Paggings, query *common.ListQuery) ([]module.Users, error)
 	UpdatedUsersByID(ctx context.Context, updateData *req_users.UpdateUsersByID, idData map[string]any) error
+	GetDeletedUser(ctx context.Context, idData map[string]any) (*module.Users, error)
+	ListTrashUser(ctx context.Context, roles []string, pagging *common.Paggings) ([]module.Users, error)
+	RestoreUsers(ctx context.Context, idData map[string]any) error
+	PurgeUsers(ctx context.Context, idData map[string]any) error
 }
 
 type usersController struct {
@@ -53,6 +57,16 @@ func (res *usersController) NewProfileUsers(ctx context.Context, idData string)
 	return dataUser, nil
 }
 
+// NewGetUserByAccount tìm tài khoản đang hoạt động theo account, trả nguyên lỗi để phân biệt không tồn tại
+func (res *usersController) NewGetUserByAccount(ctx context.Context, account string) (*module.Users, error) {
+	dataUser, err := res.u.ProfileUsers(ctx, map[string]any{"account": account})
+	if err != nil {
+		res.loggers.Errorf("Faild get user with account %s: %v", account, err)
+		return nil, err
+	}
+	return dataUser, nil
+}
+
 func (res *usersController) NewUpdatedUsers(ctx context.Context, updateData *req_users.UpdateUsers, idData string) error {
 	if err := res.u.UpdatedUsers(ctx, updateData, map[string]any{"user_id": idData}); err != nil {
 		res.loggers.Errorf("Faild update user with ID %s: %v", idData, err)
@@ -90,17 +104,17 @@ func (res *usersController) NewChanrgePwd(ctx context.Context, idData string, ch
 	return nil
 }
 
-func (res *usersController) NewForgotPwd(ctx context.Context, upPwd *req_users.ForgorPwd) error {
-	if err := res.u.ForgotPwd(ctx, upPwd); err != nil {
-		res.loggers.Errorf("Forgot password Failds: %v", err)
-		return errors.New("Forgot password failed bussiness")
+func (res *usersController) NewUpdatePasswordHash(ctx context.Context, idData string, oldHash, newHash string) error {
+	if err := res.u.UpdatePasswordHash(ctx, map[string]any{"user_id": idData}, oldHash, newHash); err != nil {
+		res.loggers.Errorf("Rehash password for user %s faild: %v", idData, err)
+		return err
 	}
-	res.loggers.Infof("Forgot password successfully")
+	res.loggers.Infof("Password hash upgraded for user %s", idData)
 	return nil
 }
 
-func (res *usersController) NewListUser(ctx context.Context, pagging *common.Paggings) ([]module.Users, error) {
-	listData, err := res.u.ListUser(ctx, pagging)
+func (res *usersController) NewListUser(ctx context.Context, pagging *common.Paggings, query *common.ListQuery) ([]module.Users, error) {
+	listData, err := res.u.ListUser(ctx, pagging, query)
 	if err != nil {
 		res.loggers.Errorf("Failed to get users list: %v", err)
 		return nil, err
@@ -117,3 +131,43 @@ func (res *usersController) NewUpdatedUsersByID(ctx context.Context, updateData
 	res.loggers.Infof("Update successfully")
 	return nil
 }
+
+// NewGetDeletedUser đọc tài khoản trong thùng rác để kiểm tra quyền trước khi khôi phục
+func (res *usersController) NewGetDeletedUser(ctx context.Context, id string) (*module.Users, error) {
+	dataUser, err := res.u.GetDeletedUser(ctx, map[string]any{"user_id": id})
+	if err != nil {
+		res.loggers.Errorf("Faild get deleted user with ID %s: %v", id, err)
+		return nil, err
+	}
+	return dataUser, nil
+}
+
+// NewListTrashUser liệt kê tài khoản trong thùng rác, roles nil là mọi role
+func (res *usersController) NewListTrashUser(ctx context.Context, roles []string, pagging *common.Paggings) ([]module.Users, error) {
+	listData, err := res.u.ListTrashUser(ctx, roles, pagging)
+	if err != nil {
+		res.loggers.Errorf("Failed to get user trash: %v", err)
+		return nil, err
+	}
+	return listData, nil
+}
+
+// NewRestoreUser trả về module.ErrAccountTaken nếu account đã thuộc về tài khoản khác
+func (res *usersController) NewRestoreUser(ctx context.Context, id string) error {
+	if err := res.u.RestoreUsers(ctx, map[string]any{"user_id": id}); err != nil {
+		res.loggers.Errorf("Failed to restore user %s: %v", id, err)
+		return err
+	}
+	res.loggers.Infof("User %s restored", id)
+	return nil
+}
+
+// NewPurgeUser xóa vĩnh viễn, chỉ áp dụng cho tài khoản đã nằm trong thùng rác
+func (res *usersController) NewPurgeUser(ctx context.Context, id string) error {
+	if err := res.u.PurgeUsers(ctx, map[string]any{"user_id": id}); err != nil {
+		res.loggers.Errorf("Failed to purge user %s: %v", id, err)
+		return err
+	}
+	res.loggers.Infof("User %s purged", id)
+	return nil
+}
@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// Loại media mà một trường upload chấp nhận
+const (
+	KindImage = "image"
+	KindVideo = "video"
+)
+
+// allowedTypes là MIME được nhận theo nội dung thật của file, không theo tên hay header của client.
+// SVG bị loại vì có thể chứa script.
+var allowedTypes = map[string]map[string]bool{
+	KindImage: {
+		"image/jpeg": true,
+		"image/png":  true,
+		"image/gif":  true,
+		"image/webp": true,
+	},
+	KindVideo: {
+		"video/mp4":       true,
+		"video/webm":      true,
+		"video/quicktime": true,
+		"video/ogg":       true,
+	},
+}
+
+// Object là file đã được lưu
+type Object struct {
+	Key         string
+	ContentType string
+	Size        int64
+}
+
+// Save đoán MIME từ nội dung rồi lưu bằng Store, chỉ nhận các loại trong allowedTypes của kind
+func Save(ctx context.Context, s Storage, r io.ReadSeeker, kind string) (*Object, error) {
+	mtype, err := mimetype.DetectReader(r)
+	if err != nil {
+		return nil, err
+	}
+	contentType := strings.SplitN(mtype.String(), ";", 2)[0]
+	if !allowedTypes[kind][contentType] {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedMedia, contentType)
+	}
+	return Store(ctx, s, r, contentType, mtype.Extension())
+}
+
+// Store băm sha256 nội dung rồi lưu dưới key ab/cd/<sha256><ext>.
+// Cùng nội dung thì cùng key nên file trùng không bị lưu hai lần và hai file cùng tên không ghi đè nhau.
+func Store(ctx context.Context, s Storage, r io.ReadSeeker, contentType, ext string) (*Object, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	hash := sha256.New()
+	size, err := io.Copy(hash, r)
+	if err != nil {
+		return nil, err
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+	obj := &Object{
+		Key:         fmt.Sprintf("%s/%s/%s%s", sum[:2], sum[2:4], sum, ext),
+		ContentType: contentType,
+		Size:        size,
+	}
+
+	exists, err := s.Exists(ctx, obj.Key)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return obj, nil
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if err := s.Put(ctx, obj.Key, r, size, contentType); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// URLFor dựng URL cho key đã lưu; giá trị cũ là URL tuyệt đối thì trả nguyên
+func URLFor(ctx context.Context, key *string) string {
+	if key == nil || *key == "" {
+		return ""
+	}
+	if strings.HasPrefix(*key, "http://") || strings.HasPrefix(*key, "https://") {
+		return *key
+	}
+	if instance == nil {
+		return ""
+	}
+	url, err := instance.URL(ctx, *key)
+	if err != nil {
+		return ""
+	}
+	return url
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	storageconfig "thelastking-blogger.com/src/config/storage_config"
+)
+
+// s3Storage lưu file trên S3 hoặc dịch vụ tương thích (MinIO), các instance dùng chung một bucket
+type s3Storage struct {
+	client     *minio.Client
+	bucket     string
+	publicURL  string
+	presignTTL time.Duration
+}
+
+// NewS3Storage kết nối tới endpoint và tạo bucket nếu chưa có
+func NewS3Storage(ctx context.Context, cfg *storageconfig.Config) (*s3Storage, error) {
+	client, err := minio.New(cfg.S3Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.S3AccessKey, cfg.S3SecretKey, ""),
+		Secure: cfg.S3UseSSL,
+		Region: cfg.S3Region, // có region thì presign không cần gọi API để hỏi vị trí bucket
+	})
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	exists, err := client.BucketExists(ctx, cfg.S3Bucket)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if err := client.MakeBucket(ctx, cfg.S3Bucket, minio.MakeBucketOptions{Region: cfg.S3Region}); err != nil {
+			return nil, err
+		}
+	}
+	return &s3Storage{
+		client:     client,
+		bucket:     cfg.S3Bucket,
+		publicURL:  cfg.S3PublicURL,
+		presignTTL: cfg.S3PresignTTL,
+	}, nil
+}
+
+// Put: key theo nội dung nên object không bao giờ đổi, client được cache lâu dài
+func (s *s3Storage) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	if !validKey(key) {
+		return ErrInvalidKey
+	}
+	_, err := s.client.PutObject(ctx, s.bucket, key, r, size, minio.PutObjectOptions{
+		ContentType:  contentType,
+		CacheControl: "public, max-age=31536000, immutable",
+	})
+	return err
+}
+
+func (s *s3Storage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	if !validKey(key) {
+		return nil, ErrInvalidKey
+	}
+	obj, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// GetObject chỉ báo lỗi khi đọc, Stat để trả ErrObjectNotFound ngay
+	if _, err := obj.Stat(); err != nil {
+		obj.Close()
+		if isNotFound(err) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, err
+	}
+	return obj, nil
+}
+
+func (s *s3Storage) Exists(ctx context.Context, key string) (bool, error) {
+	if !validKey(key) {
+		return false, ErrInvalidKey
+	}
+	_, err := s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *s3Storage) Delete(ctx context.Context, key string) error {
+	if !validKey(key) {
+		return ErrInvalidKey
+	}
+	return s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+}
+
+// URL dùng S3_PUBLIC_URL nếu có, không thì ký presigned URL có hạn S3_PRESIGN_TTL
+func (s *s3Storage) URL(ctx context.Context, key string) (string, error) {
+	if !validKey(key) {
+		return "", ErrInvalidKey
+	}
+	if s.publicURL != "" {
+		return s.publicURL + "/" + escapeKey(key), nil
+	}
+	u, err := s.client.PresignedGetObject(ctx, s.bucket, key, s.presignTTL, nil)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+func isNotFound(err error) bool {
+	code := minio.ToErrorResponse(err).Code
+	return code == "NoSuchKey" || code == "NotFound"
+}
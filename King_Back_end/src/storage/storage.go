@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+
+	storageconfig "thelastking-blogger.com/src/config/storage_config"
+)
+
+var (
+	ErrUnsupportedMedia = errors.New("unsupported media type")
+	ErrObjectNotFound   = errors.New("object not found")
+	ErrInvalidKey       = errors.New("invalid storage key")
+)
+
+// Storage lưu file media theo key; handler chỉ phụ thuộc vào interface này để đổi được nơi lưu
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Exists(ctx context.Context, key string) (bool, error)
+	Delete(ctx context.Context, key string) error
+	// URL trả về đường dẫn client dùng để tải file, được tính lại mỗi lần trả response
+	URL(ctx context.Context, key string) (string, error)
+}
+
+var (
+	once     sync.Once
+	instance Storage
+	initErr  error
+)
+
+// Init tạo Storage theo STORAGE_DRIVER: s3 cho S3/MinIO, local lưu trên đĩa của instance
+func Init() error {
+	once.Do(func() {
+		cfg := storageconfig.Get()
+		switch cfg.Driver {
+		case "s3":
+			instance, initErr = NewS3Storage(context.Background(), cfg)
+		default:
+			instance, initErr = NewLocalStorage(cfg.LocalDir, cfg.LocalURL)
+		}
+	})
+	return initErr
+}
+
+// Get trả về Storage đã được Init lúc khởi động server
+func Get() Storage {
+	return instance
+}
+
+// validKey chặn key thoát khỏi thư mục gốc (../, đường dẫn tuyệt đối)
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return false
+	}
+	clean := path.Clean(key)
+	return clean == key && clean != ".." && !strings.HasPrefix(clean, "../")
+}
+
+// escapeKey escape từng đoạn của key khi ghép vào URL vì file cũ (trước khi dùng key theo nội dung) giữ nguyên tên gốc
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
@@ -29,3 +29,28 @@ func ClearRefreshTokenCookie(c *gin.Context) {
 		true,
 	)
 }
+
+// SetOidcStateCookie gắn state OIDC với trình duyệt đã bắt đầu đăng nhập, chống giả mạo callback
+func SetOidcStateCookie(c *gin.Context, state string, duration time.Duration) {
+	c.SetCookie(
+		"oidc_state",
+		state,
+		int(duration.Seconds()),
+		"/",
+		"",
+		false, // deloy lên dự án thì đổi lại về true
+		true,
+	)
+}
+
+func ClearOidcStateCookie(c *gin.Context) {
+	c.SetCookie(
+		"oidc_state",
+		"",
+		-1,
+		"/",
+		"",
+		true,
+		true,
+	)
+}
@@ -3,19 +3,17 @@ package utils
 import (
 	"fmt"
 
-	"github.com/dgrijalva/jwt-go"
-	jwtconfig "thelastking-blogger.com/src/config/jwt_config"
+	"thelastking-blogger.com/src/module"
+	"thelastking-blogger.com/src/security/jwtkeys"
 )
 
-func ParseToken(tokenStr string) (*jwt.StandardClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenStr, &jwt.StandardClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(jwtconfig.KeyJwt), nil
-	})
+func ParseToken(tokenStr string) (*module.Token, error) {
+	token, err := jwtkeys.Parse(tokenStr, &module.Token{})
 	if err != nil {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*jwt.StandardClaims); ok && token.Valid {
+	if claims, ok := token.Claims.(*module.Token); ok && token.Valid {
 		return claims, nil
 	}
 
@@ -3,48 +3,99 @@ package utils
 import (
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
+	"github.com/golang-jwt/jwt/v5"
 	jwtconfig "thelastking-blogger.com/src/config/jwt_config"
 	"thelastking-blogger.com/src/config/logger"
 	"thelastking-blogger.com/src/module"
+	"thelastking-blogger.com/src/security/jwtkeys"
 )
 
-func GenerateTokens(data *module.Users) (string, string, error) {
-	// Claims cho Access Token
+// GenerateTokens cấp cặp access token và refresh token cho phiên sessionID (family của refresh token)
+func GenerateTokens(data *module.Users, sessionID string) (string, string, error) {
+	cfg := jwtconfig.Get()
+	now := time.Now()
+
+	// Claims cho Access Token, jti dùng để thu hồi từng token
+	accessID, err := GenerateUUID()
+	if err != nil {
+		return "", "", err
+	}
 	newClaimsAccess := &module.Token{
-		UserID: data.UserID,
-		Role:   data.Role,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Local().Add(time.Minute * time.Duration(24)).Unix(),
-			IssuedAt:  time.Now().Unix(),
-			NotBefore: time.Now().Unix(),
+		UserID:    data.UserID,
+		Role:      data.Role,
+		TokenUse:  module.TokenUseAccess,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        accessID,
+			Issuer:    cfg.Issuer,
+			Subject:   data.UserID,
+			ExpiresAt: jwt.NewNumericDate(now.Add(cfg.AccessTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
 		},
 	}
 
-	// Claims cho Refresh Token
+	// Claims cho Refresh Token, ID ngẫu nhiên để hai token sinh cùng giây không trùng hash
+	tokenID, err := GenerateUUID()
+	if err != nil {
+		return "", "", err
+	}
 	newClaimsRefresh := &module.Token{
-		UserID: data.UserID,
-		Role:   data.Role,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Local().Add(time.Hour * time.Duration(24*7)).Unix(),
-			IssuedAt:  time.Now().Unix(),
-			NotBefore: time.Now().Unix(),
+		UserID:    data.UserID,
+		Role:      data.Role,
+		TokenUse:  module.TokenUseRefresh,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			Issuer:    cfg.Issuer,
+			Subject:   data.UserID,
+			ExpiresAt: jwt.NewNumericDate(now.Add(cfg.RefreshTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
 		},
 	}
 
-	// Tạo và ký token
-	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, newClaimsAccess).SignedString([]byte(jwtconfig.KeyJwt))
+	// Tạo và ký token bằng khóa đang hoạt động
+	accessToken, err := jwtkeys.Sign(newClaimsAccess)
 	if err != nil {
 		logger.GetLogger().Errorf("Không thể ký access token: %v", err)
 		return "", "", err
 	}
 
-	refreshToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, newClaimsRefresh).SignedString([]byte(jwtconfig.KeyJwt))
+	refreshToken, err := jwtkeys.Sign(newClaimsRefresh)
 	if err != nil {
 		logger.GetLogger().Errorf("Không thể ký refresh token: %v", err)
 		return "", "", err
 	}
 
-	// Trả về access token, refresh token và tokenID
 	return accessToken, refreshToken, nil
 }
+
+// GenerateMfaPendingToken cấp token ngắn hạn sau khi đúng mật khẩu, chỉ đổi được lấy token thật ở bước 2FA
+func GenerateMfaPendingToken(data *module.Users, ttl time.Duration) (string, error) {
+	cfg := jwtconfig.Get()
+	now := time.Now()
+	tokenID, err := GenerateUUID()
+	if err != nil {
+		return "", err
+	}
+	claims := &module.Token{
+		UserID:   data.UserID,
+		Role:     data.Role,
+		TokenUse: module.TokenUseMfaPending,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			Issuer:    cfg.Issuer,
+			Subject:   data.UserID,
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+	}
+	token, err := jwtkeys.Sign(claims)
+	if err != nil {
+		logger.GetLogger().Errorf("Không thể ký mfa token: %v", err)
+		return "", err
+	}
+	return token, nil
+}
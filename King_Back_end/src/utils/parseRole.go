@@ -1,18 +1,13 @@
 package utils
 
 import (
-	"strings"
+	"thelastking-blogger.com/src/module"
 )
 
+// ParseRole chuẩn hóa role nhập vào, role không hợp lệ được coi là USER
 func ParseRole(role string) string {
-	switch strings.ToUpper(role) {
-	case "ADMIN":
-		return "ADMIN"
-	case "ROOT":
-		return "ROOT"
-	default:
-		return "USER"
-	}
+	parsed, _ := module.ParseRoles(role)
+	return parsed.String()
 }
 
 // func ParseAndValidateToken(ctx context.Context, db *gorm.DB, tokenInput string) (*module.Token, error) {